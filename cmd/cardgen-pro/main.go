@@ -1,17 +1,31 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/felipemacedo/cardgen-pro/internal/api"
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/bindb"
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/emv"
 	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/hsm"
 	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/keystore"
+	"github.com/felipemacedo/cardgen-pro/internal/ledger"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/simulator"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
 	"github.com/felipemacedo/cardgen-pro/pkg/transformer"
 )
 
@@ -48,12 +62,30 @@ func main() {
 		handleGenerate()
 	case "transform":
 		handleTransform()
+	case "tokenize":
+		handleTokenize()
 	case "serve":
 		handleServe()
+	case "iso-serve":
+		handleISOServe()
+	case "iso-send":
+		handleISOSend()
 	case "validate":
 		handleValidate()
 	case "scenarios":
 		handleScenarios()
+	case "bin":
+		handleBIN()
+	case "decode-tlv":
+		handleDecodeTLV()
+	case "dukpt":
+		handleDUKPT()
+	case "hsm-serve":
+		handleHSMServe()
+	case "keys":
+		handleKeys()
+	case "tokens":
+		handleTokens()
 	case "version":
 		fmt.Printf("cardgen-pro version %s\n", version)
 	case "help", "-h", "--help":
@@ -71,34 +103,85 @@ func printUsage() {
 	fmt.Println("\nCommands:")
 	fmt.Println("  generate    Generate test card data")
 	fmt.Println("  transform   Transform orders by injecting CVCs")
+	fmt.Println("  tokenize    Replace card or order PANs with format-preserving tokens (FF1/FF3-1), or back")
 	fmt.Println("  serve       Start HTTP API server for fixtures")
-	fmt.Println("  validate    Validate card numbers using Luhn")
+	fmt.Println("  iso-serve   Start TCP ISO-8583 issuer simulator")
+	fmt.Println("  iso-send    Send a generated ISO-8583 authorization to a host")
+	fmt.Println("  validate    Validate PANs or card files (brand, length, Luhn, expiry, CVC, Track2)")
 	fmt.Println("  scenarios   List predefined test scenarios")
+	fmt.Println("  bin         Look up brand, issuer, country and card type of a BIN")
+	fmt.Println("  decode-tlv  Decode EMV chip data (DE55 BER-TLV hex)")
+	fmt.Println("  dukpt       Derive DUKPT keys of a KSN, PIN blocks and encrypted data")
+	fmt.Println("  hsm-serve   Start TCP HSM emulator (Thales-style CVV, PIN, ARQC and key commands)")
+	fmt.Println("  keys        Manage test keys as TR-31 key blocks (add, import, export, list)")
+	fmt.Println("  tokens      Manage network tokens (DPANs) and their cryptograms in a token vault")
 	fmt.Println("  version     Print version information")
 	fmt.Println("  help        Show this help message")
 	fmt.Println("\nExamples:")
 	fmt.Println("  cardgen-pro generate --bin 400000 --brand visa --count 10 --out cards.json")
 	fmt.Println("  cardgen-pro transform --input orders.json --output orders_cvc.json")
+	fmt.Println("  cardgen-pro tokenize --input cards.json --output tokens.json --key pan-dek")
 	fmt.Println("  cardgen-pro serve --port 8080 --token my-dev-token")
+	fmt.Println("  cardgen-pro iso-serve --port 8583 --spec iso1987 --encoding bcd")
+	fmt.Println("  cardgen-pro iso-send --host localhost --port 8583 --brand visa --amount 10000")
 	fmt.Println("  cardgen-pro validate 4000000000000002")
+	fmt.Println("  cardgen-pro validate --json --file fixtures/cards_visa_5.json")
+	fmt.Println("  cardgen-pro bin 45321100")
+	fmt.Println("  cardgen-pro decode-tlv 9F2701809F360200A1")
+	fmt.Println("  cardgen-pro dukpt --bdk 0123456789ABCDEFFEDCBA9876543210 --ksn FFFF9876543210E00001")
+	fmt.Println("  cardgen-pro hsm-serve --port 1500 --message-header 4")
+	fmt.Println("  cardgen-pro keys add --keystore keys.json --name issuer-cvk --usage C0 --generate")
+	fmt.Println("  cardgen-pro tokens provision --vault tokens.json --brand mastercard")
 	fmt.Println("\nEnvironment Variables:")
 	fmt.Println("  CARDGEN_SECRET    Secret key for deterministic CVC generation")
+	fmt.Println("  CARDGEN_BINDB     BIN table (JSON) extending the bundled one")
+	fmt.Println("  CARDGEN_CVK       Test CVK-A||CVK-B (32 hex) for real CVV/CVV2/iCVV")
+	fmt.Println("  CARDGEN_IMK       Test issuer master key (32 hex) for ARQC/ARPC")
+	fmt.Println("  CARDGEN_ZPK       Test zone PIN key (32/48/64 hex) for PIN blocks in DE52")
+	fmt.Println("  CARDGEN_BDK       Test DUKPT base derivation key (32/48/64 hex) for DE52/DE53")
+	fmt.Println("  CARDGEN_PVK       Test PIN verification key (32/48 hex) for PVVs and IBM 3624 offsets")
+	fmt.Println("  CARDGEN_FPE_KEY   Test AES key (32/48/64 hex) for format-preserving PAN tokens")
+	fmt.Println("  CARDGEN_LMK       Test LMK (32/48 hex) the HSM emulator encrypts keys under")
+	fmt.Println("  CARDGEN_KEYSTORE  Key store (JSON of TR-31 key blocks) key flags can name keys in")
+	fmt.Println("  CARDGEN_KBPK      Test key block protection key (32/48 hex TDES, 64 hex AES) of the key store")
 	fmt.Println("\nFor detailed help on a command, run: cardgen-pro <command> --help")
 }
 
 func handleGenerate() {
 	fs := flag.NewFlagSet("generate", flag.ExitOnError)
 	
-	bin := fs.String("bin", "", "BIN (Bank Identification Number) - first 6 digits")
-	brand := fs.String("brand", "visa", "Card brand (visa, mastercard, amex)")
+	bin := fs.String("bin", "", "BIN (Bank Identification Number) - first 6+ digits, must belong to the brand")
+	brand := fs.String("brand", "visa", "Card brand ("+strings.Join(generator.BrandNames(), ", ")+")")
 	count := fs.Int("count", 10, "Number of cards to generate")
 	output := fs.String("out", "", "Output file path (JSON)")
 	format := fs.String("format", "json", "Output format (json, ndjson, csv)")
 	includeISO := fs.Bool("iso", false, "Include ISO-8583 fields")
 	includeTrack2 := fs.Bool("track2", false, "Include Track2 data")
+	includeTrack1 := fs.Bool("track1", false, "Include Track1 data (format B, with cardholder name)")
+	name := fs.String("name", "", "Cardholder name for Track1, SURNAME/GIVEN (default "+generator.DefaultCardholderName+")")
+	rawTracks := fs.Bool("raw-tracks", false, "Also output raw tracks (sentinels + LRC) and BCD Track2 equivalent data")
 	secret := fs.String("secret", "", "Secret for CVC generation (or use CARDGEN_SECRET env)")
+	cvk := fs.String("cvk", os.Getenv("CARDGEN_CVK"), "Key store name or test CVK-A||CVK-B (32 hex) for real CVV/CVV2/iCVV (or CARDGEN_CVK env)")
+	imk := fs.String("imk", os.Getenv("CARDGEN_IMK"), "Key store name or test issuer master key (32 hex) for verifiable ARQCs in DE55 (or CARDGEN_IMK env)")
+	includePIN := fs.Bool("pin", false, "Include a test PIN derived from the secret")
+	zpk := fs.String("zpk", os.Getenv("CARDGEN_ZPK"), "Key store name or test zone PIN key for clear/encrypted PIN blocks, implies --pin (or CARDGEN_ZPK env)")
+	pinFormat := fs.Int("pin-format", 0, "ISO 9564 PIN block format: 0, 1, 3 (3DES) or 4 (AES)")
+	bdk := fs.String("bdk", os.Getenv("CARDGEN_BDK"), "Key store name or test DUKPT BDK: per-card KSNs and PIN blocks, implies --pin (or CARDGEN_BDK env)")
+	ksn := fs.String("ksn", "", "Initial DUKPT KSN: 20 hex (TDES) or 24 hex (AES); each card uses the next one")
+	pvk := fs.String("pvk", os.Getenv("CARDGEN_PVK"), "Key store name or test PIN verification key for PVV (on the tracks) and IBM 3624 offset, implies --pin (or CARDGEN_PVK env)")
+	pvki := fs.Int("pvki", generator.DefaultPVKI, "PIN verification key index of the PVV (1-6)")
+	seed := fs.Int64("seed", 0, "Seed for reproducible output (0 = random)")
+	length := fs.Int("length", 0, "PAN length, must be allowed by the brand (0 = brand default)")
+	country := fs.String("country", "", "Only BINs issued in this country (ISO alpha-2, per the BIN database)")
+	cardType := fs.String("card-type", "", "Only credit, debit or prepaid BINs (per the BIN database)")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+	binDBFile := fs.String("bindb", os.Getenv("CARDGEN_BINDB"), "BIN table extending the bundled one (JSON, or CARDGEN_BINDB env)")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store the key flags may name keys in (or CARDGEN_KEYSTORE env, KBPK in CARDGEN_KBPK)")
 	
 	fs.Parse(os.Args[2:])
+	loadBrands(*brandsFile)
+	loadBINDB(*binDBFile)
+	keys := openKeyStore(*keyStorePath)
 
 	// Get secret from env if not provided
 	secretValue := *secret
@@ -106,35 +189,47 @@ func handleGenerate() {
 		secretValue = os.Getenv("CARDGEN_SECRET")
 	}
 
-	if secretValue == "" {
+	if secretValue == "" && *cvk == "" {
 		log.Println("⚠️  Warning: No secret provided. CVCs will not be generated.")
 		log.Println("   Set CARDGEN_SECRET environment variable or use --secret flag")
 	}
 
 	// Generate cards
 	opts := models.GenerateOptions{
-		BIN:           *bin,
-		Brand:         strings.ToLower(*brand),
-		Count:         *count,
-		Secret:        secretValue,
-		IncludeISO:    *includeISO,
-		IncludeTrack2: *includeTrack2,
+		BIN:            *bin,
+		Brand:          strings.ToLower(*brand),
+		Count:          *count,
+		Secret:         secretValue,
+		CVK:            resolveKey(keys, *cvk, keystore.CVKGenerate),
+		IMK:            resolveKey(keys, *imk, keystore.IMKDerive),
+		IncludePIN:     *includePIN,
+		ZPK:            resolveKey(keys, *zpk, keystore.ZPKEncrypt),
+		PINFormat:      *pinFormat,
+		BDK:            resolveKey(keys, *bdk, keystore.BDKDerive),
+		KSN:            *ksn,
+		PVK:            resolveKey(keys, *pvk, keystore.PVKGenerate),
+		PVKI:           *pvki,
+		IncludeISO:     *includeISO,
+		IncludeTrack2:  *includeTrack2,
+		IncludeTrack1:  *includeTrack1,
+		CardholderName: *name,
+		RawTracks:      *rawTracks,
+		Seed:           *seed,
+		PANLength:      *length,
+		Country:        *country,
+		CardType:       *cardType,
 	}
 
-	cards := []*models.Card{}
-	for i := 0; i < *count; i++ {
-		card, err := generator.GenerateCard(opts)
-		if err != nil {
-			log.Fatalf("Failed to generate card: %v", err)
-		}
+	cards, err := generator.GenerateCards(opts)
+	if err != nil {
+		log.Fatalf("Failed to generate card: %v", err)
+	}
 
-		// Add ISO fields if requested
-		if *includeISO {
-			isoFields := iso.GenerateISO8583Fields(card, 10000, "986")
-			card.ISOFields = isoFields
+	// Add ISO fields if requested (timestamped like the card, so seeded output is reproducible)
+	if *includeISO {
+		for _, card := range cards {
+			card.ISOFields = iso.GenerateISO8583FieldsAt(card, 10000, "986", card.GeneratedAt)
 		}
-
-		cards = append(cards, card)
 	}
 
 	// Output
@@ -219,13 +314,73 @@ func handleTransform() {
 	log.Printf("✓ Transformed orders and saved to %s", *output)
 }
 
+func handleTokenize() {
+	fs := flag.NewFlagSet("tokenize", flag.ExitOnError)
+
+	input := fs.String("input", "", "Input file path (cards: any format validate reads; orders: JSON or NDJSON)")
+	output := fs.String("output", "", "Output file path (JSON)")
+	kind := fs.String("type", "cards", "Input records: cards or orders")
+	key := fs.String("key", os.Getenv("CARDGEN_FPE_KEY"), "Key store name or test AES key (32/48/64 hex) (or CARDGEN_FPE_KEY env)")
+	algorithm := fs.String("algorithm", transformer.AlgorithmFF1, "FPE algorithm: ff1 or ff3-1")
+	detokenize := fs.Bool("detokenize", false, "Recover the PANs of tokens made under the same key and algorithm")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store --key may name a D0 key in (or CARDGEN_KEYSTORE env, KBPK in CARDGEN_KBPK)")
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || *output == "" {
+		log.Fatal("Error: --input and --output are required")
+	}
+	if *key == "" {
+		log.Fatal("Error: a key is required. Set CARDGEN_FPE_KEY or use --key flag")
+	}
+
+	required := keystore.DEKEncrypt
+	if *detokenize {
+		required = keystore.DEKDecrypt
+	}
+	opts := models.TokenizeOptions{
+		InputPath:  *input,
+		OutputPath: *output,
+		Key:        resolveKey(openKeyStore(*keyStorePath), *key, required),
+		Algorithm:  *algorithm,
+		Detokenize: *detokenize,
+	}
+
+	var err error
+	switch *kind {
+	case "cards":
+		err = transformer.TokenizeCards(opts)
+	case "orders":
+		err = transformer.TokenizeOrders(opts)
+	default:
+		log.Fatalf("Unknown type: %s (use cards or orders)", *kind)
+	}
+	if err != nil {
+		log.Fatalf("Failed to tokenize %s: %v", *kind, err)
+	}
+
+	action := "Tokenized"
+	if *detokenize {
+		action = "Detokenized"
+	}
+	log.Printf("✓ %s %s (%s) and saved to %s", action, *kind, strings.ToUpper(*algorithm), *output)
+}
+
 func handleServe() {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	
 	port := fs.Int("port", 8080, "HTTP server port")
 	token := fs.String("token", "", "Authentication token (required)")
+	rulesPath := fs.String("rules", "", "Authorization rules file (JSON)")
+	ledgerPath := fs.String("ledger", "", "Persist the transaction ledger to this JSON file")
+	vaultPath := fs.String("vault", "", "Persist the token vault to this JSON file")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+	binDBFile := fs.String("bindb", os.Getenv("CARDGEN_BINDB"), "BIN table extending the bundled one (JSON, or CARDGEN_BINDB env)")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store CARDGEN_CVK/IMK/ZPK/BDK/PVK and the API key parameters may name keys in (or CARDGEN_KEYSTORE env)")
 	
 	fs.Parse(os.Args[2:])
+	loadBrands(*brandsFile)
+	loadBINDB(*binDBFile)
 
 	if *token == "" {
 		log.Fatal("Error: --token is required for API server")
@@ -236,25 +391,967 @@ func handleServe() {
 	log.Printf("\nAuthentication: Bearer %s\n", *token)
 
 	server := api.NewServer(*token, *port)
+	server.SetAuthorizer(loadAuthorizer(*rulesPath, *keyStorePath))
+	server.SetKeyStore(openKeyStore(*keyStorePath))
+	if l := loadLedger(*ledgerPath); l != nil {
+		server.SetLedger(l)
+	}
+	if v := loadVault(*vaultPath); v != nil {
+		server.SetVault(v)
+	}
 	if err := server.Start(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
 
+func handleISOServe() {
+	fs := flag.NewFlagSet("iso-serve", flag.ExitOnError)
+
+	port := fs.Int("port", 8583, "TCP port to listen on")
+	specName := fs.String("spec", "iso1987", "ISO-8583 dialect: bundled spec name or path to a JSON spec")
+	encoding := fs.String("encoding", "", "Override spec encoding (ascii, bcd)")
+	header := fs.Int("header", 2, "Length header size in bytes (2, 4)")
+	headerFormat := fs.String("header-format", "binary", "Length header format (binary, ascii)")
+	rulesPath := fs.String("rules", "", "Authorization rules file (JSON)")
+	ledgerPath := fs.String("ledger", "", "Record approvals and match reversals in this JSON ledger file")
+	vaultPath := fs.String("vault", "", "Detokenize the DPANs of this token vault file (see the tokens command)")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store CARDGEN_CVK/IMK/ZPK/BDK/PVK may name keys in (or CARDGEN_KEYSTORE env)")
+
+	fs.Parse(os.Args[2:])
+	loadBrands(*brandsFile)
+
+	spec := loadSpec(*specName, *encoding)
+	framing := parseFraming(*header, *headerFormat)
+
+	log.Printf("Starting cardgen-pro ISO-8583 simulator v%s", version)
+	log.Println("⚠️  WARNING: This simulator is for TEST/SANDBOX use only")
+
+	sim := simulator.NewSimulator(fmt.Sprintf(":%d", *port), spec)
+	sim.SetFraming(framing)
+	engine := loadAuthorizer(*rulesPath, *keyStorePath)
+	if v := loadVault(*vaultPath); v != nil {
+		engine.SetVault(v)
+	}
+	sim.SetAuthorizer(engine)
+	if l := loadLedger(*ledgerPath); l != nil {
+		sim.SetLedger(l)
+	}
+	if err := sim.ListenAndServe(); err != nil {
+		log.Fatalf("Simulator error: %v", err)
+	}
+}
+
+func handleHSMServe() {
+	fs := flag.NewFlagSet("hsm-serve", flag.ExitOnError)
+
+	port := fs.Int("port", 1500, "TCP port to listen on")
+	messageHeader := fs.Int("message-header", hsm.DefaultHeaderLength, "Length of the message header echoed in responses")
+	lmkHex := fs.String("lmk", os.Getenv("CARDGEN_LMK"), "Test LMK (32/48 hex) keys travel encrypted under; clear keys if empty (or CARDGEN_LMK env)")
+	header := fs.Int("header", 2, "Length header size in bytes (2, 4)")
+	headerFormat := fs.String("header-format", "binary", "Length header format (binary, ascii)")
+
+	fs.Parse(os.Args[2:])
+
+	if *messageHeader < 0 {
+		log.Fatalf("Invalid message header length: %d", *messageHeader)
+	}
+
+	log.Printf("Starting cardgen-pro HSM emulator v%s", version)
+	log.Println("⚠️  WARNING: This emulator is for TEST/SANDBOX use only")
+
+	server := hsm.NewServer(fmt.Sprintf(":%d", *port))
+	server.SetFraming(parseFraming(*header, *headerFormat))
+	server.SetHeaderLength(*messageHeader)
+	if *lmkHex != "" {
+		lmk, err := hsm.ParseLMK(*lmkHex)
+		if err != nil {
+			log.Fatalf("Invalid LMK: %v", err)
+		}
+		server.SetLMK(lmk)
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("HSM emulator error: %v", err)
+	}
+}
+
+func handleISOSend() {
+	fs := flag.NewFlagSet("iso-send", flag.ExitOnError)
+
+	host := fs.String("host", "localhost", "Host to send the message to")
+	port := fs.Int("port", 8583, "Host TCP port")
+	brand := fs.String("brand", "visa", "Card brand ("+strings.Join(generator.BrandNames(), ", ")+")")
+	bin := fs.String("bin", "", "BIN (Bank Identification Number) - first 6 digits")
+	amount := fs.Int64("amount", 10000, "Transaction amount in minor units (cents)")
+	currency := fs.String("currency", "986", "ISO 4217 numeric currency code")
+	specName := fs.String("spec", "iso1987", "ISO-8583 dialect: bundled spec name or path to a JSON spec")
+	encoding := fs.String("encoding", "", "Override spec encoding (ascii, bcd)")
+	header := fs.Int("header", 2, "Length header size in bytes (2, 4)")
+	headerFormat := fs.String("header-format", "binary", "Length header format (binary, ascii)")
+	timeout := fs.Duration("timeout", 10*time.Second, "Time to wait for the response")
+	secret := fs.String("secret", "", "Secret for CVC generation (or use CARDGEN_SECRET env)")
+	cvk := fs.String("cvk", os.Getenv("CARDGEN_CVK"), "Key store name or test CVK-A||CVK-B (32 hex) for a real CVV2 (or CARDGEN_CVK env)")
+	imk := fs.String("imk", os.Getenv("CARDGEN_IMK"), "Key store name or test issuer master key (32 hex) for a verifiable ARQC (or CARDGEN_IMK env)")
+	zpk := fs.String("zpk", os.Getenv("CARDGEN_ZPK"), "Key store name or test zone PIN key for an encrypted PIN block in DE52 (or CARDGEN_ZPK env)")
+	pinFormat := fs.Int("pin-format", 0, "ISO 9564 PIN block format: 0, 1, 3 (3DES) or 4 (AES, needs a spec with a 16-byte DE52)")
+	bdk := fs.String("bdk", os.Getenv("CARDGEN_BDK"), "Key store name or test DUKPT BDK for a DUKPT PIN block in DE52 and KSN in DE53 (or CARDGEN_BDK env)")
+	ksn := fs.String("ksn", "", "DUKPT KSN of the terminal: 20 hex (TDES) or 24 hex (AES)")
+	pvk := fs.String("pvk", os.Getenv("CARDGEN_PVK"), "Key store name or test PIN verification key for a PVV in the DE35 Track 2 data (or CARDGEN_PVK env)")
+	pvki := fs.Int("pvki", generator.DefaultPVKI, "PIN verification key index of the PVV (1-6)")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store the key flags may name keys in (or CARDGEN_KEYSTORE env, KBPK in CARDGEN_KBPK)")
+
+	fs.Parse(os.Args[2:])
+	loadBrands(*brandsFile)
+	keys := openKeyStore(*keyStorePath)
+
+	secretValue := *secret
+	if secretValue == "" {
+		secretValue = os.Getenv("CARDGEN_SECRET")
+	}
+
+	card, err := generator.GenerateCard(models.GenerateOptions{
+		BIN:           *bin,
+		Brand:         strings.ToLower(*brand),
+		Count:         1,
+		Secret:        secretValue,
+		CVK:           resolveKey(keys, *cvk, keystore.CVKGenerate),
+		IMK:           resolveKey(keys, *imk, keystore.IMKDerive),
+		ZPK:           resolveKey(keys, *zpk, keystore.ZPKEncrypt),
+		PINFormat:     *pinFormat,
+		BDK:           resolveKey(keys, *bdk, keystore.BDKDerive),
+		KSN:           *ksn,
+		PVK:           resolveKey(keys, *pvk, keystore.PVKGenerate),
+		PVKI:          *pvki,
+		IncludeTrack2: true,
+	})
+	if err != nil {
+		log.Fatalf("Failed to generate card: %v", err)
+	}
+
+	client := iso.NewClient(fmt.Sprintf("%s:%d", *host, *port), loadSpec(*specName, *encoding))
+	client.Framing = parseFraming(*header, *headerFormat)
+	client.Timeout = *timeout
+
+	request := iso.GenerateMockAuthRequest(card, *amount, *currency)
+
+	log.Printf("Sending %s for %s (%s) to %s", request.MTI, card.MaskedPAN, card.Brand, client.Addr)
+
+	response, err := client.SendAuthRequest(request)
+	if err != nil {
+		log.Fatalf("Exchange failed: %v", err)
+	}
+
+	fmt.Printf("\n=== %s -> %s ===\n\n", request.MTI, response.MTI)
+	fmt.Print(iso.FormatFieldDiff(client.DiffAuthResponse(request, response)))
+	fmt.Printf("\nResponse: [%s] %s\n", response.ResponseCode, response.ResponseText)
+	if response.AuthCode != "" {
+		fmt.Printf("Auth code: %s\n", response.AuthCode)
+	}
+}
+
+// loadBrands registers brand definitions from a JSON file, if a path is given
+func loadBrands(path string) {
+	if path == "" {
+		return
+	}
+
+	brands, err := generator.LoadBrands(path)
+	if err != nil {
+		log.Fatalf("Failed to load brands: %v", err)
+	}
+	if err := generator.RegisterBrands(brands); err != nil {
+		log.Fatalf("Failed to register brands from %s: %v", path, err)
+	}
+}
+
+// loadBINDB merges a BIN table file over the bundled table, if a path is given
+func loadBINDB(path string) {
+	if path == "" {
+		return
+	}
+
+	db, err := bindb.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load BIN table: %v", err)
+	}
+	bindb.SetDefault(bindb.Bundled().Merge(db))
+}
+
+// loadLedger opens a file-persisted ledger, or returns nil when no path is given
+func loadLedger(path string) *ledger.Ledger {
+	if path == "" {
+		return nil
+	}
+
+	l, err := ledger.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open ledger: %v", err)
+	}
+	return l
+}
+
+// loadVault opens a file-persisted token vault, or returns nil when no path is given
+func loadVault(path string) *token.Vault {
+	if path == "" {
+		return nil
+	}
+
+	v, err := token.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open token vault: %v", err)
+	}
+	return v
+}
+
+// loadAuthorizer builds the decision engine from an optional rules file
+// Secrets and keys the rules leave unset fall back to CARDGEN_SECRET, CARDGEN_CVK, CARDGEN_IMK, CARDGEN_ZPK,
+// CARDGEN_BDK and CARDGEN_PVK; those keys may name keys in the key store
+func loadAuthorizer(path, keyStorePath string) *authorizer.Engine {
+	rules := authorizer.DefaultRules()
+	if path != "" {
+		loaded, err := authorizer.LoadRules(path)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+		rules = loaded
+	}
+	keys := openKeyStore(keyStorePath)
+
+	if rules.CVCSecret == "" {
+		rules.CVCSecret = os.Getenv("CARDGEN_SECRET")
+	}
+	if rules.CVK == "" {
+		rules.CVK = resolveKey(keys, os.Getenv("CARDGEN_CVK"), keystore.CVKVerify)
+	}
+	if rules.CVK != "" {
+		if _, err := cardcrypto.ParseCVK(rules.CVK); err != nil {
+			log.Fatalf("Invalid CVK: %v", err)
+		}
+	}
+	if rules.IMK == "" {
+		rules.IMK = resolveKey(keys, os.Getenv("CARDGEN_IMK"), keystore.IMKDerive)
+	}
+	if rules.IMK != "" {
+		if _, err := cardcrypto.ParseKey(rules.IMK); err != nil {
+			log.Fatalf("Invalid IMK: %v", err)
+		}
+	}
+	if rules.ZPK == "" {
+		rules.ZPK = resolveKey(keys, os.Getenv("CARDGEN_ZPK"), keystore.ZPKDecrypt)
+	}
+	if rules.ZPK != "" {
+		if _, err := cardcrypto.ParsePINKey(rules.ZPK); err != nil {
+			log.Fatalf("Invalid ZPK: %v", err)
+		}
+	}
+	if rules.BDK == "" {
+		rules.BDK = resolveKey(keys, os.Getenv("CARDGEN_BDK"), keystore.BDKDerive)
+	}
+	if rules.BDK != "" {
+		if _, err := cardcrypto.ParseBDK(rules.BDK); err != nil {
+			log.Fatalf("Invalid BDK: %v", err)
+		}
+	}
+	if rules.PVK == "" {
+		rules.PVK = resolveKey(keys, os.Getenv("CARDGEN_PVK"), keystore.PVKVerify)
+	}
+	if rules.PVK != "" {
+		if _, err := cardcrypto.ParsePVK(rules.PVK); err != nil {
+			log.Fatalf("Invalid PVK: %v", err)
+		}
+	}
+
+	return authorizer.NewEngine(rules)
+}
+
+// openKeyStore opens the key store at path under the CARDGEN_KBPK key, or returns nil without a path
+func openKeyStore(path string) *keystore.Store {
+	if path == "" {
+		return nil
+	}
+	return openKeyStoreWith(path, os.Getenv("CARDGEN_KBPK"))
+}
+
+// openKeyStoreWith opens the key store at path under a KBPK given as hex
+func openKeyStoreWith(path, kbpkHex string) *keystore.Store {
+	if kbpkHex == "" {
+		log.Fatal("Error: CARDGEN_KBPK is required to open a key store")
+	}
+	kbpk, err := keystore.ParseKBPK(kbpkHex)
+	if err != nil {
+		log.Fatalf("Invalid KBPK: %v", err)
+	}
+
+	store, err := keystore.Open(path, kbpk)
+	if err != nil {
+		log.Fatalf("Failed to open key store: %v", err)
+	}
+	return store
+}
+
+// resolveKey returns the hex of a key named in the key store, checking its usage and mode of use
+// Anything else is taken as a raw hex key, with a warning
+func resolveKey(store *keystore.Store, value string, required keystore.Requirement) string {
+	if value == "" {
+		return ""
+	}
+
+	if store != nil && store.Has(value) {
+		key, err := store.Key(value, required)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", required.Name, err)
+		}
+		return strings.ToUpper(hex.EncodeToString(key))
+	}
+
+	log.Printf("⚠️  Warning: raw hex %s; prefer a key store name (see cardgen-pro keys --help)", required.Name)
+	return value
+}
+
+// parseFraming builds the TCP length-header framing from CLI flags
+func parseFraming(size int, format string) iso.Framing {
+	framing := iso.Framing{HeaderSize: size}
+
+	switch format {
+	case "binary":
+	case "ascii":
+		framing.ASCII = true
+	default:
+		log.Fatalf("Unknown header format: %s (use binary or ascii)", format)
+	}
+
+	if err := framing.Validate(); err != nil {
+		log.Fatalf("Invalid framing: %v", err)
+	}
+
+	return framing
+}
+
+// loadSpec resolves an ISO-8583 spec and applies an optional encoding override
+func loadSpec(nameOrPath, encoding string) *iso.Spec {
+	spec, err := iso.ResolveSpec(nameOrPath)
+	if err != nil {
+		log.Fatalf("Failed to load spec: %v", err)
+	}
+
+	switch encoding {
+	case "":
+	case string(iso.EncodingASCII), string(iso.EncodingBCD):
+		spec = spec.WithEncoding(iso.Encoding(encoding))
+	default:
+		log.Fatalf("Unknown encoding: %s (use ascii or bcd)", encoding)
+	}
+
+	return spec
+}
+
 func handleValidate() {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+
+	file := fs.String("file", "", "Read PANs or card records from a file: JSON, NDJSON, CSV or one PAN per line (- for stdin)")
+	asJSON := fs.Bool("json", false, "Print results as JSON")
+	nowStr := fs.String("now", "", "Check expiries as of this date (YYYY-MM-DD) instead of today, e.g. for committed fixtures")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+
+	fs.Parse(os.Args[2:])
+	loadBrands(*brandsFile)
+
+	now := time.Now()
+	if *nowStr != "" {
+		parsed, err := time.Parse("2006-01-02", *nowStr)
+		if err != nil {
+			log.Fatalf("Invalid --now: %q (use YYYY-MM-DD)", *nowStr)
+		}
+		now = parsed
+	}
+
+	var cards []*models.Card
+	switch {
+	case *file != "":
+		cards = readCards(*file)
+	case fs.NArg() > 0:
+		for _, pan := range fs.Args() {
+			cards = append(cards, &models.Card{PAN: pan})
+		}
+	default:
+		// No PANs given: read stdin when piped, otherwise show usage
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Println("Usage: cardgen-pro validate [--json] [--now <YYYY-MM-DD>] [--file <path>|-] [PAN ...]")
+			os.Exit(1)
+		}
+		cards = readCards("-")
+	}
+
+	results := make([]generator.CardValidation, 0, len(cards))
+	invalid := 0
+	for _, card := range cards {
+		result := generator.ValidateCardAt(card, now)
+		if !result.Valid {
+			invalid++
+		}
+		results = append(results, result)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"results": results,
+			"total":   len(results),
+			"valid":   len(results) - invalid,
+			"invalid": invalid,
+		})
+	} else {
+		for _, result := range results {
+			brand := "unknown brand"
+			if b, ok := generator.CardBrands[result.Brand]; ok {
+				brand = b.Name
+			}
+			if result.Valid {
+				fmt.Printf("✓ Valid:   %s (%s)\n", result.MaskedPAN, brand)
+				continue
+			}
+			fmt.Printf("✗ Invalid: %s (%s)\n", result.MaskedPAN, brand)
+			for _, check := range result.Failed() {
+				fmt.Printf("    %s: %s\n", check.Name, check.Detail)
+			}
+		}
+		if len(results) > 1 {
+			fmt.Printf("\n%d cards: %d valid, %d invalid\n", len(results), len(results)-invalid, invalid)
+		}
+	}
+
+	if invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+// readCards reads card records for validate from a file, or stdin for "-"
+func readCards(path string) []*models.Card {
+	input := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", path, err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	cards, err := transformer.ReadCards(input)
+	if err != nil {
+		log.Fatalf("Failed to read cards from %s: %v", path, err)
+	}
+	return cards
+}
+
+func handleBIN() {
+	fs := flag.NewFlagSet("bin", flag.ExitOnError)
+
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	brandsFile := fs.String("brands-file", os.Getenv("CARDGEN_BRANDS"), "Additional/overriding brand definitions (JSON, or CARDGEN_BRANDS env)")
+	binDBFile := fs.String("bindb", os.Getenv("CARDGEN_BINDB"), "BIN table extending the bundled one (JSON, or CARDGEN_BINDB env)")
+
+	fs.Parse(os.Args[2:])
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: cardgen-pro bin [--json] [--bindb file] <pan-or-prefix>")
+		os.Exit(1)
+	}
+	loadBrands(*brandsFile)
+	loadBINDB(*binDBFile)
+
+	// Accept an unquoted PAN typed in groups, e.g. "bin 4532 1100 0000 0000"
+	info, err := generator.LookupBIN(strings.Join(fs.Args(), ""))
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+		return
+	}
+
+	fmt.Printf("BIN:     %s\n", info.BIN)
+	fmt.Printf("Brand:   %s\n", info.BrandName)
+	if info.Issuer != "" {
+		fmt.Printf("Issuer:  %s\n", info.Issuer)
+	}
+	if info.Country != "" {
+		fmt.Printf("Country: %s\n", info.Country)
+	}
+	if info.Type != "" {
+		fmt.Printf("Type:    %s\n", info.Type)
+	}
+	if info.Tier != "" {
+		fmt.Printf("Tier:    %s\n", info.Tier)
+	}
+	fmt.Printf("Source:  %s\n", info.Source)
+}
+
+func handleKeys() {
+	usage := "Usage: cardgen-pro keys <add|import|export|list> --keystore <file> [options]"
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: cardgen-pro validate <PAN>")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
+	subcommand := os.Args[2]
 
-	pan := os.Args[2]
+	fs := flag.NewFlagSet("keys "+subcommand, flag.ExitOnError)
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store file (JSON, or CARDGEN_KEYSTORE env); created on first add/import")
+	kbpkHex := fs.String("kbpk", os.Getenv("CARDGEN_KBPK"), "Test key block protection key: 32/48 hex TDES (version B) or 64 hex AES (version D) (or CARDGEN_KBPK env)")
+	name := fs.String("name", "", "Key name (add, import, export)")
+	keyUsage := fs.String("usage", "", "add: key usage - B0 BDK, C0 CVK, D0 DEK, E0 IMK, K0 KEK, P0 ZPK, V1 IBM 3624 PVK, V2 Visa PVK")
+	algorithm := fs.String("algorithm", "T", "add: key algorithm - T (TDES) or A (AES)")
+	mode := fs.String("mode", "", "add: mode of use - B, C, D, E, G, N, V, X (default by usage: X for B0/E0, C for C0/V*, B for D0/K0/P0)")
+	exportability := fs.String("exportability", "E", "add: E (exportable), N (non-exportable) or S (sensitive)")
+	keyVersion := fs.String("key-version", "00", "add: 2-character key version")
+	keyHex := fs.String("key", "", "add: key hex")
+	generate := fs.Bool("generate", false, "add: generate a random key instead of --key")
+	length := fs.Int("length", 16, "add --generate: key length in bytes (16/24 TDES, 16/24/32 AES)")
+	block := fs.String("block", "", "import: TR-31 key block wrapped under the KBPK")
+	toKBPK := fs.String("to-kbpk", "", "export: rewrap an exportable key under this KBPK (hex) instead")
+	asJSON := fs.Bool("json", false, "list: print the keys as JSON")
 
-	if generator.ValidateLuhn(pan) {
-		fmt.Printf("✓ Valid: %s is a valid PAN (Luhn check passed)\n", generator.MaskPAN(pan))
+	fs.Parse(os.Args[3:])
+	if *keyStorePath == "" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	store := openKeyStoreWith(*keyStorePath, *kbpkHex)
+
+	switch subcommand {
+	case "add":
+		if *name == "" || *keyUsage == "" || (*keyHex == "") == !*generate {
+			fmt.Println("Usage: cardgen-pro keys add --keystore <file> --name <name> --usage <usage> (--key <hex> | --generate) [--algorithm T] [--mode <mode>]")
+			os.Exit(1)
+		}
+
+		var key []byte
+		var err error
+		if *generate {
+			key = make([]byte, *length)
+			if _, err = rand.Read(key); err != nil {
+				log.Fatalf("Failed to generate key: %v", err)
+			}
+			if *algorithm == "T" {
+				key = cardcrypto.WithOddParity(key)
+			}
+		} else if key, err = hex.DecodeString(strings.ReplaceAll(*keyHex, " ", "")); err != nil {
+			log.Fatalf("Invalid key: must be hex: %v", err)
+		}
+
+		header := cardcrypto.TR31Header{
+			KeyUsage:      strings.ToUpper(*keyUsage),
+			Algorithm:     firstByte(*algorithm),
+			ModeOfUse:     firstByte(*mode),
+			KeyVersion:    *keyVersion,
+			Exportability: firstByte(*exportability),
+		}
+		if header.ModeOfUse == 0 {
+			header.ModeOfUse = keystore.DefaultModeOfUse(header.KeyUsage)
+		}
+		if _, err := store.Add(*name, header, key); err != nil {
+			log.Fatalf("Failed to add key: %v", err)
+		}
+		printKeyEntry(store, *name)
+	case "import":
+		if *name == "" || *block == "" {
+			fmt.Println("Usage: cardgen-pro keys import --keystore <file> --name <name> --block <key block>")
+			os.Exit(1)
+		}
+		if err := store.Import(*name, *block); err != nil {
+			log.Fatalf("Failed to import key: %v", err)
+		}
+		printKeyEntry(store, *name)
+	case "export":
+		if *name == "" {
+			fmt.Println("Usage: cardgen-pro keys export --keystore <file> --name <name> [--to-kbpk <hex>]")
+			os.Exit(1)
+		}
+		var kbpk []byte
+		if *toKBPK != "" {
+			var err error
+			if kbpk, err = keystore.ParseKBPK(*toKBPK); err != nil {
+				log.Fatalf("Invalid KBPK: %v", err)
+			}
+		}
+		exported, err := store.Export(*name, kbpk)
+		if err != nil {
+			log.Fatalf("Failed to export key: %v", err)
+		}
+		fmt.Println(exported)
+	case "list":
+		entries, err := store.List()
+		if err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(entries); err != nil {
+				log.Fatalf("Failed to encode keys: %v", err)
+			}
+			return
+		}
+		fmt.Printf("%-20s %-7s %-5s %-9s %-4s %-6s %s\n", "NAME", "VERSION", "USAGE", "ALGORITHM", "MODE", "EXPORT", "KCV")
+		for _, e := range entries {
+			fmt.Printf("%-20s %-7s %-5s %-9s %-4s %-6s %s\n", e.Name, e.Version, e.KeyUsage, e.Algorithm, e.ModeOfUse, e.Exportability, e.KCV)
+		}
+	default:
+		fmt.Printf("Unknown keys command: %s\n\n", subcommand)
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+func handleTokens() {
+	usage := "Usage: cardgen-pro tokens <provision|list|status|cryptogram> --vault <file> [options]"
+	if len(os.Args) < 3 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	subcommand := os.Args[2]
+
+	fs := flag.NewFlagSet("tokens "+subcommand, flag.ExitOnError)
+	vaultPath := fs.String("vault", "", "Token vault file (JSON); created on first provision")
+	brand := fs.String("brand", "visa", "provision: brand of the generated card ("+strings.Join(generator.BrandNames(), ", ")+")")
+	bin := fs.String("bin", "", "provision: BIN of the generated card")
+	secret := fs.String("secret", "", "provision: secret for the generated card's CVC (or use CARDGEN_SECRET env)")
+	pan := fs.String("pan", "", "provision: tokenize this FPAN instead of a generated card (needs --expiry)")
+	expiry := fs.String("expiry", "", "provision: expiry of --pan (YYMM)")
+	requestor := fs.String("requestor", token.DefaultRequestorID, "provision: token requestor ID (11 digits)")
+	dpan := fs.String("dpan", "", "status, cryptogram: the token's DPAN")
+	status := fs.String("status", "", "status: active, suspended or deleted")
+	amount := fs.Int64("amount", 10000, "cryptogram: transaction amount in minor units (cents)")
+	currency := fs.String("currency", "986", "cryptogram: ISO 4217 numeric currency code")
+	asJSON := fs.Bool("json", false, "list, cryptogram: print JSON (cryptogram: with the ISO-8583 request fields)")
+
+	fs.Parse(os.Args[3:])
+	if *vaultPath == "" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	vault := loadVault(*vaultPath)
+
+	switch subcommand {
+	case "provision":
+		card := &models.Card{PAN: *pan}
+		if *pan == "" {
+			secretValue := *secret
+			if secretValue == "" {
+				secretValue = os.Getenv("CARDGEN_SECRET")
+			}
+			var err error
+			card, err = generator.GenerateCard(models.GenerateOptions{BIN: *bin, Brand: strings.ToLower(*brand), Count: 1, Secret: secretValue})
+			if err != nil {
+				log.Fatalf("Failed to generate card: %v", err)
+			}
+		} else {
+			if len(*expiry) != 4 {
+				fmt.Println("Usage: cardgen-pro tokens provision --vault <file> --pan <fpan> --expiry <YYMM>")
+				os.Exit(1)
+			}
+			year, errYear := strconv.Atoi((*expiry)[:2])
+			month, errMonth := strconv.Atoi((*expiry)[2:])
+			if errYear != nil || errMonth != nil {
+				log.Fatalf("Invalid expiry %q: must be YYMM", *expiry)
+			}
+			card.ExpiryMonth, card.ExpiryYear = month, 2000+year
+		}
+
+		t, err := vault.Provision(card, *requestor)
+		if err != nil {
+			log.Fatalf("Failed to provision token: %v", err)
+		}
+		log.Printf("✓ Token %s (expiry %02d/%02d, requestor %s) for %s", t.DPAN, t.ExpiryMonth, t.ExpiryYear%100, t.TokenRequestorID, t.MaskedFPAN)
+
+		t.Key = ""
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(map[string]any{"token": t, "card": card}); err != nil {
+			log.Fatalf("Failed to encode token: %v", err)
+		}
+	case "list":
+		tokens := vault.List()
+		if *asJSON {
+			for _, t := range tokens {
+				t.Key = ""
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(tokens); err != nil {
+				log.Fatalf("Failed to encode tokens: %v", err)
+			}
+			return
+		}
+		fmt.Printf("%-20s %-20s %-6s %-11s %-10s %s\n", "DPAN", "FPAN", "EXPIRY", "REQUESTOR", "STATUS", "ATC")
+		for _, t := range tokens {
+			fmt.Printf("%-20s %-20s %02d/%02d  %-11s %-10s %d\n", t.DPAN, t.MaskedFPAN, t.ExpiryMonth, t.ExpiryYear%100, t.TokenRequestorID, t.Status, t.ATC)
+		}
+	case "status":
+		if *dpan == "" || *status == "" {
+			fmt.Println("Usage: cardgen-pro tokens status --vault <file> --dpan <dpan> --status <active|suspended|deleted>")
+			os.Exit(1)
+		}
+		t, err := vault.SetStatus(*dpan, token.Status(strings.ToLower(*status)))
+		if err != nil {
+			log.Fatalf("Failed to change token status: %v", err)
+		}
+		log.Printf("✓ Token %s is %s", t.DPAN, t.Status)
+	case "cryptogram":
+		if *dpan == "" {
+			fmt.Println("Usage: cardgen-pro tokens cryptogram --vault <file> --dpan <dpan> [--amount 10000] [--currency 986]")
+			os.Exit(1)
+		}
+		cryptogram, t, err := vault.Cryptogram(*dpan, *amount, *currency)
+		if err != nil {
+			log.Fatalf("Failed to generate cryptogram: %v", err)
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(map[string]any{
+				"dpan":       t.DPAN,
+				"cryptogram": cryptogram,
+				"atc":        t.ATC,
+				"iso_fields": api.TokenISOFields(t, cryptogram, *amount, *currency),
+			}); err != nil {
+				log.Fatalf("Failed to encode cryptogram: %v", err)
+			}
+			return
+		}
+		log.Printf("✓ Cryptogram for %s, ATC %d, amount %d %s (DE48 subelement %s)", t.DPAN, t.ATC, *amount, *currency, iso.SubelementTokenCryptogram)
+		fmt.Println(cryptogram)
+	default:
+		fmt.Printf("Unknown tokens command: %s\n\n", subcommand)
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+// printKeyEntry prints the header, check value and key block of a stored key
+func printKeyEntry(store *keystore.Store, name string) {
+	entries, err := store.List()
+	if err != nil {
+		log.Fatalf("Failed to list keys: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			log.Printf("✓ %s: usage %s, algorithm %s, mode %s, exportability %s, KCV %s", e.Name, e.KeyUsage, e.Algorithm, e.ModeOfUse, e.Exportability, e.KCV)
+			fmt.Println(e.KeyBlock)
+		}
+	}
+}
+
+// firstByte returns the first character of a flag value, or 0 if empty
+func firstByte(s string) byte {
+	if s == "" {
+		return 0
+	}
+	return strings.ToUpper(s)[0]
+}
+
+func handleDecodeTLV() {
+	fs := flag.NewFlagSet("decode-tlv", flag.ExitOnError)
+
+	file := fs.String("file", "", "File with hex TLV data (- for stdin)")
+	asJSON := fs.Bool("json", false, "Print the data objects as JSON")
+
+	fs.Parse(os.Args[2:])
+
+	// Hex may be typed in groups, e.g. "decode-tlv 9F27 01 80"
+	var input string
+	switch {
+	case *file != "":
+		input = readInput(*file)
+	case fs.NArg() > 0:
+		input = strings.Join(fs.Args(), "")
+	default:
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Println("Usage: cardgen-pro decode-tlv [--json] [--file <path>|-] [hex ...]")
+			os.Exit(1)
+		}
+		input = readInput("-")
+	}
+
+	objects, err := emv.DecodeHex(input)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(objects)
+		return
+	}
+	printTLV(objects, "")
+}
+
+// printTLV prints data objects one per line, indenting the children of constructed tags
+func printTLV(objects []emv.TLV, indent string) {
+	for _, object := range objects {
+		name := object.Name()
+		if name == "" {
+			name = "(unknown)"
+		}
+		if object.Constructed() {
+			fmt.Printf("%s%-6s %-45s %3d\n", indent, object.Tag, name, len(object.Value))
+			printTLV(object.Children, indent+"  ")
+			continue
+		}
+		fmt.Printf("%s%-6s %-45s %3d  %X\n", indent, object.Tag, name, len(object.Value), object.Value)
+	}
+}
+
+// readInput reads a whole file, or stdin for "-"
+func readInput(path string) string {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
 	} else {
-		fmt.Printf("✗ Invalid: %s failed Luhn check\n", generator.MaskPAN(pan))
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func handleDUKPT() {
+	fs := flag.NewFlagSet("dukpt", flag.ExitOnError)
+
+	bdkHex := fs.String("bdk", os.Getenv("CARDGEN_BDK"), "Key store name or test DUKPT base derivation key (or CARDGEN_BDK env)")
+	ksnHex := fs.String("ksn", "", "KSN: 20 hex (TDES DUKPT) or 24 hex (AES DUKPT)")
+	pin := fs.String("pin", "", "PIN to encrypt under the transaction's PIN key (needs --pan)")
+	pinBlock := fs.String("pin-block", "", "Encrypted PIN block (DE52 hex) to decrypt (needs --pan)")
+	pan := fs.String("pan", "", "PAN for the PIN block")
+	pinFormat := fs.Int("pin-format", 0, "ISO 9564 PIN block format for --pin: 0, 1, 3 (TDES DUKPT); AES DUKPT always uses 4")
+	data := fs.String("data", "", "Hex data to encrypt under the transaction's data key")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	keyStorePath := fs.String("keystore", os.Getenv("CARDGEN_KEYSTORE"), "Key store --bdk may name a key in (or CARDGEN_KEYSTORE env, KBPK in CARDGEN_KBPK)")
+
+	fs.Parse(os.Args[2:])
+	if *bdkHex == "" || *ksnHex == "" {
+		fmt.Println("Usage: cardgen-pro dukpt --bdk <hex> --ksn <hex> [--pin <pin> --pan <pan>] [--pin-block <hex> --pan <pan>] [--data <hex>] [--json]")
 		os.Exit(1)
 	}
+
+	bdk, err := cardcrypto.ParseBDK(resolveKey(openKeyStore(*keyStorePath), *bdkHex, keystore.BDKDerive))
+	if err != nil {
+		log.Fatalf("Invalid BDK: %v", err)
+	}
+	ksn, err := cardcrypto.ParseKSN(*ksnHex)
+	if err != nil {
+		log.Fatalf("Invalid KSN: %v", err)
+	}
+	initial, err := cardcrypto.DeriveInitialKey(bdk, ksn)
+	if err != nil {
+		log.Fatalf("Failed to derive the initial key: %v", err)
+	}
+	keys, err := cardcrypto.DeriveDUKPTKeys(bdk, ksn)
+	if err != nil {
+		log.Fatalf("Failed to derive the transaction keys: %v", err)
+	}
+	counter, _ := cardcrypto.KSNCounter(ksn)
+
+	result := struct {
+		Variant       string `json:"variant"`
+		KSN           string `json:"ksn"`
+		Counter       uint32 `json:"counter"`
+		NextKSN       string `json:"next_ksn,omitempty"`
+		InitialKey    string `json:"initial_key"`
+		PINKey        string `json:"pin_key"`
+		MACKey        string `json:"mac_key"`
+		DataKey       string `json:"data_key"`
+		PINBlock      string `json:"pin_block,omitempty"`
+		PIN           string `json:"pin,omitempty"`
+		PINFormat     string `json:"pin_block_format,omitempty"`
+		EncryptedData string `json:"encrypted_data,omitempty"`
+	}{
+		Variant:    "TDES",
+		KSN:        fmt.Sprintf("%X", ksn),
+		Counter:    counter,
+		InitialKey: fmt.Sprintf("%X", initial),
+		PINKey:     fmt.Sprintf("%X", keys.PIN),
+		MACKey:     fmt.Sprintf("%X", keys.MAC),
+		DataKey:    fmt.Sprintf("%X", keys.Data),
+	}
+	if keys.AES {
+		result.Variant = "AES"
+	}
+	if next, err := cardcrypto.NextKSN(ksn); err == nil {
+		result.NextKSN = fmt.Sprintf("%X", next)
+	}
+
+	if *pin != "" {
+		format := *pinFormat
+		if keys.AES {
+			format = cardcrypto.PINFormat4
+		}
+		block, err := cardcrypto.EncryptPINBlock(format, *pin, *pan, keys.PIN, nil)
+		if err != nil {
+			log.Fatalf("Failed to encrypt the PIN block: %v", err)
+		}
+		result.PINBlock = fmt.Sprintf("%X", block)
+		result.PINFormat = fmt.Sprintf("ISO-%d", format)
+	}
+	if *pinBlock != "" {
+		block, err := hex.DecodeString(strings.ReplaceAll(*pinBlock, " ", ""))
+		if err != nil {
+			log.Fatalf("Invalid PIN block: %v", err)
+		}
+		clearPIN, format, err := cardcrypto.DecryptPINBlock(block, *pan, keys.PIN)
+		if err != nil {
+			log.Fatalf("Failed to decrypt the PIN block: %v", err)
+		}
+		result.PINBlock = fmt.Sprintf("%X", block)
+		result.PIN = clearPIN
+		result.PINFormat = fmt.Sprintf("ISO-%d", format)
+	}
+	if *data != "" {
+		plain, err := hex.DecodeString(strings.ReplaceAll(*data, " ", ""))
+		if err != nil {
+			log.Fatalf("Invalid data: %v", err)
+		}
+		encrypted, err := cardcrypto.EncryptDUKPTData(keys, plain)
+		if err != nil {
+			log.Fatalf("Failed to encrypt the data: %v", err)
+		}
+		result.EncryptedData = fmt.Sprintf("%X", encrypted)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+		return
+	}
+
+	fmt.Printf("Variant:     %s DUKPT\n", result.Variant)
+	fmt.Printf("KSN:         %s (counter %d)\n", result.KSN, result.Counter)
+	if result.NextKSN != "" {
+		fmt.Printf("Next KSN:    %s\n", result.NextKSN)
+	}
+	fmt.Printf("Initial key: %s\n", result.InitialKey)
+	fmt.Printf("PIN key:     %s\n", result.PINKey)
+	fmt.Printf("MAC key:     %s\n", result.MACKey)
+	fmt.Printf("Data key:    %s\n", result.DataKey)
+	if result.PINBlock != "" {
+		fmt.Printf("PIN block:   %s (%s)\n", result.PINBlock, result.PINFormat)
+	}
+	if result.PIN != "" {
+		fmt.Printf("PIN:         %s\n", result.PIN)
+	}
+	if result.EncryptedData != "" {
+		fmt.Printf("Encrypted:   %s\n", result.EncryptedData)
+	}
 }
 
 func handleScenarios() {
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+var (
+	// ErrUnknownScenario is returned for a scenario ID not in GetScenarios
+	ErrUnknownScenario = errors.New("unknown scenario")
+	// ErrNoScenarioCard is returned for scenarios without a card (e.g. PIX, boleto)
+	ErrNoScenarioCard = errors.New("scenario has no magic card")
+)
+
+// GetScenario returns the scenario with the given ID
+func GetScenario(id string) (Scenario, bool) {
+	for _, scenario := range GetScenarios() {
+		if scenario.ID == id {
+			return scenario, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// GenerateScenarioCard generates a Luhn-valid card whose authorization yields the scenario's response code
+//
+// The PAN starts with the scenario's reserved prefix (see authorizer.MagicCards) and
+// ISOFields carry the scenario amount and currency, ready to submit to the authorizer.
+// With a secret the card gets a test PIN, and with a ZPK too its format 0 PIN block
+// in DE52, matching the chip-and-PIN entry mode (051) of DE22.
+func GenerateScenarioCard(id, secret, zpk string) (*models.Card, error) {
+	scenario, ok := GetScenario(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScenario, id)
+	}
+
+	magic, ok := authorizer.MagicCardFor(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoScenarioCard, id)
+	}
+
+	card, err := generator.GenerateCard(models.GenerateOptions{
+		BIN:           magic.PANPrefix,
+		Brand:         magic.Brand,
+		Count:         1,
+		Secret:        secret,
+		IncludePIN:    secret != "",
+		ZPK:           zpk,
+		IncludeTrack2: true,
+		Metadata:      map[string]string{"scenario": scenario.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	card.ISOFields = iso.GenerateISO8583Fields(card, scenario.Amount, scenario.Currency)
+
+	return card, nil
+}
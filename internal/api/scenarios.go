@@ -1,5 +1,7 @@
 package api
 
+import "github.com/felipemacedo/cardgen-pro/internal/token"
+
 // Scenario represents a test scenario with expected behavior
 type Scenario struct {
 	ID              string            `json:"id"`
@@ -177,7 +179,7 @@ func GetScenarios() []Scenario {
 		{
 			ID:              "tokenized_payment",
 			Name:            "Tokenized Card Payment",
-			Description:     "Payment using stored card token",
+			Description:     "Payment with a network token (DPAN) and its cryptogram",
 			ResponseCode:    "00",
 			ResponseText:    "Approved",
 			Amount:          12000,
@@ -185,9 +187,10 @@ func GetScenarios() []Scenario {
 			CardBrand:       "mastercard",
 			ExpectedOutcome: "Token-based payment successful",
 			Metadata: map[string]string{
-				"type":       "token_payment",
-				"token_id":   "tok_1A2B3C4D5E6F",
-				"token_type": "card_on_file",
+				"type":               "token_payment",
+				"token_type":         "network_token",
+				"token_bin":          token.TokenBIN("mastercard"),
+				"token_requestor_id": token.DefaultRequestorID,
 			},
 		},
 	}
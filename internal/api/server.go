@@ -1,17 +1,27 @@
 package api
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/bindb"
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
 	"github.com/felipemacedo/cardgen-pro/internal/generator"
 	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/keystore"
+	"github.com/felipemacedo/cardgen-pro/internal/ledger"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
 )
 
 // Server represents the HTTP API server for fixtures
@@ -19,6 +29,10 @@ type Server struct {
 	token      string
 	port       int
 	rateLimiter *RateLimiter
+	authorizer  *authorizer.Engine
+	ledger      *ledger.Ledger
+	vault       *token.Vault
+	keys        *keystore.Store
 }
 
 // RateLimiter implements a simple token bucket rate limiter
@@ -67,12 +81,62 @@ func (rl *RateLimiter) Allow(ip string) bool {
 }
 
 // NewServer creates a new API server
-func NewServer(token string, port int) *Server {
-	return &Server{
-		token:       token,
+func NewServer(authToken string, port int) *Server {
+	s := &Server{
+		token:       authToken,
 		port:        port,
 		rateLimiter: NewRateLimiter(100, time.Minute), // 100 requests per minute
+		ledger:      ledger.New(),
 	}
+	s.SetVault(token.New())
+	s.SetAuthorizer(authorizer.NewEngine(authorizer.DefaultRules()))
+	return s
+}
+
+// SetAuthorizer changes the decision engine used by /v1/authorize
+//
+// The engine detokenizes the DPANs of the server's token vault.
+func (s *Server) SetAuthorizer(engine *authorizer.Engine) {
+	engine.SetVault(s.vault)
+	s.authorizer = engine
+}
+
+// SetVault changes the token vault (e.g. a file-persisted one) of /v1/tokens and the authorizer
+func (s *Server) SetVault(v *token.Vault) {
+	s.vault = v
+	if s.authorizer != nil {
+		s.authorizer.SetVault(v)
+	}
+}
+
+// SetLedger changes the transaction ledger (e.g. a file-persisted one)
+func (s *Server) SetLedger(l *ledger.Ledger) {
+	s.ledger = l
+}
+
+// SetKeyStore lets the key parameters of /v1/cards and /v1/scenarios/{id}/card name keys in store
+func (s *Server) SetKeyStore(store *keystore.Store) {
+	s.keys = store
+}
+
+// resolveKey returns the hex of the key a query parameter names in the key store, checking its
+// usage and mode of use; anything else is taken as a raw hex key, with a warning
+func (s *Server) resolveKey(r *http.Request, param string, required keystore.Requirement) (string, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return "", nil
+	}
+
+	if s.keys != nil && s.keys.Has(value) {
+		key, err := s.keys.Key(value, required)
+		if err != nil {
+			return "", err
+		}
+		return strings.ToUpper(hex.EncodeToString(key)), nil
+	}
+
+	log.Printf("⚠️  Warning: raw hex %s from %s; prefer a key store name", param, r.RemoteAddr)
+	return value, nil
 }
 
 // authMiddleware validates the bearer token
@@ -133,31 +197,120 @@ func (s *Server) handleGenerateCards(w http.ResponseWriter, r *http.Request) {
 
 	secret := r.URL.Query().Get("secret")
 
-	// Generate cards
-	opts := models.GenerateOptions{
-		BIN:           bin,
-		Brand:         brand,
-		Count:         count,
-		Secret:        secret,
-		IncludeISO:    true,
-		IncludeTrack2: true,
+	// Key parameters name keys in the key store (or are raw hex)
+	keys := map[string]string{}
+	for _, k := range []struct {
+		param    string
+		required keystore.Requirement
+	}{
+		{"cvk", keystore.CVKGenerate},
+		{"imk", keystore.IMKDerive},
+		{"zpk", keystore.ZPKEncrypt},
+		{"bdk", keystore.BDKDerive},
+		{"pvk", keystore.PVKGenerate},
+	} {
+		key, err := s.resolveKey(r, k.param, k.required)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s: %v", k.param, err), http.StatusBadRequest)
+			return
+		}
+		keys[k.param] = key
+	}
+
+	var seed int64
+	if seedStr := r.URL.Query().Get("seed"); seedStr != "" {
+		parsed, err := strconv.ParseInt(seedStr, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid seed: %q", seedStr), http.StatusBadRequest)
+			return
+		}
+		seed = parsed
+	}
+
+	var length int
+	if lengthStr := r.URL.Query().Get("length"); lengthStr != "" {
+		parsed, err := strconv.Atoi(lengthStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid length: %q", lengthStr), http.StatusBadRequest)
+			return
+		}
+		length = parsed
+	}
+
+	includeTrack1, err := parseBoolParam(r, "track1")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawTracks, err := parseBoolParam(r, "raw_tracks")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	includePIN, err := parseBoolParam(r, "pin")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	cards := []*models.Card{}
-	for i := 0; i < count; i++ {
-		card, err := generator.GenerateCard(opts)
+	pvki := generator.DefaultPVKI
+	if pvkiStr := r.URL.Query().Get("pvki"); pvkiStr != "" {
+		parsed, err := strconv.Atoi(pvkiStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to generate card: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Invalid pvki: %q", pvkiStr), http.StatusBadRequest)
 			return
 		}
+		pvki = parsed
+	}
 
-		// Add ISO fields
-		if opts.IncludeISO {
-			isoFields := iso.GenerateISO8583Fields(card, 10000, "986")
-			card.ISOFields = isoFields
+	var pinFormat int
+	if formatStr := r.URL.Query().Get("pin_format"); formatStr != "" {
+		parsed, err := strconv.Atoi(formatStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid pin_format: %q", formatStr), http.StatusBadRequest)
+			return
 		}
+		pinFormat = parsed
+	}
+
+	// Generate cards
+	opts := models.GenerateOptions{
+		BIN:            bin,
+		Brand:          brand,
+		Count:          count,
+		Secret:         secret,
+		CVK:            keys["cvk"],
+		IMK:            keys["imk"],
+		IncludePIN:     includePIN,
+		ZPK:            keys["zpk"],
+		PINFormat:      pinFormat,
+		BDK:            keys["bdk"],
+		KSN:            r.URL.Query().Get("ksn"),
+		PVK:            keys["pvk"],
+		PVKI:           pvki,
+		IncludeISO:     true,
+		IncludeTrack2:  true,
+		IncludeTrack1:  includeTrack1 || r.URL.Query().Get("name") != "",
+		CardholderName: r.URL.Query().Get("name"),
+		RawTracks:      rawTracks,
+		Seed:           seed,
+		PANLength:      length,
+		Country:        r.URL.Query().Get("country"),
+		CardType:       r.URL.Query().Get("card_type"),
+	}
+
+	// Generation only fails on invalid options (brand, BIN, length, filters, keys, name, PIN)
+	cards, err := generator.GenerateCards(opts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate card: %v", err), http.StatusBadRequest)
+		return
+	}
 
-		cards = append(cards, card)
+	// Add ISO fields
+	if opts.IncludeISO {
+		for _, card := range cards {
+			card.ISOFields = iso.GenerateISO8583FieldsAt(card, 10000, "986", card.GeneratedAt)
+		}
 	}
 
 	// Return JSON
@@ -168,6 +321,19 @@ func (s *Server) handleGenerateCards(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// parseBoolParam parses an optional boolean query parameter (absent = false)
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s: %q", name, value)
+	}
+	return parsed, nil
+}
+
 // handleScenarios handles GET /v1/scenarios
 func (s *Server) handleScenarios(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -181,6 +347,100 @@ func (s *Server) handleScenarios(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(scenarios)
 }
 
+// handleBIN handles GET /v1/bins/{bin}
+func (s *Server) handleBIN(w http.ResponseWriter, r *http.Request) {
+	info, err := generator.LookupBIN(r.PathValue("bin"))
+	switch {
+	case errors.Is(err, bindb.ErrInvalidBIN):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, bindb.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleScenarioCard handles GET /v1/scenarios/{id}/card
+func (s *Server) handleScenarioCard(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("secret")
+	zpk, err := s.resolveKey(r, "zpk", keystore.ZPKEncrypt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid zpk: %v", err), http.StatusBadRequest)
+		return
+	}
+	if zpk != "" {
+		if secret == "" {
+			http.Error(w, "zpk requires a secret (test PINs derive from it)", http.StatusBadRequest)
+			return
+		}
+		if _, err := cardcrypto.ParsePINKey(zpk); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid zpk: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	card, err := GenerateScenarioCard(r.PathValue("id"), secret, zpk)
+	if errors.Is(err, ErrUnknownScenario) || errors.Is(err, ErrNoScenarioCard) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate card: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+// handleAuthorize handles POST /v1/authorize
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request iso.AuthorizationRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if request.MTI == "" {
+		request.MTI = "0100"
+	}
+	if request.Fields == nil {
+		request.Fields = iso.ISO8583Fields{}
+	}
+	if request.Timestamp.IsZero() {
+		request.Timestamp = time.Now()
+	}
+
+	var response *iso.AuthorizationResponse
+	if len(request.MTI) == 4 && request.MTI[1:] == "400" {
+		// Reversals are matched against the ledger by original STAN
+		_, err := s.ledger.Reverse(request.Fields)
+		code := ledger.ResponseCode(err)
+		response = iso.GenerateMockAuthResponse(&request, code, iso.ResponseCodes[code])
+	} else {
+		response = s.authorizer.Authorize(&request)
+		if response.ResponseCode == "00" {
+			// Never approve what the ledger refused to record (e.g. 94 for a duplicate RRN)
+			if _, err := s.ledger.Record(&request, response); err != nil {
+				log.Printf("ledger: %v", err)
+				code := ledger.ResponseCode(err)
+				response = iso.GenerateMockAuthResponse(&request, code, iso.ResponseCodes[code])
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // handleHealth handles GET /health
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -200,6 +460,19 @@ func (s *Server) Start() error {
 	// Protected endpoints
 	mux.HandleFunc("/v1/cards", s.rateLimitMiddleware(s.authMiddleware(s.handleGenerateCards)))
 	mux.HandleFunc("/v1/scenarios", s.rateLimitMiddleware(s.authMiddleware(s.handleScenarios)))
+	mux.HandleFunc("GET /v1/scenarios/{id}/card", s.rateLimitMiddleware(s.authMiddleware(s.handleScenarioCard)))
+	mux.HandleFunc("GET /v1/bins/{bin}", s.rateLimitMiddleware(s.authMiddleware(s.handleBIN)))
+	mux.HandleFunc("/v1/authorize", s.rateLimitMiddleware(s.authMiddleware(s.handleAuthorize)))
+	mux.HandleFunc("GET /v1/transactions", s.rateLimitMiddleware(s.authMiddleware(s.handleListTransactions)))
+	mux.HandleFunc("GET /v1/transactions/{ref}", s.rateLimitMiddleware(s.authMiddleware(s.handleGetTransaction)))
+	mux.HandleFunc("POST /v1/transactions/{ref}/capture", s.rateLimitMiddleware(s.authMiddleware(s.handleCapture)))
+	mux.HandleFunc("POST /v1/transactions/{ref}/void", s.rateLimitMiddleware(s.authMiddleware(s.handleVoid)))
+	mux.HandleFunc("POST /v1/transactions/{ref}/refund", s.rateLimitMiddleware(s.authMiddleware(s.handleRefund)))
+	mux.HandleFunc("POST /v1/tokens", s.rateLimitMiddleware(s.authMiddleware(s.handleProvisionToken)))
+	mux.HandleFunc("GET /v1/tokens", s.rateLimitMiddleware(s.authMiddleware(s.handleListTokens)))
+	mux.HandleFunc("GET /v1/tokens/{dpan}", s.rateLimitMiddleware(s.authMiddleware(s.handleGetToken)))
+	mux.HandleFunc("POST /v1/tokens/{dpan}/status", s.rateLimitMiddleware(s.authMiddleware(s.handleTokenStatus)))
+	mux.HandleFunc("POST /v1/tokens/{dpan}/cryptogram", s.rateLimitMiddleware(s.authMiddleware(s.handleTokenCryptogram)))
 
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Starting API server on %s", addr)
@@ -207,6 +480,13 @@ func (s *Server) Start() error {
 	log.Printf("  GET /health")
 	log.Printf("  GET /v1/cards (protected)")
 	log.Printf("  GET /v1/scenarios (protected)")
+	log.Printf("  GET /v1/scenarios/{id}/card (protected)")
+	log.Printf("  GET /v1/bins/{bin} (protected)")
+	log.Printf("  POST /v1/authorize (protected)")
+	log.Printf("  GET /v1/transactions[/{ref}] (protected)")
+	log.Printf("  POST /v1/transactions/{ref}/{capture,void,refund} (protected)")
+	log.Printf("  GET|POST /v1/tokens, GET /v1/tokens/{dpan} (protected)")
+	log.Printf("  POST /v1/tokens/{dpan}/{status,cryptogram} (protected)")
 	
 	return http.ListenAndServe(addr, mux)
 }
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
+)
+
+// provisionRequest is the body of POST /v1/tokens
+//
+// Without a PAN a card of the brand (default visa) or BIN is generated and tokenized.
+type provisionRequest struct {
+	PAN              string `json:"pan"`
+	ExpiryMonth      int    `json:"expiry_month"`
+	ExpiryYear       int    `json:"expiry_year"`
+	Brand            string `json:"brand"`
+	BIN              string `json:"bin"`
+	Secret           string `json:"secret"` // CVC secret of a generated card
+	TokenRequestorID string `json:"token_requestor_id"`
+}
+
+// provisionResponse is the new token, and the card it stands for when one was generated
+type provisionResponse struct {
+	Token *token.Token `json:"token"`
+	Card  *models.Card `json:"card,omitempty"`
+}
+
+// statusRequest is the body of POST /v1/tokens/{dpan}/status
+type statusRequest struct {
+	Status token.Status `json:"status"`
+}
+
+// cryptogramRequest is the body of POST /v1/tokens/{dpan}/cryptogram
+type cryptogramRequest struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"` // Default 986
+}
+
+// cryptogramResponse carries the cryptogram and the request fields to authorize it with
+type cryptogramResponse struct {
+	DPAN       string            `json:"dpan"`
+	Cryptogram string            `json:"cryptogram"`
+	ATC        int               `json:"atc"`
+	ISOFields  iso.ISO8583Fields `json:"iso_fields"`
+}
+
+// handleProvisionToken handles POST /v1/tokens
+func (s *Server) handleProvisionToken(w http.ResponseWriter, r *http.Request) {
+	var body provisionRequest
+	if err := decodeBody(r, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	card := &models.Card{PAN: body.PAN, ExpiryMonth: body.ExpiryMonth, ExpiryYear: body.ExpiryYear}
+	generated := body.PAN == ""
+	if generated {
+		brand := strings.ToLower(body.Brand)
+		if brand == "" && body.BIN == "" {
+			brand = "visa"
+		}
+		var err error
+		card, err = generator.GenerateCard(models.GenerateOptions{BIN: body.BIN, Brand: brand, Count: 1, Secret: body.Secret})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate card: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	t, err := s.vault.Provision(card, body.TokenRequestorID)
+	if err != nil {
+		writeToken(w, nil, err)
+		return
+	}
+
+	response := provisionResponse{Token: publicToken(t)}
+	if generated {
+		response.Card = card
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListTokens handles GET /v1/tokens
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens := s.vault.List()
+	for i, t := range tokens {
+		tokens[i] = publicToken(t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// handleGetToken handles GET /v1/tokens/{dpan}
+func (s *Server) handleGetToken(w http.ResponseWriter, r *http.Request) {
+	t, err := s.vault.Get(r.PathValue("dpan"))
+	writeToken(w, t, err)
+}
+
+// handleTokenStatus handles POST /v1/tokens/{dpan}/status
+func (s *Server) handleTokenStatus(w http.ResponseWriter, r *http.Request) {
+	var body statusRequest
+	if err := decodeBody(r, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.vault.SetStatus(r.PathValue("dpan"), body.Status)
+	writeToken(w, t, err)
+}
+
+// handleTokenCryptogram handles POST /v1/tokens/{dpan}/cryptogram
+func (s *Server) handleTokenCryptogram(w http.ResponseWriter, r *http.Request) {
+	var body cryptogramRequest
+	if err := decodeBody(r, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Amount <= 0 {
+		http.Error(w, "amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if body.Currency == "" {
+		body.Currency = "986"
+	}
+
+	dpan := r.PathValue("dpan")
+	cryptogram, t, err := s.vault.Cryptogram(dpan, body.Amount, body.Currency)
+	if err != nil {
+		writeToken(w, nil, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cryptogramResponse{
+		DPAN:       t.DPAN,
+		Cryptogram: cryptogram,
+		ATC:        t.ATC,
+		ISOFields:  TokenISOFields(t, cryptogram, body.Amount, body.Currency),
+	})
+}
+
+// TokenISOFields builds the authorization request fields of a token transaction
+//
+// DE2 and DE14 carry the DPAN and token expiry and DE48 the cryptogram. The entry mode
+// is e-commerce (812): the cryptogram stands in for chip data, so there is no DE55.
+func TokenISOFields(t *token.Token, cryptogram string, amount int64, currency string) iso.ISO8583Fields {
+	fields := iso.GenerateISO8583Fields(&models.Card{
+		PAN:         t.DPAN,
+		Brand:       t.Brand,
+		ExpiryMonth: t.ExpiryMonth,
+		ExpiryYear:  t.ExpiryYear,
+	}, amount, currency)
+
+	fields["22"] = "812"
+	delete(fields, "55")
+	fields["48"] = iso.FormatPrivateData(map[string]string{iso.SubelementTokenCryptogram: cryptogram})
+	return fields
+}
+
+// decodeBody reads a required JSON request body
+func decodeBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// publicToken returns a token without its cryptogram key
+func publicToken(t *token.Token) *token.Token {
+	t.Key = ""
+	return t
+}
+
+// writeToken writes the token, mapping vault errors to HTTP statuses
+func writeToken(w http.ResponseWriter, t *token.Token, err error) {
+	switch {
+	case errors.Is(err, token.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, token.ErrInvalidState):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(publicToken(t))
+}
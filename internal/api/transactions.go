@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/felipemacedo/cardgen-pro/internal/ledger"
+)
+
+// transitionRequest is the optional body for capture and refund (amount 0 = full/remaining amount)
+type transitionRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+// handleListTransactions handles GET /v1/transactions
+func (s *Server) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.ledger.List())
+}
+
+// handleGetTransaction handles GET /v1/transactions/{ref}
+func (s *Server) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	txn, err := s.ledger.Get(r.PathValue("ref"))
+	writeTransaction(w, txn, err)
+}
+
+// handleCapture handles POST /v1/transactions/{ref}/capture
+func (s *Server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	body, err := decodeTransition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	txn, err := s.ledger.Capture(r.PathValue("ref"), body.Amount)
+	writeTransaction(w, txn, err)
+}
+
+// handleVoid handles POST /v1/transactions/{ref}/void
+func (s *Server) handleVoid(w http.ResponseWriter, r *http.Request) {
+	txn, err := s.ledger.Void(r.PathValue("ref"))
+	writeTransaction(w, txn, err)
+}
+
+// handleRefund handles POST /v1/transactions/{ref}/refund
+func (s *Server) handleRefund(w http.ResponseWriter, r *http.Request) {
+	body, err := decodeTransition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	txn, err := s.ledger.Refund(r.PathValue("ref"), body.Amount)
+	writeTransaction(w, txn, err)
+}
+
+// decodeTransition reads the optional {"amount": n} body
+func decodeTransition(r *http.Request) (transitionRequest, error) {
+	var body transitionRequest
+	err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return body, fmt.Errorf("invalid request body: %v", err)
+	}
+	return body, nil
+}
+
+// writeTransaction writes the transaction, mapping ledger errors to HTTP statuses
+func writeTransaction(w http.ResponseWriter, txn *ledger.Transaction, err error) {
+	switch {
+	case errors.Is(err, ledger.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ledger.ErrInvalidState), errors.Is(err, ledger.ErrAmountExceeded):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(txn)
+}
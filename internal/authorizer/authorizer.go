@@ -0,0 +1,644 @@
+package authorizer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/emv"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+// Rules configures the mock authorization decision engine
+//
+// Example rules file:
+//
+//	{
+//	  "default_limit": 500000,
+//	  "bin_limits": {"400000": 50000, "5100": 100000},
+//	  "blocked_pans": ["4000000000000010"],
+//	  "cvc_secret": "my-test-secret",
+//	  "cvk": "0123456789ABCDEFFEDCBA9876543210",
+//	  "imk": "0123456789ABCDEFFEDCBA9876543210",
+//	  "zpk": "0123456789ABCDEFFEDCBA9876543210",
+//	  "bdk": "0123456789ABCDEFFEDCBA9876543210",
+//	  "pvk": "0123456789ABCDEFFEDCBA9876543210",
+//	  "pin_method": "pvv",
+//	  "pin_try_limit": 3,
+//	  "disable_magic": false
+//	}
+type Rules struct {
+	DefaultLimit int64            `json:"default_limit,omitempty"` // Max amount (minor units), 0 = unlimited
+	BINLimits    map[string]int64 `json:"bin_limits,omitempty"`    // Max amount per PAN prefix (longest prefix wins)
+	BlockedPANs  []string         `json:"blocked_pans,omitempty"`  // PANs declined as stolen
+	CVCSecret    string           `json:"cvc_secret,omitempty"`    // Secret used to verify CVC2 and derive test PINs, empty = not checked
+	CVK          string           `json:"cvk,omitempty"`           // Hex CVK-A || CVK-B; verifies 3-digit CVV2 instead of cvc_secret
+	IMK          string           `json:"imk,omitempty"`           // Hex issuer master key (MK-AC); verifies DE55 ARQCs and adds ARPCs
+	ZPK          string           `json:"zpk,omitempty"`           // Hex zone PIN key; verifies DE52 PIN blocks against the cvc_secret PIN
+	BDK          string           `json:"bdk,omitempty"`           // Hex DUKPT base derivation key; verifies DE52 PIN blocks sent with a DE53 KSN
+	PVK          string           `json:"pvk,omitempty"`           // Hex PIN verification key; PINs are verified through the PVV or IBM 3624 offset
+	PINMethod    string           `json:"pin_method,omitempty"`    // With a PVK: PINMethodPVV (default) or PINMethodIBM3624
+	PINTryLimit  int              `json:"pin_try_limit,omitempty"` // Consecutive wrong PINs before 75, 0 = DefaultPINTryLimit
+	DisableMagic bool             `json:"disable_magic,omitempty"` // Ignore magic cards and amounts (see MagicCards)
+}
+
+// DefaultPINTryLimit is the number of consecutive wrong PINs after which a card is blocked (75)
+const DefaultPINTryLimit = 3
+
+// PIN verification methods with a PVK
+const (
+	PINMethodPVV     = "pvv"     // Visa PVV from the DE35 Track 2 data, else derived from the test PIN
+	PINMethodIBM3624 = "ibm3624" // IBM 3624 offset, derived from the test PIN (the issuer's offset database)
+)
+
+// DefaultRules returns rules that only apply card-level checks (Luhn, expiry)
+func DefaultRules() *Rules {
+	return &Rules{}
+}
+
+// LoadRules loads rules from a JSON file
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules %s: %w", path, err)
+	}
+
+	for prefix, limit := range rules.BINLimits {
+		if prefix == "" || !isDigits(prefix) {
+			return nil, fmt.Errorf("bin_limits: invalid prefix %q", prefix)
+		}
+		if limit < 0 {
+			return nil, fmt.Errorf("bin_limits: negative limit for %s", prefix)
+		}
+	}
+
+	if rules.CVK != "" {
+		if _, err := cardcrypto.ParseCVK(rules.CVK); err != nil {
+			return nil, fmt.Errorf("cvk: %w", err)
+		}
+	}
+
+	if rules.IMK != "" {
+		if _, err := cardcrypto.ParseKey(rules.IMK); err != nil {
+			return nil, fmt.Errorf("imk: %w", err)
+		}
+	}
+
+	if rules.ZPK != "" {
+		if _, err := cardcrypto.ParsePINKey(rules.ZPK); err != nil {
+			return nil, fmt.Errorf("zpk: %w", err)
+		}
+	}
+	if rules.BDK != "" {
+		if _, err := cardcrypto.ParseBDK(rules.BDK); err != nil {
+			return nil, fmt.Errorf("bdk: %w", err)
+		}
+	}
+	if rules.PVK != "" {
+		if _, err := cardcrypto.ParsePVK(rules.PVK); err != nil {
+			return nil, fmt.Errorf("pvk: %w", err)
+		}
+	}
+	switch rules.PINMethod {
+	case "", PINMethodPVV, PINMethodIBM3624:
+	default:
+		return nil, fmt.Errorf("pin_method: unknown method %q (use %s or %s)", rules.PINMethod, PINMethodPVV, PINMethodIBM3624)
+	}
+	if rules.PINTryLimit < 0 {
+		return nil, fmt.Errorf("pin_try_limit: must not be negative")
+	}
+
+	return rules, nil
+}
+
+// Decision is the outcome of evaluating a request against the rules
+type Decision struct {
+	Code   string `json:"code"`             // DE39 response code
+	Text   string `json:"text"`             // Human-readable response text
+	Reason string `json:"reason,omitempty"` // Rule that produced the decision
+}
+
+// Engine decides DE39 response codes for authorization requests
+//
+// DESIGN RATIONALE:
+// - Outcomes derive from the request, so callers no longer hard-wire response codes
+// - Rules are evaluated in a fixed order; the first failing rule wins
+// - Shared by the HTTP API and the ISO-8583 simulator so both behave identically
+// - FOR TEST/SANDBOX USE ONLY
+//
+// RULE ORDER:
+// 30 - Format error (missing PAN/amount, bad DE14)
+// 14 - Invalid card number (Luhn failure, except non-Luhn ranges)
+// -- - Token checks for a DPAN in the vault (see detokenize); the rules below see the FPAN
+// ** - Magic card or amount outcome (see MagicCards, MagicAmounts)
+// 43 - Stolen card (blocked PAN list)
+// 54 - Expired card (DE14 in the past)
+// N7 - CVC2 mismatch against the CVK-derived CVV2 or GenerateDeterministicCVC
+// 63 - ARQC in DE55 does not verify with the IMK-derived session key
+// 75 - PIN tries exceeded (the card reached the PIN try limit)
+// 55 - Incorrect PIN (DE52, under the ZPK or DUKPT key, fails the PVV/offset check or
+//
+//	is not the GeneratePIN test PIN)
+//
+// 51 - Insufficient funds (amount over the per-BIN limit)
+type Engine struct {
+	rules   *Rules
+	blocked map[string]bool
+	vault   *token.Vault
+	now     func() time.Time
+
+	mu          sync.Mutex
+	pinFailures map[string]int // Consecutive wrong PINs per PAN
+}
+
+// NewEngine creates a decision engine for the given rules
+func NewEngine(rules *Rules) *Engine {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+
+	blocked := make(map[string]bool, len(rules.BlockedPANs))
+	for _, pan := range rules.BlockedPANs {
+		blocked[pan] = true
+	}
+
+	return &Engine{
+		rules:       rules,
+		blocked:     blocked,
+		now:         time.Now,
+		pinFailures: map[string]int{},
+	}
+}
+
+// SetVault sets the token vault whose DPANs are detokenized, nil for none
+func (e *Engine) SetVault(v *token.Vault) {
+	e.vault = v
+}
+
+// Decide evaluates the rules against the request fields
+func (e *Engine) Decide(fields iso.ISO8583Fields) Decision {
+	pan := fields["2"]
+	if pan == "" || fields["4"] == "" {
+		return decision("30", "missing PAN or amount")
+	}
+
+	amount, err := strconv.ParseInt(fields["4"], 10, 64)
+	if err != nil {
+		return decision("30", "invalid amount")
+	}
+
+	if !generator.ValidatePAN(pan) {
+		return decision("14", "Luhn check failed")
+	}
+
+	if d, ok := e.detokenize(fields, amount); !ok {
+		return d
+	}
+	// The terminal built the PIN block with the PAN it holds, which may be the DPAN
+	blockPAN := pan
+	fields = e.cardFields(fields)
+	pan = fields["2"]
+
+	if !e.rules.DisableMagic {
+		if d, ok := magicDecision(pan, amount); ok {
+			return d
+		}
+	}
+
+	if e.blocked[pan] {
+		return decision("43", "PAN is blocked")
+	}
+
+	month, year, hasExpiry, err := parseExpiry(fields["14"])
+	if err != nil {
+		return decision("30", err.Error())
+	}
+	if hasExpiry && e.expired(month, year) {
+		return decision("54", "card expired")
+	}
+
+	if reason := e.checkCVC(pan, month, year, hasExpiry, fields["48"]); reason != "" {
+		return decision("N7", reason)
+	}
+
+	if reason := e.checkARQC(pan, fields["23"], fields["55"]); reason != "" {
+		return decision("63", reason)
+	}
+
+	if d, ok := e.checkPIN(pan, blockPAN, fields["52"], fields["53"], fields["35"]); !ok {
+		return d
+	}
+
+	if limit, prefix := e.limitFor(pan); limit > 0 && amount > limit {
+		return decision("51", fmt.Sprintf("amount %d exceeds limit %d for %s", amount, limit, prefix))
+	}
+
+	return decision("00", "")
+}
+
+// Authorize evaluates the request and builds the mock response
+//
+// With an IMK and a verifiable ARQC, the response DE55 carries the ARPC instead of
+// the request chip data.
+func (e *Engine) Authorize(request *iso.AuthorizationRequest) *iso.AuthorizationResponse {
+	d := e.Decide(request.Fields)
+	response := iso.GenerateMockAuthResponse(request, d.Code, d.Text)
+	if de55, ok := e.ResponseICCData(request.Fields, d.Code); ok {
+		response.Fields["55"] = de55
+	}
+	return response
+}
+
+// ResponseICCData builds the response DE55 (tag 91: ARPC method 1 || ARC) for a request
+//
+// A vault DPAN is detokenized first, as in Decide. It reports false when no IMK is
+// configured or the request has no verifiable ARQC.
+func (e *Engine) ResponseICCData(fields iso.ISO8583Fields, responseCode string) (string, bool) {
+	fields = e.cardFields(fields)
+	objects, mk, err := e.chipData(fields["2"], fields["23"], fields["55"])
+	if err != nil || objects == nil || emv.VerifyARQC(objects, mk) != nil {
+		return "", false
+	}
+
+	issuerAuth, err := emv.IssuerAuthenticationData(objects, mk, responseCode)
+	if err != nil {
+		return "", false
+	}
+	de55, err := emv.EncodeHex([]emv.TLV{issuerAuth})
+	return de55, err == nil
+}
+
+// detokenize checks a DPAN found in the vault, returning the decline when a check fails
+//
+// TOKEN RULES:
+// 14 - Token deleted
+// 62 - Token suspended
+// 30 - Bad DE14
+// 54 - DE14 is not the token expiry, or the token expired
+// 63 - DE48 cryptogram missing, not verifying for the DPAN, amount and currency, or replayed
+func (e *Engine) detokenize(fields iso.ISO8583Fields, amount int64) (Decision, bool) {
+	if e.vault == nil {
+		return Decision{}, true
+	}
+	t, err := e.vault.Get(fields["2"])
+	if errors.Is(err, token.ErrNotFound) {
+		return Decision{}, true
+	}
+	if err != nil {
+		return decision("96", err.Error()), false
+	}
+
+	switch t.Status {
+	case token.StatusDeleted:
+		return decision("14", "token deleted"), false
+	case token.StatusSuspended:
+		return decision("62", "token suspended"), false
+	}
+
+	month, year, hasExpiry, err := parseExpiry(fields["14"])
+	if err != nil {
+		return decision("30", err.Error()), false
+	}
+	if hasExpiry && (month != t.ExpiryMonth || year != t.ExpiryYear) {
+		return decision("54", "expiry date does not match the token"), false
+	}
+	if e.expired(t.ExpiryMonth, t.ExpiryYear) {
+		return decision("54", "token expired"), false
+	}
+
+	subelements, err := iso.ParsePrivateData(fields["48"])
+	if err != nil {
+		return decision("30", fmt.Sprintf("invalid DE48: %v", err)), false
+	}
+	cryptogram, ok := subelements[iso.SubelementTokenCryptogram]
+	if !ok {
+		return decision("63", "token cryptogram missing"), false
+	}
+	if err := e.vault.Verify(t.DPAN, cryptogram, amount, fields["49"]); err != nil {
+		return decision("63", err.Error()), false
+	}
+	return Decision{}, true
+}
+
+// cardFields returns the fields with a vault DPAN and token expiry replaced by the FPAN and
+// card expiry, or the fields themselves when DE2 is not a token
+func (e *Engine) cardFields(fields iso.ISO8583Fields) iso.ISO8583Fields {
+	if e.vault == nil {
+		return fields
+	}
+	t, err := e.vault.Get(fields["2"])
+	if err != nil {
+		return fields
+	}
+
+	detokenized := make(iso.ISO8583Fields, len(fields))
+	for id, value := range fields {
+		detokenized[id] = value
+	}
+	detokenized["2"] = t.FPAN
+	detokenized["14"] = fmt.Sprintf("%02d%02d", t.FPANExpiryYear%100, t.FPANExpiryMonth)
+	return detokenized
+}
+
+// expired reports whether a card is past the last day of its expiry month
+func (e *Engine) expired(month, year int) bool {
+	firstOfNextMonth := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC)
+	return !e.now().UTC().Before(firstOfNextMonth)
+}
+
+// checkCVC verifies the DE48 CVC2, returning a reason on mismatch
+//
+// With a CVK, 3-digit codes are checked against the CVV2 algorithm; 4-digit codes
+// (Amex CSC) and rules without a CVK fall back to GenerateDeterministicCVC.
+func (e *Engine) checkCVC(pan string, month, year int, hasExpiry bool, de48 string) string {
+	if (e.rules.CVCSecret == "" && e.rules.CVK == "") || de48 == "" {
+		return ""
+	}
+
+	subelements, err := iso.ParsePrivateData(de48)
+	if err != nil {
+		return fmt.Sprintf("invalid DE48: %v", err)
+	}
+
+	cvc, ok := subelements[iso.SubelementCVC2]
+	if !ok {
+		return ""
+	}
+	if !hasExpiry {
+		return "CVC2 present without expiry date"
+	}
+
+	var expected string
+	switch {
+	case e.rules.CVK != "" && len(cvc) == 3:
+		cvk, err := cardcrypto.ParseCVK(e.rules.CVK)
+		if err != nil {
+			return fmt.Sprintf("invalid CVK: %v", err)
+		}
+		expected, err = cvk.CVV2(pan, fmt.Sprintf("%02d%02d", year%100, month))
+		if err != nil {
+			return "CVC2 mismatch"
+		}
+	case e.rules.CVCSecret != "":
+		var err error
+		expected, err = generator.GenerateDeterministicCVC(pan, fmt.Sprintf("%02d", month), fmt.Sprintf("%d", year), e.rules.CVCSecret)
+		if err != nil {
+			return "CVC2 mismatch"
+		}
+	default:
+		return ""
+	}
+
+	if cvc != expected {
+		return "CVC2 mismatch"
+	}
+	return ""
+}
+
+// checkARQC verifies the DE55 application cryptogram, returning a reason on failure
+func (e *Engine) checkARQC(pan, de23, de55 string) string {
+	objects, mk, err := e.chipData(pan, de23, de55)
+	if err != nil {
+		return err.Error()
+	}
+	if objects == nil {
+		return ""
+	}
+
+	if err := emv.VerifyARQC(objects, mk); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// chipData decodes DE55 and derives the card's ICC master key from the IMK and PSN (DE23)
+//
+// It returns no objects when there is nothing to verify (no IMK or no DE55).
+func (e *Engine) chipData(pan, de23, de55 string) ([]emv.TLV, []byte, error) {
+	if e.rules.IMK == "" || de55 == "" {
+		return nil, nil, nil
+	}
+
+	objects, err := emv.DecodeHex(de55)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid DE55: %v", err)
+	}
+
+	imk, err := cardcrypto.ParseKey(e.rules.IMK)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid IMK: %v", err)
+	}
+
+	// DE23 is n3; the PSN is its last two digits
+	psn := generator.DefaultPANSequence
+	if len(de23) >= 2 {
+		psn = de23[len(de23)-2:]
+	}
+	mk, err := cardcrypto.DeriveICCMasterKey(imk, pan, psn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return objects, mk, nil
+}
+
+// checkPIN verifies the DE52 PIN block and tracks consecutive failures per PAN
+//
+// Blocks with a DE53 KSN are DUKPT-encrypted (BDK), others are under the ZPK. The
+// block is decrypted with blockPAN, the DE2 as sent (a DPAN for token transactions),
+// and the PIN is checked against the card PAN.
+// Nothing is checked without DE52, the matching key, or a reference PIN (a secret,
+// or a PVV in DE35 with a PVK). A block that does not decrypt to the card's PIN
+// counts as a wrong PIN; once the try limit is reached the card answers 75 even for
+// the right PIN. A correct PIN below the limit resets the count.
+func (e *Engine) checkPIN(pan, blockPAN, de52, de53, de35 string) (Decision, bool) {
+	if de52 == "" || (e.rules.CVCSecret == "" && !e.hasTrackPVV(de35)) {
+		return Decision{}, true
+	}
+	if (de53 == "" && e.rules.ZPK == "") || (de53 != "" && e.rules.BDK == "") {
+		return Decision{}, true
+	}
+
+	limit := e.rules.PINTryLimit
+	if limit == 0 {
+		limit = DefaultPINTryLimit
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pinFailures[pan] >= limit {
+		return decision("75", fmt.Sprintf("PIN try limit of %d reached", limit)), false
+	}
+
+	reason := e.verifyPIN(pan, blockPAN, de52, de53, de35)
+	if reason == "" {
+		delete(e.pinFailures, pan)
+		return Decision{}, true
+	}
+
+	e.pinFailures[pan]++
+	if e.pinFailures[pan] >= limit {
+		return decision("75", fmt.Sprintf("%s; PIN try limit of %d reached", reason, limit)), false
+	}
+	return decision("55", reason), false
+}
+
+// verifyPIN decrypts DE52 with the PAN it was built with and checks it is the card's PIN
+func (e *Engine) verifyPIN(pan, blockPAN, de52, de53, de35 string) string {
+	key, err := e.pinKey(de53)
+	if err != nil {
+		return err.Error()
+	}
+	block, err := hex.DecodeString(de52)
+	if err != nil {
+		return "DE52 is not valid hex"
+	}
+
+	pin, _, err := cardcrypto.DecryptPINBlock(block, blockPAN, key)
+	if err != nil {
+		return err.Error()
+	}
+	if e.rules.PVK != "" {
+		return e.verifyPINWithPVK(pan, pin, de35)
+	}
+
+	expected, err := generator.GeneratePIN(pan, e.rules.CVCSecret)
+	if err != nil || pin != expected {
+		return "PIN mismatch"
+	}
+	return ""
+}
+
+// verifyPINWithPVK checks a clear PIN through the card's PVV or IBM 3624 offset
+//
+// The PVV comes from DE35 when the Track 2 data carries one; otherwise the PVV and
+// offset are re-derived from the secret's test PIN, standing in for the issuer's
+// PIN verification database.
+func (e *Engine) verifyPINWithPVK(pan, pin, de35 string) string {
+	pvk, err := cardcrypto.ParsePVK(e.rules.PVK)
+	if err != nil {
+		return fmt.Sprintf("invalid PVK: %v", err)
+	}
+
+	pvki, pvv, onTrack := track.PVV(de35)
+	if e.rules.PINMethod == PINMethodIBM3624 || !onTrack {
+		testPIN, err := generator.GeneratePIN(pan, e.rules.CVCSecret)
+		if err != nil {
+			return "no PIN verification data"
+		}
+		expected, err := generator.ComputePINVerification(pan, testPIN, generator.DefaultPVKI, e.rules.PVK)
+		if err != nil {
+			return err.Error()
+		}
+
+		if e.rules.PINMethod == PINMethodIBM3624 {
+			if got, err := cardcrypto.IBM3624PIN(pvk, pan, expected.Offset); err != nil || got != pin {
+				return "PIN offset mismatch"
+			}
+			return ""
+		}
+		pvki, pvv = expected.PVKI, expected.PVV
+	}
+
+	index, _ := strconv.Atoi(pvki)
+	if got, err := cardcrypto.PVV(pvk, pan, index, pin); err != nil || got != pvv {
+		return "PVV mismatch"
+	}
+	return ""
+}
+
+// hasTrackPVV reports whether the PIN can be verified through a DE35 PVV without a secret
+func (e *Engine) hasTrackPVV(de35 string) bool {
+	if e.rules.PVK == "" || e.rules.PINMethod == PINMethodIBM3624 {
+		return false
+	}
+	_, _, ok := track.PVV(de35)
+	return ok
+}
+
+// pinKey returns the ZPK, or the DUKPT PIN key of the DE53 KSN's transaction
+func (e *Engine) pinKey(de53 string) ([]byte, error) {
+	if de53 == "" {
+		zpk, err := cardcrypto.ParsePINKey(e.rules.ZPK)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ZPK: %v", err)
+		}
+		return zpk, nil
+	}
+
+	bdk, err := cardcrypto.ParseBDK(e.rules.BDK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BDK: %v", err)
+	}
+	ksn, err := cardcrypto.ParseKSN(de53)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DE53: %v", err)
+	}
+	keys, err := cardcrypto.DeriveDUKPTKeys(bdk, ksn)
+	if err != nil {
+		return nil, err
+	}
+	return keys.PIN, nil
+}
+
+// limitFor returns the amount limit for a PAN (longest matching BIN prefix, else default)
+func (e *Engine) limitFor(pan string) (int64, string) {
+	best := ""
+	for prefix := range e.rules.BINLimits {
+		if strings.HasPrefix(pan, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+
+	if best != "" {
+		return e.rules.BINLimits[best], best
+	}
+	return e.rules.DefaultLimit, "default"
+}
+
+// parseExpiry parses DE14 (YYMM); an empty field means no expiry was sent
+func parseExpiry(de14 string) (month, year int, ok bool, err error) {
+	if de14 == "" {
+		return 0, 0, false, nil
+	}
+
+	if len(de14) != 4 || !isDigits(de14) {
+		return 0, 0, false, fmt.Errorf("invalid expiry date: %q", de14)
+	}
+
+	yy, _ := strconv.Atoi(de14[:2])
+	month, _ = strconv.Atoi(de14[2:])
+	if month < 1 || month > 12 {
+		return 0, 0, false, fmt.Errorf("invalid expiry month: %q", de14)
+	}
+
+	return month, 2000 + yy, true, nil
+}
+
+func decision(code, reason string) Decision {
+	return Decision{Code: code, Text: iso.ResponseCodes[code], Reason: reason}
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,577 @@
+package authorizer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/emv"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
+)
+
+const testSecret = "rules-test-secret"
+
+func testEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	engine := NewEngine(&Rules{
+		DefaultLimit: 500000,
+		BINLimits:    map[string]int64{"400000": 50000, "4000000000": 1000},
+		BlockedPANs:  []string{"4000000000000010"},
+		CVCSecret:    testSecret,
+	})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+	return engine
+}
+
+func requestFields(pan, expiry, amount string) iso.ISO8583Fields {
+	return iso.ISO8583Fields{"2": pan, "4": amount, "14": expiry, "11": "000001"}
+}
+
+func TestDecide(t *testing.T) {
+	engine := testEngine(t)
+
+	validCVC, err := generator.GenerateDeterministicCVC("5100000000000016", "12", "2027", testSecret)
+	if err != nil {
+		t.Fatalf("GenerateDeterministicCVC() unexpected error: %v", err)
+	}
+
+	withCVC := func(fields iso.ISO8583Fields, cvc string) iso.ISO8583Fields {
+		fields["48"] = iso.FormatPrivateData(map[string]string{iso.SubelementCVC2: cvc})
+		return fields
+	}
+
+	tests := []struct {
+		name   string
+		fields iso.ISO8583Fields
+		want   string
+	}{
+		{"Approved", requestFields("5100000000000016", "2712", "000000010000"), "00"},
+		{"Missing amount", iso.ISO8583Fields{"2": "5100000000000016"}, "30"},
+		{"Invalid expiry", requestFields("5100000000000016", "2713", "000000010000"), "30"},
+		{"Luhn failure", requestFields("5100000000000017", "2712", "000000010000"), "14"},
+		{"Blocked PAN", requestFields("4000000000000010", "2712", "000000000100"), "43"},
+		{"Expired last month", requestFields("5100000000000016", "2605", "000000010000"), "54"},
+		{"Valid through current month", requestFields("5100000000000016", "2606", "000000010000"), "00"},
+		{"CVC match", withCVC(requestFields("5100000000000016", "2712", "000000010000"), validCVC), "00"},
+		{"CVC mismatch", withCVC(requestFields("5100000000000016", "2712", "000000010000"), "000"), "N7"},
+		{"Default limit", requestFields("5100000000000016", "2712", "000000500001"), "51"},
+		{"BIN limit", requestFields("4000001234567899", "2712", "000000050001"), "51"},
+		{"Longest prefix limit", requestFields("4000000000000002", "2712", "000000001001"), "51"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := engine.Decide(tt.fields)
+			if d.Code != tt.want {
+				t.Errorf("Decide() = %s (%s), want %s", d.Code, d.Reason, tt.want)
+			}
+			if d.Text != iso.ResponseCodes[d.Code] {
+				t.Errorf("Decide() text = %q, want %q", d.Text, iso.ResponseCodes[d.Code])
+			}
+		})
+	}
+}
+
+func TestMagicValues(t *testing.T) {
+	engine := testEngine(t)
+
+	magicPAN, err := generator.GeneratePAN("5100020005", 16)
+	if err != nil {
+		t.Fatalf("GeneratePAN() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		fields iso.ISO8583Fields
+		want   string
+	}{
+		{"Magic card overrides limit", requestFields(magicPAN, "2712", "000099999999"), "05"},
+		{"Amount ending in .51", requestFields("5100000000000016", "2712", "000000001051"), "51"},
+		{"Amount ending in .05", requestFields("5100000000000016", "2712", "000000001005"), "05"},
+		{"Magic card wins over amount", requestFields(magicPAN, "2712", "000000001051"), "05"},
+		{"Luhn still checked first", requestFields("5100020005000000", "2712", "000000001000"), "14"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := engine.Decide(tt.fields); d.Code != tt.want {
+				t.Errorf("Decide() = %s (%s), want %s", d.Code, d.Reason, tt.want)
+			}
+		})
+	}
+
+	disabled := NewEngine(&Rules{DisableMagic: true})
+	if d := disabled.Decide(requestFields(magicPAN, "2712", "000000001051")); d.Code != "00" {
+		t.Errorf("Decide() with magic disabled = %s, want 00", d.Code)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	engine := testEngine(t)
+
+	request := &iso.AuthorizationRequest{
+		MTI:    "0200",
+		Fields: requestFields("4000000000000010", "2712", "000000000100"),
+	}
+	response := engine.Authorize(request)
+
+	if response.MTI != "0210" {
+		t.Errorf("Response MTI = %s, want 0210", response.MTI)
+	}
+	if response.ResponseCode != "43" || response.Fields["39"] != "43" {
+		t.Errorf("Response code = %s / DE39 %s, want 43", response.ResponseCode, response.Fields["39"])
+	}
+	if response.AuthCode != "" {
+		t.Error("AuthCode should be empty for declined transaction")
+	}
+}
+
+func TestCVKVerification(t *testing.T) {
+	const cvk = "0123456789ABCDEFFEDCBA9876543210"
+
+	engine := NewEngine(&Rules{CVK: cvk, CVCSecret: testSecret})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	for _, brand := range []string{"visa", "mastercard", "amex"} {
+		t.Run(brand, func(t *testing.T) {
+			card, err := generator.GenerateCard(models.GenerateOptions{Brand: brand, Secret: testSecret, CVK: cvk})
+			if err != nil {
+				t.Fatalf("GenerateCard() unexpected error: %v", err)
+			}
+
+			fields := iso.GenerateISO8583Fields(card, 10000, "986")
+			if d := engine.Decide(fields); d.Code != "00" {
+				t.Errorf("Decide() with generated CVC %s = %s (%s), want 00", card.CVC, d.Code, d.Reason)
+			}
+
+			wrong := "000"
+			if len(card.CVC) == 4 {
+				wrong = "0000"
+			}
+			if wrong == card.CVC {
+				t.Skip("generated CVC collides with the wrong value")
+			}
+			fields["48"] = iso.FormatPrivateData(map[string]string{iso.SubelementCVC2: wrong})
+			if d := engine.Decide(fields); d.Code != "N7" {
+				t.Errorf("Decide() with wrong CVC = %s, want N7", d.Code)
+			}
+		})
+	}
+}
+
+func TestARQCVerification(t *testing.T) {
+	const imk = "0123456789ABCDEFFEDCBA9876543210"
+
+	engine := NewEngine(&Rules{IMK: imk})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "visa", IMK: imk})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+
+	request := iso.GenerateMockAuthRequest(card, 10000, "986")
+	response := engine.Authorize(request)
+	if response.ResponseCode != "00" {
+		t.Fatalf("Authorize() with generated ARQC = %s, want 00", response.ResponseCode)
+	}
+
+	// The response DE55 carries tag 91 (ARPC || ARC) matching the request ARQC
+	objects, _ := emv.DecodeHex(request.Fields["55"])
+	mk, _ := hex.DecodeString(card.ICCMasterKey)
+	want, _ := emv.IssuerAuthenticationData(objects, mk, "00")
+	if wantHex, _ := emv.EncodeHex([]emv.TLV{want}); response.Fields["55"] != wantHex {
+		t.Errorf("Response DE55 = %s, want %s", response.Fields["55"], wantHex)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(fields iso.ISO8583Fields)
+		want   string
+	}{
+		{"Wrong PSN", func(fields iso.ISO8583Fields) { fields["23"] = "001" }, "63"},
+		{"Altered ATC", func(fields iso.ISO8583Fields) {
+			objects, _ := emv.DecodeHex(fields["55"])
+			atc, _ := emv.Find(objects, "9F36")
+			atc.Value[1] ^= 0x01
+			fields["55"], _ = emv.EncodeHex(objects)
+		}, "63"},
+		{"Unverifiable placeholder", func(fields iso.ISO8583Fields) {
+			plain, _ := generator.GenerateCard(models.GenerateOptions{Brand: "visa"})
+			plain.PAN = fields["2"]
+			fields["55"] = iso.GenerateISO8583Fields(plain, 10000, "986")["55"]
+		}, "63"},
+		{"Invalid DE55", func(fields iso.ISO8583Fields) { fields["55"] = "9F26" }, "63"},
+		{"No chip data", func(fields iso.ISO8583Fields) { delete(fields, "55") }, "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := iso.ISO8583Fields{}
+			for k, v := range request.Fields {
+				fields[k] = v
+			}
+			tt.modify(fields)
+
+			if d := engine.Decide(fields); d.Code != tt.want {
+				t.Errorf("Decide() = %s (%s), want %s", d.Code, d.Reason, tt.want)
+			}
+			if _, ok := engine.ResponseICCData(fields, "00"); ok && tt.want != "00" {
+				t.Error("ResponseICCData() built an ARPC for an unverified ARQC")
+			}
+		})
+	}
+}
+
+func TestPINVerification(t *testing.T) {
+	const zpk = "0123456789ABCDEFFEDCBA9876543210"
+
+	engine := NewEngine(&Rules{CVCSecret: testSecret, ZPK: zpk})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "visa", Secret: testSecret, ZPK: zpk, PINFormat: 3})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	good := iso.GenerateISO8583Fields(card, 10000, "986")
+	if good["52"] != card.PINBlock {
+		t.Fatalf("DE52 = %q, want the card PIN block %q", good["52"], card.PINBlock)
+	}
+
+	// A block for another PIN, under the same key
+	wrongPIN := "0000"
+	if card.PIN == wrongPIN {
+		wrongPIN = "1111"
+	}
+	key, _ := cardcrypto.ParsePINKey(zpk)
+	wrongBlock, _ := cardcrypto.EncryptPINBlock(cardcrypto.PINFormat0, wrongPIN, card.PAN, key, nil)
+	wrong := iso.ISO8583Fields{}
+	for k, v := range good {
+		wrong[k] = v
+	}
+	wrong["52"] = hex.EncodeToString(wrongBlock)
+
+	steps := []struct {
+		name   string
+		fields iso.ISO8583Fields
+		want   string
+	}{
+		{"Correct PIN", good, "00"},
+		{"Wrong PIN", wrong, "55"},
+		{"Correct PIN resets tries", good, "00"},
+		{"Wrong PIN 1", wrong, "55"},
+		{"Wrong PIN 2", wrong, "55"},
+		{"Wrong PIN 3", wrong, "75"},
+		{"Correct PIN after limit", good, "75"},
+	}
+	for _, step := range steps {
+		if d := engine.Decide(step.fields); d.Code != step.want {
+			t.Errorf("%s: Decide() = %s (%s), want %s", step.name, d.Code, d.Reason, step.want)
+		}
+	}
+
+	// Other cards keep their own counters; requests without DE52 are not checked
+	fresh, _ := generator.GenerateCard(models.GenerateOptions{Brand: "mastercard", Secret: testSecret, ZPK: zpk, PINFormat: 4})
+	freshFields := iso.GenerateISO8583Fields(fresh, 10000, "986")
+	if d := engine.Decide(freshFields); d.Code != "00" {
+		t.Errorf("Decide(other card, format 4) = %s (%s), want 00", d.Code, d.Reason)
+	}
+	delete(wrong, "52")
+	if d := engine.Decide(wrong); d.Code != "00" {
+		t.Errorf("Decide(no DE52) = %s (%s), want 00", d.Code, d.Reason)
+	}
+	freshFields["52"] = "ZZ"
+	if d := engine.Decide(freshFields); d.Code != "55" {
+		t.Errorf("Decide(invalid DE52) = %s (%s), want 55", d.Code, d.Reason)
+	}
+}
+
+func TestDUKPTPINVerification(t *testing.T) {
+	const bdk = "0123456789ABCDEFFEDCBA9876543210"
+
+	engine := NewEngine(&Rules{CVCSecret: testSecret, BDK: bdk})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	for _, ksn := range []string{"FFFF9876543210E00000", "123456789012345600000000"} {
+		cards, err := generator.GenerateCards(models.GenerateOptions{Brand: "visa", Count: 2, Secret: testSecret, BDK: bdk, KSN: ksn})
+		if err != nil {
+			t.Fatalf("GenerateCards(KSN %s) unexpected error: %v", ksn, err)
+		}
+
+		for _, card := range cards {
+			fields := iso.GenerateISO8583Fields(card, 10000, "986")
+			if d := engine.Decide(fields); d.Code != "00" {
+				t.Errorf("Decide(KSN %s) = %s (%s), want 00", card.KSN, d.Code, d.Reason)
+			}
+		}
+
+		// The first card's block under the second card's KSN decrypts to garbage
+		fields := iso.GenerateISO8583Fields(cards[0], 10000, "986")
+		fields["52"], fields["53"] = cards[0].PINBlock, cards[1].KSN
+		if d := engine.Decide(fields); d.Code != "55" {
+			t.Errorf("Decide(KSN %s, wrong key) = %s (%s), want 55", ksn, d.Code, d.Reason)
+		}
+	}
+
+	// Without a BDK, DUKPT blocks are not checked
+	card, _ := generator.GenerateCard(models.GenerateOptions{Brand: "visa", Secret: testSecret, BDK: bdk, KSN: "FFFF9876543210E00000"})
+	fields := iso.GenerateISO8583Fields(card, 10000, "986")
+	fields["52"] = "0000000000000000"
+	if d := NewEngine(&Rules{CVCSecret: testSecret}).Decide(fields); d.Code != "00" {
+		t.Errorf("Decide(no BDK) = %s (%s), want 00", d.Code, d.Reason)
+	}
+}
+
+func TestTokenDetokenization(t *testing.T) {
+	engine := NewEngine(&Rules{CVCSecret: testSecret})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+	vault := token.New()
+	engine.SetVault(vault)
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "mastercard", Secret: testSecret})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	tok, err := vault.Provision(card, "")
+	if err != nil {
+		t.Fatalf("Provision() unexpected error: %v", err)
+	}
+
+	// A token transaction: DPAN, token expiry and cryptogram, plus the card's CVC2
+	tokenFields := func(amount int64, cvc string) iso.ISO8583Fields {
+		cryptogram, _, err := vault.Cryptogram(tok.DPAN, amount, "986")
+		if err != nil {
+			t.Fatalf("Cryptogram() unexpected error: %v", err)
+		}
+		fields := requestFields(tok.DPAN, fmt.Sprintf("%02d%02d", tok.ExpiryYear%100, tok.ExpiryMonth), fmt.Sprintf("%012d", amount))
+		fields["49"] = "986"
+		fields["48"] = iso.FormatPrivateData(map[string]string{
+			iso.SubelementTokenCryptogram: cryptogram,
+			iso.SubelementCVC2:            cvc,
+		})
+		return fields
+	}
+
+	approved := tokenFields(10000, card.CVC)
+	if d := engine.Decide(approved); d.Code != "00" {
+		t.Errorf("Decide(token) = %s (%s), want 00", d.Code, d.Reason)
+	}
+	if d := engine.Decide(approved); d.Code != "63" {
+		t.Errorf("Decide(replayed cryptogram) = %s (%s), want 63", d.Code, d.Reason)
+	}
+
+	noCryptogram := requestFields(tok.DPAN, approved["14"], "000000010000")
+	if d := engine.Decide(noCryptogram); d.Code != "63" {
+		t.Errorf("Decide(no cryptogram) = %s (%s), want 63", d.Code, d.Reason)
+	}
+
+	otherAmount := tokenFields(10000, card.CVC)
+	otherAmount["4"] = "000000020000"
+	if d := engine.Decide(otherAmount); d.Code != "63" {
+		t.Errorf("Decide(amount changed) = %s (%s), want 63", d.Code, d.Reason)
+	}
+
+	cardExpiry := tokenFields(10000, card.CVC)
+	cardExpiry["14"] = fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth)
+	if d := engine.Decide(cardExpiry); d.Code != "54" {
+		t.Errorf("Decide(card expiry with the DPAN) = %s (%s), want 54", d.Code, d.Reason)
+	}
+
+	wrongCVC := "000"
+	if card.CVC == wrongCVC {
+		wrongCVC = "111"
+	}
+	if d := engine.Decide(tokenFields(10000, wrongCVC)); d.Code != "N7" {
+		t.Errorf("Decide(token, wrong card CVC) = %s (%s), want N7", d.Code, d.Reason)
+	}
+
+	vault.SetStatus(tok.DPAN, token.StatusSuspended)
+	if d := engine.Decide(approved); d.Code != "62" {
+		t.Errorf("Decide(suspended token) = %s (%s), want 62", d.Code, d.Reason)
+	}
+	vault.SetStatus(tok.DPAN, token.StatusDeleted)
+	if d := engine.Decide(approved); d.Code != "14" {
+		t.Errorf("Decide(deleted token) = %s (%s), want 14", d.Code, d.Reason)
+	}
+
+	// Magic outcomes follow the FPAN
+	magic, _ := MagicCardFor("declined_generic")
+	declined, _ := generator.GenerateCard(models.GenerateOptions{BIN: magic.PANPrefix, Brand: magic.Brand})
+	tok, _ = vault.Provision(declined, "")
+	if d := engine.Decide(tokenFields(10000, "")); d.Code != "05" {
+		t.Errorf("Decide(token of a magic card) = %s (%s), want 05", d.Code, d.Reason)
+	}
+}
+
+func TestTokenPINAndChip(t *testing.T) {
+	const zpk = "0123456789ABCDEFFEDCBA9876543210"
+	const imk = "FEDCBA98765432100123456789ABCDEF"
+
+	engine := NewEngine(&Rules{CVCSecret: testSecret, ZPK: zpk, IMK: imk})
+	engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+	vault := token.New()
+	engine.SetVault(vault)
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "visa", Secret: testSecret, ZPK: zpk, IMK: imk})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	tok, err := vault.Provision(card, "")
+	if err != nil {
+		t.Fatalf("Provision() unexpected error: %v", err)
+	}
+
+	// The terminal holds the DPAN: its PIN block is built with the DPAN, its chip data with the card keys
+	key, _ := cardcrypto.ParsePINKey(zpk)
+	tokenFields := func(pinPAN string) iso.ISO8583Fields {
+		cryptogram, _, err := vault.Cryptogram(tok.DPAN, 10000, "986")
+		if err != nil {
+			t.Fatalf("Cryptogram() unexpected error: %v", err)
+		}
+		block, err := cardcrypto.EncryptPINBlock(cardcrypto.PINFormat0, card.PIN, pinPAN, key, nil)
+		if err != nil {
+			t.Fatalf("EncryptPINBlock() unexpected error: %v", err)
+		}
+		fields := iso.GenerateISO8583Fields(card, 10000, "986")
+		fields["2"] = tok.DPAN
+		fields["14"] = fmt.Sprintf("%02d%02d", tok.ExpiryYear%100, tok.ExpiryMonth)
+		fields["48"] = iso.FormatPrivateData(map[string]string{iso.SubelementTokenCryptogram: cryptogram})
+		fields["52"] = hex.EncodeToString(block)
+		delete(fields, "35")
+		return fields
+	}
+
+	fields := tokenFields(tok.DPAN)
+	if d := engine.Decide(fields); d.Code != "00" {
+		t.Errorf("Decide(token, DPAN PIN block) = %s (%s), want 00", d.Code, d.Reason)
+	}
+	if _, ok := engine.ResponseICCData(fields, "00"); !ok {
+		t.Error("ResponseICCData(token) built no ARPC")
+	}
+	if d := engine.Decide(tokenFields(card.PAN)); d.Code != "55" {
+		t.Errorf("Decide(token, FPAN PIN block) = %s (%s), want 55", d.Code, d.Reason)
+	}
+}
+
+func TestPVKPINVerification(t *testing.T) {
+	const zpk, pvk = "0123456789ABCDEFFEDCBA9876543210", "FEDCBA98765432100123456789ABCDEF"
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "visa", Secret: testSecret, ZPK: zpk, PVK: pvk, IncludeTrack2: true})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	good := iso.GenerateISO8583Fields(card, 10000, "986")
+
+	wrongPIN := "0000"
+	if card.PIN == wrongPIN {
+		wrongPIN = "1111"
+	}
+	key, _ := cardcrypto.ParsePINKey(zpk)
+	wrongBlock, _ := cardcrypto.EncryptPINBlock(cardcrypto.PINFormat0, wrongPIN, card.PAN, key, nil)
+	withField := func(field, value string) iso.ISO8583Fields {
+		fields := iso.ISO8583Fields{}
+		for k, v := range good {
+			fields[k] = v
+		}
+		if value == "" {
+			delete(fields, field)
+		} else {
+			fields[field] = value
+		}
+		return fields
+	}
+
+	// The DE35 PVV needs no secret; another PVV on the track rejects the right PIN
+	replacement := "0000"
+	if card.PVV == replacement {
+		replacement = "1111"
+	}
+	pvvAt := strings.IndexByte(good["35"], 'D') + 9 // After YYMM, service code and PVKI
+	otherPVV := good["35"][:pvvAt] + replacement + good["35"][pvvAt+4:]
+	tests := []struct {
+		name   string
+		rules  Rules
+		fields iso.ISO8583Fields
+		want   string
+	}{
+		{"PVV from DE35", Rules{ZPK: zpk, PVK: pvk}, good, "00"},
+		{"PVV wrong PIN", Rules{ZPK: zpk, PVK: pvk}, withField("52", hex.EncodeToString(wrongBlock)), "55"},
+		{"PVV mismatch on track", Rules{ZPK: zpk, PVK: pvk}, withField("35", otherPVV), "55"},
+		{"No DE35 or secret", Rules{ZPK: zpk, PVK: pvk}, withField("35", ""), "00"},
+		{"PVV derived from secret", Rules{CVCSecret: testSecret, ZPK: zpk, PVK: pvk}, withField("35", ""), "00"},
+		{"IBM 3624 offset", Rules{CVCSecret: testSecret, ZPK: zpk, PVK: pvk, PINMethod: PINMethodIBM3624}, good, "00"},
+		{"IBM 3624 wrong PIN", Rules{CVCSecret: testSecret, ZPK: zpk, PVK: pvk, PINMethod: PINMethodIBM3624}, withField("52", hex.EncodeToString(wrongBlock)), "55"},
+		{"IBM 3624 without secret", Rules{ZPK: zpk, PVK: pvk, PINMethod: PINMethodIBM3624}, withField("52", hex.EncodeToString(wrongBlock)), "00"},
+	}
+	for _, tt := range tests {
+		engine := NewEngine(&tt.rules)
+		engine.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+		if d := engine.Decide(tt.fields); d.Code != tt.want {
+			t.Errorf("%s: Decide() = %s (%s), want %s", tt.name, d.Code, d.Reason, tt.want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "rules.json")
+	os.WriteFile(valid, []byte(`{"default_limit": 1000, "bin_limits": {"5100": 10}, "blocked_pans": ["5100000000000016"]}`), 0o600)
+
+	rules, err := LoadRules(valid)
+	if err != nil {
+		t.Fatalf("LoadRules() unexpected error: %v", err)
+	}
+	if rules.DefaultLimit != 1000 || rules.BINLimits["5100"] != 10 || len(rules.BlockedPANs) != 1 {
+		t.Errorf("LoadRules() = %+v, unexpected content", rules)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"bin_limits": {"51AB": 10}}`), 0o600)
+	if _, err := LoadRules(invalid); err == nil {
+		t.Error("LoadRules() with non-numeric prefix expected error but got none")
+	}
+
+	badCVK := filepath.Join(dir, "bad-cvk.json")
+	os.WriteFile(badCVK, []byte(`{"cvk": "0123456789ABCDEF"}`), 0o600)
+	if _, err := LoadRules(badCVK); err == nil {
+		t.Error("LoadRules() with short CVK expected error but got none")
+	}
+
+	badIMK := filepath.Join(dir, "bad-imk.json")
+	os.WriteFile(badIMK, []byte(`{"imk": "not-a-key"}`), 0o600)
+	if _, err := LoadRules(badIMK); err == nil {
+		t.Error("LoadRules() with invalid IMK expected error but got none")
+	}
+
+	badZPK := filepath.Join(dir, "bad-zpk.json")
+	os.WriteFile(badZPK, []byte(`{"zpk": "0123456789ABCDEF"}`), 0o600)
+	if _, err := LoadRules(badZPK); err == nil {
+		t.Error("LoadRules() with short ZPK expected error but got none")
+	}
+
+	badBDK := filepath.Join(dir, "bad-bdk.json")
+	os.WriteFile(badBDK, []byte(`{"bdk": "0123456789ABCDEF"}`), 0o600)
+	if _, err := LoadRules(badBDK); err == nil {
+		t.Error("LoadRules() with short BDK expected error but got none")
+	}
+
+	badPINMethod := filepath.Join(dir, "bad-pin-method.json")
+	os.WriteFile(badPINMethod, []byte(`{"pvk": "0123456789ABCDEFFEDCBA9876543210", "pin_method": "natural"}`), 0o600)
+	if _, err := LoadRules(badPINMethod); err == nil {
+		t.Error("LoadRules() with unknown PIN method expected error but got none")
+	}
+
+	if _, err := LoadRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadRules() with missing file expected error but got none")
+	}
+}
@@ -0,0 +1,64 @@
+package authorizer
+
+import "strings"
+
+// MagicCard reserves a PAN prefix whose authorizations always produce ResponseCode
+//
+// DESIGN RATIONALE:
+// - Mirrors public gateway sandboxes: a known card number gives a known outcome
+// - Prefixes are 10 digits inside the brand's BIN range, so generated PANs stay Luhn-valid and brand-consistent
+// - One entry per card scenario in api.GetScenarios (PIX and boleto have no card)
+// - Magic values take precedence over the configured rules
+type MagicCard struct {
+	ScenarioID   string `json:"scenario_id"`
+	Brand        string `json:"brand"`
+	PANPrefix    string `json:"pan_prefix"`
+	ResponseCode string `json:"response_code"`
+}
+
+// MagicCards lists the reserved PAN prefixes, keyed by scenario
+var MagicCards = []MagicCard{
+	{ScenarioID: "success_auth", Brand: "visa", PANPrefix: "4000020000", ResponseCode: "00"},
+	{ScenarioID: "declined_generic", Brand: "mastercard", PANPrefix: "5100020005", ResponseCode: "05"},
+	{ScenarioID: "insufficient_funds", Brand: "visa", PANPrefix: "4000020051", ResponseCode: "51"},
+	{ScenarioID: "3ds_required", Brand: "visa", PANPrefix: "4000020300", ResponseCode: "00"},
+	{ScenarioID: "auth_only", Brand: "mastercard", PANPrefix: "5100020100", ResponseCode: "00"},
+	{ScenarioID: "captured", Brand: "mastercard", PANPrefix: "5100020200", ResponseCode: "00"},
+	{ScenarioID: "refunded_partial", Brand: "visa", PANPrefix: "4000020400", ResponseCode: "00"},
+	{ScenarioID: "chargeback_open", Brand: "mastercard", PANPrefix: "5100024853", ResponseCode: "00"},
+	{ScenarioID: "subscription_recurring", Brand: "visa", PANPrefix: "4000020700", ResponseCode: "00"},
+	{ScenarioID: "tokenized_payment", Brand: "mastercard", PANPrefix: "5100020800", ResponseCode: "00"},
+}
+
+// MagicAmounts maps the minor-unit suffix of an amount (e.g. 10.51 -> 51) to a response code
+//
+// Applies to any card that is not a magic card
+var MagicAmounts = map[int64]string{
+	5:  "05", // Do not honor
+	51: "51", // Insufficient funds
+}
+
+// MagicCardFor returns the magic card registered for a scenario
+func MagicCardFor(scenarioID string) (MagicCard, bool) {
+	for _, magic := range MagicCards {
+		if magic.ScenarioID == scenarioID {
+			return magic, true
+		}
+	}
+	return MagicCard{}, false
+}
+
+// magicDecision returns the forced outcome for a magic PAN or amount, if any
+func magicDecision(pan string, amount int64) (Decision, bool) {
+	for _, magic := range MagicCards {
+		if strings.HasPrefix(pan, magic.PANPrefix) {
+			return decision(magic.ResponseCode, "magic card for scenario "+magic.ScenarioID), true
+		}
+	}
+
+	if code, ok := MagicAmounts[amount%100]; ok {
+		return decision(code, "magic amount suffix"), true
+	}
+
+	return Decision{}, false
+}
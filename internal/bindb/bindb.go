@@ -0,0 +1,249 @@
+// Package bindb is an offline BIN/IIN lookup table
+//
+// A synthetic table is embedded in the binary; a JSON file with the same
+// layout can extend or override it without rebuilding.
+//
+// DESIGN RATIONALE:
+// - Lookups never touch the network, so tests stay hermetic and fast
+// - Records are keyed by 6- or 8-digit BIN; the longest match wins
+// - The package is a leaf (no internal imports) so the generator can use it
+package bindb
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed data/bins.json
+var bundledTable []byte
+
+var (
+	// ErrInvalidBIN is returned for input that is not a 6-19 digit PAN or prefix
+	ErrInvalidBIN = errors.New("BIN must be 6-19 digits")
+	// ErrNotFound is returned when no record matches
+	ErrNotFound = errors.New("BIN not found")
+)
+
+// CardTypes are the accepted Record.Type values
+var CardTypes = map[string]bool{"credit": true, "debit": true, "prepaid": true}
+
+// Record describes a single BIN
+type Record struct {
+	BIN     string `json:"bin"`               // 6 or 8 digits
+	Brand   string `json:"brand"`             // CardBrands key (e.g. "visa")
+	Issuer  string `json:"issuer,omitempty"`  // Issuing institution
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	Type    string `json:"type,omitempty"`    // credit, debit or prepaid
+	Tier    string `json:"tier,omitempty"`    // Product tier (e.g. classic, gold, platinum)
+}
+
+// File is the JSON document read by Parse and Load
+//
+// Example:
+//
+//	{
+//	  "version": "2026-10-01",
+//	  "records": [
+//	    {"bin": "45321100", "brand": "visa", "issuer": "Acme Bank", "country": "BR", "type": "debit", "tier": "gold"}
+//	  ]
+//	}
+type File struct {
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Records     []Record `json:"records"`
+}
+
+// DB is an immutable BIN table
+type DB struct {
+	version string
+	records map[string]Record
+}
+
+var (
+	bundledOnce sync.Once
+	bundled     *DB
+
+	mu      sync.RWMutex
+	current *DB
+)
+
+// Bundled returns the table embedded in the binary
+func Bundled() *DB {
+	bundledOnce.Do(func() {
+		db, err := Parse(bundledTable)
+		if err != nil {
+			panic(fmt.Sprintf("bindb: bundled table is invalid: %v", err))
+		}
+		bundled = db
+	})
+	return bundled
+}
+
+// Default returns the table used by the generator, the CLI and the API (initially Bundled)
+func Default() *DB {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current != nil {
+		return current
+	}
+	return Bundled()
+}
+
+// SetDefault replaces the table returned by Default
+func SetDefault(db *DB) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = db
+}
+
+// Load reads a table from a JSON file
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Parse parses and validates a JSON table
+func Parse(data []byte) (*DB, error) {
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse BIN table: %w", err)
+	}
+
+	db := &DB{version: file.Version, records: make(map[string]Record, len(file.Records))}
+	for _, record := range file.Records {
+		if err := validate(record); err != nil {
+			return nil, err
+		}
+		if _, dup := db.records[record.BIN]; dup {
+			return nil, fmt.Errorf("BIN %s: duplicate record", record.BIN)
+		}
+		db.records[record.BIN] = record
+	}
+	return db, nil
+}
+
+// Merge returns a table with the records of both; records in other take precedence
+func (db *DB) Merge(other *DB) *DB {
+	merged := &DB{version: db.version, records: make(map[string]Record, len(db.records)+len(other.records))}
+	for bin, record := range db.records {
+		merged.records[bin] = record
+	}
+	for bin, record := range other.records {
+		merged.records[bin] = record
+	}
+	if other.version != "" {
+		merged.version = other.version
+	}
+	return merged
+}
+
+// Version returns the table version string
+func (db *DB) Version() string {
+	return db.version
+}
+
+// Len returns the number of records
+func (db *DB) Len() int {
+	return len(db.records)
+}
+
+// Lookup finds the record for a PAN or BIN prefix, trying 8 digits before 6
+//
+// Spaces and dashes are ignored, so "4111 1111 1111 1111" works.
+func (db *DB) Lookup(panOrPrefix string) (Record, error) {
+	digits, err := Normalize(panOrPrefix)
+	if err != nil {
+		return Record{}, err
+	}
+
+	for _, width := range []int{8, 6} {
+		if len(digits) < width {
+			continue
+		}
+		if record, ok := db.records[digits[:width]]; ok {
+			return record, nil
+		}
+	}
+	return Record{}, ErrNotFound
+}
+
+// Find returns the records matching all non-empty filters, ordered by BIN
+func (db *DB) Find(brand, country, cardType string) []Record {
+	var records []Record
+	for _, record := range db.records {
+		if brand != "" && record.Brand != brand {
+			continue
+		}
+		if country != "" && !strings.EqualFold(record.Country, country) {
+			continue
+		}
+		if cardType != "" && record.Type != cardType {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].BIN < records[j].BIN })
+	return records
+}
+
+// Normalize strips spaces and dashes and checks that 6-19 digits remain
+func Normalize(panOrPrefix string) (string, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(panOrPrefix)
+	if len(digits) < 6 || len(digits) > 19 || !isDigits(digits) {
+		return "", ErrInvalidBIN
+	}
+	return digits, nil
+}
+
+func validate(record Record) error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("BIN %s: %s", record.BIN, fmt.Sprintf(format, args...))
+	}
+
+	if (len(record.BIN) != 6 && len(record.BIN) != 8) || !isDigits(record.BIN) {
+		return fail("must be 6 or 8 digits")
+	}
+	if record.Brand == "" {
+		return fail("brand is required")
+	}
+	if record.Country != "" && (len(record.Country) != 2 || !isUpperLetters(record.Country)) {
+		return fail("country must be an ISO 3166-1 alpha-2 code (e.g. BR)")
+	}
+	if record.Type != "" && !CardTypes[record.Type] {
+		return fail("type must be credit, debit or prepaid")
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isUpperLetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package bindb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundled(t *testing.T) {
+	db := Bundled()
+	if db.Len() == 0 {
+		t.Fatal("Bundled() table is empty")
+	}
+	if db.Version() == "" {
+		t.Error("Bundled() table has no version")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantBIN  string
+		wantType string
+		wantErr  error
+	}{
+		{"6-digit BIN", "445566", "445566", "debit", nil},
+		{"PAN in 6-digit record", "4455661234567890", "445566", "debit", nil},
+		{"8-digit record wins", "4455660112345678", "44556601", "prepaid", nil},
+		{"Formatted PAN", "4455 6601 1234 5678", "44556601", "prepaid", nil},
+		{"Unknown BIN", "999999", "", "", ErrNotFound},
+		{"Too short", "44556", "", "", ErrInvalidBIN},
+		{"Non-digits", "4455AB", "", "", ErrInvalidBIN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := Bundled().Lookup(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if record.BIN != tt.wantBIN || record.Type != tt.wantType {
+				t.Errorf("Lookup(%q) = %s/%s, want %s/%s", tt.input, record.BIN, record.Type, tt.wantBIN, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	records := Bundled().Find("visa", "br", "debit")
+	if len(records) == 0 {
+		t.Fatal("Find(visa, br, debit) returned no records")
+	}
+	for _, record := range records {
+		if record.Brand != "visa" || record.Country != "BR" || record.Type != "debit" {
+			t.Errorf("Find(visa, br, debit) returned %+v", record)
+		}
+	}
+}
+
+func TestLoadAndMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bins.json")
+	content := `{"version": "local", "records": [
+		{"bin": "445566", "brand": "visa", "issuer": "Override Bank", "country": "BR", "type": "credit"},
+		{"bin": "99990000", "brand": "acme", "country": "US", "type": "prepaid"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write table: %v", err)
+	}
+
+	local, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	merged := Bundled().Merge(local)
+	if merged.Len() != Bundled().Len()+1 || merged.Version() != "local" {
+		t.Errorf("Merge() = %d records version %q, want %d records version local", merged.Len(), merged.Version(), Bundled().Len()+1)
+	}
+	if record, _ := merged.Lookup("445566"); record.Issuer != "Override Bank" {
+		t.Errorf("Merge() did not override 445566: %+v", record)
+	}
+	if record, _ := Bundled().Lookup("445566"); record.Issuer == "Override Bank" {
+		t.Error("Merge() modified the bundled table")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		records string
+		wantErr string
+	}{
+		{"7-digit BIN", `{"bin": "4455661", "brand": "visa"}`, "6 or 8 digits"},
+		{"Missing brand", `{"bin": "445566"}`, "brand is required"},
+		{"Bad country", `{"bin": "445566", "brand": "visa", "country": "br"}`, "country"},
+		{"Bad type", `{"bin": "445566", "brand": "visa", "type": "charge"}`, "type"},
+		{"Duplicate", `{"bin": "445566", "brand": "visa"}, {"bin": "445566", "brand": "visa"}`, "duplicate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(`{"records": [` + tt.records + `]}`))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// CMAC computes the NIST SP 800-38B CMAC of data under a 64-bit (TDES) or 128-bit (AES) block cipher
+//
+// ALGORITHM:
+// 1. L = E(K, 0); K1 = L << 1, K2 = K1 << 1, each XORed with Rb when a 1 bit is shifted out
+// 2. The last block is XORed with K1 if complete, else padded with 80 00.. and XORed with K2
+// 3. CBC-MAC of the blocks with a zero IV
+func CMAC(c cipher.Block, data []byte) []byte {
+	size := c.BlockSize()
+	k1, k2 := cmacSubkeys(c)
+
+	n := (len(data) + size - 1) / size
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, size)
+	tail := data[(n-1)*size:]
+	if len(tail) == size {
+		for i := range last {
+			last[i] = tail[i] ^ k1[i]
+		}
+	} else {
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		for i := range last {
+			last[i] ^= k2[i]
+		}
+	}
+
+	mac := make([]byte, size)
+	for i := 0; i < n-1; i++ {
+		for j := range mac {
+			mac[j] ^= data[i*size+j]
+		}
+		c.Encrypt(mac, mac)
+	}
+	for j := range mac {
+		mac[j] ^= last[j]
+	}
+	c.Encrypt(mac, mac)
+	return mac
+}
+
+// AESKeyCheckValue returns the CMAC of a block of zeros under an AES key (ANSI X9.24-1:2017);
+// the check value is its leftmost 5 bytes
+func AESKeyCheckValue(key []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return CMAC(c, make([]byte, aes.BlockSize)), nil
+}
+
+// cmacSubkeys derives the K1 and K2 subkeys of a cipher
+func cmacSubkeys(c cipher.Block) ([]byte, []byte) {
+	rb := byte(0x87)
+	if c.BlockSize() == 8 {
+		rb = 0x1B
+	}
+
+	shift := func(in []byte) []byte {
+		out := make([]byte, len(in))
+		for i := range in {
+			out[i] = in[i] << 1
+			if i+1 < len(in) {
+				out[i] |= in[i+1] >> 7
+			}
+		}
+		if in[0]&0x80 != 0 {
+			out[len(out)-1] ^= rb
+		}
+		return out
+	}
+
+	l := make([]byte, c.BlockSize())
+	c.Encrypt(l, l)
+	k1 := shift(l)
+	return k1, shift(k1)
+}
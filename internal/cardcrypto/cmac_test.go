@@ -0,0 +1,51 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCMAC(t *testing.T) {
+	message, _ := hex.DecodeString("6BC1BEE22E409F96E93D7E117393172AAE2D8A57")
+
+	tests := []struct {
+		name, key string
+		data      []byte
+		want      string
+	}{
+		// NIST SP 800-38B examples (AES-128, 3-key TDEA)
+		{"AES empty", "2B7E151628AED2A6ABF7158809CF4F3C", nil, "BB1D6929E95937287FA37D129B756746"},
+		{"AES 20 bytes", "2B7E151628AED2A6ABF7158809CF4F3C", message, "7D85449EA6EA19C823A7BF78837DFADE"},
+		{"TDES empty", "8AA83BF8CBDA10620BC1BF19FBB6CD58BC313D4A371CA8B5", nil, "B7A688E122FFAF95"},
+		{"TDES 20 bytes", "8AA83BF8CBDA10620BC1BF19FBB6CD58BC313D4A371CA8B5", message, "743DDBE0CE2DC2ED"},
+		{"TDES 8 bytes", "8AA83BF8CBDA10620BC1BF19FBB6CD58BC313D4A371CA8B5", message[:8], "8E8F293136283797"},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		var mac []byte
+		if strings.HasPrefix(tt.name, "AES") {
+			c, _ := aes.NewCipher(key)
+			mac = CMAC(c, tt.data)
+		} else {
+			c, _ := des.NewTripleDESCipher(key)
+			mac = CMAC(c, tt.data)
+		}
+		if got := strings.ToUpper(hex.EncodeToString(mac)); got != tt.want {
+			t.Errorf("CMAC(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAESKeyCheckValue(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	kcv, err := AESKeyCheckValue(key)
+	if err != nil || strings.ToUpper(hex.EncodeToString(kcv)) != "7AD386C3760FB3498361A1CB5563BD70" {
+		t.Errorf("AESKeyCheckValue() = %X, %v; want 7AD386C3760FB3498361A1CB5563BD70", kcv, err)
+	}
+	if _, err := AESKeyCheckValue(key[:8]); err == nil {
+		t.Error("AESKeyCheckValue(8 bytes) expected error")
+	}
+}
@@ -0,0 +1,126 @@
+// Package cardcrypto implements issuer-side card security calculations
+// (card verification values) with user-supplied test keys
+//
+// FOR TEST/SANDBOX USE ONLY - keys passed to this package must never be
+// production keys, and nothing here is hardened against side channels.
+package cardcrypto
+
+import (
+	"crypto/des"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Service codes used to derive the card verification value variants
+const (
+	ServiceCodeCVV2 = "000" // CVV2/CVC2, printed on the card
+	ServiceCodeICVV = "999" // iCVV, carried in chip track equivalent data
+)
+
+// CVK is a card verification key pair: two single-length DES keys
+type CVK struct {
+	A []byte // CVK-A
+	B []byte // CVK-B
+}
+
+// ParseCVK parses a CVK from 32 hex characters (CVK-A followed by CVK-B)
+//
+// Spaces and a single ':' between the halves are ignored, so
+// "0123456789ABCDEF:FEDCBA9876543210" is accepted too.
+func ParseCVK(s string) (CVK, error) {
+	cleaned := strings.NewReplacer(" ", "", ":", "").Replace(s)
+	if len(cleaned) != 32 {
+		return CVK{}, fmt.Errorf("CVK must be 32 hex characters (CVK-A || CVK-B), got %d", len(cleaned))
+	}
+
+	key, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return CVK{}, fmt.Errorf("CVK must be hex: %w", err)
+	}
+	return CVK{A: key[:8], B: key[8:]}, nil
+}
+
+// CVV computes the card verification value for a PAN, expiry (YYMM) and service code
+//
+// ALGORITHM (Visa CVV / Mastercard CVC):
+// 1. PAN || expiry || service code, right-padded with zeros to 32 digits
+// 2. Encrypt the first 16 digits with CVK-A (DES)
+// 3. XOR with the last 16 digits
+// 4. Encrypt with CVK-A, decrypt with CVK-B, encrypt with CVK-A
+// 5. Decimalize: digits of the result left to right, then A-F as 0-5; keep 3
+func (k CVK) CVV(pan, expiry, serviceCode string) (string, error) {
+	if len(k.A) != 8 || len(k.B) != 8 {
+		return "", fmt.Errorf("CVK-A and CVK-B must be 8 bytes each")
+	}
+	if !isDigits(pan) || len(pan) < 12 || len(pan) > 19 {
+		return "", fmt.Errorf("PAN must be 12-19 digits")
+	}
+	if len(expiry) != 4 || !isDigits(expiry) {
+		return "", fmt.Errorf("expiry must be YYMM")
+	}
+	if len(serviceCode) != 3 || !isDigits(serviceCode) {
+		return "", fmt.Errorf("service code must be 3 digits")
+	}
+
+	data := pan + expiry + serviceCode
+	data += strings.Repeat("0", 32-len(data))
+	block1, _ := hex.DecodeString(data[:16])
+	block2, _ := hex.DecodeString(data[16:])
+
+	cipherA, err := des.NewCipher(k.A)
+	if err != nil {
+		return "", err
+	}
+	tripleDES, err := des.NewTripleDESCipher(append(append(append([]byte{}, k.A...), k.B...), k.A...))
+	if err != nil {
+		return "", err
+	}
+
+	result := make([]byte, 8)
+	cipherA.Encrypt(result, block1)
+	for i := range result {
+		result[i] ^= block2[i]
+	}
+	tripleDES.Encrypt(result, result)
+
+	return Decimalize(hex.EncodeToString(result), 3), nil
+}
+
+// CVV2 computes the CVV2/CVC2 printed on the card (service code 000)
+func (k CVK) CVV2(pan, expiry string) (string, error) {
+	return k.CVV(pan, expiry, ServiceCodeCVV2)
+}
+
+// ICVV computes the iCVV for chip track equivalent data (service code 999)
+func (k CVK) ICVV(pan, expiry string) (string, error) {
+	return k.CVV(pan, expiry, ServiceCodeICVV)
+}
+
+// Decimalize extracts n decimal digits from a hex string: first the digits 0-9
+// in order, then the letters A-F mapped to 0-5
+func Decimalize(hexString string, n int) string {
+	hexString = strings.ToUpper(hexString)
+
+	digits := make([]byte, 0, n)
+	for i := 0; i < len(hexString) && len(digits) < n; i++ {
+		if c := hexString[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+	for i := 0; i < len(hexString) && len(digits) < n; i++ {
+		if c := hexString[i]; c >= 'A' && c <= 'F' {
+			digits = append(digits, '0'+c-'A')
+		}
+	}
+	return string(digits)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,87 @@
+package cardcrypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCVV(t *testing.T) {
+	cvk, err := ParseCVK("0123456789ABCDEFFEDCBA9876543210")
+	if err != nil {
+		t.Fatalf("ParseCVK() unexpected error: %v", err)
+	}
+
+	// Published test vector
+	cvv, err := cvk.CVV("4123456789012345", "8701", "101")
+	if err != nil || cvv != "561" {
+		t.Errorf("CVV() = %s, %v; want 561", cvv, err)
+	}
+
+	cvv2, _ := cvk.CVV2("4123456789012345", "8701")
+	icvv, _ := cvk.ICVV("4123456789012345", "8701")
+	if len(cvv2) != 3 || len(icvv) != 3 || cvv2 == icvv {
+		t.Errorf("CVV2() = %s, ICVV() = %s; want distinct 3-digit values", cvv2, icvv)
+	}
+	if want, _ := cvk.CVV("4123456789012345", "8701", ServiceCodeCVV2); cvv2 != want {
+		t.Errorf("CVV2() = %s, want CVV with service code 000 = %s", cvv2, want)
+	}
+}
+
+func TestCVVErrors(t *testing.T) {
+	cvk, _ := ParseCVK("0123456789ABCDEF:FEDCBA9876543210")
+
+	tests := []struct {
+		name, pan, expiry, serviceCode, wantErr string
+	}{
+		{"Short PAN", "41234567890", "8701", "101", "PAN"},
+		{"Bad expiry", "4123456789012345", "870", "101", "expiry"},
+		{"Bad service code", "4123456789012345", "8701", "1A1", "service code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cvk.CVV(tt.pan, tt.expiry, tt.serviceCode)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CVV() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCVK(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"0123456789ABCDEFFEDCBA9876543210", false},
+		{"0123456789abcdef:fedcba9876543210", false},
+		{"0123 4567 89AB CDEF FEDC BA98 7654 3210", false},
+		{"0123456789ABCDEF", true},
+		{"0123456789ABCDEFFEDCBA987654321G", true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCVK(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCVK(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDecimalize(t *testing.T) {
+	tests := []struct {
+		hex  string
+		n    int
+		want string
+	}{
+		{"A1B2C3D4", 3, "123"},
+		{"ABCDEF12", 3, "120"},
+		{"abcdefab", 4, "0123"},
+	}
+
+	for _, tt := range tests {
+		if got := Decimalize(tt.hex, tt.n); got != tt.want {
+			t.Errorf("Decimalize(%s, %d) = %s, want %s", tt.hex, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// KSN lengths, which also select the DUKPT variant
+const (
+	KSNLengthTDES = 10 // ANSI X9.24-1: key set ID + device ID (59 bits) || counter (21 bits)
+	KSNLengthAES  = 12 // ANSI X9.24-3: initial key ID (64 bits) || counter (32 bits)
+)
+
+// Counter limits: devices skip counter values with too many 1 bits
+const (
+	maxCounterOnesTDES = 10
+	maxCounterOnesAES  = 16
+	tdesCounterMask    = 0x1FFFFF
+)
+
+// TDES DUKPT key masks and variants (ANSI X9.24-1, 2009)
+var (
+	dukptKeyMask     = []byte{0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0, 0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0}
+	dukptPINVariant  = []byte{0, 0, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF}
+	dukptMACVariant  = []byte{0, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0}
+	dukptDataVariant = []byte{0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0, 0}
+)
+
+// DUKPTKeys are the working keys of one DUKPT transaction
+type DUKPTKeys struct {
+	AES  bool   // AES DUKPT (12-byte KSN): AES-128 working keys; else double-length 3DES
+	PIN  []byte // PIN encryption key
+	MAC  []byte // MAC key (request direction)
+	Data []byte // Data encryption key (request direction)
+}
+
+// ParseBDK parses a base derivation key from hex: 32 hex characters for TDES DUKPT,
+// 32, 48 or 64 for AES DUKPT
+func ParseBDK(s string) ([]byte, error) {
+	return parseVariableKey("BDK", s)
+}
+
+// ParseKSN parses a key serial number: 20 hex characters (TDES DUKPT) or 24 (AES DUKPT)
+func ParseKSN(s string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(s, " ", "")
+	if len(cleaned) != 2*KSNLengthTDES && len(cleaned) != 2*KSNLengthAES {
+		return nil, fmt.Errorf("KSN must be 20 (TDES) or 24 (AES) hex characters, got %d", len(cleaned))
+	}
+
+	ksn, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("KSN must be hex: %w", err)
+	}
+	return ksn, nil
+}
+
+// DeriveInitialKey derives the key injected into the device: the TDES IPEK or the
+// AES DUKPT initial key, depending on the KSN length
+func DeriveInitialKey(bdk, ksn []byte) ([]byte, error) {
+	switch len(ksn) {
+	case KSNLengthTDES:
+		return DeriveIPEK(bdk, ksn)
+	case KSNLengthAES:
+		return DeriveAESInitialKey(bdk, ksn[:8])
+	default:
+		return nil, fmt.Errorf("KSN must be %d or %d bytes, got %d", KSNLengthTDES, KSNLengthAES, len(ksn))
+	}
+}
+
+// DeriveDUKPTKeys derives the working keys of the transaction a KSN identifies
+func DeriveDUKPTKeys(bdk, ksn []byte) (DUKPTKeys, error) {
+	switch len(ksn) {
+	case KSNLengthTDES:
+		key, err := DeriveTDESTransactionKey(bdk, ksn)
+		if err != nil {
+			return DUKPTKeys{}, err
+		}
+		// The data key is the variant encrypted under itself, half by half
+		variant := xorBytes(key, dukptDataVariant)
+		data, err := encryptHalves(variant, variant[:8], variant[8:])
+		if err != nil {
+			return DUKPTKeys{}, err
+		}
+		return DUKPTKeys{
+			PIN:  xorBytes(key, dukptPINVariant),
+			MAC:  xorBytes(key, dukptMACVariant),
+			Data: data,
+		}, nil
+	case KSNLengthAES:
+		keys := DUKPTKeys{AES: true}
+		var err error
+		if keys.PIN, err = DeriveAESWorkingKey(bdk, ksn, AESKeyUsagePIN); err != nil {
+			return DUKPTKeys{}, err
+		}
+		if keys.MAC, err = DeriveAESWorkingKey(bdk, ksn, AESKeyUsageMAC); err != nil {
+			return DUKPTKeys{}, err
+		}
+		if keys.Data, err = DeriveAESWorkingKey(bdk, ksn, AESKeyUsageData); err != nil {
+			return DUKPTKeys{}, err
+		}
+		return keys, nil
+	default:
+		return DUKPTKeys{}, fmt.Errorf("KSN must be %d or %d bytes, got %d", KSNLengthTDES, KSNLengthAES, len(ksn))
+	}
+}
+
+// NextKSN returns the KSN of the device's next transaction
+//
+// The counter skips values with more 1 bits than the device can derive keys for
+// (10 for TDES, 16 for AES), as X9.24 devices do.
+func NextKSN(ksn []byte) ([]byte, error) {
+	counter, limit, maxOnes, err := ksnCounter(ksn)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		counter++
+		if counter > limit {
+			return nil, fmt.Errorf("KSN counter exhausted")
+		}
+		if bits.OnesCount32(counter) <= maxOnes {
+			return withCounter(ksn, counter), nil
+		}
+	}
+}
+
+// KSNCounter returns the transaction counter of a KSN
+func KSNCounter(ksn []byte) (uint32, error) {
+	counter, _, _, err := ksnCounter(ksn)
+	return counter, err
+}
+
+// DeriveIPEK derives the TDES initial PIN encryption key from the BDK and KSN
+//
+// ALGORITHM (ANSI X9.24-1 A.6):
+// 1. R = leftmost 8 bytes of the KSN with the 21-bit counter cleared
+// 2. IPEK = 3DES(BDK, R) || 3DES(BDK XOR C0C0C0C000000000C0C0C0C000000000, R)
+func DeriveIPEK(bdk, ksn []byte) ([]byte, error) {
+	if len(bdk) != 16 || len(ksn) != KSNLengthTDES {
+		return nil, fmt.Errorf("TDES DUKPT needs a 16-byte BDK and a %d-byte KSN", KSNLengthTDES)
+	}
+
+	register := append([]byte{}, ksn[:8]...)
+	register[7] &= 0xE0
+
+	left, err := tripleDES(bdk)
+	if err != nil {
+		return nil, err
+	}
+	right, err := tripleDES(xorBytes(bdk, dukptKeyMask))
+	if err != nil {
+		return nil, err
+	}
+
+	ipek := make([]byte, 16)
+	left.Encrypt(ipek[:8], register)
+	right.Encrypt(ipek[8:], register)
+	return ipek, nil
+}
+
+// DeriveTDESTransactionKey derives the TDES DUKPT key of a KSN's transaction
+//
+// ALGORITHM (ANSI X9.24-1 A.2): starting from the IPEK and the rightmost 8 KSN bytes
+// with the counter cleared, for each counter bit set (most significant first): set
+// the bit in the register and replace the key with the non-reversible key
+// generation of (key, register).
+func DeriveTDESTransactionKey(bdk, ksn []byte) ([]byte, error) {
+	key, err := DeriveIPEK(bdk, ksn)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := KSNCounter(ksn)
+	if err != nil {
+		return nil, err
+	}
+	if counter == 0 || bits.OnesCount32(counter) > maxCounterOnesTDES {
+		return nil, fmt.Errorf("KSN counter %d is not a valid transaction counter", counter)
+	}
+
+	register := binary.BigEndian.Uint64(ksn[2:]) &^ tdesCounterMask
+	for bit := uint32(1 << 20); bit > 0; bit >>= 1 {
+		if counter&bit == 0 {
+			continue
+		}
+		register |= uint64(bit)
+		data := binary.BigEndian.AppendUint64(nil, register)
+		if key, err = nonReversibleKey(key, data); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// nonReversibleKey is the X9.24-1 non-reversible key generation process:
+// each half is DES(key left, data XOR key right) XOR key right, the left half
+// computed with the key XOR the C0C0C0C0 mask
+func nonReversibleKey(key, data []byte) ([]byte, error) {
+	half := func(k []byte) ([]byte, error) {
+		c, err := des.NewCipher(k[:8])
+		if err != nil {
+			return nil, err
+		}
+		out := xorBytes(data, k[8:])
+		c.Encrypt(out, out)
+		return xorBytes(out, k[8:]), nil
+	}
+
+	right, err := half(key)
+	if err != nil {
+		return nil, err
+	}
+	left, err := half(xorBytes(key, dukptKeyMask))
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// EncryptDUKPTData encrypts data under the transaction's data key (CBC, zero IV)
+//
+// The data is right-padded with zeros to the cipher block size (8 bytes for TDES,
+// 16 for AES); the padding is not removed on decryption.
+func EncryptDUKPTData(keys DUKPTKeys, data []byte) ([]byte, error) {
+	block, err := dataCipher(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, data...)
+	for len(out) == 0 || len(out)%block.BlockSize() != 0 {
+		out = append(out, 0)
+	}
+	cipher.NewCBCEncrypter(block, make([]byte, block.BlockSize())).CryptBlocks(out, out)
+	return out, nil
+}
+
+// DecryptDUKPTData decrypts data encrypted with EncryptDUKPTData, keeping its zero padding
+func DecryptDUKPTData(keys DUKPTKeys, data []byte) ([]byte, error) {
+	block, err := dataCipher(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("encrypted data must be a multiple of %d bytes", block.BlockSize())
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, make([]byte, block.BlockSize())).CryptBlocks(out, data)
+	return out, nil
+}
+
+func dataCipher(keys DUKPTKeys) (cipher.Block, error) {
+	if keys.AES {
+		return aes.NewCipher(keys.Data)
+	}
+	return tripleDES(keys.Data)
+}
+
+// ksnCounter extracts the counter of a TDES or AES KSN with its limits
+func ksnCounter(ksn []byte) (counter, limit uint32, maxOnes int, err error) {
+	switch len(ksn) {
+	case KSNLengthTDES:
+		counter = uint32(ksn[7]&0x1F)<<16 | uint32(ksn[8])<<8 | uint32(ksn[9])
+		return counter, tdesCounterMask, maxCounterOnesTDES, nil
+	case KSNLengthAES:
+		return binary.BigEndian.Uint32(ksn[8:]), 0xFFFFFFFF, maxCounterOnesAES, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("KSN must be %d or %d bytes, got %d", KSNLengthTDES, KSNLengthAES, len(ksn))
+	}
+}
+
+// withCounter returns a copy of the KSN with the given counter
+func withCounter(ksn []byte, counter uint32) []byte {
+	out := append([]byte{}, ksn...)
+	if len(out) == KSNLengthTDES {
+		out[7] = out[7]&0xE0 | byte(counter>>16)
+		out[8], out[9] = byte(counter>>8), byte(counter)
+		return out
+	}
+	binary.BigEndian.PutUint32(out[8:], counter)
+	return out
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
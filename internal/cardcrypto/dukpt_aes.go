@@ -0,0 +1,136 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// AES DUKPT key usage indicators (ANSI X9.24-3, table 2)
+const (
+	AESKeyUsagePIN           uint16 = 0x1000 // PIN encryption
+	AESKeyUsageMAC           uint16 = 0x2000 // MAC generation
+	AESKeyUsageData          uint16 = 0x3000 // Data encryption, encrypt
+	aesKeyUsageKeyDerivation uint16 = 0x8000
+	aesKeyUsageInitialKey    uint16 = 0x8001
+)
+
+// AES DUKPT key algorithm indicators (ANSI X9.24-3, table 3)
+const (
+	aesAlgorithmAES128 uint16 = 0x0002
+	aesAlgorithmAES192 uint16 = 0x0003
+	aesAlgorithmAES256 uint16 = 0x0004
+)
+
+// DeriveAESInitialKey derives the AES DUKPT initial key from the BDK and the
+// 8-byte initial key ID (BDK ID || derivation ID, the leftmost KSN bytes)
+func DeriveAESInitialKey(bdk, ikid []byte) ([]byte, error) {
+	algorithm, err := aesAlgorithm(bdk)
+	if err != nil {
+		return nil, err
+	}
+	if len(ikid) != 8 {
+		return nil, fmt.Errorf("initial key ID must be 8 bytes, got %d", len(ikid))
+	}
+
+	data := aesDerivationData(aesKeyUsageInitialKey, algorithm, ikid)
+	return aesDeriveKey(bdk, algorithm, data)
+}
+
+// DeriveAESWorkingKey derives an AES-128 working key of a KSN's transaction
+//
+// ALGORITHM (ANSI X9.24-3, 6.3):
+// 1. Start from the initial key and a working counter of 0
+// 2. For each counter bit set (most significant first): set it in the working
+// counter and derive the next key with usage "key derivation" and the
+// rightmost 4 bytes of the initial key ID || working counter
+// 3. Derive the working key from the final key with the requested usage
+func DeriveAESWorkingKey(bdk, ksn []byte, usage uint16) ([]byte, error) {
+	if len(ksn) != KSNLengthAES {
+		return nil, fmt.Errorf("AES DUKPT KSN must be %d bytes, got %d", KSNLengthAES, len(ksn))
+	}
+	key, err := DeriveAESInitialKey(bdk, ksn[:8])
+	if err != nil {
+		return nil, err
+	}
+	algorithm, _ := aesAlgorithm(bdk)
+
+	counter := binary.BigEndian.Uint32(ksn[8:])
+	if counter == 0 || bits.OnesCount32(counter) > maxCounterOnesAES {
+		return nil, fmt.Errorf("KSN counter %d is not a valid transaction counter", counter)
+	}
+
+	var working uint32
+	for bit := uint32(1 << 31); bit > 0; bit >>= 1 {
+		if counter&bit == 0 {
+			continue
+		}
+		working |= bit
+		data := aesDerivationData(aesKeyUsageKeyDerivation, algorithm, transactionID(ksn[4:8], working))
+		if key, err = aesDeriveKey(key, algorithm, data); err != nil {
+			return nil, err
+		}
+	}
+
+	data := aesDerivationData(usage, aesAlgorithmAES128, transactionID(ksn[4:8], counter))
+	return aesDeriveKey(key, aesAlgorithmAES128, data)
+}
+
+// aesDerivationData builds the 16-byte derivation data block: version (01), key
+// block counter, key usage, algorithm, key length in bits and the 8-byte ID
+func aesDerivationData(usage, algorithm uint16, id []byte) []byte {
+	data := []byte{0x01, 0x01}
+	data = binary.BigEndian.AppendUint16(data, usage)
+	data = binary.BigEndian.AppendUint16(data, algorithm)
+	data = binary.BigEndian.AppendUint16(data, uint16(8*aesKeyLength(algorithm)))
+	return append(data, id...)
+}
+
+// aesDeriveKey encrypts the derivation data under the derivation key, once per
+// 16 bytes of key material, incrementing the key block counter
+func aesDeriveKey(derivationKey []byte, algorithm uint16, data []byte) ([]byte, error) {
+	c, err := aes.NewCipher(derivationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	length := aesKeyLength(algorithm)
+	key := make([]byte, 0, 32)
+	block := make([]byte, 16)
+	for i := byte(1); len(key) < length; i++ {
+		data[1] = i
+		c.Encrypt(block, data)
+		key = append(key, block...)
+	}
+	return key[:length], nil
+}
+
+// transactionID is the rightmost 4 bytes of the initial key ID || a counter
+func transactionID(deviceID []byte, counter uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, deviceID...), counter)
+}
+
+func aesAlgorithm(key []byte) (uint16, error) {
+	switch len(key) {
+	case 16:
+		return aesAlgorithmAES128, nil
+	case 24:
+		return aesAlgorithmAES192, nil
+	case 32:
+		return aesAlgorithmAES256, nil
+	default:
+		return 0, fmt.Errorf("AES DUKPT BDK must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+func aesKeyLength(algorithm uint16) int {
+	switch algorithm {
+	case aesAlgorithmAES192:
+		return 24
+	case aesAlgorithmAES256:
+		return 32
+	default:
+		return 16
+	}
+}
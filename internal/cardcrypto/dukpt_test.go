@@ -0,0 +1,144 @@
+package cardcrypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// ANSI X9.24-1 (TDES) and X9.24-3 (AES) test vectors
+const (
+	testBDK    = "0123456789ABCDEFFEDCBA9876543210"
+	testAESBDK = "FEDCBA9876543210F1F1F1F1F1F1F1F1"
+)
+
+func TestDeriveTDESDUKPT(t *testing.T) {
+	bdk, _ := hex.DecodeString(testBDK)
+
+	ksn, _ := ParseKSN("FFFF9876543210E00000")
+	if ipek, err := DeriveIPEK(bdk, ksn); err != nil || strings.ToUpper(hex.EncodeToString(ipek)) != "6AC292FAA1315B4D858AB3A3D7D5933A" {
+		t.Errorf("DeriveIPEK() = %X, %v; want 6AC292FAA1315B4D858AB3A3D7D5933A", ipek, err)
+	}
+
+	tests := []struct {
+		ksn, key, pinBlock string
+	}{
+		{"FFFF9876543210E00001", "042666B49184CFA368DE9628D0397BC9", "1B9C1845EB993A7A"},
+		{"FFFF9876543210E00002", "C46551CEF9FD24B0AA9AD834130D3BC7", "10A01C8D02C69107"},
+		{"FFFF9876543210E00003", "0DF3D9422ACA56E547676D07AD6BADFA", "18DC07B94797B466"},
+	}
+	for _, tt := range tests {
+		ksn, _ := ParseKSN(tt.ksn)
+		key, err := DeriveTDESTransactionKey(bdk, ksn)
+		if err != nil || strings.ToUpper(hex.EncodeToString(key)) != tt.key {
+			t.Errorf("DeriveTDESTransactionKey(%s) = %X, %v; want %s", tt.ksn, key, err, tt.key)
+		}
+
+		// PIN 1234, PAN 4012345678909, format 0 under the PIN variant
+		keys, _ := DeriveDUKPTKeys(bdk, ksn)
+		block, err := EncryptPINBlock(PINFormat0, "1234", "4012345678909", keys.PIN, nil)
+		if err != nil || strings.ToUpper(hex.EncodeToString(block)) != tt.pinBlock {
+			t.Errorf("PIN block at %s = %X, %v; want %s", tt.ksn, block, err, tt.pinBlock)
+		}
+	}
+
+	if _, err := DeriveTDESTransactionKey(bdk, ksn); err == nil {
+		t.Error("DeriveTDESTransactionKey(counter 0) expected error")
+	}
+	if _, err := DeriveIPEK(bdk[:8], ksn); err == nil {
+		t.Error("DeriveIPEK(8-byte BDK) expected error")
+	}
+}
+
+func TestDeriveAESDUKPT(t *testing.T) {
+	bdk, _ := hex.DecodeString(testAESBDK)
+
+	ksn, _ := ParseKSN("123456789012345600000001")
+	initial, err := DeriveInitialKey(bdk, ksn)
+	if err != nil || strings.ToUpper(hex.EncodeToString(initial)) != "1273671EA26AC29AFA4D1084127652A1" {
+		t.Errorf("DeriveInitialKey() = %X, %v; want 1273671EA26AC29AFA4D1084127652A1", initial, err)
+	}
+
+	keys, err := DeriveDUKPTKeys(bdk, ksn)
+	if err != nil || !keys.AES || strings.ToUpper(hex.EncodeToString(keys.PIN)) != "AF8CB133A78F8DC2D1359F18527593FB" {
+		t.Errorf("DeriveDUKPTKeys() PIN key = %X, %v; want AF8CB133A78F8DC2D1359F18527593FB", keys.PIN, err)
+	}
+	if bytes.Equal(keys.PIN, keys.MAC) || bytes.Equal(keys.PIN, keys.Data) {
+		t.Error("DeriveDUKPTKeys() returned the same key for different usages")
+	}
+
+	// Each transaction gets a fresh key
+	next, _ := NextKSN(ksn)
+	nextKeys, _ := DeriveDUKPTKeys(bdk, next)
+	if bytes.Equal(keys.PIN, nextKeys.PIN) {
+		t.Error("DeriveDUKPTKeys() PIN key unchanged for the next KSN")
+	}
+
+	// 192- and 256-bit BDKs derive AES-128 working keys too
+	for _, size := range []int{24, 32} {
+		keys, err := DeriveDUKPTKeys(bytes.Repeat([]byte{0x11}, size), ksn)
+		if err != nil || len(keys.PIN) != 16 {
+			t.Errorf("DeriveDUKPTKeys(%d-byte BDK) = %d-byte key, %v", size, len(keys.PIN), err)
+		}
+	}
+	if _, err := DeriveDUKPTKeys(bdk[:8], ksn); err == nil {
+		t.Error("DeriveDUKPTKeys(8-byte AES BDK) expected error")
+	}
+}
+
+func TestNextKSN(t *testing.T) {
+	tests := []struct{ ksn, want string }{
+		{"FFFF9876543210E00000", "FFFF9876543210E00001"},
+		{"FFFF9876543210E00001", "FFFF9876543210E00002"},
+		{"FFFF9876543210E003FE", "FFFF9876543210E003FF"}, // 10 bits set is still valid
+		{"FFFF9876543210E007FE", "FFFF9876543210E00800"}, // 0x7FF has 11 bits set and is skipped
+		{"123456789012345600000001", "123456789012345600000002"},
+	}
+	for _, tt := range tests {
+		ksn, _ := ParseKSN(tt.ksn)
+		next, err := NextKSN(ksn)
+		if err != nil || strings.ToUpper(hex.EncodeToString(next)) != tt.want {
+			t.Errorf("NextKSN(%s) = %X, %v; want %s", tt.ksn, next, err, tt.want)
+		}
+	}
+
+	exhausted, _ := ParseKSN("FFFF9876543210FFFC00")
+	if next, err := NextKSN(exhausted); err == nil {
+		t.Errorf("NextKSN(last counter) = %X, expected error", next)
+	}
+}
+
+func TestDUKPTData(t *testing.T) {
+	for _, ksnHex := range []string{"FFFF9876543210E00001", "123456789012345600000001"} {
+		bdk, _ := hex.DecodeString(testBDK)
+		if len(ksnHex) == 24 {
+			bdk, _ = hex.DecodeString(testAESBDK)
+		}
+		ksn, _ := ParseKSN(ksnHex)
+		keys, _ := DeriveDUKPTKeys(bdk, ksn)
+
+		plain := []byte(";4012345678909=2512?")
+		encrypted, err := EncryptDUKPTData(keys, plain)
+		if err != nil || bytes.Contains(encrypted, plain[:8]) {
+			t.Fatalf("EncryptDUKPTData(%s) = %X, %v", ksnHex, encrypted, err)
+		}
+		decrypted, err := DecryptDUKPTData(keys, encrypted)
+		if err != nil || !bytes.Equal(bytes.TrimRight(decrypted, "\x00"), plain) {
+			t.Errorf("DecryptDUKPTData(%s) = %q, %v; want %q", ksnHex, decrypted, err, plain)
+		}
+	}
+}
+
+func TestParseKSN(t *testing.T) {
+	for _, ksn := range []string{"FFFF9876543210E00001", "FFFF 9876 5432 10E0 0001", "123456789012345600000001"} {
+		if _, err := ParseKSN(ksn); err != nil {
+			t.Errorf("ParseKSN(%q) unexpected error: %v", ksn, err)
+		}
+	}
+	for _, ksn := range []string{"", "FFFF9876543210E0000", "ZZFF9876543210E00001"} {
+		if _, err := ParseKSN(ksn); err == nil {
+			t.Errorf("ParseKSN(%q) expected error", ksn)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cardcrypto
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ParseKey parses a double-length 3DES key from 32 hex characters (spaces ignored)
+func ParseKey(s string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(s, " ", "")
+	if len(cleaned) != 32 {
+		return nil, fmt.Errorf("key must be 32 hex characters (double-length 3DES), got %d", len(cleaned))
+	}
+
+	key, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("key must be hex: %w", err)
+	}
+	return key, nil
+}
+
+// DeriveICCMasterKey derives the ICC master key (MK-AC) from an issuer master key
+//
+// Option A applies to PANs of up to 16 digits, option B to longer ones (EMV Book 2, A1.4).
+func DeriveICCMasterKey(imk []byte, pan, psn string) ([]byte, error) {
+	if len(pan) <= 16 {
+		return MasterKeyOptionA(imk, pan, psn)
+	}
+	return MasterKeyOptionB(imk, pan, psn)
+}
+
+// MasterKeyOptionA derives the ICC master key from the rightmost 16 digits of PAN || PSN
+//
+// ALGORITHM (EMV Book 2, A1.4.1):
+// 1. Y = rightmost 16 digits of PAN || PSN, left-padded with zeros
+// 2. ZL = 3DES(IMK, Y), ZR = 3DES(IMK, Y XOR FF..FF)
+// 3. MK = ZL || ZR with odd parity
+func MasterKeyOptionA(imk []byte, pan, psn string) ([]byte, error) {
+	if err := checkPANAndPSN(pan, psn); err != nil {
+		return nil, err
+	}
+
+	y := pan + psn
+	if len(y) > 16 {
+		y = y[len(y)-16:]
+	}
+	return deriveFromDigits(imk, strings.Repeat("0", 16-len(y))+y)
+}
+
+// MasterKeyOptionB derives the ICC master key for PANs longer than 16 digits
+//
+// ALGORITHM (EMV Book 2, A1.4.2):
+// 1. X = PAN || PSN, with a leading zero if of odd length
+// 2. Y = 16 digits decimalized from SHA-1(X): digits first, then A-F as 0-5
+// 3. MK derived from Y as in option A
+func MasterKeyOptionB(imk []byte, pan, psn string) ([]byte, error) {
+	if err := checkPANAndPSN(pan, psn); err != nil {
+		return nil, err
+	}
+
+	x := pan + psn
+	if len(x)%2 == 1 {
+		x = "0" + x
+	}
+	packed, _ := hex.DecodeString(x)
+	digest := sha1.Sum(packed)
+	return deriveFromDigits(imk, Decimalize(hex.EncodeToString(digest[:]), 16))
+}
+
+// SessionKey derives the EMV common session key (SK-AC) from the ICC master key and ATC
+//
+// ALGORITHM (EMV Book 2, A1.3):
+// SK = 3DES(MK, ATC || F0 || 00..00) || 3DES(MK, ATC || 0F || 00..00)
+func SessionKey(mk, atc []byte) ([]byte, error) {
+	if len(atc) != 2 {
+		return nil, fmt.Errorf("ATC must be 2 bytes, got %d", len(atc))
+	}
+
+	left := []byte{atc[0], atc[1], 0xF0, 0, 0, 0, 0, 0}
+	right := []byte{atc[0], atc[1], 0x0F, 0, 0, 0, 0, 0}
+	return encryptHalves(mk, left, right)
+}
+
+// SessionKeyMastercard derives the Mastercard proprietary session key (M/Chip CVN 10)
+//
+// ALGORITHM (M/Chip 4 SKD):
+// SK = 3DES(MK, ATC || F0 || 00 || UN) || 3DES(MK, ATC || 0F || 00 || UN)
+func SessionKeyMastercard(mk, atc, un []byte) ([]byte, error) {
+	if len(atc) != 2 || len(un) != 4 {
+		return nil, fmt.Errorf("ATC must be 2 bytes and UN 4 bytes, got %d and %d", len(atc), len(un))
+	}
+
+	left := append([]byte{atc[0], atc[1], 0xF0, 0}, un...)
+	right := append([]byte{atc[0], atc[1], 0x0F, 0}, un...)
+	return encryptHalves(mk, left, right)
+}
+
+// ARQC computes an application cryptogram over the transaction data with the session key
+//
+// The MAC is ISO 9797-1 algorithm 3 with padding method 2 (0x80, then zeros).
+func ARQC(sessionKey, data []byte) ([]byte, error) {
+	return RetailMAC(sessionKey, data)
+}
+
+// ARPC computes the authorization response cryptogram (method 1)
+//
+// ARPC = 3DES(SK, ARQC XOR (ARC || 00..00)), with the 2-character authorization
+// response code (e.g. the DE39 "00") as ASCII.
+func ARPC(sessionKey, arqc []byte, arc string) ([]byte, error) {
+	if len(arqc) != 8 || len(arc) != 2 {
+		return nil, fmt.Errorf("ARQC must be 8 bytes and ARC 2 characters")
+	}
+	c, err := tripleDES(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	block := append([]byte{}, arqc...)
+	block[0] ^= arc[0]
+	block[1] ^= arc[1]
+	c.Encrypt(block, block)
+	return block, nil
+}
+
+// RetailMAC computes the ISO 9797-1 algorithm 3 MAC (padding method 2) with a double-length key
+func RetailMAC(key, data []byte) ([]byte, error) {
+	padded := append(append([]byte{}, data...), 0x80)
+	for len(padded)%8 != 0 {
+		padded = append(padded, 0)
+	}
+	return retailMAC(key, padded)
+}
+
+// retailMAC chains the padded blocks with single DES under the left key half, then
+// decrypts the result with the right half and encrypts it again with the left
+func retailMAC(key, padded []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("MAC key must be 16 bytes, got %d", len(key))
+	}
+	left, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	right, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	mac := make([]byte, 8)
+	for i := 0; i+8 <= len(padded); i += 8 {
+		for j := 0; j < 8; j++ {
+			mac[j] ^= padded[i+j]
+		}
+		left.Encrypt(mac, mac)
+	}
+	right.Decrypt(mac, mac)
+	left.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// deriveFromDigits encrypts 16 digits and their complement under the IMK (option A step 2)
+func deriveFromDigits(imk []byte, digits string) ([]byte, error) {
+	y, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, err
+	}
+
+	complement := make([]byte, 8)
+	for i := range y {
+		complement[i] = y[i] ^ 0xFF
+	}
+
+	key, err := encryptHalves(imk, y, complement)
+	if err != nil {
+		return nil, err
+	}
+	return WithOddParity(key), nil
+}
+
+// encryptHalves encrypts two blocks with a double-length key and concatenates them
+func encryptHalves(key, left, right []byte) ([]byte, error) {
+	c, err := tripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 16)
+	c.Encrypt(out[:8], left)
+	c.Encrypt(out[8:], right)
+	return out, nil
+}
+
+// tripleDES creates a 2-key 3DES cipher (K1, K2, K1) from a 16-byte key
+func tripleDES(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("key must be 16 bytes (double-length), got %d", len(key))
+	}
+	return des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+}
+
+// WithOddParity sets the low bit of each byte so it has an odd number of 1 bits
+func WithOddParity(key []byte) []byte {
+	for i, b := range key {
+		ones := 0
+		for v := b >> 1; v != 0; v >>= 1 {
+			ones += int(v & 1)
+		}
+		key[i] = b&0xFE | byte((ones+1)%2)
+	}
+	return key
+}
+
+func checkPANAndPSN(pan, psn string) error {
+	if !isDigits(pan) || len(pan) < 12 || len(pan) > 19 {
+		return fmt.Errorf("PAN must be 12-19 digits")
+	}
+	if len(psn) != 2 || !isDigits(psn) {
+		return fmt.Errorf("PAN sequence number must be 2 digits")
+	}
+	return nil
+}
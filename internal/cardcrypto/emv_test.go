@@ -0,0 +1,115 @@
+package cardcrypto
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/hex"
+	"math/bits"
+	"testing"
+)
+
+const testIMK = "0123456789ABCDEFFEDCBA9876543210"
+
+func TestRetailMAC(t *testing.T) {
+	key, _ := ParseKey(testIMK)
+
+	// ANSI X9.19 test vector (data is block aligned, so no padding applies)
+	mac, err := retailMAC(key, []byte("Now is the time for all "))
+	if err != nil || hex.EncodeToString(mac) != "a1c72e74ea3fa9b6" {
+		t.Errorf("retailMAC() = %X, %v; want A1C72E74EA3FA9B6", mac, err)
+	}
+
+	// Padding method 2 always adds 0x80, even to aligned data
+	padded, _ := retailMAC(key, append([]byte("Now is the time for all "), 0x80, 0, 0, 0, 0, 0, 0, 0))
+	if got, _ := RetailMAC(key, []byte("Now is the time for all ")); !bytes.Equal(got, padded) {
+		t.Errorf("RetailMAC() = %X, want %X", got, padded)
+	}
+}
+
+func TestDeriveICCMasterKey(t *testing.T) {
+	imk, _ := ParseKey(testIMK)
+
+	mk, err := DeriveICCMasterKey(imk, "4000000000000002", "00")
+	if err != nil {
+		t.Fatalf("DeriveICCMasterKey() unexpected error: %v", err)
+	}
+	for i, b := range mk {
+		if bits.OnesCount8(b)%2 != 1 {
+			t.Errorf("DeriveICCMasterKey() byte %d = %02X, want odd parity", i, b)
+		}
+	}
+
+	// Option A: Y is the rightmost 16 digits of PAN || PSN
+	c, _ := des.NewTripleDESCipher(append(append([]byte{}, imk...), imk[:8]...))
+	y, _ := hex.DecodeString("0000000000000200")
+	zl := make([]byte, 8)
+	c.Encrypt(zl, y)
+	if !bytes.Equal(mk[:8], WithOddParity(zl)) {
+		t.Errorf("DeriveICCMasterKey() left half = %X, want 3DES(IMK, Y) = %X", mk[:8], zl)
+	}
+
+	// PANs over 16 digits use option B, and the PSN changes the key
+	long, _ := DeriveICCMasterKey(imk, "6217001234567890123", "00")
+	optionB, _ := MasterKeyOptionB(imk, "6217001234567890123", "00")
+	optionA, _ := MasterKeyOptionA(imk, "6217001234567890123", "00")
+	if !bytes.Equal(long, optionB) || bytes.Equal(long, optionA) {
+		t.Errorf("DeriveICCMasterKey(19 digits) = %X, want option B %X", long, optionB)
+	}
+	if other, _ := DeriveICCMasterKey(imk, "4000000000000002", "01"); bytes.Equal(other, mk) {
+		t.Error("DeriveICCMasterKey() ignores the PSN")
+	}
+
+	if _, err := DeriveICCMasterKey(imk, "4000000000000002", "0"); err == nil {
+		t.Error("DeriveICCMasterKey() with 1-digit PSN expected error but got none")
+	}
+	if _, err := DeriveICCMasterKey(imk[:8], "4000000000000002", "00"); err == nil {
+		t.Error("DeriveICCMasterKey() with single-length IMK expected error but got none")
+	}
+}
+
+func TestARQCAndARPC(t *testing.T) {
+	imk, _ := ParseKey(testIMK)
+	mk, _ := DeriveICCMasterKey(imk, "4000000000000002", "00")
+
+	sk1, _ := SessionKey(mk, []byte{0x00, 0x01})
+	sk2, _ := SessionKey(mk, []byte{0x00, 0x02})
+	if bytes.Equal(sk1, sk2) {
+		t.Error("SessionKey() is the same for different ATCs")
+	}
+	if _, err := SessionKey(mk, []byte{0x01}); err == nil {
+		t.Error("SessionKey() with 1-byte ATC expected error but got none")
+	}
+
+	// The Mastercard session key also depends on the unpredictable number
+	mc1, _ := SessionKeyMastercard(mk, []byte{0x00, 0x01}, []byte{1, 2, 3, 4})
+	mc2, _ := SessionKeyMastercard(mk, []byte{0x00, 0x01}, []byte{1, 2, 3, 5})
+	if len(mc1) != 16 || bytes.Equal(mc1, mc2) || bytes.Equal(mc1, sk1) {
+		t.Errorf("SessionKeyMastercard() = %X and %X, want distinct 16-byte keys", mc1, mc2)
+	}
+	if _, err := SessionKeyMastercard(mk, []byte{0x00, 0x01}, []byte{1, 2, 3}); err == nil {
+		t.Error("SessionKeyMastercard() with 3-byte UN expected error but got none")
+	}
+
+	arqc, err := ARQC(sk1, []byte("transaction data"))
+	if err != nil || len(arqc) != 8 {
+		t.Fatalf("ARQC() = %X, %v; want 8 bytes", arqc, err)
+	}
+
+	// Method 1: decrypting the ARPC gives ARQC XOR ARC
+	arpc, err := ARPC(sk1, arqc, "00")
+	if err != nil {
+		t.Fatalf("ARPC() unexpected error: %v", err)
+	}
+	c, _ := des.NewTripleDESCipher(append(append([]byte{}, sk1...), sk1[:8]...))
+	plain := make([]byte, 8)
+	c.Decrypt(plain, arpc)
+	plain[0] ^= '0'
+	plain[1] ^= '0'
+	if !bytes.Equal(plain, arqc) {
+		t.Errorf("ARPC() decrypts to %X, want ARQC %X XOR ARC", plain, arqc)
+	}
+
+	if declined, _ := ARPC(sk1, arqc, "05"); bytes.Equal(declined, arpc) {
+		t.Error("ARPC() is the same for different response codes")
+	}
+}
@@ -0,0 +1,297 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// fpeMinDomain is the smallest domain (radix^length) NIST SP 800-38G Rev. 1 allows
+const fpeMinDomain = 1000000
+
+// FF3TweakLength is the FF3-1 tweak length in bytes (56 bits)
+const FF3TweakLength = 7
+
+// fpeDigits are the numerals of radixes up to 36
+const fpeDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// FF1 is the NIST SP 800-38G FF1 format-preserving cipher over numeral strings of a radix
+type FF1 struct {
+	block cipher.Block
+	radix int
+}
+
+// NewFF1 creates an FF1 cipher under an AES-128, -192 or -256 key for a radix of 2 to 36
+func NewFF1(key []byte, radix int) (*FF1, error) {
+	block, err := newFPECipher(key, radix)
+	if err != nil {
+		return nil, err
+	}
+	return &FF1{block: block, radix: radix}, nil
+}
+
+// Encrypt enciphers a numeral string under a tweak
+//
+// ALGORITHM (SP 800-38G FF1, 10 Feistel rounds):
+// 1. A, B = the first floor(n/2) and last ceil(n/2) numerals
+// 2. P = 01 02 01 || radix (3 bytes) || 0A || u mod 256 || n (4 bytes) || t (4 bytes)
+// 3. Round i: Q = T || zero pad || i || NUM(B) in b bytes; R = CBC-MAC(P || Q);
+// S = R || E(R ^ 1) || E(R ^ 2).. truncated to d bytes
+// 4. C = (NUM(A) + NUM(S)) mod radix^m; A, B = B, C
+func (f *FF1) Encrypt(tweak []byte, x string) (string, error) {
+	return f.cipher(tweak, x, true)
+}
+
+// Decrypt deciphers a numeral string under a tweak
+func (f *FF1) Decrypt(tweak []byte, x string) (string, error) {
+	return f.cipher(tweak, x, false)
+}
+
+func (f *FF1) cipher(tweak []byte, x string, encrypt bool) (string, error) {
+	n := len(x)
+	if err := checkFPELength(f.radix, n, math.MaxInt32); err != nil {
+		return "", err
+	}
+	numerals, err := parseNumerals(x, f.radix)
+	if err != nil {
+		return "", err
+	}
+
+	u, v := n/2, n-n/2
+	a, b := numerals[:u], numerals[u:]
+	radix := big.NewInt(int64(f.radix))
+	byteLength := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(f.radix))) / 8))
+	d := 4*((byteLength+3)/4) + 4
+
+	p := []byte{1, 2, 1, byte(f.radix >> 16), byte(f.radix >> 8), byte(f.radix), 10, byte(u),
+		byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n),
+		byte(len(tweak) >> 24), byte(len(tweak) >> 16), byte(len(tweak) >> 8), byte(len(tweak))}
+	pad := (16 - (len(tweak)+byteLength+1)%16) % 16
+
+	round := func(i int, x []int) *big.Int {
+		q := make([]byte, 0, len(tweak)+pad+1+byteLength)
+		q = append(q, tweak...)
+		q = append(q, make([]byte, pad)...)
+		q = append(q, byte(i))
+		q = append(q, numeralsValue(x, radix).FillBytes(make([]byte, byteLength))...)
+
+		r := make([]byte, 16)
+		data := append(append([]byte(nil), p...), q...)
+		for j := 0; j < len(data); j += 16 {
+			for k := range r {
+				r[k] ^= data[j+k]
+			}
+			f.block.Encrypt(r, r)
+		}
+
+		s := append([]byte(nil), r...)
+		for j := 1; len(s) < d; j++ {
+			block := append([]byte(nil), r...)
+			for k := 0; k < 8; k++ {
+				block[15-k] ^= byte(uint64(j) >> (8 * k))
+			}
+			f.block.Encrypt(block, block)
+			s = append(s, block...)
+		}
+		return new(big.Int).SetBytes(s[:d])
+	}
+
+	if encrypt {
+		for i := 0; i < 10; i++ {
+			m := u
+			if i%2 == 1 {
+				m = v
+			}
+			c := new(big.Int).Add(numeralsValue(a, radix), round(i, b))
+			a, b = b, valueNumerals(c, radix, m)
+		}
+	} else {
+		for i := 9; i >= 0; i-- {
+			m := u
+			if i%2 == 1 {
+				m = v
+			}
+			c := new(big.Int).Sub(numeralsValue(b, radix), round(i, a))
+			a, b = valueNumerals(c, radix, m), a
+		}
+	}
+	return formatNumerals(append(append([]int(nil), a...), b...)), nil
+}
+
+// FF31 is the NIST SP 800-38G Rev. 1 FF3-1 format-preserving cipher over numeral strings of a radix
+type FF31 struct {
+	block cipher.Block
+	radix int
+}
+
+// NewFF31 creates an FF3-1 cipher under an AES-128, -192 or -256 key for a radix of 2 to 36
+func NewFF31(key []byte, radix int) (*FF31, error) {
+	// FF3-1 enciphers with the byte-reversed key
+	reversed := make([]byte, len(key))
+	for i := range key {
+		reversed[i] = key[len(key)-1-i]
+	}
+	block, err := newFPECipher(reversed, radix)
+	if err != nil {
+		return nil, err
+	}
+	return &FF31{block: block, radix: radix}, nil
+}
+
+// Encrypt enciphers a numeral string under a 56-bit tweak
+//
+// ALGORITHM (SP 800-38G Rev. 1 FF3-1, 8 Feistel rounds):
+// 1. A, B = the first ceil(n/2) and last floor(n/2) numerals
+// 2. TL = T bits 0-27 || 0000, TR = T bits 32-55 || T bits 28-31 || 0000
+// 3. Round i: W = TR (even) or TL (odd); P = W ^ i || NUM(REV(B)) in 12 bytes;
+// S = REVB(E(REVB(P)))
+// 4. C = REV((NUM(REV(A)) + NUM(S)) mod radix^m); A, B = B, C
+func (f *FF31) Encrypt(tweak []byte, x string) (string, error) {
+	return f.cipher(tweak, x, true)
+}
+
+// Decrypt deciphers a numeral string under a 56-bit tweak
+func (f *FF31) Decrypt(tweak []byte, x string) (string, error) {
+	return f.cipher(tweak, x, false)
+}
+
+func (f *FF31) cipher(tweak []byte, x string, encrypt bool) (string, error) {
+	if len(tweak) != FF3TweakLength {
+		return "", fmt.Errorf("FF3-1 tweak must be %d bytes, got %d", FF3TweakLength, len(tweak))
+	}
+	n := len(x)
+	maxLength := 2 * int(math.Floor(96/math.Log2(float64(f.radix))))
+	if err := checkFPELength(f.radix, n, maxLength); err != nil {
+		return "", err
+	}
+	numerals, err := parseNumerals(x, f.radix)
+	if err != nil {
+		return "", err
+	}
+
+	u, v := (n+1)/2, n/2
+	a, b := numerals[:u], numerals[u:]
+	radix := big.NewInt(int64(f.radix))
+	tl := []byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xF0}
+	tr := []byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+
+	round := func(i int, x []int) *big.Int {
+		w := tr
+		if i%2 == 1 {
+			w = tl
+		}
+		p := make([]byte, 16)
+		copy(p, w)
+		p[3] ^= byte(i)
+		numeralsValue(reverseNumerals(x), radix).FillBytes(p[4:])
+
+		reverseBytes(p)
+		f.block.Encrypt(p, p)
+		reverseBytes(p)
+		return new(big.Int).SetBytes(p)
+	}
+
+	if encrypt {
+		for i := 0; i < 8; i++ {
+			m := u
+			if i%2 == 1 {
+				m = v
+			}
+			c := new(big.Int).Add(numeralsValue(reverseNumerals(a), radix), round(i, b))
+			a, b = b, reverseNumerals(valueNumerals(c, radix, m))
+		}
+	} else {
+		for i := 7; i >= 0; i-- {
+			m := u
+			if i%2 == 1 {
+				m = v
+			}
+			c := new(big.Int).Sub(numeralsValue(reverseNumerals(b), radix), round(i, a))
+			a, b = reverseNumerals(valueNumerals(c, radix, m)), a
+		}
+	}
+	return formatNumerals(append(append([]int(nil), a...), b...)), nil
+}
+
+// newFPECipher checks the radix and creates the AES cipher of an FPE key
+func newFPECipher(key []byte, radix int) (cipher.Block, error) {
+	if radix < 2 || radix > len(fpeDigits) {
+		return nil, fmt.Errorf("FPE radix must be 2 to %d, got %d", len(fpeDigits), radix)
+	}
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("FPE key must be 16, 24 or 32 bytes (AES), got %d", len(key))
+	}
+	return aes.NewCipher(key)
+}
+
+// checkFPELength checks a numeral string length against the domain limits
+func checkFPELength(radix, n, maxLength int) error {
+	if n < 2 || math.Pow(float64(radix), float64(n)) < fpeMinDomain {
+		return fmt.Errorf("FPE input of %d numerals is below the minimum domain of %d values", n, fpeMinDomain)
+	}
+	if n > maxLength {
+		return fmt.Errorf("FPE input of %d numerals exceeds the maximum of %d", n, maxLength)
+	}
+	return nil
+}
+
+// parseNumerals converts a string to numerals of a radix
+func parseNumerals(x string, radix int) ([]int, error) {
+	numerals := make([]int, len(x))
+	for i, c := range strings.ToLower(x) {
+		n := strings.IndexRune(fpeDigits, c)
+		if n < 0 || n >= radix {
+			return nil, fmt.Errorf("FPE input %q is not a radix %d numeral string", x, radix)
+		}
+		numerals[i] = n
+	}
+	return numerals, nil
+}
+
+// formatNumerals converts numerals to a string
+func formatNumerals(numerals []int) string {
+	var sb strings.Builder
+	for _, n := range numerals {
+		sb.WriteByte(fpeDigits[n])
+	}
+	return sb.String()
+}
+
+// numeralsValue returns NUM_radix(x), the number the numerals represent (most significant first)
+func numeralsValue(x []int, radix *big.Int) *big.Int {
+	value := new(big.Int)
+	for _, n := range x {
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(n)))
+	}
+	return value
+}
+
+// valueNumerals returns STR^m_radix(c mod radix^m), the m numerals of a number
+func valueNumerals(c *big.Int, radix *big.Int, m int) []int {
+	value := new(big.Int).Mod(c, new(big.Int).Exp(radix, big.NewInt(int64(m)), nil))
+	numerals := make([]int, m)
+	digit := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		value.DivMod(value, radix, digit)
+		numerals[i] = int(digit.Int64())
+	}
+	return numerals
+}
+
+func reverseNumerals(x []int) []int {
+	reversed := make([]int, len(x))
+	for i, n := range x {
+		reversed[len(x)-1-i] = n
+	}
+	return reversed
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
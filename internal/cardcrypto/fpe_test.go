@@ -0,0 +1,92 @@
+package cardcrypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestFF1(t *testing.T) {
+	tests := []struct {
+		name, key, tweak string
+		radix            int
+		plaintext, want  string
+	}{
+		// NIST SP 800-38G FF1 samples 1-3 (AES-128) and 7 (AES-256)
+		{"sample 1", "2B7E151628AED2A6ABF7158809CF4F3C", "", 10, "0123456789", "2433477484"},
+		{"sample 2", "2B7E151628AED2A6ABF7158809CF4F3C", "39383736353433323130", 10, "0123456789", "6124200773"},
+		{"sample 3", "2B7E151628AED2A6ABF7158809CF4F3C", "3737373770717273373737", 36, "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{"sample 7", "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "", 10, "0123456789", "6657667009"},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		tweak, _ := hex.DecodeString(tt.tweak)
+		ff1, err := NewFF1(key, tt.radix)
+		if err != nil {
+			t.Fatalf("NewFF1(%s) unexpected error: %v", tt.name, err)
+		}
+
+		got, err := ff1.Encrypt(tweak, tt.plaintext)
+		if err != nil || got != tt.want {
+			t.Errorf("FF1 %s Encrypt() = %s, %v; want %s", tt.name, got, err, tt.want)
+		}
+		if back, err := ff1.Decrypt(tweak, got); err != nil || back != tt.plaintext {
+			t.Errorf("FF1 %s Decrypt() = %s, %v; want %s", tt.name, back, err, tt.plaintext)
+		}
+	}
+}
+
+func TestFF31(t *testing.T) {
+	// NIST FF3-1 sample (AES-128, 56-bit tweak)
+	key, _ := hex.DecodeString("2DE79D232DF5585D68CE47882AE256D6")
+	tweak, _ := hex.DecodeString("CBD09280979564")
+	ff31, err := NewFF31(key, 10)
+	if err != nil {
+		t.Fatalf("NewFF31() unexpected error: %v", err)
+	}
+
+	got, err := ff31.Encrypt(tweak, "3992520240")
+	if err != nil || got != "8901801106" {
+		t.Errorf("FF3-1 Encrypt() = %s, %v; want 8901801106", got, err)
+	}
+	if back, err := ff31.Decrypt(tweak, got); err != nil || back != "3992520240" {
+		t.Errorf("FF3-1 Decrypt() = %s, %v; want 3992520240", back, err)
+	}
+
+	if _, err := ff31.Encrypt(tweak[:6], "3992520240"); err == nil {
+		t.Error("FF3-1 Encrypt(6-byte tweak) expected error")
+	}
+	if _, err := ff31.Encrypt(tweak, "123456789012345678901234567890123456789012345678901234567890"); err == nil {
+		t.Error("FF3-1 Encrypt(60 numerals) expected error: over the maximum length")
+	}
+}
+
+func TestFPEDomain(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	ff1, _ := NewFF1(key, 10)
+
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"123456", true},
+		{"12345", false}, // 10^5 is below the minimum domain
+		{"12345a", false},
+		{"000000000000", true},
+	}
+	for _, tt := range tests {
+		got, err := ff1.Encrypt(nil, tt.input)
+		if tt.ok && (err != nil || len(got) != len(tt.input)) {
+			t.Errorf("FF1 Encrypt(%s) = %s, %v; want %d numerals", tt.input, got, err, len(tt.input))
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("FF1 Encrypt(%s) expected error", tt.input)
+		}
+	}
+
+	if _, err := NewFF1(key[:10], 10); err == nil {
+		t.Error("NewFF1(10-byte key) expected error")
+	}
+	if _, err := NewFF1(key, 37); err == nil {
+		t.Error("NewFF1(radix 37) expected error")
+	}
+}
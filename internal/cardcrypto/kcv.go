@@ -0,0 +1,29 @@
+package cardcrypto
+
+import (
+	"crypto/des"
+	"fmt"
+)
+
+// KeyCheckValue encrypts a block of zeros under a single-, double- or triple-length
+// DES key; the check value is its leftmost bytes (usually 3, shown as 6 hex)
+func KeyCheckValue(key []byte) ([]byte, error) {
+	zeros := make([]byte, 8)
+	switch len(key) {
+	case 8:
+		c, err := des.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		c.Encrypt(zeros, zeros)
+	case 16, 24:
+		c, err := pinCipherDES(key)
+		if err != nil {
+			return nil, err
+		}
+		c.Encrypt(zeros, zeros)
+	default:
+		return nil, fmt.Errorf("DES key must be 8, 16 or 24 bytes, got %d", len(key))
+	}
+	return zeros, nil
+}
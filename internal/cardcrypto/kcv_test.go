@@ -0,0 +1,29 @@
+package cardcrypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestKeyCheckValue(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"0123456789ABCDEFFEDCBA9876543210", "08D7B4FB629D0885"},
+		{"0123456789ABCDEF0123456789ABCDEF", "D5D44FF720683D0D"}, // K1 = K2: single DES
+		{"0123456789ABCDEF", "D5D44FF720683D0D"},
+		{"0123456789ABCDEFFEDCBA98765432100123456789ABCDEF", "08D7B4FB629D0885"}, // K3 = K1
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		kcv, err := KeyCheckValue(key)
+		if err != nil || strings.ToUpper(hex.EncodeToString(kcv)) != tt.want {
+			t.Errorf("KeyCheckValue(%s) = %X, %v; want %s", tt.key, kcv, err, tt.want)
+		}
+	}
+
+	if _, err := KeyCheckValue(make([]byte, 32)); err == nil {
+		t.Error("KeyCheckValue(32 bytes) expected error")
+	}
+}
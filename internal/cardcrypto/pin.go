@@ -0,0 +1,323 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PIN block formats (ISO 9564-1)
+const (
+	PINFormat0 = 0 // PIN XOR PAN, 8 bytes, 3DES
+	PINFormat1 = 1 // PIN with random fill, no PAN, 8 bytes, 3DES
+	PINFormat3 = 3 // Format 0 with random A-F fill, 8 bytes, 3DES
+	PINFormat4 = 4 // PIN and PAN fields chained through AES, 16 bytes
+)
+
+// PIN lengths allowed by ISO 9564-1
+const (
+	MinPINLength = 4
+	MaxPINLength = 12
+)
+
+var (
+	// ErrPINBlock is returned when a PIN block does not decode to a well-formed PIN field
+	ErrPINBlock = errors.New("invalid PIN block")
+	// ErrPINLength is returned when a decoded PIN block holds a PIN outside 4-12 digits
+	ErrPINLength = errors.New("invalid PIN block: PIN length")
+)
+
+// ParsePINKey parses a zone PIN key (ZPK) from hex (spaces ignored)
+//
+// 16- and 24-byte keys serve 3DES formats 0, 1 and 3; 16-, 24- and 32-byte keys
+// serve AES format 4.
+func ParsePINKey(s string) ([]byte, error) {
+	return parseVariableKey("ZPK", s)
+}
+
+// parseVariableKey parses a 16-, 24- or 32-byte key from hex (spaces ignored)
+func parseVariableKey(name, s string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(s, " ", "")
+	if len(cleaned) != 32 && len(cleaned) != 48 && len(cleaned) != 64 {
+		return nil, fmt.Errorf("%s must be 32, 48 or 64 hex characters, got %d", name, len(cleaned))
+	}
+
+	key, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be hex: %w", name, err)
+	}
+	return key, nil
+}
+
+// ClearPINBlock builds the clear PIN block of a format
+//
+// Formats 0, 1 and 3 return the 8-byte block that is encrypted under the ZPK.
+// Format 4 returns the 16-byte plaintext PIN field; its PAN field only enters
+// during encipherment (see EncryptPINBlock). Random fill is read from random,
+// or crypto/rand when nil.
+func ClearPINBlock(format int, pin, pan string, random io.Reader) ([]byte, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	switch format {
+	case PINFormat0:
+		return xorPANField(pinField(format, pin, func() byte { return 0xF }), pan)
+	case PINFormat1:
+		return pinFieldWithFill(format, pin, random, 0, 16)
+	case PINFormat3:
+		block, err := pinFieldWithFill(format, pin, random, 0xA, 6)
+		if err != nil {
+			return nil, err
+		}
+		return xorPANField(block, pan)
+	case PINFormat4:
+		field := pinField(format, pin, func() byte { return 0xA })
+		fill := make([]byte, 8)
+		if _, err := io.ReadFull(random, fill); err != nil {
+			return nil, fmt.Errorf("random fill: %w", err)
+		}
+		return append(field, fill...), nil
+	default:
+		return nil, fmt.Errorf("unsupported PIN block format %d (want 0, 1, 3 or 4)", format)
+	}
+}
+
+// EncryptPINBlock builds and encrypts a PIN block under a ZPK
+//
+// ALGORITHM:
+// - Formats 0, 1, 3: 3DES-ECB of the clear 8-byte block
+// - Format 4 (ISO 9564-1 AES encipherment):
+// 1. X = AES(K, plaintext PIN field)
+// 2. Y = X XOR plaintext PAN field
+// 3. Block = AES(K, Y)
+func EncryptPINBlock(format int, pin, pan string, zpk []byte, random io.Reader) ([]byte, error) {
+	clear, err := ClearPINBlock(format, pin, pan, random)
+	if err != nil {
+		return nil, err
+	}
+
+	if format != PINFormat4 {
+		c, err := pinCipherDES(zpk)
+		if err != nil {
+			return nil, err
+		}
+		c.Encrypt(clear, clear)
+		return clear, nil
+	}
+
+	panField, err := panFieldFormat4(pan)
+	if err != nil {
+		return nil, err
+	}
+	c, err := aes.NewCipher(zpk)
+	if err != nil {
+		return nil, fmt.Errorf("ZPK: %w", err)
+	}
+	c.Encrypt(clear, clear)
+	for i := range clear {
+		clear[i] ^= panField[i]
+	}
+	c.Encrypt(clear, clear)
+	return clear, nil
+}
+
+// DecryptPINBlock decrypts a PIN block under a ZPK and returns the PIN and its format
+//
+// 8-byte blocks are 3DES formats 0, 1 or 3 (the control nibble tells them apart);
+// 16-byte blocks are AES format 4. A block that does not decode to a well-formed
+// PIN field (wrong key, wrong PAN) is an ErrPINBlock, or an ErrPINLength when only
+// its PIN length is out of range.
+func DecryptPINBlock(block []byte, pan string, zpk []byte) (string, int, error) {
+	switch len(block) {
+	case 8:
+		c, err := pinCipherDES(zpk)
+		if err != nil {
+			return "", 0, err
+		}
+		clear := make([]byte, 8)
+		c.Decrypt(clear, block)
+
+		format := int(clear[0] >> 4)
+		if format != PINFormat0 && format != PINFormat1 && format != PINFormat3 {
+			return "", 0, fmt.Errorf("%w: control field %X", ErrPINBlock, format)
+		}
+		if format == PINFormat0 || format == PINFormat3 {
+			if clear, err = xorPANField(clear, pan); err != nil {
+				return "", 0, err
+			}
+		}
+		pin, err := parsePINField(clear, format)
+		return pin, format, err
+	case 16:
+		panField, err := panFieldFormat4(pan)
+		if err != nil {
+			return "", 0, err
+		}
+		c, err := aes.NewCipher(zpk)
+		if err != nil {
+			return "", 0, fmt.Errorf("ZPK: %w", err)
+		}
+		clear := make([]byte, 16)
+		c.Decrypt(clear, block)
+		for i := range clear {
+			clear[i] ^= panField[i]
+		}
+		c.Decrypt(clear, clear)
+
+		pin, err := parsePINField(clear, PINFormat4)
+		return pin, PINFormat4, err
+	default:
+		return "", 0, fmt.Errorf("%w: must be 8 or 16 bytes, got %d", ErrPINBlock, len(block))
+	}
+}
+
+// validatePIN checks a PIN is 4-12 digits
+func validatePIN(pin string) error {
+	if !isDigits(pin) || len(pin) < MinPINLength || len(pin) > MaxPINLength {
+		return fmt.Errorf("PIN must be %d-%d digits", MinPINLength, MaxPINLength)
+	}
+	return nil
+}
+
+// pinField lays out control nibble, PIN length and PIN digits, then fill nibbles up to 8 bytes
+func pinField(format int, pin string, fill func() byte) []byte {
+	nibbles := make([]byte, 0, 16)
+	nibbles = append(nibbles, byte(format), byte(len(pin)))
+	for i := 0; i < len(pin); i++ {
+		nibbles = append(nibbles, pin[i]-'0')
+	}
+	for len(nibbles) < 16 {
+		nibbles = append(nibbles, fill())
+	}
+	return packNibbles(nibbles)
+}
+
+// pinFieldWithFill builds an 8-byte PIN field with random fill nibbles in [base, base+span)
+func pinFieldWithFill(format int, pin string, random io.Reader, base, span byte) ([]byte, error) {
+	fill := make([]byte, 16)
+	if _, err := io.ReadFull(random, fill); err != nil {
+		return nil, fmt.Errorf("random fill: %w", err)
+	}
+
+	next := 0
+	return pinField(format, pin, func() byte {
+		nibble := base + fill[next]%span
+		next++
+		return nibble
+	}), nil
+}
+
+// xorPANField XORs an 8-byte block with the format 0/3 PAN field:
+// 0000 || the 12 rightmost PAN digits excluding the check digit
+func xorPANField(block []byte, pan string) ([]byte, error) {
+	if !isDigits(pan) || len(pan) < 12 || len(pan) > 19 {
+		return nil, fmt.Errorf("PAN must be 12-19 digits")
+	}
+
+	digits := pan[:len(pan)-1]
+	if len(digits) < 12 {
+		digits = strings.Repeat("0", 12-len(digits)) + digits
+	}
+	digits = digits[len(digits)-12:]
+	field, _ := hex.DecodeString("0000" + digits)
+
+	out := make([]byte, 8)
+	for i := range out {
+		out[i] = block[i] ^ field[i]
+	}
+	return out, nil
+}
+
+// panFieldFormat4 builds the format 4 PAN field: M (PAN length - 12) || PAN
+// (left-padded with zeros to 12 digits) || zeros to 32 nibbles
+func panFieldFormat4(pan string) ([]byte, error) {
+	if !isDigits(pan) || len(pan) > 19 {
+		return nil, fmt.Errorf("PAN must be up to 19 digits")
+	}
+
+	m := 0
+	if len(pan) > 12 {
+		m = len(pan) - 12
+	} else {
+		pan = strings.Repeat("0", 12-len(pan)) + pan
+	}
+	field := fmt.Sprintf("%X%s", m, pan)
+	field += strings.Repeat("0", 32-len(field))
+	return hex.DecodeString(field)
+}
+
+// parsePINField extracts the PIN from a clear PIN field, checking its fill
+func parsePINField(clear []byte, format int) (string, error) {
+	nibbles := unpackNibbles(clear)
+	if int(nibbles[0]) != format {
+		return "", fmt.Errorf("%w: control field %X", ErrPINBlock, nibbles[0])
+	}
+
+	n := int(nibbles[1])
+	if n < MinPINLength || n > MaxPINLength {
+		return "", fmt.Errorf("%w %d", ErrPINLength, n)
+	}
+
+	pin := make([]byte, n)
+	for i := range pin {
+		if nibbles[2+i] > 9 {
+			return "", fmt.Errorf("%w: non-decimal PIN digit", ErrPINBlock)
+		}
+		pin[i] = '0' + nibbles[2+i]
+	}
+
+	// Format 1 fill is arbitrary; format 4 only constrains the PIN field half
+	for _, nibble := range nibbles[2+n : 16] {
+		valid := true
+		switch format {
+		case PINFormat0:
+			valid = nibble == 0xF
+		case PINFormat3:
+			valid = nibble >= 0xA
+		case PINFormat4:
+			valid = nibble == 0xA
+		}
+		if !valid {
+			return "", fmt.Errorf("%w: bad fill", ErrPINBlock)
+		}
+	}
+	return string(pin), nil
+}
+
+// pinCipherDES creates the 3DES cipher of a double- or triple-length ZPK or PVK
+func pinCipherDES(key []byte) (cipher.Block, error) {
+	switch len(key) {
+	case 16:
+		return tripleDES(key)
+	case 24:
+		return des.NewTripleDESCipher(key)
+	default:
+		return nil, fmt.Errorf("3DES key must be 16 or 24 bytes, got %d", len(key))
+	}
+}
+
+func packNibbles(nibbles []byte) []byte {
+	out := make([]byte, len(nibbles)/2)
+	for i := range out {
+		out[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return out
+}
+
+func unpackNibbles(data []byte) []byte {
+	out := make([]byte, 0, 2*len(data))
+	for _, b := range data {
+		out = append(out, b>>4, b&0x0F)
+	}
+	return out
+}
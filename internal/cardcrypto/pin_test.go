@@ -0,0 +1,130 @@
+package cardcrypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testZPK = "0123456789ABCDEFFEDCBA9876543210"
+
+func TestClearPINBlock(t *testing.T) {
+	// Format 0: 041234FFFFFFFFFF XOR 0000111111111111
+	block, err := ClearPINBlock(PINFormat0, "1234", "4111111111111111", nil)
+	if err != nil || strings.ToUpper(hex.EncodeToString(block)) != "041225EEEEEEEEEE" {
+		t.Errorf("ClearPINBlock(format 0) = %X, %v; want 041225EEEEEEEEEE", block, err)
+	}
+
+	// Format 1 carries no PAN; format 3 fill nibbles are A-F
+	fill := bytes.NewReader(bytes.Repeat([]byte{0x00}, 16))
+	block, _ = ClearPINBlock(PINFormat1, "1234", "", fill)
+	if got := strings.ToUpper(hex.EncodeToString(block)); got != "1412340000000000" {
+		t.Errorf("ClearPINBlock(format 1) = %s, want 1412340000000000", got)
+	}
+	block, _ = ClearPINBlock(PINFormat3, "1234", "4111111111111111", bytes.NewReader(make([]byte, 16)))
+	if got := strings.ToUpper(hex.EncodeToString(block)); got != "341225BBBBBBBBBB" {
+		t.Errorf("ClearPINBlock(format 3) = %s, want 341225BBBBBBBBBB", got)
+	}
+
+	// Format 4 PIN field: A fill, then 8 random bytes
+	block, _ = ClearPINBlock(PINFormat4, "1234", "4111111111111111", bytes.NewReader([]byte{0, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}))
+	if got := strings.ToUpper(hex.EncodeToString(block)); got != "441234AAAAAAAAAA0011223344556677" {
+		t.Errorf("ClearPINBlock(format 4) = %s", got)
+	}
+
+	invalid := []struct {
+		format   int
+		pin, pan string
+	}{
+		{PINFormat0, "123", "4111111111111111"},
+		{PINFormat0, "1234567890123", "4111111111111111"},
+		{PINFormat0, "12a4", "4111111111111111"},
+		{PINFormat0, "1234", "41111"},
+		{2, "1234", "4111111111111111"},
+	}
+	for _, tc := range invalid {
+		if _, err := ClearPINBlock(tc.format, tc.pin, tc.pan, nil); err == nil {
+			t.Errorf("ClearPINBlock(%d, %q, %q) expected error", tc.format, tc.pin, tc.pan)
+		}
+	}
+}
+
+func TestEncryptPINBlock(t *testing.T) {
+	zpk, _ := ParsePINKey(testZPK)
+
+	block, err := EncryptPINBlock(PINFormat0, "1234", "4111111111111111", zpk, nil)
+	if err != nil || strings.ToUpper(hex.EncodeToString(block)) != "2A3D408A1977DDE9" {
+		t.Errorf("EncryptPINBlock(format 0) = %X, %v; want 2A3D408A1977DDE9", block, err)
+	}
+
+	// AES format 4 with a 19-digit PAN (M = 7)
+	aesKey, _ := ParsePINKey("00112233445566778899AABBCCDDEEFF")
+	fill := bytes.NewReader([]byte{0, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77})
+	block, err = EncryptPINBlock(PINFormat4, "1234", "1234567890123456789", aesKey, fill)
+	if err != nil || strings.ToUpper(hex.EncodeToString(block)) != "299011308C073FEFB9660DA33700A17D" {
+		t.Errorf("EncryptPINBlock(format 4) = %X, %v; want 299011308C073FEFB9660DA33700A17D", block, err)
+	}
+
+	if _, err := EncryptPINBlock(PINFormat0, "1234", "4111111111111111", make([]byte, 32), nil); err == nil {
+		t.Error("EncryptPINBlock(format 0, 32-byte key) expected error")
+	}
+}
+
+func TestDecryptPINBlock(t *testing.T) {
+	zpk, _ := ParsePINKey(testZPK)
+	pan := "5500000000000004"
+
+	for _, format := range []int{PINFormat0, PINFormat1, PINFormat3, PINFormat4} {
+		block, err := EncryptPINBlock(format, "987654", pan, zpk, nil)
+		if err != nil {
+			t.Fatalf("EncryptPINBlock(format %d) unexpected error: %v", format, err)
+		}
+
+		pin, got, err := DecryptPINBlock(block, pan, zpk)
+		if err != nil || pin != "987654" || got != format {
+			t.Errorf("DecryptPINBlock(format %d) = %q, %d, %v; want 987654", format, pin, got, err)
+		}
+	}
+
+	// A wrong key or (for formats 0 and 4) a wrong PAN garbles the PIN field
+	block, _ := EncryptPINBlock(PINFormat0, "1234", pan, zpk, nil)
+	otherKey, _ := ParsePINKey("FEDCBA98765432100123456789ABCDEF")
+	if _, _, err := DecryptPINBlock(block, pan, otherKey); !errors.Is(err, ErrPINBlock) && !errors.Is(err, ErrPINLength) {
+		t.Errorf("DecryptPINBlock(wrong key) error = %v, want ErrPINBlock or ErrPINLength", err)
+	}
+	if pin, _, err := DecryptPINBlock(block, "4111111111111111", zpk); err == nil && pin == "1234" {
+		t.Error("DecryptPINBlock(wrong PAN) returned the PIN")
+	}
+	block, _ = EncryptPINBlock(PINFormat4, "1234", pan, zpk, nil)
+	if _, _, err := DecryptPINBlock(block, "4111111111111111", zpk); err == nil {
+		t.Error("DecryptPINBlock(format 4, wrong PAN) expected error")
+	}
+
+	if _, _, err := DecryptPINBlock(make([]byte, 12), pan, zpk); !errors.Is(err, ErrPINBlock) {
+		t.Errorf("DecryptPINBlock(12 bytes) error = %v, want ErrPINBlock", err)
+	}
+
+	// A 3-digit PIN: well-formed format 0 block, PIN length out of range
+	c, _ := pinCipherDES(zpk)
+	clear, _ := xorPANField(pinField(PINFormat0, "123", func() byte { return 0xF }), pan)
+	short := make([]byte, 8)
+	c.Encrypt(short, clear)
+	if _, _, err := DecryptPINBlock(short, pan, zpk); !errors.Is(err, ErrPINLength) {
+		t.Errorf("DecryptPINBlock(3-digit PIN) error = %v, want ErrPINLength", err)
+	}
+}
+
+func TestParsePINKey(t *testing.T) {
+	for _, key := range []string{testZPK, "0123 4567 89AB CDEF FEDC BA98 7654 3210", strings.Repeat("AB", 24), strings.Repeat("CD", 32)} {
+		if _, err := ParsePINKey(key); err != nil {
+			t.Errorf("ParsePINKey(%q) unexpected error: %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "0123456789ABCDEF", strings.Repeat("ZZ", 16)} {
+		if _, err := ParsePINKey(key); err == nil {
+			t.Errorf("ParsePINKey(%q) expected error", key)
+		}
+	}
+}
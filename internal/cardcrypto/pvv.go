@@ -0,0 +1,127 @@
+package cardcrypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PVKI bounds: PVKI 0 means the card carries no PVV
+const (
+	MinPVKI = 1
+	MaxPVKI = 6
+)
+
+// DefaultDecimalizationTable maps the hex digits 0-F of an IBM 3624 intermediate PIN to decimals
+const DefaultDecimalizationTable = "0123456789012345"
+
+// ParsePVK parses a PIN verification key from 32 or 48 hex characters (double- or
+// triple-length 3DES, spaces ignored)
+func ParsePVK(s string) ([]byte, error) {
+	key, err := parseVariableKey("PVK", s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 32 {
+		return nil, fmt.Errorf("PVK must be 32 or 48 hex characters (3DES), got 64")
+	}
+	return key, nil
+}
+
+// PVV computes the Visa PIN verification value of a PIN
+//
+// ALGORITHM (Visa PVV):
+// 1. TSP = 11 rightmost PAN digits excluding the check digit || PVKI || 4 leftmost PIN digits
+// 2. Encrypt the 16-digit TSP with the PVK (3DES)
+// 3. Decimalize: digits of the result left to right, then A-F as 0-5; keep 4
+func PVV(pvk []byte, pan string, pvki int, pin string) (string, error) {
+	if len(pan) < 12 || !isDigits(pan) {
+		return "", fmt.Errorf("PAN must be at least 12 digits")
+	}
+	if pvki < MinPVKI || pvki > MaxPVKI {
+		return "", fmt.Errorf("PVKI must be %d-%d, got %d", MinPVKI, MaxPVKI, pvki)
+	}
+	if err := validatePIN(pin); err != nil {
+		return "", err
+	}
+
+	c, err := pinCipherDES(pvk)
+	if err != nil {
+		return "", fmt.Errorf("PVK: %w", err)
+	}
+
+	tsp, _ := hex.DecodeString(pan[len(pan)-12:len(pan)-1] + fmt.Sprint(pvki) + pin[:4])
+	c.Encrypt(tsp, tsp)
+	return Decimalize(hex.EncodeToString(tsp), 4), nil
+}
+
+// IBM3624NaturalPIN computes the natural PIN of a PAN with the IBM 3624 method
+//
+// ALGORITHM (IBM 3624):
+// 1. Validation data = the 16 leftmost PAN digits, right-padded with F
+// 2. Encrypt it with the PVK (3DES)
+// 3. Map each hex digit of the result through the decimalization table; the
+// natural PIN is the leftmost length digits
+func IBM3624NaturalPIN(pvk []byte, pan string, length int) (string, error) {
+	if len(pan) < 12 || !isDigits(pan) {
+		return "", fmt.Errorf("PAN must be at least 12 digits")
+	}
+	if length < MinPINLength || length > MaxPINLength {
+		return "", fmt.Errorf("PIN length must be %d-%d, got %d", MinPINLength, MaxPINLength, length)
+	}
+
+	c, err := pinCipherDES(pvk)
+	if err != nil {
+		return "", fmt.Errorf("PVK: %w", err)
+	}
+
+	validation := pan
+	if len(validation) > 16 {
+		validation = validation[:16]
+	}
+	data, _ := hex.DecodeString(validation + strings.Repeat("F", 16-len(validation)))
+	c.Encrypt(data, data)
+
+	intermediate := strings.ToUpper(hex.EncodeToString(data))
+	natural := make([]byte, length)
+	for i := range natural {
+		natural[i] = DefaultDecimalizationTable[strings.IndexByte("0123456789ABCDEF", intermediate[i])]
+	}
+	return string(natural), nil
+}
+
+// IBM3624Offset computes the offset that turns the natural PIN into the given PIN:
+// each digit is (PIN digit - natural PIN digit) mod 10
+func IBM3624Offset(pvk []byte, pan, pin string) (string, error) {
+	if err := validatePIN(pin); err != nil {
+		return "", err
+	}
+	natural, err := IBM3624NaturalPIN(pvk, pan, len(pin))
+	if err != nil {
+		return "", err
+	}
+
+	offset := make([]byte, len(pin))
+	for i := range offset {
+		offset[i] = '0' + (pin[i]-natural[i]+10)%10
+	}
+	return string(offset), nil
+}
+
+// IBM3624PIN returns the PIN an offset selects: each digit is (natural PIN digit +
+// offset digit) mod 10
+func IBM3624PIN(pvk []byte, pan, offset string) (string, error) {
+	if !isDigits(offset) {
+		return "", fmt.Errorf("offset must be digits")
+	}
+	natural, err := IBM3624NaturalPIN(pvk, pan, len(offset))
+	if err != nil {
+		return "", err
+	}
+
+	pin := make([]byte, len(offset))
+	for i := range pin {
+		pin[i] = '0' + (natural[i]-'0'+offset[i]-'0')%10
+	}
+	return string(pin), nil
+}
@@ -0,0 +1,89 @@
+package cardcrypto
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPVK = "0123456789ABCDEFFEDCBA9876543210"
+
+func TestPVV(t *testing.T) {
+	pvk, _ := ParsePVK(testPVK)
+
+	// TSP 1111111111111234 encrypts to 946B41C3A8F83E68
+	pvv, err := PVV(pvk, "4111111111111111", 1, "1234")
+	if err != nil || pvv != "9464" {
+		t.Errorf("PVV() = %q, %v; want 9464", pvv, err)
+	}
+
+	// Only the 4 leftmost PIN digits and the PVKI enter the TSP
+	if long, _ := PVV(pvk, "4111111111111111", 1, "123456"); long != pvv {
+		t.Errorf("PVV(6-digit PIN) = %q, want %q", long, pvv)
+	}
+	if other, _ := PVV(pvk, "4111111111111111", 2, "1234"); other == pvv {
+		t.Errorf("PVV(PVKI 2) = %q, want a different PVV", other)
+	}
+
+	invalid := []struct {
+		pan  string
+		pvki int
+		pin  string
+	}{
+		{"41111111111", 1, "1234"},
+		{"4111111111111111", 0, "1234"},
+		{"4111111111111111", 7, "1234"},
+		{"4111111111111111", 1, "12a4"},
+	}
+	for _, tc := range invalid {
+		if _, err := PVV(pvk, tc.pan, tc.pvki, tc.pin); err == nil {
+			t.Errorf("PVV(%q, %d, %q) expected error", tc.pan, tc.pvki, tc.pin)
+		}
+	}
+}
+
+func TestIBM3624(t *testing.T) {
+	pvk, _ := ParsePVK(testPVK)
+	pan := "4111111111111111"
+
+	// Validation data 4111111111111111 encrypts to 69D9405C8462F410; D decimalizes to 3
+	natural, err := IBM3624NaturalPIN(pvk, pan, 4)
+	if err != nil || natural != "6939" {
+		t.Errorf("IBM3624NaturalPIN() = %q, %v; want 6939", natural, err)
+	}
+
+	offset, err := IBM3624Offset(pvk, pan, "1234")
+	if err != nil || offset != "5305" {
+		t.Errorf("IBM3624Offset(1234) = %q, %v; want 5305", offset, err)
+	}
+	if zero, _ := IBM3624Offset(pvk, pan, natural); zero != "0000" {
+		t.Errorf("IBM3624Offset(natural PIN) = %q, want 0000", zero)
+	}
+
+	for _, pin := range []string{"1234", "0000", "987654"} {
+		offset, _ := IBM3624Offset(pvk, pan, pin)
+		if got, err := IBM3624PIN(pvk, pan, offset); err != nil || got != pin {
+			t.Errorf("IBM3624PIN(%s) = %q, %v; want %s", offset, got, err, pin)
+		}
+	}
+
+	// 19-digit PANs use their 16 leftmost digits as validation data
+	if _, err := IBM3624NaturalPIN(pvk, "6011000000000000004", 4); err != nil {
+		t.Errorf("IBM3624NaturalPIN(19-digit PAN) unexpected error: %v", err)
+	}
+	if _, err := IBM3624PIN(pvk, pan, "12"); err == nil {
+		t.Error("IBM3624PIN(2-digit offset) expected error")
+	}
+}
+
+func TestParsePVK(t *testing.T) {
+	for _, key := range []string{testPVK, strings.Repeat("AB", 24)} {
+		if _, err := ParsePVK(key); err != nil {
+			t.Errorf("ParsePVK(%q) unexpected error: %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "0123456789ABCDEF", strings.Repeat("CD", 32)} {
+		if _, err := ParsePVK(key); err == nil {
+			t.Errorf("ParsePVK(%q) expected error", key)
+		}
+	}
+}
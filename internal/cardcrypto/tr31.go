@@ -0,0 +1,261 @@
+package cardcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TR-31 key block versions
+const (
+	TR31VersionB = 'B' // TDES KBPK, CMAC-derived keys, 8-byte MAC
+	TR31VersionD = 'D' // AES KBPK, CMAC-derived keys, 16-byte MAC
+)
+
+// tr31HeaderLength is the length of the fixed TR-31 header
+const tr31HeaderLength = 16
+
+// TR31Header is the clear header of a TR-31 key block: what the key is and how it may be used
+type TR31Header struct {
+	Version       byte   // 'B' or 'D'
+	KeyUsage      string // e.g. "P0" PIN encryption, "C0" CVK, "B0" BDK, "E0" EMV master key
+	Algorithm     byte   // 'T' TDES, 'A' AES
+	ModeOfUse     byte   // e.g. 'B' encrypt/decrypt, 'C' generate/verify, 'X' derive keys, 'N' unrestricted
+	KeyVersion    string // 2 characters, "00" when unused
+	Exportability byte   // 'E' exportable, 'N' non-exportable, 'S' sensitive
+}
+
+// String returns the header as it appears in a key block, with its length field zeroed
+func (h TR31Header) String() string {
+	return fmt.Sprintf("%c0000%s%c%c%s%c0000", h.Version, h.KeyUsage, h.Algorithm, h.ModeOfUse, h.KeyVersion, h.Exportability)
+}
+
+// WrapTR31 wraps a key under a KBPK as an ANSI X9.143 (TR-31) key block of version B or D
+//
+// ALGORITHM (key derivation binding method):
+// 1. KBEK and KBAK = CMAC(KBPK, counter || usage || 00 || algorithm || length) blocks,
+// usage 0000 for the encryption key and 0001 for the authentication key
+// 2. Key field = 2-byte key length in bits || key || random padding to the block size
+// 3. MAC = CMAC(KBAK, header || key field)
+// 4. Encrypted key field = CBC(KBEK, IV = MAC)
+// 5. Key block = header || hex(encrypted key field) || hex(MAC)
+//
+// Random padding is read from random, or crypto/rand when nil.
+func WrapTR31(kbpk []byte, header TR31Header, key []byte, random io.Reader) (string, error) {
+	if err := header.validate(); err != nil {
+		return "", err
+	}
+	if err := checkTR31Key(header.Algorithm, key); err != nil {
+		return "", err
+	}
+	kbek, kbak, err := tr31Keys(header.Version, kbpk)
+	if err != nil {
+		return "", err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	size := kbek.BlockSize()
+	field := make([]byte, (2+len(key)+size-1)/size*size)
+	field[0], field[1] = byte(len(key)*8>>8), byte(len(key)*8)
+	copy(field[2:], key)
+	if _, err := io.ReadFull(random, field[2+len(key):]); err != nil {
+		return "", fmt.Errorf("failed to read padding: %w", err)
+	}
+
+	head := header.String()
+	head = head[:1] + fmt.Sprintf("%04d", tr31HeaderLength+2*len(field)+2*size) + head[5:]
+	mac := CMAC(kbak, append([]byte(head), field...))
+
+	encrypted := make([]byte, len(field))
+	cipher.NewCBCEncrypter(kbek, mac).CryptBlocks(encrypted, field)
+	return head + strings.ToUpper(hex.EncodeToString(encrypted)+hex.EncodeToString(mac)), nil
+}
+
+// UnwrapTR31 verifies a version B or D key block under a KBPK and returns its header and key
+//
+// Optional header blocks are accepted and covered by the MAC but not interpreted.
+func UnwrapTR31(kbpk []byte, block string) (TR31Header, []byte, error) {
+	block = strings.TrimSpace(block)
+	header, headerLength, err := parseTR31Header(block)
+	if err != nil {
+		return TR31Header{}, nil, err
+	}
+	kbek, kbak, err := tr31Keys(header.Version, kbpk)
+	if err != nil {
+		return TR31Header{}, nil, err
+	}
+
+	size := kbek.BlockSize()
+	body, err := hex.DecodeString(block[headerLength:])
+	if err != nil {
+		return TR31Header{}, nil, fmt.Errorf("key block: key data must be hex: %w", err)
+	}
+	if len(body) < 2*size || len(body)%size != 0 {
+		return TR31Header{}, nil, fmt.Errorf("key block: key data of %d bytes is not whole %d-byte blocks", len(body), size)
+	}
+
+	encrypted, mac := body[:len(body)-size], body[len(body)-size:]
+	field := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(kbek, mac).CryptBlocks(field, encrypted)
+
+	expected := CMAC(kbak, append([]byte(block[:headerLength]), field...))
+	if subtle.ConstantTimeCompare(expected, mac) != 1 {
+		return TR31Header{}, nil, fmt.Errorf("key block: MAC verification failed (wrong KBPK or altered block)")
+	}
+
+	n := (int(field[0])<<8 | int(field[1])) / 8
+	if n == 0 || 2+n > len(field) {
+		return TR31Header{}, nil, fmt.Errorf("key block: invalid key length %d bits", n*8)
+	}
+	key := field[2 : 2+n]
+	if err := checkTR31Key(header.Algorithm, key); err != nil {
+		return TR31Header{}, nil, err
+	}
+	return header, key, nil
+}
+
+// ParseTR31Header reads the header of a key block without unwrapping it
+func ParseTR31Header(block string) (TR31Header, error) {
+	header, _, err := parseTR31Header(block)
+	return header, err
+}
+
+// parseTR31Header parses and checks the header and returns its length with optional blocks
+func parseTR31Header(block string) (TR31Header, int, error) {
+	block = strings.TrimSpace(block)
+	if len(block) < tr31HeaderLength {
+		return TR31Header{}, 0, fmt.Errorf("key block must be at least %d characters, got %d", tr31HeaderLength, len(block))
+	}
+
+	header := TR31Header{
+		Version:       block[0],
+		KeyUsage:      block[5:7],
+		Algorithm:     block[7],
+		ModeOfUse:     block[8],
+		KeyVersion:    block[9:11],
+		Exportability: block[11],
+	}
+	if err := header.validate(); err != nil {
+		return TR31Header{}, 0, err
+	}
+
+	length, err := strconv.Atoi(block[1:5])
+	if err != nil || length != len(block) {
+		return TR31Header{}, 0, fmt.Errorf("key block: length field %q does not match %d characters", block[1:5], len(block))
+	}
+
+	count, err := strconv.Atoi(block[12:14])
+	if err != nil {
+		return TR31Header{}, 0, fmt.Errorf("key block: invalid optional block count %q", block[12:14])
+	}
+	end := tr31HeaderLength
+	for i := 0; i < count; i++ {
+		if end+4 > len(block) {
+			return TR31Header{}, 0, fmt.Errorf("key block: optional block %d is truncated", i+1)
+		}
+		n, err := strconv.ParseUint(block[end+2:end+4], 16, 8)
+		if err != nil || n < 4 || end+int(n) > len(block) {
+			return TR31Header{}, 0, fmt.Errorf("key block: optional block %s has an invalid length", block[end:end+2])
+		}
+		end += int(n)
+	}
+	return header, end, nil
+}
+
+// validate checks the header fields are well-formed
+func (h TR31Header) validate() error {
+	if h.Version != TR31VersionB && h.Version != TR31VersionD {
+		return fmt.Errorf("key block: unsupported version %q (use B or D)", h.Version)
+	}
+	if len(h.KeyUsage) != 2 || !isAlphanumeric(h.KeyUsage) {
+		return fmt.Errorf("key block: key usage must be 2 alphanumeric characters, got %q", h.KeyUsage)
+	}
+	if h.Algorithm != 'T' && h.Algorithm != 'A' {
+		return fmt.Errorf("key block: unsupported algorithm %q (use T or A)", h.Algorithm)
+	}
+	if !strings.ContainsRune("BCDEGNSTVXY", rune(h.ModeOfUse)) {
+		return fmt.Errorf("key block: invalid mode of use %q", h.ModeOfUse)
+	}
+	if len(h.KeyVersion) != 2 || !isAlphanumeric(h.KeyVersion) {
+		return fmt.Errorf("key block: key version must be 2 alphanumeric characters, got %q", h.KeyVersion)
+	}
+	if !strings.ContainsRune("ENS", rune(h.Exportability)) {
+		return fmt.Errorf("key block: invalid exportability %q (use E, N or S)", h.Exportability)
+	}
+	return nil
+}
+
+// tr31Keys derives the key block encryption and authentication keys of a KBPK
+func tr31Keys(version byte, kbpk []byte) (kbek, kbak cipher.Block, err error) {
+	var algorithm uint16
+	var c cipher.Block
+	switch version {
+	case TR31VersionB:
+		if len(kbpk) != 16 && len(kbpk) != 24 {
+			return nil, nil, fmt.Errorf("version B KBPK must be 16 or 24 bytes (TDES), got %d", len(kbpk))
+		}
+		algorithm = uint16(len(kbpk)/8 - 2) // 0000 2TDEA, 0001 3TDEA
+		c, err = pinCipherDES(kbpk)
+	case TR31VersionD:
+		if len(kbpk) != 16 && len(kbpk) != 24 && len(kbpk) != 32 {
+			return nil, nil, fmt.Errorf("version D KBPK must be 16, 24 or 32 bytes (AES), got %d", len(kbpk))
+		}
+		algorithm = uint16(len(kbpk) / 8) // 0002 AES-128, 0003 AES-192, 0004 AES-256
+		c, err = aes.NewCipher(kbpk)
+	default:
+		return nil, nil, fmt.Errorf("key block: unsupported version %q (use B or D)", version)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	derive := func(usage uint16) (cipher.Block, error) {
+		bits := len(kbpk) * 8
+		var key []byte
+		for counter := byte(1); len(key) < len(kbpk); counter++ {
+			data := []byte{counter, byte(usage >> 8), byte(usage), 0x00, byte(algorithm >> 8), byte(algorithm), byte(bits >> 8), byte(bits)}
+			key = append(key, CMAC(c, data)...)
+		}
+		if version == TR31VersionB {
+			return pinCipherDES(key[:len(kbpk)])
+		}
+		return aes.NewCipher(key[:len(kbpk)])
+	}
+
+	if kbek, err = derive(0x0000); err != nil {
+		return nil, nil, err
+	}
+	if kbak, err = derive(0x0001); err != nil {
+		return nil, nil, err
+	}
+	return kbek, kbak, nil
+}
+
+// checkTR31Key checks a key's length suits its algorithm
+func checkTR31Key(algorithm byte, key []byte) error {
+	switch {
+	case algorithm == 'T' && (len(key) == 16 || len(key) == 24):
+	case algorithm == 'A' && (len(key) == 16 || len(key) == 24 || len(key) == 32):
+	default:
+		return fmt.Errorf("key block: %d-byte key does not suit algorithm %q", len(key), algorithm)
+	}
+	return nil
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
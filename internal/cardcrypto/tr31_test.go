@@ -0,0 +1,105 @@
+package cardcrypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestUnwrapTR31Vector(t *testing.T) {
+	// ANSI X9.143 (TR-31:2018) version D example: AES-256 KBPK, AES-128 PIN key
+	kbpk, _ := hex.DecodeString("88E1AB2A2E3DD38C1FA039A536500CC8A87AB9D62DC92C01058FA79F44657DE6")
+	block := "D0112P0AE00E0000B82679114F470F540165EDFBF7E250FCEA43F810D215F8D207E2E417C07156A27E8E31DA05F7425509593D03A457DC34"
+
+	header, key, err := UnwrapTR31(kbpk, block)
+	if err != nil {
+		t.Fatalf("UnwrapTR31() unexpected error: %v", err)
+	}
+	want := TR31Header{Version: 'D', KeyUsage: "P0", Algorithm: 'A', ModeOfUse: 'E', KeyVersion: "00", Exportability: 'E'}
+	if header != want || strings.ToUpper(hex.EncodeToString(key)) != "3F419E1CB7079442AA37474C2EFBF8B8" {
+		t.Errorf("UnwrapTR31() = %+v %X, want %+v 3F419E1CB7079442AA37474C2EFBF8B8", header, key, want)
+	}
+}
+
+func TestWrapTR31(t *testing.T) {
+	tdesKBPK, _ := hex.DecodeString("89E88CF7931444F334BD7547FC3F380C")
+	aesKBPK, _ := hex.DecodeString("88E1AB2A2E3DD38C1FA039A536500CC8A87AB9D62DC92C01058FA79F44657DE6")
+	tdesKey, _ := hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+	aesKey, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+
+	tests := []struct {
+		name   string
+		kbpk   []byte
+		header TR31Header
+		key    []byte
+		length string
+	}{
+		{"B TDES", tdesKBPK, TR31Header{'B', "C0", 'T', 'C', "00", 'E'}, tdesKey, "0080"},
+		{"D TDES", aesKBPK, TR31Header{'D', "P0", 'T', 'B', "00", 'E'}, tdesKey, "0112"},
+		{"D AES-256", aesKBPK, TR31Header{'D', "B0", 'A', 'X', "01", 'N'}, aesKey, "0144"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := WrapTR31(tt.kbpk, tt.header, tt.key, nil)
+			if err != nil {
+				t.Fatalf("WrapTR31() unexpected error: %v", err)
+			}
+			if block[1:5] != tt.length || block[5:16] != tt.header.String()[5:] {
+				t.Errorf("WrapTR31() header = %s, want length %s and %s", block[:16], tt.length, tt.header)
+			}
+
+			header, key, err := UnwrapTR31(tt.kbpk, block)
+			if err != nil || header != tt.header || !bytes.Equal(key, tt.key) {
+				t.Errorf("UnwrapTR31() = %+v %X, %v; want %+v %X", header, key, err, tt.header, tt.key)
+			}
+
+			// The header is bound to the key: changing the mode of use breaks the MAC
+			altered := block[:8] + "N" + block[9:]
+			if _, _, err := UnwrapTR31(tt.kbpk, altered); err == nil {
+				t.Error("UnwrapTR31(altered header) expected error")
+			}
+			wrong := append([]byte{}, tt.kbpk...)
+			wrong[0] ^= 0x02 // Not the DES parity bit
+			if _, _, err := UnwrapTR31(wrong, block); err == nil {
+				t.Error("UnwrapTR31(wrong KBPK) expected error")
+			}
+		})
+	}
+
+	invalid := []struct {
+		name   string
+		kbpk   []byte
+		header TR31Header
+		key    []byte
+	}{
+		{"version A", tdesKBPK, TR31Header{'A', "C0", 'T', 'C', "00", 'E'}, tdesKey},
+		{"AES KBPK for B", aesKBPK, TR31Header{'B', "C0", 'T', 'C', "00", 'E'}, tdesKey},
+		{"AES key as TDES", tdesKBPK, TR31Header{'B', "B0", 'T', 'X', "00", 'E'}, aesKey},
+		{"mode of use", tdesKBPK, TR31Header{'B', "C0", 'T', 'Q', "00", 'E'}, tdesKey},
+		{"lowercase usage", tdesKBPK, TR31Header{'B', "c0", 'T', 'C', "00", 'E'}, tdesKey},
+	}
+	for _, tt := range invalid {
+		if _, err := WrapTR31(tt.kbpk, tt.header, tt.key, nil); err == nil {
+			t.Errorf("WrapTR31(%s) expected error", tt.name)
+		}
+	}
+}
+
+func TestParseTR31Header(t *testing.T) {
+	header, err := ParseTR31Header("D0112P0AE00E0000B82679114F470F540165EDFBF7E250FCEA43F810D215F8D207E2E417C07156A27E8E31DA05F7425509593D03A457DC34")
+	if err != nil || header.KeyUsage != "P0" || header.ModeOfUse != 'E' {
+		t.Errorf("ParseTR31Header() = %+v, %v; want usage P0, mode E", header, err)
+	}
+
+	// Optional blocks are skipped: one 8-character block ("KS" with 4 characters of data)
+	if _, end, err := parseTR31Header("B0032B0TX00N0100KS08ABCD" + strings.Repeat("0", 8)); err != nil || end != 24 {
+		t.Errorf("parseTR31Header(optional block) = %d, %v; want header length 24", end, err)
+	}
+
+	for _, block := range []string{"B0080C0TC00E00", "B0099C0TC00E0000", "B0016C0TC00E0100"} {
+		if _, err := ParseTR31Header(block); err == nil {
+			t.Errorf("ParseTR31Header(%q) expected error", block)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package emv
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGenerateICCData(t *testing.T) {
+	tx := Transaction{
+		PAN:      "5100000000000008",
+		Brand:    "Mastercard",
+		Amount:   12345,
+		Currency: "986",
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	objects, err := GenerateICCData(tx)
+	if err != nil {
+		t.Fatalf("GenerateICCData() unexpected error: %v", err)
+	}
+	if len(objects) != len(DE55Tags) {
+		t.Fatalf("GenerateICCData() returned %d tags, want %d", len(objects), len(DE55Tags))
+	}
+
+	want := map[string]string{
+		"9F27": "80",
+		"9F02": "000000012345",
+		"5F2A": "0986",
+		"9F1A": "0076",
+		"9A":   "260102",
+		"9C":   "00",
+		"84":   "A0000000041010",
+	}
+	for i, object := range objects {
+		if object.Tag != DE55Tags[i] {
+			t.Errorf("tag %d = %s, want %s", i, object.Tag, DE55Tags[i])
+		}
+		if value, ok := want[object.Tag]; ok && fmt.Sprintf("%X", object.Value) != value {
+			t.Errorf("tag %s = %X, want %s", object.Tag, object.Value, value)
+		}
+	}
+	if iad, _ := Find(objects, "9F10"); len(iad.Value) != 18 {
+		t.Errorf("9F10 = %X, want 18-byte M/Chip layout", iad.Value)
+	}
+
+	// Reproducible for the same transaction, different for another time
+	again, _ := GenerateICCData(tx)
+	first, _ := EncodeHex(objects)
+	second, _ := EncodeHex(again)
+	tx.Time = tx.Time.Add(time.Second)
+	later, _ := GenerateICCData(tx)
+	third, _ := EncodeHex(later)
+	if first != second || first == third {
+		t.Errorf("GenerateICCData() reproducible = %v, varies with time = %v; want true, true", first == second, first != third)
+	}
+
+	// Unknown brands omit the AID; bad currencies are rejected
+	tx.Brand = "Acme Debit"
+	if objects, _ := GenerateICCData(tx); len(objects) != len(DE55Tags)-1 {
+		t.Errorf("GenerateICCData(unknown brand) returned %d tags, want %d", len(objects), len(DE55Tags)-1)
+	}
+	tx.Currency = "BRL"
+	if _, err := GenerateICCData(tx); err == nil {
+		t.Error("GenerateICCData() with alphabetic currency expected error but got none")
+	}
+}
@@ -0,0 +1,126 @@
+package emv
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+// CDOL1Tags are the data elements the ARQC is computed over, in order
+//
+// This is the Visa CVN 18 layout (CDOL1 data followed by the full issuer application
+// data), used for every issuer application data except M/Chip CVN 10.
+var CDOL1Tags = []string{"9F02", "9F03", "9F1A", "95", "5F2A", "9A", "9C", "9F37", "82", "9F36", "9F10"}
+
+// MChipCDOL1Tags are the data elements of an M/Chip CVN 10 ARQC, in order
+//
+// The card verification results from the issuer application data follow them in
+// place of the full 9F10.
+var MChipCDOL1Tags = []string{"9F02", "9F03", "9F1A", "95", "5F2A", "9A", "9C", "9F37", "82", "9F36"}
+
+// mchipIADLength and cvnMChip identify M/Chip CVN 10 issuer application data:
+// DKI, CVN, CVR (6), DAC/IDN (2), counters (8)
+const (
+	mchipIADLength = 18
+	cvnMChip       = 0x10
+)
+
+// ARQCData concatenates the CDOL1 values of a tag set
+//
+// The layout follows the cryptogram version of the issuer application data (9F10):
+// M/Chip CVN 10 ends with the CVR, anything else with the full 9F10 (Visa CVN 18).
+func ARQCData(objects []TLV) ([]byte, error) {
+	tags := CDOL1Tags
+	cvr, mchip := mchipCVR(objects)
+	if mchip {
+		tags = MChipCDOL1Tags
+	}
+
+	var data []byte
+	for _, tag := range tags {
+		object, ok := Find(objects, tag)
+		if !ok {
+			return nil, fmt.Errorf("missing tag %s for ARQC", tag)
+		}
+		data = append(data, object.Value...)
+	}
+	return append(data, cvr...), nil
+}
+
+// GenerateARQC computes the ARQC of a tag set with the ICC master key
+//
+// The session key is the EMV common session key for the tag set's ATC (9F36), or the
+// Mastercard session key for its ATC and unpredictable number (9F37) with M/Chip CVN 10.
+func GenerateARQC(objects []TLV, mk []byte) ([]byte, error) {
+	sessionKey, err := sessionKeyFor(objects, mk)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ARQCData(objects)
+	if err != nil {
+		return nil, err
+	}
+	return cardcrypto.ARQC(sessionKey, data)
+}
+
+// VerifyARQC checks the application cryptogram (9F26) of a tag set
+func VerifyARQC(objects []TLV, mk []byte) error {
+	cryptogram, ok := Find(objects, "9F26")
+	if !ok {
+		return fmt.Errorf("missing tag 9F26")
+	}
+
+	expected, err := GenerateARQC(objects, mk)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(cryptogram.Value, expected) {
+		return fmt.Errorf("ARQC mismatch")
+	}
+	return nil
+}
+
+// IssuerAuthenticationData builds tag 91 (ARPC method 1 || ARC) for a response code
+func IssuerAuthenticationData(objects []TLV, mk []byte, responseCode string) (TLV, error) {
+	cryptogram, ok := Find(objects, "9F26")
+	if !ok {
+		return TLV{}, fmt.Errorf("missing tag 9F26")
+	}
+	sessionKey, err := sessionKeyFor(objects, mk)
+	if err != nil {
+		return TLV{}, err
+	}
+
+	arpc, err := cardcrypto.ARPC(sessionKey, cryptogram.Value, responseCode)
+	if err != nil {
+		return TLV{}, err
+	}
+	return TLV{Tag: "91", Value: append(arpc, responseCode...)}, nil
+}
+
+// sessionKeyFor derives the session key from the ICC master key and the tag set's ATC
+func sessionKeyFor(objects []TLV, mk []byte) ([]byte, error) {
+	atc, ok := Find(objects, "9F36")
+	if !ok {
+		return nil, fmt.Errorf("missing tag 9F36")
+	}
+	if _, mchip := mchipCVR(objects); !mchip {
+		return cardcrypto.SessionKey(mk, atc.Value)
+	}
+
+	un, ok := Find(objects, "9F37")
+	if !ok {
+		return nil, fmt.Errorf("missing tag 9F37")
+	}
+	return cardcrypto.SessionKeyMastercard(mk, atc.Value, un.Value)
+}
+
+// mchipCVR returns the card verification results of M/Chip CVN 10 issuer application data
+func mchipCVR(objects []TLV) ([]byte, bool) {
+	iad, ok := Find(objects, "9F10")
+	if !ok || len(iad.Value) != mchipIADLength || iad.Value[1] != cvnMChip {
+		return nil, false
+	}
+	return iad.Value[2:8], true
+}
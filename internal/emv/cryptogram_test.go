@@ -0,0 +1,89 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+func TestARQCRoundTrip(t *testing.T) {
+	imk, _ := cardcrypto.ParseKey("0123456789ABCDEFFEDCBA9876543210")
+
+	tests := []struct {
+		brand string
+		pan   string
+	}{
+		{"Visa", "4000000000000002"},
+		{"Mastercard", "5500000000000004"},
+	}
+	for _, tt := range tests {
+		mk, _ := cardcrypto.DeriveICCMasterKey(imk, tt.pan, "00")
+		objects, err := GenerateICCData(Transaction{
+			PAN:       tt.pan,
+			Brand:     tt.brand,
+			Amount:    10000,
+			Currency:  "986",
+			Time:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			MasterKey: mk,
+		})
+		if err != nil {
+			t.Fatalf("GenerateICCData(%s) unexpected error: %v", tt.brand, err)
+		}
+		if err := VerifyARQC(objects, mk); err != nil {
+			t.Errorf("VerifyARQC(%s) unexpected error: %v", tt.brand, err)
+		}
+
+		issuerAuth, err := IssuerAuthenticationData(objects, mk, "00")
+		if err != nil || issuerAuth.Tag != "91" || len(issuerAuth.Value) != 10 || string(issuerAuth.Value[8:]) != "00" {
+			t.Errorf("IssuerAuthenticationData(%s) = %+v, %v; want tag 91 with 8-byte ARPC and ARC 00", tt.brand, issuerAuth, err)
+		}
+
+		// Another card's key, or altered transaction data, fails verification
+		other, _ := cardcrypto.DeriveICCMasterKey(imk, "4000000000000010", "00")
+		if err := VerifyARQC(objects, other); err == nil {
+			t.Errorf("VerifyARQC(%s) with another card's key expected error but got none", tt.brand)
+		}
+
+		amount, _ := Find(objects, "9F02")
+		amount.Value[5] ^= 0x01
+		if err := VerifyARQC(objects, mk); err == nil || !strings.Contains(err.Error(), "mismatch") {
+			t.Errorf("VerifyARQC(%s) with altered amount error = %v, want mismatch", tt.brand, err)
+		}
+		amount.Value[5] ^= 0x01
+
+		if err := VerifyARQC(objects[1:], mk); err == nil {
+			t.Errorf("VerifyARQC(%s) without 9F26 expected error but got none", tt.brand)
+		}
+	}
+}
+
+func TestARQCDataFollowsCVN(t *testing.T) {
+	base := []TLV{
+		{Tag: "9F02", Value: []byte{0, 0, 0, 1, 0, 0}},
+		{Tag: "9F03", Value: make([]byte, 6)},
+		{Tag: "9F1A", Value: []byte{0x00, 0x76}},
+		{Tag: "95", Value: make([]byte, 5)},
+		{Tag: "5F2A", Value: []byte{0x09, 0x86}},
+		{Tag: "9A", Value: []byte{0x26, 0x01, 0x01}},
+		{Tag: "9C", Value: []byte{0x00}},
+		{Tag: "9F37", Value: []byte{1, 2, 3, 4}},
+		{Tag: "82", Value: []byte{0x3C, 0x00}},
+		{Tag: "9F36", Value: []byte{0x00, 0x2A}},
+	}
+	visa, _ := hex.DecodeString(issuerApplicationData("visa"))
+	mchip, _ := hex.DecodeString(issuerApplicationData("mastercard"))
+
+	// Visa CVN 18 appends the full IAD, M/Chip CVN 10 only the CVR
+	data, _ := ARQCData(append(append([]TLV{}, base...), TLV{Tag: "9F10", Value: visa}))
+	if !bytes.HasSuffix(data, visa) {
+		t.Errorf("ARQCData() with Visa IAD = %X, want it to end with %X", data, visa)
+	}
+	data, _ = ARQCData(append(append([]TLV{}, base...), TLV{Tag: "9F10", Value: mchip}))
+	if len(data) != 39 || !bytes.HasSuffix(data, mchip[2:8]) {
+		t.Errorf("ARQCData() with M/Chip IAD = %X, want CDOL1 data and CVR %X", data, mchip[2:8])
+	}
+}
@@ -0,0 +1,56 @@
+package emv
+
+// TagNames maps common EMV tags to their names (EMV Book 3, Annex A)
+var TagNames = map[string]string{
+	"4F":   "Application Identifier (AID) - card",
+	"50":   "Application Label",
+	"57":   "Track 2 Equivalent Data",
+	"5A":   "Application PAN",
+	"5F20": "Cardholder Name",
+	"5F24": "Application Expiration Date",
+	"5F25": "Application Effective Date",
+	"5F28": "Issuer Country Code",
+	"5F2A": "Transaction Currency Code",
+	"5F34": "PAN Sequence Number",
+	"6F":   "File Control Information (FCI) Template",
+	"70":   "READ RECORD Response Message Template",
+	"71":   "Issuer Script Template 1",
+	"72":   "Issuer Script Template 2",
+	"77":   "Response Message Template Format 2",
+	"80":   "Response Message Template Format 1",
+	"82":   "Application Interchange Profile",
+	"84":   "Dedicated File (DF) Name",
+	"86":   "Issuer Script Command",
+	"8A":   "Authorisation Response Code",
+	"8C":   "CDOL1",
+	"8D":   "CDOL2",
+	"8E":   "CVM List",
+	"91":   "Issuer Authentication Data",
+	"95":   "Terminal Verification Results",
+	"9A":   "Transaction Date",
+	"9B":   "Transaction Status Information",
+	"9C":   "Transaction Type",
+	"9F02": "Amount, Authorised (Numeric)",
+	"9F03": "Amount, Other (Numeric)",
+	"9F06": "Application Identifier (AID) - terminal",
+	"9F07": "Application Usage Control",
+	"9F08": "Application Version Number - card",
+	"9F09": "Application Version Number - terminal",
+	"9F0D": "Issuer Action Code - Default",
+	"9F0E": "Issuer Action Code - Denial",
+	"9F0F": "Issuer Action Code - Online",
+	"9F10": "Issuer Application Data",
+	"9F1A": "Terminal Country Code",
+	"9F1E": "Interface Device (IFD) Serial Number",
+	"9F21": "Transaction Time",
+	"9F26": "Application Cryptogram",
+	"9F27": "Cryptogram Information Data",
+	"9F33": "Terminal Capabilities",
+	"9F34": "Cardholder Verification Method (CVM) Results",
+	"9F35": "Terminal Type",
+	"9F36": "Application Transaction Counter (ATC)",
+	"9F37": "Unpredictable Number",
+	"9F41": "Transaction Sequence Counter",
+	"9F53": "Transaction Category Code",
+	"9F6E": "Form Factor Indicator / Third Party Data",
+}
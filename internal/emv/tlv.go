@@ -0,0 +1,211 @@
+// Package emv builds and parses EMV chip data (BER-TLV), as carried in ISO-8583 DE55
+//
+// DESIGN RATIONALE:
+// - Tags are upper-case hex strings ("9F26"), like ISO-8583 field numbers are strings
+// - Values are raw bytes; hex is only used at the edges (DE55, CLI, JSON)
+// - Constructed tags (e.g. 70, 77) are decoded into children so captures can be inspected
+//
+// FOR TEST/SANDBOX USE ONLY
+package emv
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TLV is a single BER-TLV data object
+type TLV struct {
+	Tag      string // Upper-case hex, e.g. "9F26"
+	Value    []byte
+	Children []TLV // Decoded children of a constructed tag
+}
+
+// New creates a TLV from a tag and a hex value
+func New(tag, hexValue string) (TLV, error) {
+	value, err := hex.DecodeString(hexValue)
+	if err != nil {
+		return TLV{}, fmt.Errorf("tag %s: value is not valid hex: %w", tag, err)
+	}
+	return TLV{Tag: strings.ToUpper(tag), Value: value}, nil
+}
+
+// Name returns the EMV name of the tag, or "" when unknown
+func (t TLV) Name() string {
+	return TagNames[t.Tag]
+}
+
+// Constructed reports whether the tag encodes other data objects
+func (t TLV) Constructed() bool {
+	tag, err := hex.DecodeString(t.Tag)
+	return err == nil && len(tag) > 0 && tag[0]&0x20 != 0
+}
+
+// MarshalJSON renders the value as hex and adds the tag name
+func (t TLV) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tag      string `json:"tag"`
+		Name     string `json:"name,omitempty"`
+		Length   int    `json:"length"`
+		Value    string `json:"value"`
+		Children []TLV  `json:"children,omitempty"`
+	}{t.Tag, t.Name(), len(t.Value), strings.ToUpper(hex.EncodeToString(t.Value)), t.Children})
+}
+
+// Encode serializes data objects as BER-TLV
+func Encode(objects []TLV) ([]byte, error) {
+	var out []byte
+	for _, object := range objects {
+		tag, err := parseTag(object.Tag)
+		if err != nil {
+			return nil, err
+		}
+		if len(object.Value) > 0xFFFF {
+			return nil, fmt.Errorf("tag %s: value of %d bytes is too long", object.Tag, len(object.Value))
+		}
+
+		out = append(out, tag...)
+		out = append(out, encodeLength(len(object.Value))...)
+		out = append(out, object.Value...)
+	}
+	return out, nil
+}
+
+// EncodeHex serializes data objects as upper-case hex (the DE55 representation)
+func EncodeHex(objects []TLV) (string, error) {
+	data, err := Encode(objects)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(data)), nil
+}
+
+// Decode parses BER-TLV data, descending into constructed tags
+//
+// 0x00 and 0xFF bytes between objects are padding and are skipped.
+func Decode(data []byte) ([]TLV, error) {
+	var objects []TLV
+
+	for i := 0; i < len(data); {
+		if data[i] == 0x00 || data[i] == 0xFF {
+			i++
+			continue
+		}
+
+		// Tag: 1 byte, or more when the low 5 bits are all set; subsequent bytes
+		// continue while their high bit is set
+		start := i
+		i++
+		if data[start]&0x1F == 0x1F {
+			for i < len(data) && data[i]&0x80 != 0 {
+				i++
+			}
+			i++
+		}
+		if i > len(data) {
+			return nil, fmt.Errorf("truncated tag at offset %d", start)
+		}
+		object := TLV{Tag: strings.ToUpper(hex.EncodeToString(data[start:i]))}
+
+		length, n, err := decodeLength(data[i:])
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", object.Tag, err)
+		}
+		i += n
+		if i+length > len(data) {
+			return nil, fmt.Errorf("tag %s: value of %d bytes truncated at offset %d", object.Tag, length, i)
+		}
+		object.Value = data[i : i+length]
+		i += length
+
+		if object.Constructed() {
+			children, err := Decode(object.Value)
+			if err != nil {
+				return nil, fmt.Errorf("tag %s: %w", object.Tag, err)
+			}
+			object.Children = children
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// DecodeHex parses hex BER-TLV data, ignoring whitespace
+func DecodeHex(s string) ([]TLV, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("TLV data is not valid hex: %w", err)
+	}
+	return Decode(data)
+}
+
+// Find returns the first data object with the tag, searching constructed tags too
+func Find(objects []TLV, tag string) (TLV, bool) {
+	tag = strings.ToUpper(tag)
+	for _, object := range objects {
+		if object.Tag == tag {
+			return object, true
+		}
+		if found, ok := Find(object.Children, tag); ok {
+			return found, true
+		}
+	}
+	return TLV{}, false
+}
+
+// parseTag validates a hex tag: a multi-byte tag continues while the high bit is set
+func parseTag(tag string) ([]byte, error) {
+	raw, err := hex.DecodeString(tag)
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("invalid tag %q", tag)
+	}
+
+	multiByte := raw[0]&0x1F == 0x1F
+	for i := 1; i < len(raw); i++ {
+		if !multiByte || (i < len(raw)-1) != (raw[i]&0x80 != 0) {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	if multiByte && len(raw) == 1 {
+		return nil, fmt.Errorf("invalid tag %q: missing subsequent byte", tag)
+	}
+	return raw, nil
+}
+
+// encodeLength encodes a BER length: short form below 128, else 0x81/0x82 + bytes
+func encodeLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n <= 0xFF:
+		return []byte{0x81, byte(n)}
+	default:
+		return []byte{0x82, byte(n >> 8), byte(n)}
+	}
+}
+
+// decodeLength decodes a BER length, returning it and the bytes consumed
+func decodeLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("missing length")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+
+	n := int(data[0] & 0x7F)
+	if n == 0 || n > 2 {
+		return 0, 0, fmt.Errorf("unsupported length encoding 0x%02X", data[0])
+	}
+	if len(data) < 1+n {
+		return 0, 0, fmt.Errorf("truncated length")
+	}
+
+	length := 0
+	for _, b := range data[1 : 1+n] {
+		length = length<<8 | int(b)
+	}
+	return length, 1 + n, nil
+}
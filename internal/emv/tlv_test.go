@@ -0,0 +1,89 @@
+package emv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	// Issuer script template: a 197-byte command makes a 200-byte value
+	script := append([]byte{0x86, 0x81, 0xC5}, bytes.Repeat([]byte{0x01}, 197)...)
+	objects := []TLV{
+		{Tag: "9F27", Value: []byte{0x80}},
+		{Tag: "95", Value: make([]byte, 5)},
+		{Tag: "71", Value: script},
+		{Tag: "9C", Value: nil},
+	}
+
+	data, err := Encode(objects)
+	if err != nil {
+		t.Fatalf("Encode() unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte{0x9F, 0x27, 0x01, 0x80, 0x95, 0x05}) {
+		t.Errorf("Encode() = %X..., want 9F270180 9505...", data[:8])
+	}
+	if !bytes.Contains(data, []byte{0x71, 0x81, 0xC8}) {
+		t.Error("Encode() did not use the 0x81 long length form for 200 bytes")
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+	if len(decoded) != 4 || decoded[0].Tag != "9F27" || decoded[3].Tag != "9C" || len(decoded[3].Value) != 0 {
+		t.Fatalf("Decode() = %+v, want 4 objects 9F27..9C", decoded)
+	}
+	if len(decoded[2].Children) != 1 || decoded[2].Children[0].Tag != "86" || len(decoded[2].Children[0].Value) != 197 {
+		t.Errorf("Decode() children of 71 = %+v, want one 86 of 197 bytes", decoded[2].Children)
+	}
+}
+
+func TestDecodeHex(t *testing.T) {
+	// Constructed template with padding, lower case and whitespace
+	objects, err := DecodeHex("70 10 5a08 4000000000000002 5F24 03 271231 00 00")
+	if err != nil {
+		t.Fatalf("DecodeHex() unexpected error: %v", err)
+	}
+
+	pan, ok := Find(objects, "5a")
+	if !ok || pan.Name() != "Application PAN" || pan.Value[7] != 0x02 {
+		t.Errorf("Find(5A) = %+v, %v; want the PAN inside template 70", pan, ok)
+	}
+	if expiry, ok := Find(objects, "5F24"); !ok || len(expiry.Value) != 3 {
+		t.Errorf("Find(5F24) = %+v, %v; want a 3-byte expiry", expiry, ok)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name, input, wantErr string
+	}{
+		{"Not hex", "9F27G1", "hex"},
+		{"Truncated tag", "9F", "truncated tag"},
+		{"Missing length", "9F27", "missing length"},
+		{"Truncated value", "9F270280", "truncated"},
+		{"Unsupported length", "9F278400000001", "unsupported length"},
+		{"Bad child", "7003" + "9F2705", "tag 70"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeHex(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeHex(%s) error = %v, want error containing %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeInvalidTag(t *testing.T) {
+	for _, tag := range []string{"", "9F", "9F8", "ZZ", "5F80", "9F2727"} {
+		if _, err := Encode([]TLV{{Tag: tag}}); err == nil {
+			t.Errorf("Encode(tag %q) expected error but got none", tag)
+		}
+	}
+	if _, err := Encode([]TLV{{Tag: "5F8101"}}); err != nil {
+		t.Errorf("Encode(tag 5F8101) unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// rangeBounds parses a BIN range into numeric bounds
+func rangeBounds(r models.BINRange) (start, end int64, err error) {
+	if len(r.Start) != len(r.End) || r.Start == "" {
+		return 0, 0, fmt.Errorf("BIN range %s-%s: start and end must have the same number of digits", r.Start, r.End)
+	}
+
+	start, err = strconv.ParseInt(r.Start, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("BIN range %s-%s: invalid start", r.Start, r.End)
+	}
+	end, err = strconv.ParseInt(r.End, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("BIN range %s-%s: invalid end", r.Start, r.End)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("BIN range %s-%s: end before start", r.Start, r.End)
+	}
+
+	return start, end, nil
+}
+
+// rangeContains reports whether a BIN (or longer prefix) falls within the range
+func rangeContains(r models.BINRange, bin string) bool {
+	if len(bin) < len(r.Start) {
+		return false
+	}
+
+	start, end, err := rangeBounds(r)
+	if err != nil {
+		return false
+	}
+
+	prefix, err := strconv.ParseInt(bin[:len(r.Start)], 10, 64)
+	return err == nil && prefix >= start && prefix <= end
+}
+
+// findRange returns the brand's BIN range containing bin
+func findRange(brand models.CardBrand, bin string) (models.BINRange, bool) {
+	for _, r := range brand.BINRanges {
+		if rangeContains(r, bin) {
+			return r, true
+		}
+	}
+	return models.BINRange{}, false
+}
+
+// pickBIN selects a BIN uniformly across all of the brand's ranges
+//
+// DESIGN RATIONALE:
+// - Ranges are weighted by size, so every BIN in the brand is equally likely
+// - e.g. Mastercard yields 2-series BINs in proportion to the 222100-272099 range
+func pickBIN(rng Random, ranges []models.BINRange) (string, models.BINRange, error) {
+	var total int64
+	for _, r := range ranges {
+		start, end, err := rangeBounds(r)
+		if err != nil {
+			return "", models.BINRange{}, err
+		}
+		total += end - start + 1
+	}
+	if total == 0 {
+		return "", models.BINRange{}, fmt.Errorf("brand has no BIN ranges")
+	}
+
+	pick := int64(rng.IntN(int(total)))
+	for _, r := range ranges {
+		start, end, _ := rangeBounds(r)
+		if size := end - start + 1; pick >= size {
+			pick -= size
+			continue
+		}
+		return fmt.Sprintf("%0*d", len(r.Start), start+pick), r, nil
+	}
+
+	return "", models.BINRange{}, fmt.Errorf("failed to pick BIN")
+}
+
+// selectBIN returns the BIN to generate under: the requested one (which must belong to the brand),
+// a BIN database record matching the country/card type filters, or one picked across all brand ranges
+func selectBIN(rng Random, brandKey string, brand models.CardBrand, opts models.GenerateOptions) (string, models.BINRange, error) {
+	switch {
+	case opts.BIN != "":
+		r, ok := findRange(brand, opts.BIN)
+		if !ok {
+			return "", models.BINRange{}, fmt.Errorf("BIN %s does not belong to %s", opts.BIN, brand.Name)
+		}
+		return opts.BIN, r, nil
+	case opts.Country != "" || opts.CardType != "":
+		return pickRecordBIN(rng, brandKey, brand, opts)
+	default:
+		return pickBIN(rng, brand.BINRanges)
+	}
+}
+
+// resolveLength returns the PAN length for a range: the requested length if allowed by the brand
+// and the range, else the range's own length, else the brand's first length
+func resolveLength(brand models.CardBrand, r models.BINRange, requested int) (int, error) {
+	if requested == 0 {
+		if r.Length != 0 {
+			return r.Length, nil
+		}
+		if len(r.Lengths) > 0 {
+			return r.Lengths[0], nil
+		}
+		return brand.PANLength[0], nil
+	}
+
+	if !containsInt(brand.PANLength, requested) {
+		return 0, fmt.Errorf("%s does not issue %d-digit PANs (allowed: %v)", brand.Name, requested, brand.PANLength)
+	}
+	if !rangeIssues(r, requested) {
+		return 0, fmt.Errorf("%s BIN range %s-%s does not issue %d-digit PANs (allowed: %v)", brand.Name, r.Start, r.End, requested, r.Lengths)
+	}
+	return requested, nil
+}
+
+// rangeIssues reports whether a range issues PANs of a length (0 for the default length)
+func rangeIssues(r models.BINRange, length int) bool {
+	return length == 0 || len(r.Lengths) == 0 || containsInt(r.Lengths, length)
+}
+
+// rangeSpan returns the number of 6-digit-normalized BINs covered by a range (for narrowest-match)
+func rangeSpan(r models.BINRange) int64 {
+	start, end, err := rangeBounds(r)
+	if err != nil {
+		return 0
+	}
+
+	span := end - start + 1
+	for width := len(r.Start); width < 8; width++ {
+		span *= 10
+	}
+	return span
+}
+
+// DetectBrand returns the CardBrands key for a PAN or BIN prefix
+//
+// When ranges of several brands contain the PAN (e.g. Elo 401178 inside Visa 4),
+// the narrowest range wins.
+func DetectBrand(pan string) (string, bool) {
+	best, bestSpan := "", int64(0)
+	for _, key := range BrandNames() {
+		for _, r := range CardBrands[key].BINRanges {
+			if !rangeContains(r, pan) {
+				continue
+			}
+			if span := rangeSpan(r); best == "" || span < bestSpan {
+				best, bestSpan = key, span
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// ValidatePAN checks a PAN's length and check digit, skipping Luhn for non-Luhn ranges
+func ValidatePAN(pan string) bool {
+	if ValidateLuhn(pan) {
+		return true
+	}
+
+	key, ok := DetectBrand(pan)
+	if !ok || len(pan) < 12 || len(pan) > 19 {
+		return false
+	}
+	for _, r := range CardBrands[key].BINRanges {
+		if r.NonLuhn && rangeContains(r, pan) {
+			_, err := strconv.ParseUint(pan, 10, 64)
+			return err == nil
+		}
+	}
+	return false
+}
+
+// BrandNames returns the CardBrands keys in alphabetical order
+func BrandNames() []string {
+	names := make([]string, 0, len(CardBrands))
+	for name := range CardBrands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,260 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// BrandsFile is the JSON document accepted by LoadBrands
+//
+// Example:
+//
+//	{
+//	  "brands": {
+//	    "acmebank": {
+//	      "name": "Acme Bank Debit",
+//	      "bin_ranges": [{"start": "99990000", "end": "99990999", "length": 16}],
+//	      "pan_length": [16],
+//	      "cvc_length": 3,
+//	      "service_code": "201",
+//	      "issuer_name": "Acme Bank",
+//	      "country": "BR",
+//	      "product_type": "debit"
+//	    }
+//	  }
+//	}
+type BrandsFile struct {
+	Brands map[string]models.CardBrand `json:"brands"`
+}
+
+// productTypes are the accepted CardBrand.ProductType values
+var productTypes = map[string]bool{"credit": true, "debit": true, "prepaid": true, "combo": true}
+
+// LoadBrands reads and validates brand definitions from a JSON file
+func LoadBrands(path string) (map[string]models.CardBrand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file BrandsFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse brands file %s: %w", path, err)
+	}
+	if len(file.Brands) == 0 {
+		return nil, fmt.Errorf("brands file %s defines no brands", path)
+	}
+
+	for key, brand := range file.Brands {
+		if err := ValidateBrand(key, brand); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := CheckOverlaps(file.Brands); err != nil {
+		return nil, err
+	}
+
+	return file.Brands, nil
+}
+
+// RegisterBrands adds or replaces entries in CardBrands
+// The merged catalog is checked for overlaps first; on error CardBrands is unchanged.
+func RegisterBrands(brands map[string]models.CardBrand) error {
+	merged := make(map[string]models.CardBrand, len(CardBrands)+len(brands))
+	for key, brand := range CardBrands {
+		merged[key] = brand
+	}
+	for key, brand := range brands {
+		if err := ValidateBrand(key, brand); err != nil {
+			return err
+		}
+		merged[key] = brand
+	}
+
+	if err := CheckOverlaps(merged); err != nil {
+		return err
+	}
+
+	CardBrands = merged
+	return nil
+}
+
+// ValidateBrand checks a brand definition against the schema
+func ValidateBrand(key string, brand models.CardBrand) error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("brand %s: %s", key, fmt.Sprintf(format, args...))
+	}
+
+	if key == "" || !isLowerKey(key) {
+		return fmt.Errorf("brand key %q must be lowercase letters, digits, '-' or '_'", key)
+	}
+	if brand.Name == "" {
+		return fail("name is required")
+	}
+	if len(brand.BINRanges) == 0 {
+		return fail("at least one BIN range is required")
+	}
+	if len(brand.PANLength) == 0 {
+		return fail("pan_length is required")
+	}
+	for _, length := range brand.PANLength {
+		if length < 12 || length > 19 {
+			return fail("PAN length %d outside 12-19", length)
+		}
+	}
+	if brand.CVCLength != 3 && brand.CVCLength != 4 {
+		return fail("cvc_length must be 3 or 4")
+	}
+	if len(brand.ServiceCode) != 3 || !isDigitString(brand.ServiceCode) {
+		return fail("service_code must be 3 digits")
+	}
+	if brand.Country != "" && (len(brand.Country) != 2 || brand.Country[0] < 'A' || brand.Country[0] > 'Z' || brand.Country[1] < 'A' || brand.Country[1] > 'Z') {
+		return fail("country must be an ISO 3166-1 alpha-2 code (e.g. BR)")
+	}
+	if brand.ProductType != "" && !productTypes[brand.ProductType] {
+		return fail("product_type must be credit, debit, prepaid or combo")
+	}
+
+	for _, r := range brand.BINRanges {
+		// Range starts are whole BINs: a PAN is generated under one of them
+		if !isDigitString(r.Start) || len(r.Start) < 6 || len(r.Start) > 8 {
+			return fail("BIN range start %q must be 6-8 digits", r.Start)
+		}
+		if _, _, err := rangeBounds(r); err != nil {
+			return fail("%v", err)
+		}
+		if r.Length != 0 && !containsInt(brand.PANLength, r.Length) {
+			return fail("BIN range %s-%s length %d not in pan_length", r.Start, r.End, r.Length)
+		}
+		for _, length := range r.Lengths {
+			if !containsInt(brand.PANLength, length) {
+				return fail("BIN range %s-%s length %d not in pan_length", r.Start, r.End, length)
+			}
+		}
+		if r.Length != 0 && len(r.Lengths) > 0 && !containsInt(r.Lengths, r.Length) {
+			return fail("BIN range %s-%s length %d not in its lengths", r.Start, r.End, r.Length)
+		}
+	}
+
+	if brand.TokenBIN != "" {
+		if !isDigitString(brand.TokenBIN) || len(brand.TokenBIN) < 6 || len(brand.TokenBIN) > 8 {
+			return fail("token_bin %q must be 6-8 digits", brand.TokenBIN)
+		}
+		inRange := false
+		for _, r := range brand.BINRanges {
+			if !r.NonLuhn && rangeContains(r, brand.TokenBIN) {
+				inRange = true
+			}
+		}
+		if !inRange {
+			return fail("token_bin %s not in a Luhn BIN range of the brand", brand.TokenBIN)
+		}
+	}
+
+	return nil
+}
+
+// CheckOverlaps reports BIN ranges that overlap ambiguously
+//
+// Ranges of different brands may nest (the narrower range wins in DetectBrand),
+// but identical or partially overlapping ranges are rejected, as are overlapping
+// ranges within the same brand.
+func CheckOverlaps(brands map[string]models.CardBrand) error {
+	type span struct {
+		brand      string
+		r          models.BINRange
+		start, end int64
+	}
+
+	var spans []span
+	for key, brand := range brands {
+		for _, r := range brand.BINRanges {
+			start, end, err := normalizedBounds(r)
+			if err != nil {
+				return fmt.Errorf("brand %s: %w", key, err)
+			}
+			spans = append(spans, span{brand: key, r: r, start: start, end: end})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		if spans[i].end != spans[j].end {
+			return spans[i].end > spans[j].end
+		}
+		return spans[i].brand < spans[j].brand
+	})
+
+	for i := range spans {
+		for j := i + 1; j < len(spans) && spans[j].start <= spans[i].end; j++ {
+			a, b := spans[i], spans[j]
+			describe := fmt.Sprintf("%s %s-%s and %s %s-%s", a.brand, a.r.Start, a.r.End, b.brand, b.r.Start, b.r.End)
+
+			switch {
+			case a.brand == b.brand:
+				return fmt.Errorf("overlapping BIN ranges within brand: %s", describe)
+			case a.start == b.start && a.end == b.end:
+				return fmt.Errorf("identical BIN ranges: %s", describe)
+			case b.end > a.end:
+				return fmt.Errorf("partially overlapping BIN ranges: %s", describe)
+			}
+			// Otherwise b nests inside a, which is allowed
+		}
+	}
+
+	return nil
+}
+
+// normalizedBounds scales a range to 8-digit bounds so ranges of different widths compare
+func normalizedBounds(r models.BINRange) (int64, int64, error) {
+	start, end, err := rangeBounds(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	scale := int64(1)
+	for width := len(r.Start); width < 8; width++ {
+		scale *= 10
+	}
+	return start * scale, end*scale + scale - 1, nil
+}
+
+func isLowerKey(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigitString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func writeBrandsFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "brands.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write brands file: %v", err)
+	}
+	return path
+}
+
+func TestBuiltinBrandsHaveNoOverlaps(t *testing.T) {
+	for _, key := range BrandNames() {
+		if err := ValidateBrand(key, CardBrands[key]); err != nil {
+			t.Errorf("ValidateBrand() unexpected error: %v", err)
+		}
+	}
+	if err := CheckOverlaps(CardBrands); err != nil {
+		t.Errorf("CheckOverlaps(CardBrands) unexpected error: %v", err)
+	}
+}
+
+func TestLoadBrands(t *testing.T) {
+	brand := `"name": "Acme Debit", "pan_length": [16], "cvc_length": 3, "service_code": "201"`
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"Valid 8-digit BIN", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "99990000", "end": "99990999", "length": 16}], "country": "BR", "product_type": "debit"}}}`, ""},
+		{"With token BIN", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999909"}], "token_bin": "99990901"}}}`, ""},
+		{"Nested in another brand", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "41234500", "end": "41234599"}]}}}`, ""},
+		{"Unknown field", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999909"}], "colour": "red"}}}`, "unknown field"},
+		{"Missing name", `{"brands": {"acme": {"bin_ranges": [{"start": "999900", "end": "999909"}], "pan_length": [16], "cvc_length": 3, "service_code": "201"}}}`, "name is required"},
+		{"Bad country", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999909"}], "country": "Brazil"}}}`, "country"},
+		{"Range length not allowed", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999909", "length": 19}]}}}`, "not in pan_length"},
+		{"4-digit BIN", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "9999", "end": "9999"}]}}}`, "must be 6-8 digits"},
+		{"Token BIN outside ranges", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999909"}], "token_bin": "999910"}}}`, "token_bin 999910 not in"},
+		{"End before start", `{"brands": {"acme": {` + brand + `, "bin_ranges": [{"start": "999909", "end": "999900"}]}}}`, "end before start"},
+		{"Partial overlap", `{"brands": {"a": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999950"}]}, "b": {` + brand + `, "bin_ranges": [{"start": "999940", "end": "999990"}]}}}`, "partially overlapping"},
+		{"Identical ranges", `{"brands": {"a": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999950"}]}, "b": {` + brand + `, "bin_ranges": [{"start": "999900", "end": "999950"}]}}}`, "identical"},
+		{"No brands", `{"brands": {}}`, "defines no brands"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brands, err := LoadBrands(writeBrandsFile(t, tt.content))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadBrands() unexpected error: %v", err)
+				}
+				// Every brand that loads must also generate
+				original := CardBrands
+				t.Cleanup(func() { CardBrands = original })
+				if err := RegisterBrands(brands); err != nil {
+					t.Fatalf("RegisterBrands() unexpected error: %v", err)
+				}
+				for key, brand := range brands {
+					card, err := NewGenerator(1).Card(models.GenerateOptions{Brand: key})
+					if err != nil || !ValidatePAN(card.PAN) {
+						t.Errorf("generating from loaded brand %s (%s) = %v, %v; want a valid card", key, brand.Name, card, err)
+					}
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadBrands() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterBrands(t *testing.T) {
+	original := CardBrands
+	t.Cleanup(func() { CardBrands = original })
+
+	acme := models.CardBrand{
+		Name:        "Acme Debit",
+		BINRanges:   []models.BINRange{{Start: "41234500", End: "41234599", Length: 16}},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "201",
+		IssuerName:  "Acme Bank",
+		Country:     "BR",
+		ProductType: "debit",
+	}
+	if err := RegisterBrands(map[string]models.CardBrand{"acme": acme}); err != nil {
+		t.Fatalf("RegisterBrands() unexpected error: %v", err)
+	}
+
+	card, err := GenerateCard(models.GenerateOptions{Brand: "acme"})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(card.PAN, "412345") || card.IssuerName != "Acme Bank" || card.Country != "BR" || card.ProductType != "debit" {
+		t.Errorf("GenerateCard() = %+v, want Acme card", card)
+	}
+	if brand, _ := DetectBrand(card.PAN); brand != "acme" {
+		t.Errorf("DetectBrand(%s) = %s, want acme (narrowest range)", card.MaskedPAN, brand)
+	}
+
+	// Overriding a brand with a range that partially overlaps another is rejected atomically
+	clash := acme
+	clash.BINRanges = []models.BINRange{{Start: "509500", End: "510500", Length: 16}}
+	if err := RegisterBrands(map[string]models.CardBrand{"acme": clash}); err == nil {
+		t.Error("RegisterBrands() with overlapping range expected error but got none")
+	}
+	if CardBrands["acme"].BINRanges[0].Start != "41234500" {
+		t.Error("RegisterBrands() changed CardBrands despite an error")
+	}
+}
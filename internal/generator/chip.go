@@ -0,0 +1,25 @@
+package generator
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+// DefaultPANSequence is the PAN sequence number (PSN, DE23) of generated chip cards
+const DefaultPANSequence = "00"
+
+// DeriveICCMasterKey derives a card's ICC master key (hex) from a hex issuer master key
+func DeriveICCMasterKey(pan, psn, imkHex string) (string, error) {
+	imk, err := cardcrypto.ParseKey(imkHex)
+	if err != nil {
+		return "", err
+	}
+
+	mk, err := cardcrypto.DeriveICCMasterKey(imk, pan, psn)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(mk)), nil
+}
@@ -0,0 +1,33 @@
+package generator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestGenerateCardIMK(t *testing.T) {
+	const imk = "0123456789ABCDEFFEDCBA9876543210"
+
+	card, err := GenerateCard(models.GenerateOptions{Brand: "mastercard", IMK: imk})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+
+	want, _ := DeriveICCMasterKey(card.PAN, DefaultPANSequence, imk)
+	if card.PANSequence != DefaultPANSequence || card.ICCMasterKey != want || len(want) != 32 {
+		t.Errorf("GenerateCard() PSN/MK = %q/%q, want %q/%q", card.PANSequence, card.ICCMasterKey, DefaultPANSequence, want)
+	}
+
+	// The master key never leaves the process in card output
+	data, _ := json.Marshal(card)
+	if strings.Contains(string(data), card.ICCMasterKey) {
+		t.Error("JSON output contains the ICC master key")
+	}
+
+	if _, err := GenerateCard(models.GenerateOptions{Brand: "visa", IMK: "0123"}); err == nil {
+		t.Error("GenerateCard() with short IMK expected error but got none")
+	}
+}
@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+// CVKCodes are the card verification values derived from a CVK
+type CVKCodes struct {
+	CVV  string // Magnetic stripe, with the card's service code
+	CVV2 string // Printed on the card (service code 000)
+	ICVV string // Chip track equivalent data (service code 999)
+}
+
+// ComputeCVKCodes computes CVV, CVV2 and iCVV with a hex CVK-A || CVK-B key pair
+func ComputeCVKCodes(pan string, month, year int, serviceCode, cvkHex string) (CVKCodes, error) {
+	cvk, err := cardcrypto.ParseCVK(cvkHex)
+	if err != nil {
+		return CVKCodes{}, err
+	}
+
+	expiry := fmt.Sprintf("%02d%02d", year%100, month)
+
+	var codes CVKCodes
+	if codes.CVV, err = cvk.CVV(pan, expiry, serviceCode); err != nil {
+		return CVKCodes{}, err
+	}
+	if codes.CVV2, err = cvk.CVV2(pan, expiry); err != nil {
+		return CVKCodes{}, err
+	}
+	if codes.ICVV, err = cvk.ICVV(pan, expiry); err != nil {
+		return CVKCodes{}, err
+	}
+	return codes, nil
+}
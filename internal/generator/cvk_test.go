@@ -0,0 +1,40 @@
+package generator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestGenerateCardCVK(t *testing.T) {
+	const cvkHex = "0123456789ABCDEFFEDCBA9876543210"
+	cvk, _ := cardcrypto.ParseCVK(cvkHex)
+
+	card, err := GenerateCard(models.GenerateOptions{Brand: "visa", CVK: cvkHex, IncludeTrack2: true})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+
+	expiry := fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth)
+	cvv, _ := cvk.CVV(card.PAN, expiry, CardBrands["visa"].ServiceCode)
+	cvv2, _ := cvk.CVV2(card.PAN, expiry)
+	icvv, _ := cvk.ICVV(card.PAN, expiry)
+	if card.CVV != cvv || card.CVC != cvv2 || card.ICVV != icvv {
+		t.Errorf("GenerateCard() CVV/CVC/iCVV = %s/%s/%s, want %s/%s/%s", card.CVV, card.CVC, card.ICVV, cvv, cvv2, icvv)
+	}
+
+	// Amex CSC is a different algorithm: keep the 4-digit HMAC CVC, no CVV/iCVV
+	amex, err := GenerateCard(models.GenerateOptions{Brand: "amex", CVK: cvkHex, Secret: "cvk-test-secret"})
+	if err != nil {
+		t.Fatalf("GenerateCard(amex) unexpected error: %v", err)
+	}
+	if len(amex.CVC) != 4 || amex.CVV != "" || amex.ICVV != "" {
+		t.Errorf("GenerateCard(amex) CVC/CVV/iCVV = %q/%q/%q, want 4-digit CVC only", amex.CVC, amex.CVV, amex.ICVV)
+	}
+
+	if _, err := GenerateCard(models.GenerateOptions{Brand: "visa", CVK: "0123"}); err == nil {
+		t.Error("GenerateCard() with short CVK expected error but got none")
+	}
+}
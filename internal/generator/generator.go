@@ -2,20 +2,22 @@ package generator
 
 import (
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
 )
 
 // CardBrands defines well-known card brand configurations
 // These are TEST BINs and ranges - DO NOT USE IN PRODUCTION
+//
+// Ranges of different brands may nest (e.g. Elo BINs inside the Visa 4-series);
+// DetectBrand resolves them to the narrowest range.
 var CardBrands = map[string]models.CardBrand{
 	"visa": {
 		Name: "Visa",
@@ -46,18 +48,173 @@ var CardBrands = map[string]models.CardBrand{
 		CVCLength:   4,
 		ServiceCode: "201",
 	},
+	"elo": {
+		Name: "Elo",
+		BINRanges: []models.BINRange{
+			{Start: "401178", End: "401179", Length: 16},
+			{Start: "431274", End: "431274", Length: 16},
+			{Start: "438935", End: "438935", Length: 16},
+			{Start: "451416", End: "451416", Length: 16},
+			{Start: "457393", End: "457393", Length: 16},
+			{Start: "457631", End: "457632", Length: 16},
+			{Start: "504175", End: "504175", Length: 16},
+			{Start: "506699", End: "506778", Length: 16},
+			{Start: "509000", End: "509999", Length: 16},
+			{Start: "627780", End: "627780", Length: 16},
+			{Start: "636297", End: "636297", Length: 16},
+			{Start: "636368", End: "636368", Length: 16},
+			{Start: "650031", End: "650033", Length: 16},
+			{Start: "650035", End: "650051", Length: 16},
+			{Start: "650405", End: "650439", Length: 16},
+			{Start: "650485", End: "650538", Length: 16},
+			{Start: "650541", End: "650598", Length: 16},
+			{Start: "650700", End: "650718", Length: 16},
+			{Start: "650720", End: "650727", Length: 16},
+			{Start: "650901", End: "650978", Length: 16},
+			{Start: "651652", End: "651679", Length: 16},
+			{Start: "655000", End: "655019", Length: 16},
+			{Start: "655021", End: "655058", Length: 16},
+		},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"hipercard": {
+		Name: "Hipercard",
+		BINRanges: []models.BINRange{
+			{Start: "606282", End: "606282", Length: 16},
+			{Start: "384100", End: "384100", Length: 16},
+			{Start: "384140", End: "384140", Length: 16},
+			{Start: "384160", End: "384160", Length: 16},
+		},
+		PANLength:   []int{13, 16, 19},
+		CVCLength:   3,
+		ServiceCode: "501", // Domestic use only
+	},
+	"hiper": {
+		Name: "Hiper",
+		BINRanges: []models.BINRange{
+			{Start: "637095", End: "637095", Length: 16},
+			{Start: "637568", End: "637568", Length: 16},
+			{Start: "637599", End: "637599", Length: 16},
+			{Start: "637609", End: "637609", Length: 16},
+			{Start: "637612", End: "637612", Length: 16},
+		},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "501",
+	},
+	"cabal": {
+		Name: "Cabal",
+		BINRanges: []models.BINRange{
+			{Start: "589657", End: "589657", Length: 16},
+			{Start: "600691", End: "600691", Length: 16},
+			{Start: "603522", End: "603522", Length: 16},
+			{Start: "604201", End: "604219", Length: 16},
+			{Start: "604400", End: "604499", Length: 16},
+			{Start: "627170", End: "627170", Length: 16},
+		},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "501",
+	},
+	"discover": {
+		Name: "Discover",
+		BINRanges: []models.BINRange{
+			{Start: "601100", End: "601199", Length: 16},
+			{Start: "644000", End: "649999", Length: 16},
+			{Start: "650000", End: "659999", Length: 16},
+		},
+		PANLength:   []int{16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"jcb": {
+		Name: "JCB",
+		BINRanges: []models.BINRange{
+			{Start: "352800", End: "358999", Length: 16},
+		},
+		PANLength:   []int{16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"diners": {
+		Name: "Diners Club",
+		BINRanges: []models.BINRange{
+			{Start: "300000", End: "305999", Length: 14},
+			{Start: "309500", End: "309599", Length: 14},
+			{Start: "360000", End: "369999", Length: 14},
+			{Start: "380000", End: "399999", Length: 16},
+		},
+		PANLength:   []int{14, 15, 16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"unionpay": {
+		Name: "UnionPay",
+		BINRanges: []models.BINRange{
+			{Start: "620000", End: "621699", Length: 16},
+			{Start: "621700", End: "621799", Length: 19, Lengths: []int{19}, NonLuhn: true}, // Legacy debit, no Luhn check digit
+			{Start: "621800", End: "629999", Length: 16},
+			{Start: "810000", End: "817199", Length: 16},
+		},
+		PANLength:   []int{16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"maestro": {
+		Name: "Maestro",
+		BINRanges: []models.BINRange{
+			{Start: "501800", End: "501899", Length: 16},
+			{Start: "502000", End: "502099", Length: 16},
+			{Start: "503800", End: "503899", Length: 16},
+			{Start: "589300", End: "589399", Length: 16},
+			{Start: "630400", End: "630499", Length: 16},
+			{Start: "675900", End: "675999", Length: 16},
+			{Start: "676100", End: "676399", Length: 16},
+		},
+		PANLength:   []int{12, 13, 14, 15, 16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "201",
+	},
+	"rupay": {
+		Name: "RuPay",
+		BINRanges: []models.BINRange{
+			{Start: "508500", End: "508999", Length: 16},
+			{Start: "606985", End: "607984", Length: 16},
+			{Start: "608001", End: "608500", Length: 16},
+			{Start: "652150", End: "653149", Length: 16},
+		},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "501",
+	},
+	"mir": {
+		Name: "Mir",
+		BINRanges: []models.BINRange{
+			{Start: "220000", End: "220499", Length: 16},
+		},
+		PANLength:   []int{16, 17, 18, 19},
+		CVCLength:   3,
+		ServiceCode: "501",
+	},
 }
 
 // GeneratePAN generates a valid PAN using Luhn algorithm
 // BIN: Bank Identification Number (first 6 digits)
-// length: total PAN length (13-19 for most cards, 15 for Amex)
+// length: total PAN length (12-19; 15 for Amex, 12+ for Maestro)
 func GeneratePAN(bin string, length int) (string, error) {
+	return generatePAN(cryptoRandom{}, bin, length, false)
+}
+
+// generatePAN builds bin + random digits + check digit; nonLuhn uses a random final digit instead
+func generatePAN(rng Random, bin string, length int, nonLuhn bool) (string, error) {
 	if len(bin) < 6 {
 		return "", fmt.Errorf("BIN must be at least 6 digits")
 	}
 
-	if length < 13 || length > 19 {
-		return "", fmt.Errorf("PAN length must be between 13 and 19")
+	if length < 12 || length > 19 {
+		return "", fmt.Errorf("PAN length must be between 12 and 19")
 	}
 
 	// Calculate how many random digits we need (excluding check digit)
@@ -68,43 +225,33 @@ func GeneratePAN(bin string, length int) (string, error) {
 	}
 
 	// Generate random middle digits
-	randomPart := generateRandomDigits(randomDigitsNeeded)
+	randomPart := randomDigits(rng, randomDigitsNeeded)
 
 	// Construct partial PAN (BIN + random digits)
 	partialPAN := bin + randomPart
 
+	if nonLuhn {
+		return partialPAN + randomDigits(rng, 1), nil
+	}
+
 	// Append Luhn check digit
 	fullPAN := AppendLuhnCheckDigit(partialPAN)
 
 	return fullPAN, nil
 }
 
-// generateRandomDigits generates n random digits
-func generateRandomDigits(n int) string {
-	if n <= 0 {
-		return ""
-	}
-
-	digits := make([]byte, n)
-	for i := 0; i < n; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(10))
-		digits[i] = byte('0' + num.Int64())
-	}
-	return string(digits)
-}
-
 // GenerateExpiry generates a plausible expiry date
 // Returns month (1-12) and year (current year + 1 to current year + 5)
 func GenerateExpiry() (month int, year int) {
-	now := time.Now()
-	
+	return generateExpiry(cryptoRandom{}, time.Now())
+}
+
+func generateExpiry(rng Random, now time.Time) (month int, year int) {
 	// Random month
-	monthBig, _ := rand.Int(rand.Reader, big.NewInt(12))
-	month = int(monthBig.Int64()) + 1
+	month = rng.IntN(12) + 1
 
 	// Random year offset (1-5 years in the future)
-	yearOffsetBig, _ := rand.Int(rand.Reader, big.NewInt(5))
-	year = now.Year() + int(yearOffsetBig.Int64()) + 1
+	year = now.Year() + rng.IntN(5) + 1
 
 	return month, year
 }
@@ -186,13 +333,17 @@ func GenerateDeterministicCVC(pan, expMonth, expYear, secret string) (string, er
 // - Discretionary data is random (used by issuers for various purposes)
 // - This is a SIMPLIFIED version for testing; real Track2 has more fields
 func GenerateTrack2(pan string, month, year int, serviceCode string) string {
+	return generateTrack2(cryptoRandom{}, pan, month, year, serviceCode)
+}
+
+func generateTrack2(rng Random, pan string, month, year int, serviceCode string) string {
 	// Format expiry as YYMM
 	yy := year % 100
 	expiry := fmt.Sprintf("%02d%02d", yy, month)
 
 	// Generate random discretionary data (3-5 digits)
 	discretionaryLength := 4
-	discretionary := generateRandomDigits(discretionaryLength)
+	discretionary := randomDigits(rng, discretionaryLength)
 
 	// Track2 format: PAN=YYMM<ServiceCode><Discretionary>
 	track2 := fmt.Sprintf("%s=%s%s%s", pan, expiry, serviceCode, discretionary)
@@ -215,38 +366,83 @@ func MaskPAN(pan string) string {
 }
 
 // GenerateCard generates a complete card with all data
+// With opts.Seed set, the same options always yield the same card
 func GenerateCard(opts models.GenerateOptions) (*models.Card, error) {
+	return NewGenerator(opts.Seed).Card(opts)
+}
+
+// GenerateCards generates opts.Count cards from a single random source
+// With opts.Seed set, the same options always yield the same sequence of cards
+func GenerateCards(opts models.GenerateOptions) ([]*models.Card, error) {
+	g := NewGenerator(opts.Seed)
+
+	cards := make([]*models.Card, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		card, err := g.Card(opts)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// Card generates the next card from the generator's random source and clock
+func (g *Generator) Card(opts models.GenerateOptions) (*models.Card, error) {
 	// Determine brand config
-	brandConfig, ok := CardBrands[strings.ToLower(opts.Brand)]
+	brandKey := strings.ToLower(opts.Brand)
+	brandConfig, ok := CardBrands[brandKey]
 	if !ok {
 		return nil, fmt.Errorf("unknown brand: %s", opts.Brand)
 	}
 
-	// Use provided BIN or generate from brand
-	bin := opts.BIN
-	if bin == "" {
-		// Use first BIN range of brand
-		bin = brandConfig.BINRanges[0].Start
+	if opts.BIN != "" && (opts.Country != "" || opts.CardType != "") {
+		return nil, fmt.Errorf("a BIN cannot be combined with country or card type filters")
 	}
 
-	// Determine PAN length
-	panLength := brandConfig.PANLength[0]
-	if len(brandConfig.BINRanges) > 0 {
-		panLength = brandConfig.BINRanges[0].Length
-	}
+	// Pick the BIN and generate the PAN; unless the BIN was given, retry while the
+	// PAN lands in a range another brand nests inside this one (e.g. RuPay within
+	// Discover) or in a BIN database record that does not match the filters
+	var pan string
+	for attempt := 0; ; attempt++ {
+		bin, binRange, err := selectBIN(g.rng, brandKey, brandConfig, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ranges limited to other lengths (e.g. UnionPay 19-digit debit) are picked again
+		if opts.BIN == "" && !rangeIssues(binRange, opts.PANLength) && attempt < maxPickAttempts {
+			continue
+		}
+
+		// Determine PAN length
+		panLength, err := resolveLength(brandConfig, binRange, opts.PANLength)
+		if err != nil {
+			return nil, err
+		}
+
+		// Generate PAN
+		pan, err = generatePAN(g.rng, bin, panLength, binRange.NonLuhn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate PAN: %w", err)
+		}
 
-	// Generate PAN
-	pan, err := GeneratePAN(bin, panLength)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate PAN: %w", err)
+		if opts.BIN != "" || acceptPAN(brandKey, pan, opts) {
+			break
+		}
+		if attempt == maxPickAttempts {
+			return nil, fmt.Errorf("failed to generate a %s PAN matching the options", brandConfig.Name)
+		}
 	}
 
 	// Generate expiry
-	month, year := GenerateExpiry()
+	now := g.clock()
+	month, year := generateExpiry(g.rng, now)
 
 	// Generate CVC if secret provided
 	var cvc string
 	if opts.Secret != "" {
+		var err error
 		cvc, err = GenerateDeterministicCVC(
 			pan,
 			fmt.Sprintf("%02d", month),
@@ -258,6 +454,18 @@ func GenerateCard(opts models.GenerateOptions) (*models.Card, error) {
 		}
 	}
 
+	// With a CVK, compute real CVV/CVV2/iCVV; the CVV2 replaces the HMAC CVC.
+	// 4-digit codes (Amex CSC) use a different algorithm and keep the HMAC CVC.
+	var codes CVKCodes
+	if opts.CVK != "" && brandConfig.CVCLength == 3 {
+		var err error
+		codes, err = ComputeCVKCodes(pan, month, year, brandConfig.ServiceCode, opts.CVK)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute CVV: %w", err)
+		}
+		cvc = codes.CVV2
+	}
+
 	card := &models.Card{
 		PAN:         pan,
 		MaskedPAN:   MaskPAN(pan),
@@ -265,13 +473,57 @@ func GenerateCard(opts models.GenerateOptions) (*models.Card, error) {
 		ExpiryMonth: month,
 		ExpiryYear:  year,
 		CVC:         cvc,
-		GeneratedAt: time.Now(),
+		CVV:         codes.CVV,
+		ICVV:        codes.ICVV,
+		GeneratedAt: now,
 		Metadata:    opts.Metadata,
+		IssuerName:  brandConfig.IssuerName,
+		Country:     brandConfig.Country,
+		ProductType: brandConfig.ProductType,
 	}
 
-	// Generate Track2 if requested
-	if opts.IncludeTrack2 {
-		card.Track2 = GenerateTrack2(pan, month, year, brandConfig.ServiceCode)
+	// Prefer issuer details from the BIN database over the brand defaults
+	if record, ok := binRecord(brandKey, pan); ok {
+		card.IssuerName = firstNonEmpty(record.Issuer, card.IssuerName)
+		card.Country = firstNonEmpty(record.Country, card.Country)
+		card.ProductType = firstNonEmpty(record.Type, card.ProductType)
+		card.ProductTier = record.Tier
+	}
+
+	// With an IMK, personalize the chip: PSN and the ICC master key behind its ARQCs
+	if opts.IMK != "" {
+		mk, err := DeriveICCMasterKey(pan, DefaultPANSequence, opts.IMK)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive ICC master key: %w", err)
+		}
+		card.PANSequence, card.ICCMasterKey = DefaultPANSequence, mk
+	}
+
+	// With a PVK, derive the test PIN's PVV and offset; the PVV goes on the tracks
+	if opts.PVK != "" {
+		if err := addPINVerification(card, opts); err != nil {
+			return nil, fmt.Errorf("failed to compute PIN verification values: %w", err)
+		}
+	}
+
+	// Generate tracks if requested
+	if opts.IncludeTrack1 || opts.IncludeTrack2 {
+		fields := track.Fields{
+			PAN:         pan,
+			Name:        opts.CardholderName,
+			Expiry:      fmt.Sprintf("%02d%02d", year%100, month),
+			ServiceCode: brandConfig.ServiceCode,
+		}
+		if err := addTracks(g.rng, card, fields, codes, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Derive the test PIN and, with a ZPK or DUKPT keys, its PIN blocks
+	if opts.IncludePIN || opts.ZPK != "" || opts.BDK != "" || opts.PVK != "" {
+		if err := g.addPIN(card, opts); err != nil {
+			return nil, fmt.Errorf("failed to generate PIN: %w", err)
+		}
 	}
 
 	return card, nil
@@ -1,7 +1,11 @@
 package generator
 
 import (
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
 )
 
 func TestValidateLuhn(t *testing.T) {
@@ -91,7 +95,8 @@ func TestGeneratePAN(t *testing.T) {
 		{"Valid Mastercard", "510000", 16, false},
 		{"Valid Amex", "340000", 15, false},
 		{"BIN too short", "4000", 16, true},
-		{"Length too short", "400000", 12, true},
+		{"Valid Maestro 12", "501800", 12, false},
+		{"Length too short", "400000", 11, true},
 		{"Length too long", "400000", 20, true},
 		{"BIN longer than PAN", "40000000000000000", 16, true},
 	}
@@ -138,9 +143,10 @@ func TestGenerateExpiry(t *testing.T) {
 			t.Errorf("GenerateExpiry() month = %d, want 1-12", month)
 		}
 
-		// Should be in the future
-		if year < 2025 || year > 2030 {
-			t.Errorf("GenerateExpiry() year = %d, want 2025-2030", year)
+		// Should be 1-5 years in the future
+		currentYear := time.Now().Year()
+		if year < currentYear+1 || year > currentYear+5 {
+			t.Errorf("GenerateExpiry() year = %d, want %d-%d", year, currentYear+1, currentYear+5)
 		}
 	}
 }
@@ -279,3 +285,204 @@ func BenchmarkGenerateDeterministicCVC(b *testing.B) {
 		GenerateDeterministicCVC("4000000000000002", "12", "2027", "test-secret")
 	}
 }
+
+func TestSeededGeneration(t *testing.T) {
+	opts := models.GenerateOptions{
+		Brand:         "visa",
+		Count:         5,
+		Secret:        "seed-test-secret",
+		IncludeTrack2: true,
+		Seed:          42,
+	}
+
+	first, err := GenerateCards(opts)
+	if err != nil {
+		t.Fatalf("GenerateCards() unexpected error: %v", err)
+	}
+	second, _ := GenerateCards(opts)
+
+	if !reflect.DeepEqual(first, second) {
+		t.Error("GenerateCards() with the same seed produced different cards")
+	}
+
+	seen := map[string]bool{}
+	for i, card := range first {
+		if seen[card.PAN] {
+			t.Errorf("Seeded sequence repeated PAN %s", card.MaskedPAN)
+		}
+		seen[card.PAN] = true
+
+		want := SeedEpoch.Add(time.Duration(i) * time.Second)
+		if !card.GeneratedAt.Equal(want) {
+			t.Errorf("cards[%d].GeneratedAt = %v, want %v", i, card.GeneratedAt, want)
+		}
+		if card.ExpiryYear < SeedEpoch.Year()+1 || card.ExpiryYear > SeedEpoch.Year()+5 {
+			t.Errorf("cards[%d].ExpiryYear = %d, want relative to the seed epoch", i, card.ExpiryYear)
+		}
+	}
+
+	single, _ := GenerateCard(opts)
+	if !reflect.DeepEqual(single, first[0]) {
+		t.Error("GenerateCard() with a seed differs from the first card of GenerateCards()")
+	}
+
+	opts.Seed = 43
+	other, _ := GenerateCards(opts)
+	if other[0].PAN == first[0].PAN {
+		t.Error("Different seeds produced the same PAN")
+	}
+}
+
+func TestSeededGenerationStable(t *testing.T) {
+	// Pins the PCG stream: changing it breaks byte-for-byte fixture regeneration
+	card, err := GenerateCard(models.GenerateOptions{Brand: "mastercard", Seed: 2026, IncludeTrack2: true})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+
+	if card.Track2 != "5165847598574683=31042014547" {
+		t.Errorf("GenerateCard(seed 2026) Track2 = %s, want 5165847598574683=31042014547", card.Track2)
+	}
+}
+
+func TestGenerateCardBINRanges(t *testing.T) {
+	g := NewGenerator(7)
+
+	twoSeries := 0
+	for i := 0; i < 500; i++ {
+		card, err := g.Card(models.GenerateOptions{Brand: "mastercard"})
+		if err != nil {
+			t.Fatalf("Card() unexpected error: %v", err)
+		}
+		if _, ok := findRange(CardBrands["mastercard"], card.PAN); !ok {
+			t.Fatalf("PAN %s outside Mastercard ranges", card.MaskedPAN)
+		}
+		if card.PAN[0] == '2' {
+			twoSeries++
+		}
+	}
+
+	// 222100-272099 is 50000 of the 100000 Mastercard BINs
+	if twoSeries < 150 || twoSeries > 350 {
+		t.Errorf("2-series Mastercard PANs = %d of 500, want about half", twoSeries)
+	}
+}
+
+func TestGenerateCardOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      models.GenerateOptions
+		wantLen   int
+		shouldErr bool
+	}{
+		{"Visa default length", models.GenerateOptions{Brand: "visa"}, 16, false},
+		{"Visa 13 digits", models.GenerateOptions{Brand: "visa", PANLength: 13}, 13, false},
+		{"Visa 19 digits", models.GenerateOptions{Brand: "visa", PANLength: 19}, 19, false},
+		{"Amex default length", models.GenerateOptions{Brand: "amex"}, 15, false},
+		{"Mastercard 2-series BIN", models.GenerateOptions{Brand: "mastercard", BIN: "222100"}, 16, false},
+		{"Length not issued by brand", models.GenerateOptions{Brand: "mastercard", PANLength: 19}, 0, true},
+		{"UnionPay Luhn range 16 digits", models.GenerateOptions{Brand: "unionpay", PANLength: 16}, 16, false},
+		{"Length not issued by range", models.GenerateOptions{Brand: "unionpay", BIN: "621700", PANLength: 16}, 0, true},
+		{"BIN from another brand", models.GenerateOptions{Brand: "visa", BIN: "510000"}, 0, true},
+		{"BIN outside brand ranges", models.GenerateOptions{Brand: "mastercard", BIN: "560000"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := GenerateCard(tt.opts)
+			if tt.shouldErr {
+				if err == nil {
+					t.Errorf("GenerateCard(%+v) expected error but got none", tt.opts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateCard(%+v) unexpected error: %v", tt.opts, err)
+			}
+
+			if len(card.PAN) != tt.wantLen {
+				t.Errorf("PAN length = %d, want %d", len(card.PAN), tt.wantLen)
+			}
+			if !ValidateLuhn(card.PAN) {
+				t.Errorf("PAN %s failed Luhn check", card.MaskedPAN)
+			}
+		})
+	}
+}
+
+func TestDetectBrand(t *testing.T) {
+	tests := []struct {
+		pan   string
+		brand string
+	}{
+		{"4000000000000002", "visa"},
+		{"4011780000000000", "elo"}, // Elo BIN nested in the Visa 4-series
+		{"5100000000000016", "mastercard"},
+		{"2221000000000009", "mastercard"},
+		{"2200000000000004", "mir"},
+		{"340000000000009", "amex"},
+		{"6062820000000000", "hipercard"},
+		{"6370950000000000", "hiper"},
+		{"6042010000000000", "cabal"},
+		{"6011000000000004", "discover"},
+		{"6521500000000000", "rupay"}, // RuPay nested in Discover 65
+		{"3528000000000007", "jcb"},
+		{"30000000000004", "diners"},
+		{"6200000000000005", "unionpay"},
+		{"501800000000", "maestro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.brand, func(t *testing.T) {
+			brand, ok := DetectBrand(tt.pan)
+			if !ok || brand != tt.brand {
+				t.Errorf("DetectBrand(%s) = %q, %v; want %q", tt.pan, brand, ok, tt.brand)
+			}
+		})
+	}
+
+	if brand, ok := DetectBrand("9999999999999995"); ok {
+		t.Errorf("DetectBrand() of unknown PAN = %q, want no match", brand)
+	}
+}
+
+func TestGenerateCardAllBrands(t *testing.T) {
+	for _, name := range BrandNames() {
+		t.Run(name, func(t *testing.T) {
+			brand := CardBrands[name]
+			for _, length := range brand.PANLength {
+				card, err := GenerateCard(models.GenerateOptions{Brand: name, PANLength: length})
+				if err != nil {
+					t.Fatalf("GenerateCard(%s, %d) unexpected error: %v", name, length, err)
+				}
+				if len(card.PAN) != length {
+					t.Errorf("PAN length = %d, want %d", len(card.PAN), length)
+				}
+				if !ValidatePAN(card.PAN) {
+					t.Errorf("PAN %s failed validation", card.PAN)
+				}
+				if detected, _ := DetectBrand(card.PAN); CardBrands[detected].Name == "" {
+					t.Errorf("DetectBrand(%s) found no brand", card.PAN)
+				}
+			}
+		})
+	}
+}
+
+func TestNonLuhnRange(t *testing.T) {
+	card, err := GenerateCard(models.GenerateOptions{Brand: "unionpay", BIN: "621700", Seed: 1})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	if len(card.PAN) != 19 {
+		t.Errorf("Non-Luhn UnionPay PAN length = %d, want 19", len(card.PAN))
+	}
+	if !ValidatePAN(card.PAN) {
+		t.Errorf("ValidatePAN(%s) = false for non-Luhn range", card.PAN)
+	}
+
+	// Luhn is still enforced outside non-Luhn ranges
+	if ValidatePAN("6200000000000006") {
+		t.Error("ValidatePAN() accepted a UnionPay PAN with a bad check digit")
+	}
+}
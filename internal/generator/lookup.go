@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/bindb"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// maxPickAttempts bounds BIN/PAN retries when a PAN lands in a nested brand or a non-matching record
+const maxPickAttempts = 100
+
+// BINInfo describes a BIN: the BIN database record when there is one, else the brand's defaults
+type BINInfo struct {
+	bindb.Record
+	BrandName string `json:"brand_name,omitempty"`
+	Source    string `json:"source"` // "bindb" or "brand_range"
+}
+
+// LookupBIN describes a PAN or BIN prefix using the BIN database, falling back to brand ranges
+//
+// Returns bindb.ErrInvalidBIN for malformed input and bindb.ErrNotFound when
+// neither the database nor any brand range knows the BIN.
+func LookupBIN(panOrPrefix string) (BINInfo, error) {
+	digits, err := bindb.Normalize(panOrPrefix)
+	if err != nil {
+		return BINInfo{}, err
+	}
+
+	if record, err := bindb.Default().Lookup(digits); err == nil {
+		return BINInfo{Record: record, BrandName: CardBrands[record.Brand].Name, Source: "bindb"}, nil
+	}
+
+	key, ok := DetectBrand(digits)
+	if !ok {
+		return BINInfo{}, bindb.ErrNotFound
+	}
+
+	brand := CardBrands[key]
+	record := bindb.Record{
+		BIN:     digits[:min(len(digits), 8)],
+		Brand:   key,
+		Issuer:  brand.IssuerName,
+		Country: brand.Country,
+		Type:    brand.ProductType,
+	}
+	return BINInfo{Record: record, BrandName: brand.Name, Source: "brand_range"}, nil
+}
+
+// binRecord returns the BIN database record for a PAN, if it belongs to the brand
+func binRecord(brandKey, pan string) (bindb.Record, bool) {
+	record, err := bindb.Default().Lookup(pan)
+	if err != nil || record.Brand != brandKey {
+		return bindb.Record{}, false
+	}
+	return record, true
+}
+
+// acceptPAN reports whether a generated PAN is detected as the brand and matches the filters
+func acceptPAN(brandKey, pan string, opts models.GenerateOptions) bool {
+	if detected, _ := DetectBrand(pan); detected != brandKey {
+		return false
+	}
+	if opts.Country == "" && opts.CardType == "" {
+		return true
+	}
+	record, ok := binRecord(brandKey, pan)
+	return ok && matchesFilters(record, opts)
+}
+
+// matchesFilters reports whether a record satisfies the country and card type options
+func matchesFilters(record bindb.Record, opts models.GenerateOptions) bool {
+	if opts.Country != "" && !strings.EqualFold(record.Country, opts.Country) {
+		return false
+	}
+	return opts.CardType == "" || record.Type == opts.CardType
+}
+
+// pickRecordBIN selects a BIN database record of the brand matching the country and card type options
+func pickRecordBIN(rng Random, brandKey string, brand models.CardBrand, opts models.GenerateOptions) (string, models.BINRange, error) {
+	if opts.CardType != "" && !bindb.CardTypes[opts.CardType] {
+		return "", models.BINRange{}, fmt.Errorf("unknown card type: %s (use credit, debit or prepaid)", opts.CardType)
+	}
+
+	type candidate struct {
+		bin string
+		r   models.BINRange
+	}
+
+	var candidates []candidate
+	for _, record := range bindb.Default().Find(brandKey, opts.Country, opts.CardType) {
+		if r, ok := findRange(brand, record.BIN); ok {
+			candidates = append(candidates, candidate{bin: record.BIN, r: r})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", models.BINRange{}, fmt.Errorf("no %s BINs in the BIN database for country %q and card type %q", brand.Name, opts.Country, opts.CardType)
+	}
+
+	picked := candidates[rng.IntN(len(candidates))]
+	return picked.bin, picked.r, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/bindb"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestBundledBINsMatchBrands(t *testing.T) {
+	for _, record := range bindb.Bundled().Find("", "", "") {
+		brand, ok := CardBrands[record.Brand]
+		if !ok {
+			t.Errorf("BIN %s: unknown brand %s", record.BIN, record.Brand)
+			continue
+		}
+		if _, ok := findRange(brand, record.BIN); !ok {
+			t.Errorf("BIN %s is outside the %s ranges", record.BIN, brand.Name)
+		}
+		if detected, _ := DetectBrand(record.BIN); detected != record.Brand {
+			t.Errorf("BIN %s: DetectBrand() = %s, table says %s", record.BIN, detected, record.Brand)
+		}
+	}
+}
+
+func TestLookupBIN(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantBrand  string
+		wantSource string
+		wantErr    error
+	}{
+		{"Database record", "4532110000000000", "visa", "bindb", nil},
+		{"Brand range fallback", "4999990000000000", "visa", "brand_range", nil},
+		{"Nested brand fallback", "401178", "elo", "brand_range", nil},
+		{"Unknown", "999999", "", "", bindb.ErrNotFound},
+		{"Invalid", "4532", "", "", bindb.ErrInvalidBIN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := LookupBIN(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LookupBIN(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if info.Brand != tt.wantBrand || info.Source != tt.wantSource {
+				t.Errorf("LookupBIN(%q) = %s/%s, want %s/%s", tt.input, info.Brand, info.Source, tt.wantBrand, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestGenerateCardFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     models.GenerateOptions
+		wantErr  string
+		wantType string
+	}{
+		{"Visa BR debit", models.GenerateOptions{Brand: "visa", Country: "BR", CardType: "debit"}, "", "debit"},
+		{"Visa BR prepaid (8-digit BIN)", models.GenerateOptions{Brand: "visa", Country: "br", CardType: "prepaid"}, "", "prepaid"},
+		{"Elo credit", models.GenerateOptions{Brand: "elo", CardType: "credit"}, "", "credit"},
+		{"No match", models.GenerateOptions{Brand: "elo", Country: "US"}, "no Elo BINs", ""},
+		{"Unknown type", models.GenerateOptions{Brand: "visa", CardType: "charge"}, "unknown card type", ""},
+		{"BIN and filter", models.GenerateOptions{Brand: "visa", BIN: "445566", CardType: "debit"}, "cannot be combined", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Count = 50
+			tt.opts.Seed = 13
+			cards, err := GenerateCards(tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GenerateCards() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateCards() unexpected error: %v", err)
+			}
+
+			for _, card := range cards {
+				record, err := bindb.Default().Lookup(card.PAN)
+				if err != nil {
+					t.Fatalf("Generated PAN %s not in the BIN database: %v", card.MaskedPAN, err)
+				}
+				if record.Type != tt.wantType || card.ProductType != tt.wantType {
+					t.Errorf("Card %s type = %s (record %s), want %s", card.MaskedPAN, card.ProductType, record.Type, tt.wantType)
+				}
+				if tt.opts.Country != "" && !strings.EqualFold(card.Country, tt.opts.Country) {
+					t.Errorf("Card %s country = %s, want %s", card.MaskedPAN, card.Country, tt.opts.Country)
+				}
+				if card.IssuerName != record.Issuer || card.ProductTier != record.Tier {
+					t.Errorf("Card %s issuer/tier = %s/%s, want %s/%s", card.MaskedPAN, card.IssuerName, card.ProductTier, record.Issuer, record.Tier)
+				}
+			}
+		})
+	}
+}
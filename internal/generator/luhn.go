@@ -8,7 +8,7 @@ import (
 // Luhn algorithm: used by all major card networks (ISO/IEC 7812)
 // Returns true if the PAN is valid according to Luhn checksum
 func ValidateLuhn(pan string) bool {
-	if len(pan) < 13 || len(pan) > 19 {
+	if len(pan) < 12 || len(pan) > 19 {
 		return false
 	}
 
@@ -0,0 +1,132 @@
+package generator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// PINLength is the length of generated test PINs
+const PINLength = 4
+
+// GeneratePIN derives a card's test PIN from its PAN and the secret
+//
+// DESIGN RATIONALE:
+// - HMAC-SHA256 over "PIN|" + PAN, like the CVC, so the mock authorizer can
+// re-derive the PIN from the same secret without a PIN database
+// - Decimalized like an IBM 3624 natural PIN, so every PIN is 4 digits
+// - FOR TEST/SANDBOX USE ONLY
+func GeneratePIN(pan, secret string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("secret is required for PIN generation")
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte("PIN|" + pan))
+	return cardcrypto.Decimalize(hex.EncodeToString(h.Sum(nil)), PINLength), nil
+}
+
+// addPIN sets the card's test PIN and, with a ZPK or DUKPT keys, its clear and
+// encrypted PIN blocks
+//
+// Random fill (formats 1, 3 and 4) is drawn from the generator, so seeded output
+// stays reproducible.
+func (g *Generator) addPIN(card *models.Card, opts models.GenerateOptions) error {
+	pin, err := GeneratePIN(card.PAN, opts.Secret)
+	if err != nil {
+		return err
+	}
+	card.PIN = pin
+
+	var key []byte
+	format := opts.PINFormat
+	switch {
+	case opts.ZPK != "" && opts.BDK != "":
+		return fmt.Errorf("use either a ZPK or a DUKPT BDK, not both")
+	case opts.ZPK != "":
+		if key, err = cardcrypto.ParsePINKey(opts.ZPK); err != nil {
+			return err
+		}
+	case opts.BDK != "":
+		ksn, keys, err := g.nextDUKPT(opts.BDK, opts.KSN)
+		if err != nil {
+			return err
+		}
+		key = keys.PIN
+		if keys.AES {
+			// AES PIN keys only encrypt 16-byte format 4 blocks
+			if format != cardcrypto.PINFormat0 && format != cardcrypto.PINFormat4 {
+				return fmt.Errorf("AES DUKPT uses PIN block format 4, not %d", format)
+			}
+			format = cardcrypto.PINFormat4
+		} else if format == cardcrypto.PINFormat4 {
+			return fmt.Errorf("PIN block format 4 needs AES DUKPT (a 24-hex KSN)")
+		}
+		card.KSN = strings.ToUpper(hex.EncodeToString(ksn))
+	default:
+		return nil
+	}
+
+	// Draw the fill once so the clear and encrypted blocks share it
+	fill := make([]byte, 16)
+	for i := range fill {
+		fill[i] = byte(g.rng.IntN(256))
+	}
+
+	clear, err := cardcrypto.ClearPINBlock(format, pin, card.PAN, bytes.NewReader(fill))
+	if err != nil {
+		return err
+	}
+	block, err := cardcrypto.EncryptPINBlock(format, pin, card.PAN, key, bytes.NewReader(fill))
+	if err != nil {
+		return err
+	}
+
+	card.PINBlockFormat = fmt.Sprintf("ISO-%d", format)
+	card.ClearPINBlock = strings.ToUpper(hex.EncodeToString(clear))
+	card.PINBlock = strings.ToUpper(hex.EncodeToString(block))
+	return nil
+}
+
+// nextDUKPT advances the generator's KSN and derives the transaction keys
+//
+// The first card uses the given KSN, or the next one when its counter is 0 (the
+// KSN a device is loaded with); each following card uses the next KSN.
+func (g *Generator) nextDUKPT(bdkHex, ksnHex string) ([]byte, cardcrypto.DUKPTKeys, error) {
+	bdk, err := cardcrypto.ParseBDK(bdkHex)
+	if err != nil {
+		return nil, cardcrypto.DUKPTKeys{}, err
+	}
+
+	ksn := g.ksn
+	if ksn == nil {
+		if ksnHex == "" {
+			return nil, cardcrypto.DUKPTKeys{}, fmt.Errorf("a KSN is required with a BDK")
+		}
+		if ksn, err = cardcrypto.ParseKSN(ksnHex); err != nil {
+			return nil, cardcrypto.DUKPTKeys{}, err
+		}
+		counter, err := cardcrypto.KSNCounter(ksn)
+		if err == nil && counter == 0 {
+			ksn, err = cardcrypto.NextKSN(ksn)
+		}
+		if err != nil {
+			return nil, cardcrypto.DUKPTKeys{}, err
+		}
+	} else if ksn, err = cardcrypto.NextKSN(ksn); err != nil {
+		return nil, cardcrypto.DUKPTKeys{}, err
+	}
+
+	keys, err := cardcrypto.DeriveDUKPTKeys(bdk, ksn)
+	if err != nil {
+		return nil, cardcrypto.DUKPTKeys{}, err
+	}
+	g.ksn = ksn
+	return ksn, keys, nil
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestGeneratePIN(t *testing.T) {
+	pin, err := GeneratePIN("4111111111111111", "test-secret")
+	if err != nil || len(pin) != PINLength || !isDigitString(pin) {
+		t.Fatalf("GeneratePIN() = %q, %v; want %d digits", pin, err, PINLength)
+	}
+
+	if again, _ := GeneratePIN("4111111111111111", "test-secret"); again != pin {
+		t.Errorf("GeneratePIN() not deterministic: %q != %q", again, pin)
+	}
+	if _, err := GeneratePIN("4111111111111111", ""); err == nil {
+		t.Error("GeneratePIN() without secret expected error")
+	}
+}
+
+func TestGenerateCardPIN(t *testing.T) {
+	const zpk = "0123456789ABCDEFFEDCBA9876543210"
+
+	card, err := GenerateCard(models.GenerateOptions{Brand: "visa", Secret: "test-secret", IncludePIN: true})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	if want, _ := GeneratePIN(card.PAN, "test-secret"); card.PIN != want || card.PINBlock != "" {
+		t.Errorf("GenerateCard() PIN = %q (block %q), want %q without a block", card.PIN, card.PINBlock, want)
+	}
+
+	for _, format := range []int{0, 1, 3, 4} {
+		opts := models.GenerateOptions{Brand: "mastercard", Secret: "test-secret", ZPK: zpk, PINFormat: format, Seed: 7}
+		card, err := GenerateCard(opts)
+		if err != nil {
+			t.Fatalf("GenerateCard(format %d) unexpected error: %v", format, err)
+		}
+
+		key, _ := cardcrypto.ParsePINKey(zpk)
+		block, _ := hex.DecodeString(card.PINBlock)
+		pin, got, err := cardcrypto.DecryptPINBlock(block, card.PAN, key)
+		if err != nil || pin != card.PIN || got != format || card.PINBlockFormat != fmt.Sprintf("ISO-%d", format) {
+			t.Errorf("GenerateCard(format %d) block %s decrypts to %q/%d (%v), want %q", format, card.PINBlock, pin, got, err, card.PIN)
+		}
+
+		// Seeded fill keeps randomized formats reproducible
+		if again, _ := GenerateCard(opts); again.PINBlock != card.PINBlock {
+			t.Errorf("GenerateCard(format %d, seeded) PIN block %s != %s", format, again.PINBlock, card.PINBlock)
+		}
+	}
+
+	invalid := []models.GenerateOptions{
+		{Brand: "visa", IncludePIN: true},
+		{Brand: "visa", Secret: "test-secret", ZPK: "0123"},
+		{Brand: "visa", Secret: "test-secret", ZPK: zpk, PINFormat: 2},
+	}
+	for _, opts := range invalid {
+		if _, err := GenerateCard(opts); err == nil {
+			t.Errorf("GenerateCard(%+v) expected error", opts)
+		}
+	}
+}
+
+func TestGenerateCardsDUKPT(t *testing.T) {
+	tests := []struct {
+		bdk, ksn   string
+		wantKSNs   []string
+		wantFormat string
+	}{
+		{"0123456789ABCDEFFEDCBA9876543210", "FFFF9876543210E00000",
+			[]string{"FFFF9876543210E00001", "FFFF9876543210E00002", "FFFF9876543210E00003"}, "ISO-0"},
+		{"FEDCBA9876543210F1F1F1F1F1F1F1F1", "123456789012345600000005",
+			[]string{"123456789012345600000005", "123456789012345600000006", "123456789012345600000007"}, "ISO-4"},
+	}
+
+	for _, tt := range tests {
+		cards, err := GenerateCards(models.GenerateOptions{Brand: "visa", Count: 3, Secret: "test-secret", BDK: tt.bdk, KSN: tt.ksn})
+		if err != nil {
+			t.Fatalf("GenerateCards(KSN %s) unexpected error: %v", tt.ksn, err)
+		}
+
+		bdk, _ := cardcrypto.ParseBDK(tt.bdk)
+		for i, card := range cards {
+			if card.KSN != tt.wantKSNs[i] || card.PINBlockFormat != tt.wantFormat {
+				t.Errorf("card %d KSN/format = %s/%s, want %s/%s", i, card.KSN, card.PINBlockFormat, tt.wantKSNs[i], tt.wantFormat)
+			}
+
+			ksn, _ := cardcrypto.ParseKSN(card.KSN)
+			keys, _ := cardcrypto.DeriveDUKPTKeys(bdk, ksn)
+			block, _ := hex.DecodeString(card.PINBlock)
+			if pin, _, err := cardcrypto.DecryptPINBlock(block, card.PAN, keys.PIN); err != nil || pin != card.PIN {
+				t.Errorf("card %d PIN block decrypts to %q (%v), want %q", i, pin, err, card.PIN)
+			}
+		}
+	}
+
+	invalid := []models.GenerateOptions{
+		{Brand: "visa", Secret: "test-secret", BDK: "0123456789ABCDEFFEDCBA9876543210"},
+		{Brand: "visa", Secret: "test-secret", BDK: "0123456789ABCDEFFEDCBA9876543210", KSN: "FFFF9876543210E00001", ZPK: "0123456789ABCDEFFEDCBA9876543210"},
+		{Brand: "visa", Secret: "test-secret", BDK: "0123456789ABCDEFFEDCBA9876543210", KSN: "FFFF9876543210E00001", PINFormat: 4},
+		{Brand: "visa", Secret: "test-secret", BDK: "FEDCBA9876543210F1F1F1F1F1F1F1F1", KSN: "123456789012345600000001", PINFormat: 3},
+	}
+	for _, opts := range invalid {
+		if _, err := GenerateCard(opts); err == nil {
+			t.Errorf("GenerateCard(BDK %s, KSN %q, ZPK %q, format %d) expected error", opts.BDK, opts.KSN, opts.ZPK, opts.PINFormat)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// DefaultPVKI is the PIN verification key index used when none is given
+const DefaultPVKI = 1
+
+// PINVerification are the PIN verification values derived from a PVK
+type PINVerification struct {
+	PVKI   string // PIN verification key index, 1-6
+	PVV    string // Visa PVV, carried on Track 2
+	Offset string // IBM 3624 offset, kept in the issuer's database
+}
+
+// ComputePINVerification computes the PVV and IBM 3624 offset of a PIN with a hex PVK
+func ComputePINVerification(pan, pin string, pvki int, pvkHex string) (PINVerification, error) {
+	pvk, err := cardcrypto.ParsePVK(pvkHex)
+	if err != nil {
+		return PINVerification{}, err
+	}
+	if pvki == 0 {
+		pvki = DefaultPVKI
+	}
+
+	pvv, err := cardcrypto.PVV(pvk, pan, pvki, pin)
+	if err != nil {
+		return PINVerification{}, err
+	}
+	offset, err := cardcrypto.IBM3624Offset(pvk, pan, pin)
+	if err != nil {
+		return PINVerification{}, err
+	}
+	return PINVerification{PVKI: fmt.Sprint(pvki), PVV: pvv, Offset: offset}, nil
+}
+
+// addPINVerification sets the PVKI, PVV and IBM 3624 offset of the card's test PIN
+func addPINVerification(card *models.Card, opts models.GenerateOptions) error {
+	pin, err := GeneratePIN(card.PAN, opts.Secret)
+	if err != nil {
+		return err
+	}
+
+	values, err := ComputePINVerification(card.PAN, pin, opts.PVKI, opts.PVK)
+	if err != nil {
+		return err
+	}
+	card.PVKI, card.PVV, card.PINOffset = values.PVKI, values.PVV, values.Offset
+	return nil
+}
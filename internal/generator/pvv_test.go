@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+func TestComputePINVerification(t *testing.T) {
+	values, err := ComputePINVerification("4111111111111111", "1234", 0, "0123456789ABCDEFFEDCBA9876543210")
+	if err != nil {
+		t.Fatalf("ComputePINVerification() unexpected error: %v", err)
+	}
+	if values.PVKI != "1" || values.PVV != "9464" || values.Offset != "5305" {
+		t.Errorf("ComputePINVerification() = %+v, want PVKI 1, PVV 9464, offset 5305", values)
+	}
+
+	if _, err := ComputePINVerification("4111111111111111", "1234", 7, "0123456789ABCDEFFEDCBA9876543210"); err == nil {
+		t.Error("ComputePINVerification(PVKI 7) expected error")
+	}
+}
+
+func TestGenerateCardPVV(t *testing.T) {
+	const pvkHex = "0123456789ABCDEFFEDCBA9876543210"
+	pvk, _ := cardcrypto.ParsePVK(pvkHex)
+
+	tests := []struct {
+		opts models.GenerateOptions
+		pvki int
+	}{
+		{models.GenerateOptions{Brand: "visa", Secret: "test-secret", PVK: pvkHex, PVKI: 3, IncludeTrack2: true}, 3},
+		{models.GenerateOptions{Brand: "mastercard", Secret: "test-secret", PVK: pvkHex, CVK: pvkHex, IncludeTrack1: true, IncludeTrack2: true}, DefaultPVKI},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		card, err := GenerateCard(opts)
+		if err != nil {
+			t.Fatalf("GenerateCard(%s) unexpected error: %v", opts.Brand, err)
+		}
+
+		pvki := fmt.Sprint(tt.pvki)
+		pvv, _ := cardcrypto.PVV(pvk, card.PAN, tt.pvki, card.PIN)
+		offset, _ := cardcrypto.IBM3624Offset(pvk, card.PAN, card.PIN)
+		if card.PIN == "" || card.PVKI != pvki || card.PVV != pvv || card.PINOffset != offset {
+			t.Errorf("GenerateCard(%s) PIN/PVKI/PVV/offset = %q/%q/%q/%q, want PVKI %s, PVV %s, offset %s",
+				opts.Brand, card.PIN, card.PVKI, card.PVV, card.PINOffset, pvki, pvv, offset)
+		}
+
+		// PVKI + PVV sit at their standard positions, ahead of the CVV (zeros without a CVK)
+		cvv := card.CVV
+		if cvv == "" {
+			cvv = zeroCVV
+		}
+		if gotPVKI, gotPVV, ok := track.PVV(card.Track2); !ok || gotPVKI != pvki || gotPVV != pvv || !strings.HasSuffix(card.Track2, pvki+pvv+cvv) {
+			t.Errorf("GenerateCard(%s) Track2 = %q, want PVKI %s + PVV %s + CVV %s", opts.Brand, card.Track2, pvki, pvv, cvv)
+		}
+		if opts.IncludeTrack1 && !strings.HasSuffix(card.Track1, pvki+pvv+cvv) {
+			t.Errorf("GenerateCard(%s) Track1 = %q, want the Track 2 discretionary data", opts.Brand, card.Track1)
+		}
+	}
+
+	invalid := []models.GenerateOptions{
+		{Brand: "visa", PVK: pvkHex},
+		{Brand: "visa", Secret: "test-secret", PVK: "0123"},
+		{Brand: "visa", Secret: "test-secret", PVK: pvkHex, PVKI: 9},
+	}
+	for _, opts := range invalid {
+		if _, err := GenerateCard(opts); err == nil {
+			t.Errorf("GenerateCard(PVK %q, PVKI %d) expected error", opts.PVK, opts.PVKI)
+		}
+	}
+}
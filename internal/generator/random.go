@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"crypto/rand"
+	"math/big"
+	mrand "math/rand/v2"
+	"time"
+)
+
+// Random is the source of randomness for generated card data
+type Random interface {
+	// IntN returns a uniform random number in [0, n)
+	IntN(n int) int
+}
+
+// SeedEpoch is the fixed clock start used in seeded mode
+var SeedEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedStream is the PCG stream selector; changing it changes every seeded output
+const seedStream = 0x63617264_67656e00 // "cardgen\x00"
+
+// cryptoRandom draws from crypto/rand (the default, non-reproducible mode)
+type cryptoRandom struct{}
+
+func (cryptoRandom) IntN(n int) int {
+	num, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(num.Int64())
+}
+
+// NewRandom returns a deterministic PCG source for a non-zero seed, or crypto/rand for seed 0
+//
+// DESIGN RATIONALE:
+// - PCG from math/rand/v2 is fully specified, so a seed yields the same sequence on every platform and Go version
+// - Seeded output is for reproducible fixtures only; it is predictable by design
+func NewRandom(seed int64) Random {
+	if seed == 0 {
+		return cryptoRandom{}
+	}
+	return mrand.New(mrand.NewPCG(uint64(seed), seedStream))
+}
+
+// Generator produces cards from a single random source and clock
+//
+// In seeded mode the clock starts at SeedEpoch and advances one second per card,
+// so timestamps, STAN and RRN are reproducible too.
+type Generator struct {
+	rng   Random
+	clock func() time.Time
+	ksn   []byte // DUKPT KSN of the last card; each card is the device's next transaction
+}
+
+// NewGenerator creates a generator; seed 0 uses crypto/rand and the wall clock
+func NewGenerator(seed int64) *Generator {
+	if seed == 0 {
+		return &Generator{rng: cryptoRandom{}, clock: time.Now}
+	}
+
+	tick := 0
+	return &Generator{
+		rng: NewRandom(seed),
+		clock: func() time.Time {
+			now := SeedEpoch.Add(time.Duration(tick) * time.Second)
+			tick++
+			return now
+		},
+	}
+}
+
+// randomDigits returns n random digits from rng
+func randomDigits(rng Random, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	digits := make([]byte, n)
+	for i := 0; i < n; i++ {
+		digits[i] = byte('0' + rng.IntN(10))
+	}
+	return string(digits)
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+// DefaultCardholderName is the Track 1 name used when none is given
+const DefaultCardholderName = "TEST/CARDHOLDER"
+
+// Discretionary data placeholders when only a CVK or only a PVK is configured
+const (
+	noPVKI  = "0" // PVKI 0: the card carries no PVV
+	zeroPVV = "0000"
+	zeroCVV = "000"
+)
+
+// addTracks fills the requested Track 1/Track 2 data on the card
+//
+// DESIGN RATIONALE:
+// - With a CVK or PVK, discretionary data carries PVKI + PVV + CVV at their standard
+// positions, zero-filled for the missing key
+// - Without keys, 4 random digits keep the historical (seeded) Track 2 output
+// - Both tracks share the same discretionary data, as on a real stripe
+func addTracks(rng Random, card *models.Card, fields track.Fields, codes CVKCodes, opts models.GenerateOptions) error {
+	switch {
+	case codes.CVV != "" || card.PVV != "":
+		pvki, pvv, cvv := noPVKI, zeroPVV, codes.CVV
+		if card.PVV != "" {
+			pvki, pvv = card.PVKI, card.PVV
+		}
+		if cvv == "" {
+			cvv = zeroCVV
+		}
+		fields.Discretionary = track.Discretionary(pvki, pvv, cvv)
+	default:
+		fields.Discretionary = randomDigits(rng, 4)
+	}
+	if fields.Name == "" {
+		fields.Name = DefaultCardholderName
+	}
+
+	if opts.IncludeTrack2 {
+		track2, err := track.Track2(fields)
+		if err != nil {
+			return err
+		}
+		card.Track2 = track2
+		if opts.RawTracks {
+			card.RawTrack2 = track.Raw2(track2)
+			card.Track2Equivalent = track.EquivalentBCD(track2)
+		}
+	}
+
+	if opts.IncludeTrack1 {
+		track1, err := track.Track1(fields)
+		if err != nil {
+			return err
+		}
+		card.Track1 = track1
+		card.CardholderName, _ = track.FormatName(fields.Name)
+		if opts.RawTracks {
+			card.RawTrack1 = track.Raw1(track1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+func TestGenerateCardTracks(t *testing.T) {
+	card, err := GenerateCard(models.GenerateOptions{
+		Brand:          "mastercard",
+		CVK:            "0123456789ABCDEFFEDCBA9876543210",
+		IncludeTrack1:  true,
+		IncludeTrack2:  true,
+		CardholderName: "Doe/Jane",
+		RawTracks:      true,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+
+	expiry := fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth)
+	discretionary := noPVKI + zeroPVV + card.CVV
+	wantTrack2 := card.PAN + "=" + expiry + CardBrands["mastercard"].ServiceCode + discretionary
+	if card.Track2 != wantTrack2 {
+		t.Errorf("GenerateCard() Track2 = %q, want %q", card.Track2, wantTrack2)
+	}
+	if !strings.HasPrefix(card.Track1, "B"+card.PAN+"^DOE/JANE^"+expiry) || !strings.HasSuffix(card.Track1, discretionary) {
+		t.Errorf("GenerateCard() Track1 = %q, want format B with name and shared discretionary data", card.Track1)
+	}
+	if card.CardholderName != "DOE/JANE" {
+		t.Errorf("GenerateCard() CardholderName = %q, want DOE/JANE", card.CardholderName)
+	}
+	if !track.CheckLRC(card.RawTrack1) || !track.CheckLRC(card.RawTrack2) {
+		t.Errorf("GenerateCard() raw tracks %q / %q have invalid LRC", card.RawTrack1, card.RawTrack2)
+	}
+	if !strings.Contains(card.Track2Equivalent, "D") || len(card.Track2Equivalent)%2 != 0 {
+		t.Errorf("GenerateCard() Track2Equivalent = %q, want even-length BCD with D separator", card.Track2Equivalent)
+	}
+	if result := ValidateCard(card); !result.Valid {
+		t.Errorf("ValidateCard() = %+v, want valid", result.Failed())
+	}
+}
+
+func TestGenerateCardTrack1Defaults(t *testing.T) {
+	card, err := GenerateCard(models.GenerateOptions{Brand: "visa", IncludeTrack1: true})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	if card.CardholderName != DefaultCardholderName || card.Track2 != "" || card.RawTrack1 != "" {
+		t.Errorf("GenerateCard() = name %q, Track2 %q, RawTrack1 %q; want default name and Track 1 only",
+			card.CardholderName, card.Track2, card.RawTrack1)
+	}
+
+	if _, err := GenerateCard(models.GenerateOptions{Brand: "visa", IncludeTrack1: true, CardholderName: "José"}); err == nil {
+		t.Error("GenerateCard() with non-ASCII name expected error but got none")
+	}
+}
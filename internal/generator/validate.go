@@ -0,0 +1,189 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+// Check names reported by ValidateCard
+const (
+	CheckBrand  = "brand"
+	CheckLength = "length"
+	CheckLuhn   = "luhn"
+	CheckExpiry = "expiry"
+	CheckCVC    = "cvc"
+	CheckTrack2 = "track2"
+	CheckTrack1 = "track1"
+)
+
+// Check is the outcome of a single validation check
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// CardValidation is the result of validating a card record
+type CardValidation struct {
+	MaskedPAN string  `json:"masked_pan"`
+	Brand     string  `json:"brand,omitempty"` // Detected CardBrands key
+	Valid     bool    `json:"valid"`
+	Checks    []Check `json:"checks"`
+}
+
+// Failed returns the checks that did not pass
+func (v CardValidation) Failed() []Check {
+	var failed []Check
+	for _, check := range v.Checks {
+		if !check.OK {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// ValidateCard checks a card record for internal consistency
+//
+// Brand, length and Luhn checks always run; expiry, CVC and track checks run
+// only when the record carries those fields, so a bare PAN validates too.
+func ValidateCard(card *models.Card) CardValidation {
+	return ValidateCardAt(card, time.Now())
+}
+
+// ValidateCardAt is ValidateCard with expiries checked as of now, e.g. a fixed date
+// for committed fixtures that must keep validating
+func ValidateCardAt(card *models.Card, now time.Time) CardValidation {
+	pan := card.PAN
+	result := CardValidation{MaskedPAN: MaskPAN(pan)}
+	add := func(name string, ok bool, format string, args ...interface{}) {
+		result.Checks = append(result.Checks, Check{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	key, detected := DetectBrand(pan)
+	brand := CardBrands[key]
+	result.Brand = key
+
+	// Brand: detectable, and matching the record's brand when it names one
+	switch {
+	case !isDigitString(pan):
+		add(CheckBrand, false, "PAN must contain only digits")
+	case !detected:
+		add(CheckBrand, false, "no brand matches BIN %s", pan[:min(len(pan), 6)])
+	case card.Brand != "" && !strings.EqualFold(card.Brand, key) && !strings.EqualFold(card.Brand, brand.Name):
+		add(CheckBrand, false, "record says %s, BIN belongs to %s", card.Brand, brand.Name)
+	default:
+		add(CheckBrand, true, "%s", brand.Name)
+	}
+
+	// Length: allowed by the detected brand
+	if detected {
+		add(CheckLength, containsInt(brand.PANLength, len(pan)), "%d digits (%s allows %v)", len(pan), brand.Name, brand.PANLength)
+	} else {
+		add(CheckLength, len(pan) >= 12 && len(pan) <= 19, "%d digits (12-19 allowed)", len(pan))
+	}
+
+	// Luhn: check digit, unless the PAN is in a non-Luhn range
+	switch {
+	case ValidateLuhn(pan):
+		add(CheckLuhn, true, "")
+	case ValidatePAN(pan):
+		add(CheckLuhn, true, "non-Luhn range")
+	default:
+		add(CheckLuhn, false, "check digit mismatch")
+	}
+
+	// Expiry: a valid month that has not passed
+	if card.ExpiryMonth != 0 || card.ExpiryYear != 0 {
+		switch {
+		case card.ExpiryMonth < 1 || card.ExpiryMonth > 12:
+			add(CheckExpiry, false, "invalid month %d", card.ExpiryMonth)
+		case card.ExpiryYear*12+card.ExpiryMonth < now.Year()*12+int(now.Month()):
+			add(CheckExpiry, false, "expired %02d/%d", card.ExpiryMonth, card.ExpiryYear)
+		default:
+			add(CheckExpiry, true, "%02d/%d", card.ExpiryMonth, card.ExpiryYear)
+		}
+	}
+
+	// CVC: digits of the brand's length
+	if card.CVC != "" {
+		want := 3
+		if detected {
+			want = brand.CVCLength
+		}
+		add(CheckCVC, isDigitString(card.CVC) && len(card.CVC) == want, "%d digits, want %d", len(card.CVC), want)
+	}
+
+	// Track 2: same PAN and expiry, a service code, and within capacity
+	if card.Track2 != "" {
+		ok, detail := checkTrack2(card)
+		add(CheckTrack2, ok, "%s", detail)
+	}
+
+	// Track 1: format B, same PAN and expiry, a valid name, and within capacity
+	if card.Track1 != "" {
+		ok, detail := checkTrack1(card)
+		add(CheckTrack1, ok, "%s", detail)
+	}
+
+	result.Valid = len(result.Failed()) == 0
+	return result
+}
+
+// checkTrack2 compares Track 2 equivalent data (PAN=YYMMSSS...) with the card record
+func checkTrack2(card *models.Card) (bool, string) {
+	track2 := card.Track2
+	pan, rest, found := strings.Cut(strings.ReplaceAll(track2, "D", "="), "=")
+
+	switch {
+	case !found:
+		return false, "missing field separator"
+	case len(track2) > track.MaxTrack2Length:
+		return false, fmt.Sprintf("%d characters, exceeds %d", len(track2), track.MaxTrack2Length)
+	case pan != card.PAN:
+		return false, fmt.Sprintf("PAN %s differs from record", MaskPAN(pan))
+	case len(rest) < 7 || !isDigitString(rest):
+		return false, "expiry and service code must be 7+ digits after the separator"
+	}
+
+	if card.ExpiryMonth != 0 || card.ExpiryYear != 0 {
+		if want := fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth); rest[:4] != want {
+			return false, fmt.Sprintf("expiry %s differs from record %s (YYMM)", rest[:4], want)
+		}
+	}
+
+	return true, fmt.Sprintf("service code %s", rest[4:7])
+}
+
+// checkTrack1 compares Track 1 format B data (B<PAN>^<NAME>^YYMMSSS...) with the card record
+func checkTrack1(card *models.Card) (bool, string) {
+	track1 := card.Track1
+	parts := strings.SplitN(track1, string(track.Track1Separator), 3)
+
+	switch {
+	case track1[0] != track.FormatCodeB:
+		return false, fmt.Sprintf("format code %q, want %q", track1[0], track.FormatCodeB)
+	case len(parts) != 3:
+		return false, "missing field separator"
+	case len(track1) > track.MaxTrack1Length:
+		return false, fmt.Sprintf("%d characters, exceeds %d", len(track1), track.MaxTrack1Length)
+	case parts[0][1:] != card.PAN:
+		return false, fmt.Sprintf("PAN %s differs from record", MaskPAN(parts[0][1:]))
+	case len(parts[2]) < 7 || !isDigitString(parts[2][:7]):
+		return false, "expiry and service code must be 7 digits after the name"
+	}
+
+	if _, err := track.FormatName(parts[1]); err != nil {
+		return false, err.Error()
+	}
+	if card.ExpiryMonth != 0 || card.ExpiryYear != 0 {
+		if want := fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth); parts[2][:4] != want {
+			return false, fmt.Sprintf("expiry %s differs from record %s (YYMM)", parts[2][:4], want)
+		}
+	}
+
+	return true, fmt.Sprintf("name %s, service code %s", parts[1], parts[2][4:7])
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestValidateCard(t *testing.T) {
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	valid := models.Card{
+		PAN:         "4000000000000002",
+		Brand:       "Visa",
+		ExpiryMonth: 12,
+		ExpiryYear:  2027,
+		CVC:         "123",
+		Track2:      "4000000000000002=27122011234",
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(card *models.Card)
+		wantFailed []string
+	}{
+		{"Valid record", func(card *models.Card) {}, nil},
+		{"Bare PAN", func(card *models.Card) { *card = models.Card{PAN: "5100000000000016"} }, nil},
+		{"Brand key", func(card *models.Card) { card.Brand = "visa" }, nil},
+		{"Brand mismatch", func(card *models.Card) { card.Brand = "Mastercard" }, []string{CheckBrand}},
+		{"Unknown BIN", func(card *models.Card) { *card = models.Card{PAN: "9999990000000006"} }, []string{CheckBrand}},
+		{"Bad Luhn", func(card *models.Card) { card.PAN, card.Track2 = "4000000000000003", "" }, []string{CheckLuhn}},
+		{"Length not issued", func(card *models.Card) { card.PAN, card.Track2 = "400000000000006", "" }, []string{CheckLength}},
+		{"Amex 16 digits", func(card *models.Card) { *card = models.Card{PAN: "3400000000000009"} }, []string{CheckLength, CheckLuhn}},
+		{"Current month", func(card *models.Card) { card.ExpiryMonth, card.ExpiryYear, card.Track2 = 10, 2026, "" }, nil},
+		{"Expired", func(card *models.Card) { card.ExpiryMonth, card.ExpiryYear, card.Track2 = 9, 2026, "" }, []string{CheckExpiry}},
+		{"Invalid month", func(card *models.Card) { card.ExpiryMonth, card.Track2 = 13, "" }, []string{CheckExpiry}},
+		{"CVC length", func(card *models.Card) { card.CVC = "1234" }, []string{CheckCVC}},
+		{"Amex CVC", func(card *models.Card) { *card = models.Card{PAN: "340000000000009", CVC: "123"} }, []string{CheckCVC}},
+		{"Track2 PAN", func(card *models.Card) { card.Track2 = "4000000000000010=27122011234" }, []string{CheckTrack2}},
+		{"Track2 expiry", func(card *models.Card) { card.Track2 = "4000000000000002=28122011234" }, []string{CheckTrack2}},
+		{"Track2 D separator", func(card *models.Card) { card.Track2 = "4000000000000002D27122011234" }, nil},
+		{"Track2 no separator", func(card *models.Card) { card.Track2 = "400000000000000227122011234" }, []string{CheckTrack2}},
+		{"Track2 too long", func(card *models.Card) { card.Track2 += "12345678901" }, []string{CheckTrack2}},
+		{"Track1 valid", func(card *models.Card) { card.Track1 = "B4000000000000002^DOE/JOHN^27122011234" }, nil},
+		{"Track1 format code", func(card *models.Card) { card.Track1 = "A4000000000000002^DOE/JOHN^27122011234" }, []string{CheckTrack1}},
+		{"Track1 PAN", func(card *models.Card) { card.Track1 = "B4000000000000010^DOE/JOHN^27122011234" }, []string{CheckTrack1}},
+		{"Track1 expiry", func(card *models.Card) { card.Track1 = "B4000000000000002^DOE/JOHN^28122011234" }, []string{CheckTrack1}},
+		{"Track1 name", func(card *models.Card) { card.Track1 = "B4000000000000002^D^27122011234" }, []string{CheckTrack1}},
+		{"Track1 no separator", func(card *models.Card) { card.Track1 = "B4000000000000002DOE/JOHN27122011234" }, []string{CheckTrack1}},
+		{"Non-Luhn UnionPay", func(card *models.Card) { *card = models.Card{PAN: "6217001234567890123"} }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := valid
+			tt.modify(&card)
+
+			result := ValidateCardAt(&card, now)
+			var failed []string
+			for _, check := range result.Failed() {
+				failed = append(failed, check.Name)
+			}
+
+			if len(failed) != len(tt.wantFailed) || result.Valid != (len(tt.wantFailed) == 0) {
+				t.Fatalf("ValidateCardAt() failed checks = %v (%+v), want %v", failed, result.Checks, tt.wantFailed)
+			}
+			for i := range failed {
+				if failed[i] != tt.wantFailed[i] {
+					t.Errorf("ValidateCardAt() failed checks = %v, want %v", failed, tt.wantFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedCards(t *testing.T) {
+	for _, brand := range BrandNames() {
+		cards, err := GenerateCards(models.GenerateOptions{Brand: brand, Count: 20, Secret: "validate-secret", IncludeTrack2: true})
+		if err != nil {
+			t.Fatalf("GenerateCards(%s) unexpected error: %v", brand, err)
+		}
+		for _, card := range cards {
+			if result := ValidateCard(card); !result.Valid {
+				t.Errorf("ValidateCard(%s) = %+v, want valid", card.MaskedPAN, result.Failed())
+			}
+		}
+	}
+}
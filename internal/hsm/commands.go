@@ -0,0 +1,466 @@
+package hsm
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+// FirmwareVersion is reported by the NC diagnostics command
+const FirmwareVersion = "CARDGEN01"
+
+// fullPANScheme is the KQ scheme ID whose requests carry the full PAN instead of the packed PAN/PSN
+const fullPANScheme = "3"
+
+// mastercardScheme is the KQ scheme ID whose session keys use the Mastercard derivation (M/Chip CVN 10)
+const mastercardScheme = "1"
+
+// ErrorCodes are the response error codes the emulator returns
+var ErrorCodes = map[string]string{
+	"00": "No error",
+	"01": "Verification failure",
+	"04": "Invalid key type code",
+	"15": "Invalid input data",
+	"20": "PIN block does not contain valid values",
+	"23": "Invalid PIN block format code",
+	"24": "PIN is fewer than 4 or more than 12 digits long",
+	"26": "Invalid key scheme",
+	"68": "Command disabled",
+}
+
+// pinFormatCodes maps Thales PIN block format codes to ISO 9564 formats
+var pinFormatCodes = map[string]int{
+	"01": cardcrypto.PINFormat0,
+	"05": cardcrypto.PINFormat1,
+	"47": cardcrypto.PINFormat3,
+}
+
+// keySchemes maps key scheme tags to key lengths in bytes
+var keySchemes = map[byte]int{
+	'Z': 8,
+	'U': 16,
+	'X': 16,
+	'T': 24,
+	'Y': 24,
+}
+
+// commands maps host command codes to their handlers
+var commands = map[string]func(*request) []byte{
+	"A0": generateKey,
+	"CW": generateCVV,
+	"CY": verifyCVV,
+	"CC": translatePIN,
+	"EC": verifyPINWithPVV,
+	"KQ": verifyARQC,
+	"NC": diagnostics,
+}
+
+// Execute runs a command on its fields and returns the response data and error code
+func (s *Server) Execute(code string, data []byte) ([]byte, string) {
+	handler, ok := commands[code]
+	if !ok {
+		return nil, "68"
+	}
+
+	r := &request{data: data, lmk: s.lmk}
+	response := handler(r)
+	if r.err != "" {
+		return nil, r.err
+	}
+	return response, "00"
+}
+
+// generateKey (A0): mode 1H (0 = generate), key type 3H, key scheme 1A
+// Response: key (scheme + hex, under the LMK if set), check value 6H
+func generateKey(r *request) []byte {
+	mode := r.next(1)
+	keyType := r.next(3)
+	scheme := r.next(1)
+	if r.err != "" {
+		return nil
+	}
+	if string(mode) != "0" {
+		r.fail("15")
+		return nil
+	}
+	if _, err := strconv.ParseUint(string(keyType), 16, 12); err != nil {
+		r.fail("04")
+		return nil
+	}
+	length, ok := keySchemes[scheme[0]]
+	if !ok {
+		r.fail("26")
+		return nil
+	}
+
+	key := make([]byte, length)
+	if _, err := rand.Read(key); err != nil {
+		r.fail("15")
+		return nil
+	}
+	key = cardcrypto.WithOddParity(key)
+
+	kcv, _ := cardcrypto.KeyCheckValue(key)
+	return []byte(string(scheme) + r.exportKey(key) + hexString(kcv[:3]))
+}
+
+// generateCVV (CW): CVK, PAN up to 19N, ';', expiry 4N (YYMM), service code 3N
+// Response: CVV 3N
+func generateCVV(r *request) []byte {
+	cvv := r.cvv(r.key())
+	if r.err != "" {
+		return nil
+	}
+	return []byte(cvv)
+}
+
+// verifyCVV (CY): CVK, CVV 3N, PAN up to 19N, ';', expiry 4N (YYMM), service code 3N
+// Error 01 when the CVV does not verify
+func verifyCVV(r *request) []byte {
+	key := r.key()
+	presented := r.digits(3)
+	expected := r.cvv(key)
+	if r.err == "" && presented != expected {
+		r.fail("01")
+	}
+	return nil
+}
+
+// translatePIN (CC): source ZPK, destination ZPK, maximum PIN length 2N, PIN block 16H,
+// source format 2N, destination format 2N, account number 12N
+// Response: PIN length 2N, PIN block 16H, destination format 2N
+func translatePIN(r *request) []byte {
+	source := r.key()
+	destination := r.key()
+	maxLength, _ := strconv.Atoi(r.digits(2))
+	block := r.hex(16)
+	sourceFormat := r.pinFormat()
+	destinationCode := string(r.next(2))
+	account := r.digits(12)
+	if r.err != "" {
+		return nil
+	}
+	destinationFormat, ok := pinFormatCodes[destinationCode]
+	if !ok {
+		r.fail("23")
+		return nil
+	}
+
+	pin := r.decryptPIN(block, sourceFormat, account, source)
+	if r.err != "" {
+		return nil
+	}
+	if len(pin) > maxLength {
+		r.fail("24")
+		return nil
+	}
+
+	translated, err := cardcrypto.EncryptPINBlock(destinationFormat, pin, account+"0", destination, nil)
+	if err != nil {
+		r.fail("15")
+		return nil
+	}
+	return []byte(fmt.Sprintf("%02d%s%s", len(pin), hexString(translated), destinationCode))
+}
+
+// verifyPINWithPVV (EC): ZPK, PVK, PIN block 16H, format 2N, account number 12N,
+// PVKI 1N, PVV 4N
+// Error 01 when the PIN's PVV does not match
+func verifyPINWithPVV(r *request) []byte {
+	zpk := r.key()
+	pvk := r.key()
+	block := r.hex(16)
+	format := r.pinFormat()
+	account := r.digits(12)
+	pvki, _ := strconv.Atoi(r.digits(1))
+	pvv := r.digits(4)
+	if r.err != "" {
+		return nil
+	}
+
+	pin := r.decryptPIN(block, format, account, zpk)
+	if r.err != "" {
+		return nil
+	}
+	expected, err := cardcrypto.PVV(pvk, account+"0", pvki, pin)
+	if err != nil {
+		r.fail("15")
+		return nil
+	}
+	if expected != pvv {
+		r.fail("01")
+	}
+	return nil
+}
+
+// verifyARQC (KQ): mode 1N (0 = verify ARQC, 1 = verify and generate ARPC, 2 = ARPC only),
+// scheme ID 1N, MK-AC (issuer master key), PAN/PSN 8B (scheme ID 3: PAN, ';', PSN 2N),
+// ATC 2B, unpredictable number 4B, transaction data length 2H, transaction data, ';',
+// ARQC 8B, ARC 2A (modes 1 and 2)
+// Response: ARPC 8B (modes 1 and 2); error 01 when the ARQC does not verify
+//
+// The packed PAN/PSN holds the 14 rightmost PAN digits, enough for option A only; with
+// scheme ID 3 the full PAN is sent and PANs over 16 digits derive the ICC master key with
+// option B. Scheme ID 1 derives the Mastercard session key from the ATC and unpredictable
+// number, as M/Chip DE55 data does; the other scheme IDs use the EMV common session key.
+func verifyARQC(r *request) []byte {
+	mode := string(r.next(1))
+	scheme := r.digits(1)
+	imk := r.key()
+	var pan, psn string
+	if scheme == fullPANScheme {
+		pan = r.pan()
+		psn = r.digits(2)
+	} else if panPSN := r.next(8); r.err == "" {
+		digits := hexString(panPSN)
+		pan, psn = digits[:14], digits[14:]
+	}
+	atc := r.next(2)
+	un := r.next(4)
+	length, err := strconv.ParseUint(string(r.next(2)), 16, 8)
+	if err != nil {
+		r.fail("15")
+	}
+	data := r.next(int(length))
+	r.delimiter()
+	arqc := r.next(8)
+	var arc string
+	if mode == "1" || mode == "2" {
+		arc = string(r.next(2))
+	} else if mode != "0" {
+		r.fail("15")
+	}
+	if r.err != "" {
+		return nil
+	}
+
+	mk, err := cardcrypto.DeriveICCMasterKey(imk, pan, psn)
+	if err != nil {
+		r.fail("15")
+		return nil
+	}
+	var sessionKey []byte
+	if scheme == mastercardScheme {
+		sessionKey, err = cardcrypto.SessionKeyMastercard(mk, atc, un)
+	} else {
+		sessionKey, err = cardcrypto.SessionKey(mk, atc)
+	}
+	if err != nil {
+		r.fail("15")
+		return nil
+	}
+
+	if mode != "2" {
+		expected, err := cardcrypto.ARQC(sessionKey, data)
+		if err != nil || !bytes.Equal(expected, arqc) {
+			r.fail("01")
+			return nil
+		}
+	}
+	if mode == "0" {
+		return nil
+	}
+
+	arpc, err := cardcrypto.ARPC(sessionKey, arqc, arc)
+	if err != nil {
+		r.fail("15")
+		return nil
+	}
+	return arpc
+}
+
+// diagnostics (NC): no fields
+// Response: LMK check value 16H (zeros with clear keys), firmware version 9A
+func diagnostics(r *request) []byte {
+	kcv := make([]byte, 8)
+	if r.lmk != nil {
+		kcv, _ = cardcrypto.KeyCheckValue(r.lmk)
+	}
+	return []byte(hexString(kcv) + FirmwareVersion)
+}
+
+// request reads the fields of a command in order
+//
+// The first failure is kept as the response error code and later reads return zero
+// values, so handlers check r.err once after reading their fields.
+type request struct {
+	data []byte
+	lmk  []byte
+	err  string
+}
+
+func (r *request) fail(code string) {
+	if r.err == "" {
+		r.err = code
+	}
+}
+
+// next reads n bytes
+func (r *request) next(n int) []byte {
+	if r.err != "" {
+		return nil
+	}
+	if len(r.data) < n {
+		r.fail("15")
+		return nil
+	}
+	field := r.data[:n]
+	r.data = r.data[n:]
+	return field
+}
+
+// digits reads n decimal digits
+func (r *request) digits(n int) string {
+	field := string(r.next(n))
+	for i := 0; i < len(field); i++ {
+		if field[i] < '0' || field[i] > '9' {
+			r.fail("15")
+			return ""
+		}
+	}
+	return field
+}
+
+// hex reads n hex characters as bytes
+func (r *request) hex(n int) []byte {
+	field, err := hex.DecodeString(string(r.next(n)))
+	if err != nil {
+		r.fail("15")
+	}
+	return field
+}
+
+// delimiter reads the ';' field delimiter
+func (r *request) delimiter() {
+	if d := r.next(1); r.err == "" && d[0] != ';' {
+		r.fail("15")
+	}
+}
+
+// pan reads a PAN of up to 19 digits ended by the ';' delimiter
+func (r *request) pan() string {
+	if r.err != "" {
+		return ""
+	}
+	end := bytes.IndexByte(r.data, ';')
+	if end < 12 || end > 19 {
+		r.fail("15")
+		return ""
+	}
+	pan := r.digits(end)
+	r.delimiter()
+	return pan
+}
+
+// key reads a key: a scheme tag (Z, U, X, T, Y) and its hex, or 32 untagged hex
+// characters; with an LMK the key is decrypted from under it
+func (r *request) key() []byte {
+	if r.err != "" || len(r.data) == 0 {
+		r.fail("15")
+		return nil
+	}
+
+	length, tagged := keySchemes[r.data[0]]
+	if tagged {
+		r.next(1)
+	} else {
+		length = 16
+	}
+	key := r.hex(2 * length)
+	if r.err != "" {
+		return nil
+	}
+
+	if r.lmk != nil {
+		c, err := lmkCipher(r.lmk)
+		if err != nil {
+			r.fail("15")
+			return nil
+		}
+		for i := 0; i < len(key); i += 8 {
+			c.Decrypt(key[i:i+8], key[i:i+8])
+		}
+	}
+	if len(key) == 8 {
+		key = append(key, key...) // Single-length keys as K || K 3DES
+	}
+	return key
+}
+
+// exportKey returns a key as hex, encrypted under the LMK if set
+func (r *request) exportKey(key []byte) string {
+	if r.lmk == nil {
+		return hexString(key)
+	}
+
+	c, _ := lmkCipher(r.lmk)
+	out := make([]byte, len(key))
+	for i := 0; i < len(key); i += 8 {
+		c.Encrypt(out[i:i+8], key[i:i+8])
+	}
+	return hexString(out)
+}
+
+// cvv reads a PAN, expiry and service code and computes their CVV under a CVK
+func (r *request) cvv(key []byte) string {
+	pan := r.pan()
+	expiry := r.digits(4)
+	serviceCode := r.digits(3)
+	if r.err != "" {
+		return ""
+	}
+	if len(key) != 16 {
+		r.fail("26")
+		return ""
+	}
+
+	cvk := cardcrypto.CVK{A: key[:8], B: key[8:]}
+	cvv, err := cvk.CVV(pan, expiry, serviceCode)
+	if err != nil {
+		r.fail("15")
+	}
+	return cvv
+}
+
+// pinFormat reads a 2-digit PIN block format code
+func (r *request) pinFormat() int {
+	code := string(r.next(2))
+	format, ok := pinFormatCodes[code]
+	if r.err == "" && !ok {
+		r.fail("23")
+	}
+	return format
+}
+
+// decryptPIN decrypts a PIN block of the expected format for a 12-digit account number
+func (r *request) decryptPIN(block []byte, format int, account string, zpk []byte) string {
+	// The account number is the 12 rightmost PAN digits before the check digit
+	pin, got, err := cardcrypto.DecryptPINBlock(block, account+"0", zpk)
+	switch {
+	case errors.Is(err, cardcrypto.ErrPINLength):
+		r.fail("24")
+	case err != nil || got != format:
+		r.fail("20")
+	}
+	return pin
+}
+
+// lmkCipher creates the 3DES cipher of a double- or triple-length LMK
+func lmkCipher(lmk []byte) (cipher.Block, error) {
+	if len(lmk) == 16 {
+		lmk = append(append([]byte{}, lmk...), lmk[:8]...)
+	}
+	return des.NewTripleDESCipher(lmk)
+}
+
+func hexString(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
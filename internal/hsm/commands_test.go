@@ -0,0 +1,214 @@
+package hsm
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+const (
+	testKey     = "0123456789ABCDEFFEDCBA9876543210"
+	testKey2    = "89ABCDEF0123456776543210FEDCBA98"
+	testPAN     = "4111111111111111"
+	testAccount = "111111111111" // 12 rightmost digits before the check digit
+)
+
+// execute sends a command through HandleMessage with a 4-byte header
+func execute(t *testing.T, s *Server, command string) (errorCode, data string) {
+	t.Helper()
+	reply, err := s.HandleMessage([]byte("0001" + command))
+	if err != nil {
+		t.Fatalf("HandleMessage(%s) unexpected error: %v", command[:2], err)
+	}
+	if want := "0001" + ResponseCode(command[:2]); !strings.HasPrefix(string(reply), want) {
+		t.Fatalf("HandleMessage(%s) = %q, want prefix %s", command[:2], reply, want)
+	}
+	return string(reply[6:8]), string(reply[8:])
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestGenerateKey(t *testing.T) {
+	s := NewServer("")
+	for scheme, length := range map[string]int{"Z": 16, "U": 32, "T": 48} {
+		code, data := execute(t, s, "A00000"+scheme)
+		if code != "00" || len(data) != 1+length+6 || data[:1] != scheme {
+			t.Fatalf("A0 scheme %s = %s %q, want a %d-hex key and KCV", scheme, code, data, length)
+		}
+
+		key := mustHex(data[1 : 1+length])
+		if string(cardcrypto.WithOddParity(append([]byte{}, key...))) != string(key) {
+			t.Errorf("A0 scheme %s key %X does not have odd parity", scheme, key)
+		}
+		kcv, _ := cardcrypto.KeyCheckValue(key)
+		if data[1+length:] != strings.ToUpper(hex.EncodeToString(kcv[:3])) {
+			t.Errorf("A0 scheme %s KCV = %s, want %X", scheme, data[1+length:], kcv[:3])
+		}
+	}
+
+	for command, want := range map[string]string{"A01000U": "15", "A000G0U": "04", "A00000Q": "26"} {
+		if code, _ := execute(t, s, command); code != want {
+			t.Errorf("%s error = %s, want %s", command, code, want)
+		}
+	}
+}
+
+func TestGenerateAndVerifyCVV(t *testing.T) {
+	s := NewServer("")
+	cvk, _ := cardcrypto.ParseCVK(testKey)
+	cvv, _ := cvk.CVV(testPAN, "2812", "101")
+
+	code, data := execute(t, s, "CWU"+testKey+testPAN+";2812101")
+	if code != "00" || data != cvv {
+		t.Errorf("CW = %s %q, want 00 %s", code, data, cvv)
+	}
+
+	if code, _ := execute(t, s, "CYU"+testKey+cvv+testPAN+";2812101"); code != "00" {
+		t.Errorf("CY with the CVV error = %s, want 00", code)
+	}
+	if code, _ := execute(t, s, "CYU"+testKey+cvv+testPAN+";2812201"); code != "01" {
+		t.Errorf("CY with another service code error = %s, want 01", code)
+	}
+	if code, _ := execute(t, s, "CWU"+testKey+testPAN+"2812101"); code != "15" {
+		t.Errorf("CW without delimiter error = %s, want 15", code)
+	}
+}
+
+func TestTranslatePIN(t *testing.T) {
+	s := NewServer("")
+	block, _ := cardcrypto.EncryptPINBlock(cardcrypto.PINFormat0, "1234", testPAN, mustHex(testKey), nil)
+	blockHex := strings.ToUpper(hex.EncodeToString(block))
+
+	code, data := execute(t, s, "CCU"+testKey+"U"+testKey2+"12"+blockHex+"0147"+testAccount)
+	if code != "00" || len(data) != 2+16+2 || data[:2] != "04" || data[18:] != "47" {
+		t.Fatalf("CC = %s %q, want 00 04<block>47", code, data)
+	}
+	pin, format, err := cardcrypto.DecryptPINBlock(mustHex(data[2:18]), testPAN, mustHex(testKey2))
+	if err != nil || pin != "1234" || format != cardcrypto.PINFormat3 {
+		t.Errorf("CC translated block decrypts to %q format %d (%v), want 1234 format 3", pin, format, err)
+	}
+
+	tests := []struct {
+		name, command, want string
+	}{
+		{"PIN too long", "CCU" + testKey + "U" + testKey2 + "03" + blockHex + "0101" + testAccount, "24"},
+		{"wrong key", "CCU" + testKey2 + "U" + testKey + "12" + blockHex + "0101" + testAccount, "20"},
+		{"wrong source format", "CCU" + testKey + "U" + testKey2 + "12" + blockHex + "0501" + testAccount, "20"},
+		{"unknown format", "CCU" + testKey + "U" + testKey2 + "12" + blockHex + "0148" + testAccount, "23"},
+	}
+	for _, tt := range tests {
+		if code, _ := execute(t, s, tt.command); code != tt.want {
+			t.Errorf("CC %s error = %s, want %s", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestVerifyPINWithPVV(t *testing.T) {
+	s := NewServer("")
+	block, _ := cardcrypto.EncryptPINBlock(cardcrypto.PINFormat0, "1234", testPAN, mustHex(testKey2), nil)
+	blockHex := strings.ToUpper(hex.EncodeToString(block))
+	pvv, _ := cardcrypto.PVV(mustHex(testKey), testPAN, 1, "1234")
+
+	if code, _ := execute(t, s, "ECU"+testKey2+"U"+testKey+blockHex+"01"+testAccount+"1"+pvv); code != "00" {
+		t.Errorf("EC with the PVV error = %s, want 00", code)
+	}
+	if code, _ := execute(t, s, "ECU"+testKey2+"U"+testKey+blockHex+"01"+testAccount+"2"+pvv); code != "01" {
+		t.Errorf("EC with another PVKI error = %s, want 01", code)
+	}
+}
+
+func TestVerifyARQC(t *testing.T) {
+	s := NewServer("")
+	mk, _ := cardcrypto.MasterKeyOptionA(mustHex(testKey), testPAN, "01")
+	sessionKey, _ := cardcrypto.SessionKey(mk, []byte{0x00, 0x2A})
+	data := mustHex("000000010000000000000000098600000000000986261016008912345600001E0300002A")
+	arqc, _ := cardcrypto.ARQC(sessionKey, data)
+	arpc, _ := cardcrypto.ARPC(sessionKey, arqc, "00")
+
+	// PAN/PSN: 14 rightmost PAN digits and the PSN, as 8 packed bytes
+	fields := func(mode string, arqc []byte) string {
+		return "KQ" + mode + "0U" + testKey + string(mustHex("1111111111111101")) + "\x00\x2A" +
+			string(data[len(data)-11:len(data)-7]) + strings.ToUpper(hex.EncodeToString([]byte{byte(len(data))})) +
+			string(data) + ";" + string(arqc)
+	}
+
+	if code, _ := execute(t, s, fields("0", arqc)); code != "00" {
+		t.Errorf("KQ mode 0 error = %s, want 00", code)
+	}
+	code, response := execute(t, s, fields("1", arqc)+"00")
+	if code != "00" || response != string(arpc) {
+		t.Errorf("KQ mode 1 = %s %X, want 00 %X", code, response, arpc)
+	}
+
+	tampered := append([]byte{}, arqc...)
+	tampered[0] ^= 0xFF
+	if code, _ := execute(t, s, fields("1", tampered)+"00"); code != "01" {
+		t.Errorf("KQ with a tampered ARQC error = %s, want 01", code)
+	}
+	if code, _ := execute(t, s, fields("1", arqc)); code != "15" {
+		t.Errorf("KQ mode 1 without ARC error = %s, want 15", code)
+	}
+
+	// Scheme ID 3: the full PAN, so 19-digit PANs derive the ICC master key with option B
+	for _, pan := range []string{testPAN, "6011000990139424015"} {
+		mk, _ := cardcrypto.DeriveICCMasterKey(mustHex(testKey), pan, "01")
+		sessionKey, _ := cardcrypto.SessionKey(mk, []byte{0x00, 0x2A})
+		arqc, _ := cardcrypto.ARQC(sessionKey, data)
+		command := "KQ03U" + testKey + pan + ";01" + "\x00\x2A" +
+			string(data[len(data)-11:len(data)-7]) + strings.ToUpper(hex.EncodeToString([]byte{byte(len(data))})) +
+			string(data) + ";" + string(arqc)
+		if code, _ := execute(t, s, command); code != "00" {
+			t.Errorf("KQ scheme 3 with a %d-digit PAN error = %s, want 00", len(pan), code)
+		}
+	}
+
+	// Scheme ID 1: the Mastercard session key from the ATC and unpredictable number
+	un := data[len(data)-11 : len(data)-7]
+	mcKey, _ := cardcrypto.SessionKeyMastercard(mk, []byte{0x00, 0x2A}, un)
+	mcARQC, _ := cardcrypto.ARQC(mcKey, data)
+	command := "KQ01U" + testKey + string(mustHex("1111111111111101")) + "\x00\x2A" + string(un) +
+		strings.ToUpper(hex.EncodeToString([]byte{byte(len(data))})) + string(data) + ";" + string(mcARQC)
+	if code, _ := execute(t, s, command); code != "00" {
+		t.Errorf("KQ scheme 1 error = %s, want 00", code)
+	}
+	if code, _ := execute(t, s, fields("0", mcARQC)); code != "01" {
+		t.Errorf("KQ scheme 0 with a Mastercard ARQC error = %s, want 01", code)
+	}
+}
+
+func TestLMK(t *testing.T) {
+	lmk := mustHex(testKey2)
+	s := NewServer("")
+	s.SetLMK(lmk)
+
+	_, data := execute(t, s, "NC")
+	kcv, _ := cardcrypto.KeyCheckValue(lmk)
+	if want := strings.ToUpper(hex.EncodeToString(kcv)) + FirmwareVersion; data != want {
+		t.Errorf("NC = %q, want %q", data, want)
+	}
+
+	// A generated key comes back under the LMK and is usable as-is in later commands
+	_, key := execute(t, s, "A00000U")
+	code, cvv := execute(t, s, "CW"+key[:33]+testPAN+";2812101")
+	if code != "00" {
+		t.Fatalf("CW with the LMK-encrypted key error = %s, want 00", code)
+	}
+
+	clear := mustHex(key[1:33])
+	c, _ := lmkCipher(lmk)
+	for i := 0; i < len(clear); i += 8 {
+		c.Decrypt(clear[i:i+8], clear[i:i+8])
+	}
+	cvk := cardcrypto.CVK{A: clear[:8], B: clear[8:]}
+	if want, _ := cvk.CVV(testPAN, "2812", "101"); cvv != want {
+		t.Errorf("CW under LMK = %s, want %s", cvv, want)
+	}
+}
@@ -0,0 +1,211 @@
+// Package hsm emulates a payment HSM speaking a Thales-style host command protocol
+//
+// FOR TEST/SANDBOX USE ONLY - the emulator holds test keys in memory and is not a
+// security device. It lets services that call an HSM run in CI without one.
+package hsm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+)
+
+// DefaultHeaderLength is the length of the message header echoed in each response
+const DefaultHeaderLength = 4
+
+// Server is a TCP HSM emulator
+//
+// DESIGN RATIONALE:
+// - Messages are <length><header><command code><fields>, as on a Thales payShield:
+// a 2-byte binary length, a fixed-length header echoed back, then a 2-character command
+// - The response code is the command code with its second character incremented
+// (CW -> CX), followed by a 2-digit error code ("00" = no error)
+// - Cryptography is the same cardcrypto code that generates cards, so values the
+// generator produces verify here
+// - Keys are clear hex unless an LMK is set; then they travel encrypted under it
+// - The setters configure the server before Serve: connections read the LMK, header
+// length and framing without locking, so they must not change while serving
+// - FOR TEST/SANDBOX USE ONLY
+//
+// SUPPORTED COMMANDS:
+// A0 Generate a key                  -> A1
+// CW Generate a CVV                  -> CX
+// CY Verify a CVV                    -> CZ
+// CC Translate a PIN from ZPK to ZPK -> CD
+// EC Verify a PIN with a Visa PVV    -> ED
+// KQ Verify an ARQC / generate ARPC  -> KR
+// NC Diagnostics                     -> ND
+type Server struct {
+	addr         string
+	framing      iso.Framing
+	headerLength int
+	lmk          []byte
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer creates an HSM emulator listening on addr (e.g. ":1500") with clear keys
+func NewServer(addr string) *Server {
+	return &Server{
+		addr:         addr,
+		framing:      iso.DefaultFraming,
+		headerLength: DefaultHeaderLength,
+		conns:        make(map[net.Conn]struct{}),
+	}
+}
+
+// SetLMK makes keys in commands and responses travel encrypted under a double- or
+// triple-length test LMK (3DES ECB); nil means clear keys. Call it before Serve.
+func (s *Server) SetLMK(lmk []byte) {
+	s.lmk = lmk
+}
+
+// ParseLMK parses a double- or triple-length test LMK from 32 or 48 hex characters
+func ParseLMK(s string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(s, " ", "")
+	if len(cleaned) != 32 && len(cleaned) != 48 {
+		return nil, fmt.Errorf("LMK must be 32 or 48 hex characters (3DES), got %d", len(cleaned))
+	}
+
+	lmk, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("LMK must be hex: %w", err)
+	}
+	return lmk, nil
+}
+
+// SetHeaderLength changes the length of the message header echoed in responses (before Serve)
+func (s *Server) SetHeaderLength(n int) {
+	s.headerLength = n
+}
+
+// SetFraming changes the length header used to delimit messages (before Serve)
+func (s *Server) SetFraming(framing iso.Framing) {
+	s.framing = framing
+}
+
+// ListenAndServe listens on the configured address and serves connections
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	keys := "clear keys"
+	if s.lmk != nil {
+		keys = "keys under LMK"
+	}
+	log.Printf("HSM emulator listening on %s (header %d, %s)", listener.Addr(), s.headerLength, keys)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				s.wg.Wait()
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops the listener and closes all open connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn processes framed commands from a single connection
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	for {
+		message, err := s.framing.Read(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[%s] read error: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		reply, err := s.HandleMessage(message)
+		if err != nil {
+			log.Printf("[%s] %v", conn.RemoteAddr(), err)
+		}
+
+		if err := s.framing.Write(conn, reply); err != nil {
+			log.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// HandleMessage executes one command message and builds the response message
+//
+// Command failures are reported in the response error code. A message too short to
+// carry a header and command code is also an error: it is answered with error 15
+// after what it has of a header and command code, space-padded.
+func (s *Server) HandleMessage(message []byte) ([]byte, error) {
+	if len(message) < s.headerLength+2 {
+		padded := append(append([]byte{}, message...), bytes.Repeat([]byte(" "), s.headerLength+2-len(message))...)
+		return append(padded, "15"...), fmt.Errorf("message of %d bytes has no command code", len(message))
+	}
+
+	header := message[:s.headerLength]
+	code := string(message[s.headerLength : s.headerLength+2])
+	data, errorCode := s.Execute(code, message[s.headerLength+2:])
+
+	log.Printf("%s -> %s %s %s", code, ResponseCode(code), errorCode, ErrorCodes[errorCode])
+
+	reply := append(append([]byte{}, header...), ResponseCode(code)+errorCode...)
+	return append(reply, data...), nil
+}
+
+// ResponseCode returns the response code of a command: its second character incremented
+func ResponseCode(command string) string {
+	if len(command) != 2 || command[1] == 'Z' || command[1] == '9' {
+		return command
+	}
+	return command[:1] + string(command[1]+1)
+}
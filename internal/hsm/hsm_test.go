@@ -0,0 +1,73 @@
+package hsm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+)
+
+func TestResponseCode(t *testing.T) {
+	tests := map[string]string{"CW": "CX", "A0": "A1", "KQ": "KR", "NC": "ND", "X": "X"}
+	for command, want := range tests {
+		if got := ResponseCode(command); got != want {
+			t.Errorf("ResponseCode(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestParseLMK(t *testing.T) {
+	for _, valid := range []string{"0123456789ABCDEFFEDCBA9876543210", "0123456789ABCDEF FEDCBA9876543210 0123456789ABCDEF"} {
+		if _, err := ParseLMK(valid); err != nil {
+			t.Errorf("ParseLMK(%q) unexpected error: %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"0123456789ABCDEF", "0123456789ABCDEFFEDCBA987654321G"} {
+		if _, err := ParseLMK(invalid); err == nil {
+			t.Errorf("ParseLMK(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	s := NewServer("")
+	go s.Serve(listener)
+	defer s.Close()
+
+	conn, err := net.DialTimeout("tcp", listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	tests := []struct {
+		command, want string
+	}{
+		{"HDR1NC", "HDR1ND000000000000000000" + FirmwareVersion},
+		{"HDR2ZZ", "HDR2ZZ68"},
+		{"HDR3C", "HDR3C 15"},
+	}
+	for _, tt := range tests {
+		if err := iso.DefaultFraming.Write(conn, []byte(tt.command)); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+		reply, err := iso.DefaultFraming.Read(conn)
+		if err != nil {
+			t.Fatalf("Read() unexpected error: %v", err)
+		}
+		if string(reply) != tt.want {
+			t.Errorf("%s reply = %q, want %q", tt.command, reply, tt.want)
+		}
+	}
+
+	if reply, err := s.HandleMessage([]byte("HDR")); err == nil || string(reply) != "HDR   15" {
+		t.Errorf("HandleMessage() without a command code = %q, %v; want \"HDR   15\" and an error", reply, err)
+	}
+}
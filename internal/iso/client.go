@@ -0,0 +1,197 @@
+package iso
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+)
+
+// Client sends ISO-8583 messages to a host over TCP and awaits the response
+//
+// DESIGN RATIONALE:
+// - One connection per exchange keeps the client stateless and simple
+// - The same Timeout bounds dial, write and read so a silent host never hangs tests
+// - Framing and Spec must match the host (see Framing and Spec)
+type Client struct {
+	Addr    string
+	Packer  *Packer
+	Framing Framing
+	Timeout time.Duration
+}
+
+// NewClient creates a client for addr (host:port) using the default 2-byte framing
+func NewClient(addr string, spec *Spec) *Client {
+	return &Client{
+		Addr:    addr,
+		Packer:  NewPacker(spec),
+		Framing: DefaultFraming,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Exchange packs and sends a message, then reads and unpacks the response
+func (c *Client) Exchange(mti string, fields ISO8583Fields) (string, ISO8583Fields, error) {
+	message, err := c.Packer.Pack(mti, fields)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to pack request: %w", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", c.Addr, c.Timeout)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to connect to %s: %w", c.Addr, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return "", nil, err
+	}
+
+	if err := c.Framing.Write(conn, message); err != nil {
+		return "", nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	reply, err := c.Framing.Read(conn)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	responseMTI, responseFields, err := c.Packer.Unpack(reply)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unpack response: %w", err)
+	}
+
+	return responseMTI, responseFields, nil
+}
+
+// SendAuthRequest sends an authorization request and decodes the response
+// Fields are sent in the spec's formats (see DialectFields); the response code is
+// reported as a 1987 response code even when DE39 carries a 1993 action code.
+func (c *Client) SendAuthRequest(request *AuthorizationRequest) (*AuthorizationResponse, error) {
+	spec := c.Packer.Spec()
+	mti, fields, err := c.Exchange(versionedMTI(request.MTI, spec), DialectFields(request.Fields, spec, request.Timestamp))
+	if err != nil {
+		return nil, err
+	}
+
+	responseCode := ResponseCodeOf(fields["39"])
+
+	return &AuthorizationResponse{
+		MTI:          mti,
+		Fields:       fields,
+		ResponseCode: responseCode,
+		ResponseText: ResponseCodes[responseCode],
+		AuthCode:     strings.TrimSpace(fields["38"]),
+		Timestamp:    time.Now(),
+	}, nil
+}
+
+// DiffAuthResponse compares the fields of an authorization request and its response
+//
+// Both sides go through the spec's formats (see DialectFields), so under ISO 8583:1993
+// the request's DE12, DE22 and DE39 are compared as they were sent, not in their 1987
+// shape.
+func (c *Client) DiffAuthResponse(request *AuthorizationRequest, response *AuthorizationResponse) []FieldDiff {
+	spec := c.Packer.Spec()
+	return DiffFields(DialectFields(request.Fields, spec, request.Timestamp), DialectFields(response.Fields, spec, request.Timestamp))
+}
+
+// FieldDiff describes how a field differs between a request and its response
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+	Status   string `json:"status"` // "same", "changed", "added" or "removed"
+}
+
+// DiffFields compares request and response fields, ordered by field number
+func DiffFields(request, response ISO8583Fields) []FieldDiff {
+	keys := map[string]bool{}
+	for key := range request {
+		keys[key] = true
+	}
+	for key := range response {
+		keys[key] = true
+	}
+
+	ordered := make([]string, 0, len(keys))
+	for key := range keys {
+		ordered = append(ordered, key)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, _ := strconv.Atoi(ordered[i])
+		b, _ := strconv.Atoi(ordered[j])
+		return a < b
+	})
+
+	diffs := make([]FieldDiff, 0, len(ordered))
+	for _, key := range ordered {
+		reqValue, inRequest := request[key]
+		respValue, inResponse := response[key]
+
+		status := "same"
+		switch {
+		case !inRequest:
+			status = "added"
+		case !inResponse:
+			status = "removed"
+		case strings.TrimRight(reqValue, " ") != strings.TrimRight(respValue, " "):
+			// Fixed-length alphanumeric fields come back space-padded
+			status = "changed"
+		}
+
+		diffs = append(diffs, FieldDiff{Field: key, Request: reqValue, Response: respValue, Status: status})
+	}
+
+	return diffs
+}
+
+// FormatFieldDiff formats a field diff as a readable table (for debugging)
+//
+// Values are masked (see MaskField), so the table is safe to print to logs.
+func FormatFieldDiff(diffs []FieldDiff) string {
+	markers := map[string]string{"same": " ", "changed": "~", "added": "+", "removed": "-"}
+
+	result := fmt.Sprintf("  %-5s %-40s %-40s\n", "Field", "Request", "Response")
+	for _, diff := range diffs {
+		result += fmt.Sprintf("%s %-5s %-40s %-40s\n", markers[diff.Status], diff.Field,
+			MaskField(diff.Field, diff.Request), MaskField(diff.Field, diff.Response))
+	}
+
+	return result
+}
+
+// MaskField masks the card data in a field value for display: the PAN in DE2, Track 2
+// (DE35) and Track 1 (DE45), and the CVC2 subelement of DE48; other fields are unchanged
+func MaskField(field, value string) string {
+	switch field {
+	case "2":
+		return generator.MaskPAN(value)
+	case "35":
+		// PAN, then the '=' (or 'D') separator
+		if end := strings.IndexAny(value, "=D"); end > 0 {
+			return generator.MaskPAN(value[:end]) + value[end:]
+		}
+		return generator.MaskPAN(value)
+	case "45":
+		// Format code 'B', PAN, then the '^' separator
+		if end := strings.IndexByte(value, '^'); end > 1 {
+			return value[:1] + generator.MaskPAN(value[1:end]) + value[end:]
+		}
+		return generator.MaskPAN(value)
+	case "48":
+		subelements, err := ParsePrivateData(value)
+		if err != nil {
+			return strings.Repeat("*", len(value))
+		}
+		if cvc, ok := subelements[SubelementCVC2]; ok {
+			subelements[SubelementCVC2] = strings.Repeat("*", len(cvc))
+			return FormatPrivateData(subelements)
+		}
+	}
+	return value
+}
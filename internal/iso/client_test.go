@@ -0,0 +1,199 @@
+package iso
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestFraming(t *testing.T) {
+	tests := []struct {
+		name    string
+		framing Framing
+		header  []byte
+	}{
+		{"2-byte binary", Framing{HeaderSize: 2}, []byte{0x00, 0x05}},
+		{"4-byte binary", Framing{HeaderSize: 4}, []byte{0x00, 0x00, 0x00, 0x05}},
+		{"4-byte ASCII", Framing{HeaderSize: 4, ASCII: true}, []byte("0005")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.framing.Write(&buf, []byte("hello")); err != nil {
+				t.Fatalf("Write() unexpected error: %v", err)
+			}
+
+			if !bytes.HasPrefix(buf.Bytes(), tt.header) {
+				t.Errorf("Write() header = %X, want %X", buf.Bytes()[:tt.framing.HeaderSize], tt.header)
+			}
+
+			message, err := tt.framing.Read(&buf)
+			if err != nil {
+				t.Fatalf("Read() unexpected error: %v", err)
+			}
+			if string(message) != "hello" {
+				t.Errorf("Read() = %q, want hello", message)
+			}
+		})
+	}
+
+	if err := (Framing{HeaderSize: 3}).Write(&bytes.Buffer{}, []byte("x")); err == nil {
+		t.Error("Write() with 3-byte header expected error but got none")
+	}
+	if err := (Framing{HeaderSize: 2, ASCII: true}).Write(&bytes.Buffer{}, make([]byte, 100)); err == nil {
+		t.Error("Write() of 100 bytes with 2-digit ASCII header expected error but got none")
+	}
+}
+
+func TestClientSendAuthRequest(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	framing := Framing{HeaderSize: 4, ASCII: true}
+	packer := NewPacker(DefaultSpec())
+
+	// Minimal host: approve everything and echo the request fields
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		message, err := framing.Read(conn)
+		if err != nil {
+			return
+		}
+		mti, fields, err := packer.Unpack(message)
+		if err != nil {
+			return
+		}
+		responseMTI, _ := ResponseMTI(mti)
+		fields["38"] = "123456"
+		fields["39"] = "00"
+		reply, _ := packer.Pack(responseMTI, fields)
+		framing.Write(conn, reply)
+	}()
+
+	client := NewClient(listener.Addr().String(), DefaultSpec())
+	client.Framing = framing
+	client.Timeout = 5 * time.Second
+
+	card := &models.Card{PAN: "4000000000000002", ExpiryMonth: 12, ExpiryYear: 2027}
+	request := GenerateMockAuthRequest(card, 10000, "986")
+
+	response, err := client.SendAuthRequest(request)
+	if err != nil {
+		t.Fatalf("SendAuthRequest() unexpected error: %v", err)
+	}
+
+	if response.MTI != "0110" {
+		t.Errorf("Response MTI = %s, want 0110", response.MTI)
+	}
+	if response.ResponseCode != "00" || response.ResponseText != "Approved" {
+		t.Errorf("Response = [%s] %s, want [00] Approved", response.ResponseCode, response.ResponseText)
+	}
+	if response.AuthCode != "123456" {
+		t.Errorf("AuthCode = %s, want 123456", response.AuthCode)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Host that accepts but never answers
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	client := NewClient(listener.Addr().String(), DefaultSpec())
+	client.Timeout = 100 * time.Millisecond
+
+	if _, _, err := client.Exchange("0800", ISO8583Fields{"11": "000001", "70": "301"}); err == nil {
+		t.Error("Exchange() expected timeout error but got none")
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	request := ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "35": "4000000000000002=2712"}
+	response := ISO8583Fields{"2": "4000000000000002", "4": "000000005000", "39": "00"}
+
+	diffs := DiffFields(request, response)
+
+	want := []struct{ field, status string }{
+		{"2", "same"},
+		{"4", "changed"},
+		{"35", "removed"},
+		{"39", "added"},
+	}
+
+	if len(diffs) != len(want) {
+		t.Fatalf("DiffFields() returned %d diffs, want %d", len(diffs), len(want))
+	}
+	for i, w := range want {
+		if diffs[i].Field != w.field || diffs[i].Status != w.status {
+			t.Errorf("diffs[%d] = %s/%s, want %s/%s", i, diffs[i].Field, diffs[i].Status, w.field, w.status)
+		}
+	}
+
+	if FormatFieldDiff(diffs) == "" {
+		t.Error("FormatFieldDiff() returned empty string")
+	}
+}
+
+func TestDiffAuthResponse(t *testing.T) {
+	iso1993, _ := BundledSpec("iso1993")
+	client := NewClient("127.0.0.1:0", iso1993)
+
+	// A 1993 host echoes DE12 and DE22 in the formats the request was sent in
+	card := &models.Card{PAN: "4000000000000002", ExpiryMonth: 12, ExpiryYear: 2027}
+	request := GenerateMockAuthRequest(card, 10000, "986")
+	fields := DialectFields(request.Fields, iso1993, request.Timestamp)
+	fields["39"] = ActionCode("00")
+	response := &AuthorizationResponse{MTI: "1110", Fields: fields, ResponseCode: "00"}
+
+	for _, diff := range client.DiffAuthResponse(request, response) {
+		want := "same"
+		if diff.Field == "39" {
+			want = "added"
+		}
+		if diff.Status != want {
+			t.Errorf("DiffAuthResponse() field %s = %s (%q -> %q), want %s", diff.Field, diff.Status, diff.Request, diff.Response, want)
+		}
+	}
+}
+
+func TestFormatFieldDiffMasksCardData(t *testing.T) {
+	request := ISO8583Fields{
+		"2":  "4000000000000002",
+		"35": "4000000000000002=2712201",
+		"45": "B4000000000000002^CARDHOLDER/TEST^2712201",
+		"48": FormatPrivateData(map[string]string{SubelementCVC2: "123", "43": "TAVV"}),
+	}
+
+	table := FormatFieldDiff(DiffFields(request, ISO8583Fields{"2": "4000000000000002", "39": "00"}))
+	if strings.Contains(table, "4000000000000002") || strings.Contains(table, "9203123") {
+		t.Errorf("FormatFieldDiff() leaks the PAN or CVC2:\n%s", table)
+	}
+	for _, want := range []string{"400000******0002=2712201", "B400000******0002^CARDHOLDER", "9203***"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("FormatFieldDiff() missing %q:\n%s", want, table)
+		}
+	}
+}
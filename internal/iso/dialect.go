@@ -0,0 +1,134 @@
+package iso
+
+import (
+	"strings"
+	"time"
+)
+
+// ActionCodes maps ISO 8583:1987 response codes to ISO 8583:1993 action codes (DE39 n 3)
+var ActionCodes = map[string]string{
+	"00": "000", // Approved
+	"01": "107", // Refer to card issuer
+	"03": "109", // Invalid merchant
+	"04": "200", // Do not honour, pick up card
+	"05": "100", // Do not honour
+	"12": "902", // Invalid transaction
+	"13": "110", // Invalid amount
+	"14": "111", // Invalid card number
+	"25": "914", // Not able to trace back to original transaction
+	"30": "904", // Format error
+	"41": "208", // Lost card, pick up
+	"43": "209", // Stolen card, pick up
+	"51": "116", // Not sufficient funds
+	"54": "101", // Expired card
+	"55": "117", // Incorrect PIN
+	"57": "119", // Transaction not permitted to cardholder
+	"58": "120", // Transaction not permitted to terminal
+	"61": "121", // Exceeds withdrawal amount limit
+	"62": "104", // Restricted card
+	"63": "122", // Security violation
+	"65": "123", // Exceeds withdrawal frequency limit
+	"75": "106", // Allowable PIN tries exceeded
+	"91": "907", // Card issuer or switch inoperative
+	"94": "913", // Duplicate transmission
+	"96": "909", // System malfunction
+	"N7": "129", // Suspected counterfeit card (no 1993 code for a CVV2 failure)
+}
+
+// ActionCode returns the ISO 8583:1993 action code of a response code
+// Response codes without a mapping (e.g. from custom rules) become 100, do not honour.
+func ActionCode(responseCode string) string {
+	if code, ok := ActionCodes[responseCode]; ok {
+		return code
+	}
+	return "100"
+}
+
+// ResponseCodeOf returns the ISO 8583:1987 response code of a DE39 value
+// Three-digit action codes are mapped back; unmapped ones become 00 (approval range 0xx) or 05.
+func ResponseCodeOf(de39 string) string {
+	de39 = strings.TrimSpace(de39)
+	if len(de39) != 3 || !isDigits(de39) {
+		return de39
+	}
+
+	for responseCode, actionCode := range ActionCodes {
+		if actionCode == de39 {
+			return responseCode
+		}
+	}
+	if de39[0] == '0' {
+		return "00"
+	}
+	return "05"
+}
+
+// DialectFields rewrites fields generated and decided in ISO 8583:1987 shape to the
+// formats of a spec's fields (e.g. ISO 8583:1993); values already in shape are kept
+//
+// DESIGN RATIONALE:
+// - The target format is read from the spec's field table, not its name, so custom
+// dialects with 1993-style fields are converted too
+// - DE39 n 3: response codes become action codes (see ActionCodes)
+// - DE12 of 12 digits: the local time hhmmss becomes YYMMDDhhmmss, dated by now
+// - DE22 of 12 positions: the POS entry mode becomes a POS data code (see posDataCode)
+func DialectFields(fields ISO8583Fields, spec *Spec, now time.Time) ISO8583Fields {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	converted := ISO8583Fields{}
+	for k, v := range fields {
+		converted[k] = v
+	}
+
+	if value, ok := fields["39"]; ok && len(value) == 2 {
+		if de39 := spec.Fields[39]; de39.Type == TypeNumeric && de39.Length == 3 {
+			converted["39"] = ActionCode(value)
+		}
+	}
+	if value, ok := fields["12"]; ok && len(value) == 6 && spec.Fields[12].Length == 12 {
+		converted["12"] = now.Format("060102") + value
+	}
+	if value, ok := fields["22"]; ok && len(value) == 3 && spec.Fields[22].Length == 12 {
+		_, pinPresent := fields["52"]
+		converted["22"] = posDataCode(value, pinPresent)
+	}
+
+	return converted
+}
+
+// posDataCode converts a 1987 POS entry mode (2-digit PAN entry mode and PIN entry
+// capability) to a 12-position ISO 8583:1993 POS data code. Positions: 1 card data input
+// capability, 2 cardholder authentication capability, 3 card capture capability,
+// 4 operating environment, 5 cardholder present, 6 card present, 7 card data input mode,
+// 8 cardholder authentication method, 9 authentication entity, 10 card data output
+// capability, 11 terminal output capability, 12 PIN capture capability.
+func posDataCode(entryMode string, pinPresent bool) string {
+	// 1987 PAN entry modes: 01 manual, 02/90 magnetic stripe, 05/07 chip, 81 e-commerce
+	inputModes := map[string]byte{"01": '6', "02": '2', "05": '5', "07": '5', "81": '6', "90": '2'}
+
+	code := []byte("000000000000")
+	if mode, ok := inputModes[entryMode[:2]]; ok {
+		code[0], code[6] = mode, mode
+	}
+
+	if entryMode[:2] == "81" {
+		code[3], code[4], code[5] = '0', '5', '0' // No terminal, electronic order, card not present
+	} else {
+		code[3], code[4], code[5] = '1', '0', '1' // Attended on premises, cardholder and card present
+	}
+
+	switch entryMode[2] {
+	case '1':
+		code[1], code[11] = '1', 'C' // PIN capable, up to 12 digits
+	case '0':
+		code[11] = '1' // PIN capture capability unknown
+	}
+
+	if pinPresent {
+		code[7], code[8] = '1', '3' // Online PIN, verified by the authorizing agent
+	}
+
+	return string(code)
+}
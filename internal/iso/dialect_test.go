@@ -0,0 +1,60 @@
+package iso
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActionCodes(t *testing.T) {
+	for responseCode := range ResponseCodes {
+		actionCode := ActionCode(responseCode)
+		if len(actionCode) != 3 || !isDigits(actionCode) {
+			t.Errorf("ActionCode(%s) = %q, want 3 digits", responseCode, actionCode)
+		}
+		if back := ResponseCodeOf(actionCode); back != responseCode {
+			t.Errorf("ResponseCodeOf(%s) = %s, want %s", actionCode, back, responseCode)
+		}
+	}
+
+	tests := map[string]string{"Q1": "100", "00": "000", "N7": "129"}
+	for responseCode, want := range tests {
+		if got := ActionCode(responseCode); got != want {
+			t.Errorf("ActionCode(%s) = %s, want %s", responseCode, got, want)
+		}
+	}
+	if got := ResponseCodeOf("001"); got != "00" {
+		t.Errorf("ResponseCodeOf(001) = %s, want 00 (approval range)", got)
+	}
+	if got := ResponseCodeOf("51"); got != "51" {
+		t.Errorf("ResponseCodeOf(51) = %s, want 1987 codes unchanged", got)
+	}
+}
+
+func TestDialectFields(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 30, 45, 0, time.UTC)
+	fields := ISO8583Fields{"12": "123045", "22": "051", "39": "N7", "52": "1B9C1845EB993A7A"}
+
+	iso1987, _ := BundledSpec("iso1987")
+	if converted := DialectFields(fields, iso1987, now); converted["12"] != "123045" || converted["22"] != "051" || converted["39"] != "N7" {
+		t.Errorf("DialectFields(iso1987) = %v, want fields unchanged", converted)
+	}
+
+	iso1993, _ := BundledSpec("iso1993")
+	converted := DialectFields(fields, iso1993, now)
+	want := ISO8583Fields{"12": "261016123045", "22": "51010151300C", "39": "129", "52": "1B9C1845EB993A7A"}
+	for field, value := range want {
+		if converted[field] != value {
+			t.Errorf("DialectFields(iso1993) field %s = %q, want %q", field, converted[field], value)
+		}
+	}
+	if fields["39"] != "N7" {
+		t.Error("DialectFields() modified its input")
+	}
+	if again := DialectFields(converted, iso1993, now); again["12"] != converted["12"] || again["22"] != converted["22"] || again["39"] != "129" {
+		t.Errorf("DialectFields() of converted fields = %v, want them unchanged", again)
+	}
+
+	if code := posDataCode("812", false); code != "600050600000" {
+		t.Errorf("posDataCode(812) = %s, want 600050600000", code)
+	}
+}
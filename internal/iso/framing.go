@@ -0,0 +1,118 @@
+package iso
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MaxFrameSize is the largest message that fits a 2-byte length header
+const MaxFrameSize = 0xFFFF
+
+// Framing describes the length header that delimits messages on a TCP stream
+//
+// DESIGN RATIONALE:
+// - TCP is a byte stream; switches prefix each message with its length
+// - Most hosts use a 2-byte binary header; some use 4 bytes or ASCII digits
+// - The header never includes its own size
+type Framing struct {
+	HeaderSize int  // Header length in bytes (2 or 4)
+	ASCII      bool // Header holds zero-padded decimal digits instead of a binary integer
+}
+
+// DefaultFraming is a 2-byte big-endian binary length header
+var DefaultFraming = Framing{HeaderSize: 2}
+
+// Validate checks that the framing is supported
+func (f Framing) Validate() error {
+	if f.HeaderSize != 2 && f.HeaderSize != 4 {
+		return fmt.Errorf("header size must be 2 or 4 bytes, got %d", f.HeaderSize)
+	}
+	return nil
+}
+
+// maxSize returns the largest message length the header can express
+func (f Framing) maxSize() int {
+	switch {
+	case f.ASCII && f.HeaderSize == 2:
+		return 99
+	case f.ASCII:
+		return 9999
+	case f.HeaderSize == 2:
+		return MaxFrameSize
+	default:
+		return 1 << 20 // 4-byte binary headers are capped to guard against bogus lengths
+	}
+}
+
+// Write writes a message prefixed with its length header
+func (f Framing) Write(w io.Writer, message []byte) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if len(message) > f.maxSize() {
+		return fmt.Errorf("message too large for %d-byte header: %d bytes", f.HeaderSize, len(message))
+	}
+
+	frame := make([]byte, f.HeaderSize, f.HeaderSize+len(message))
+	switch {
+	case f.ASCII:
+		copy(frame, fmt.Sprintf("%0*d", f.HeaderSize, len(message)))
+	case f.HeaderSize == 2:
+		binary.BigEndian.PutUint16(frame, uint16(len(message)))
+	default:
+		binary.BigEndian.PutUint32(frame, uint32(len(message)))
+	}
+	frame = append(frame, message...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// Read reads a message prefixed with its length header
+func (f Framing) Read(r io.Reader) ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, f.HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	var size int
+	switch {
+	case f.ASCII:
+		n, err := strconv.Atoi(string(header))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid ASCII length header: %q", header)
+		}
+		size = n
+	case f.HeaderSize == 2:
+		size = int(binary.BigEndian.Uint16(header))
+	default:
+		size = int(binary.BigEndian.Uint32(header))
+	}
+
+	if size > f.maxSize() {
+		return nil, fmt.Errorf("length header %d exceeds maximum %d", size, f.maxSize())
+	}
+
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("failed to read %d byte message: %w", size, err)
+	}
+
+	return message, nil
+}
+
+// WriteFrame writes a message prefixed with a 2-byte big-endian length header
+func WriteFrame(w io.Writer, message []byte) error {
+	return DefaultFraming.Write(w, message)
+}
+
+// ReadFrame reads a message prefixed with a 2-byte big-endian length header
+func ReadFrame(r io.Reader) ([]byte, error) {
+	return DefaultFraming.Read(r)
+}
@@ -1,10 +1,15 @@
 package iso
 
 import (
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/felipemacedo/cardgen-pro/internal/emv"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
 )
 
 // ISO8583Fields represents common ISO-8583 message fields
@@ -26,59 +31,151 @@ import (
 // 13 - Local Transaction Date
 // 14 - Expiration Date (YYMM)
 // 22 - Point of Service Entry Mode
-// 35 - Track 2 Data
+// 23 - Card Sequence Number (PSN)
+// 35 - Track 2 Data (Track 2 equivalent, 'D' separator)
 // 37 - Retrieval Reference Number
 // 41 - Card Acceptor Terminal ID
 // 42 - Card Acceptor ID Code
+// 45 - Track 1 Data (format B)
 // 49 - Transaction Currency Code
-// 55 - EMV/ICC Data (chip)
+// 52 - PIN Data (encrypted PIN block, hex)
+// 53 - Security Related Control Information (DUKPT KSN, hex)
+// 55 - EMV/ICC Data (chip, BER-TLV as hex)
 // 95 - Replacement Amounts
 type ISO8583Fields map[string]string
 
 // GenerateISO8583Fields generates a map of common ISO-8583 fields for a card
 // This simulates an authorization request message (MTI 0100)
 func GenerateISO8583Fields(card *models.Card, amount int64, currency string) ISO8583Fields {
-	now := time.Now()
+	return GenerateISO8583FieldsAt(card, amount, currency, time.Now())
+}
 
+// GenerateISO8583FieldsAt generates the fields as of a given time
+// Dates, STAN and RRN derive from now, so a fixed time gives reproducible fields.
+// Values are in ISO 8583:1987 shape; DialectFields converts them for other dialects.
+func GenerateISO8583FieldsAt(card *models.Card, amount int64, currency string, now time.Time) ISO8583Fields {
 	fields := ISO8583Fields{
 		"2":  card.PAN,                           // Primary Account Number
 		"3":  "000000",                            // Processing Code (purchase)
 		"4":  fmt.Sprintf("%012d", amount),        // Transaction Amount (12 digits, padded)
 		"7":  now.Format("0102150405"),            // Transmission Date & Time (MMDDhhmmss)
-		"11": generateSTAN(),                      // System Trace Audit Number
+		"11": generateSTAN(now),                   // System Trace Audit Number
 		"12": now.Format("150405"),                // Local Time (hhmmss)
 		"13": now.Format("0102"),                  // Local Date (MMDD)
 		"14": fmt.Sprintf("%02d%02d", card.ExpiryYear%100, card.ExpiryMonth), // Expiry (YYMM)
 		"22": "051",                               // POS Entry Mode (chip with PIN)
-		"37": generateRRN(),                       // Retrieval Reference Number
+		"37": generateRRN(now),                    // Retrieval Reference Number
 		"41": "TERM0001",                          // Terminal ID
 		"42": "MERCHANT000001",                    // Merchant ID
 		"49": currency,                            // Currency Code (e.g., "986" for BRL)
 	}
 
-	// Add Track2 if available
+	// Add Track 2 equivalent data ('D' separator) and Track 1 if available
 	if card.Track2 != "" {
-		fields["35"] = card.Track2
+		fields["35"] = track.Equivalent(card.Track2)
+	}
+	if card.Track1 != "" {
+		fields["45"] = card.Track1
+	}
+
+	// Add chip data, as DE22 declares a chip read (skipped for non-numeric currencies);
+	// cards personalized with an ICC master key send a verifiable ARQC and their PSN
+	masterKey, _ := hex.DecodeString(card.ICCMasterKey)
+	if iccData, err := emv.GenerateICCData(emv.Transaction{
+		PAN:       card.PAN,
+		Brand:     card.Brand,
+		Amount:    amount,
+		Currency:  currency,
+		Time:      now,
+		MasterKey: masterKey,
+	}); err == nil {
+		fields["55"], _ = emv.EncodeHex(iccData)
+	}
+	if card.PANSequence != "" {
+		fields["23"] = "0" + card.PANSequence
+	}
+
+	// Add the encrypted PIN block, as DE22 declares PIN entry, and its DUKPT KSN;
+	// format 4 blocks are 16 bytes and need a dialect whose DE52 is not the 8-byte
+	// field of ISO 8583:1987/1993, and KSNs one whose DE53 is not the 1987 n 16
+	if card.PINBlock != "" {
+		fields["52"] = card.PINBlock
+	}
+	if card.KSN != "" {
+		fields["53"] = card.KSN
+	}
+
+	// Add CVC2 as a private data subelement if available
+	if card.CVC != "" {
+		fields["48"] = FormatPrivateData(map[string]string{SubelementCVC2: card.CVC})
 	}
 
 	return fields
 }
 
+// SubelementCVC2 is the DE48 subelement carrying the CVC2/CVV2 (Mastercard-style)
+const SubelementCVC2 = "92"
+
+// SubelementTokenCryptogram is the DE48 subelement carrying a network token's cryptogram (TAVV)
+const SubelementTokenCryptogram = "43"
+
+// ParsePrivateData parses DE48 as a sequence of subelements: tag (2) + length (2) + value
+func ParsePrivateData(de48 string) (map[string]string, error) {
+	subelements := map[string]string{}
+
+	for i := 0; i < len(de48); {
+		if i+4 > len(de48) {
+			return nil, fmt.Errorf("truncated subelement header at offset %d", i)
+		}
+
+		tag := de48[i : i+2]
+		length, err := strconv.Atoi(de48[i+2 : i+4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid length for subelement %s: %q", tag, de48[i+2:i+4])
+		}
+
+		i += 4
+		if i+length > len(de48) {
+			return nil, fmt.Errorf("subelement %s truncated", tag)
+		}
+
+		subelements[tag] = de48[i : i+length]
+		i += length
+	}
+
+	return subelements, nil
+}
+
+// FormatPrivateData formats subelements as DE48 (ordered by tag)
+func FormatPrivateData(subelements map[string]string) string {
+	tags := make([]string, 0, len(subelements))
+	for tag := range subelements {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	result := ""
+	for _, tag := range tags {
+		result += fmt.Sprintf("%s%02d%s", tag, len(subelements[tag]), subelements[tag])
+	}
+	return result
+}
+
 // generateSTAN generates a System Trace Audit Number (6 digits)
-func generateSTAN() string {
-	return fmt.Sprintf("%06d", time.Now().Unix()%1000000)
+func generateSTAN(now time.Time) string {
+	return fmt.Sprintf("%06d", now.Unix()%1000000)
 }
 
 // generateRRN generates a Retrieval Reference Number (12 chars: YYMMDDHHMMSS)
-func generateRRN() string {
-	return time.Now().Format("060102150405")
+func generateRRN(now time.Time) string {
+	return now.Format("060102150405")
 }
 
 // FormatISO8583 formats ISO fields as a readable string (for debugging)
 func FormatISO8583(fields ISO8583Fields) string {
 	result := "ISO-8583 Fields:\n"
 	
-	fieldOrder := []string{"2", "3", "4", "7", "11", "12", "13", "14", "22", "35", "37", "41", "42", "49"}
+	fieldOrder := []string{"2", "3", "4", "7", "11", "12", "13", "14", "22", "23", "35", "37", "38", "39", "41", "42", "45", "48", "49", "52", "53", "55"}
 	
 	for _, field := range fieldOrder {
 		if value, ok := fields[field]; ok {
@@ -108,10 +205,11 @@ type AuthorizationResponse struct {
 
 // GenerateMockAuthRequest generates a mock ISO-8583 authorization request
 func GenerateMockAuthRequest(card *models.Card, amount int64, currency string) *AuthorizationRequest {
+	now := time.Now()
 	return &AuthorizationRequest{
 		MTI:       "0100", // Authorization request
-		Fields:    GenerateISO8583Fields(card, amount, currency),
-		Timestamp: time.Now(),
+		Fields:    GenerateISO8583FieldsAt(card, amount, currency, now),
+		Timestamp: now,
 	}
 }
 
@@ -132,8 +230,14 @@ func GenerateMockAuthResponse(request *AuthorizationRequest, responseCode string
 		authCode = fmt.Sprintf("AUTH%06d", time.Now().Unix()%1000000)
 	}
 
+	// Authorization response (0110), or the matching response for 0200/0400
+	mti, err := ResponseMTI(request.MTI)
+	if err != nil {
+		mti = "0110"
+	}
+
 	return &AuthorizationResponse{
-		MTI:          "0110", // Authorization response
+		MTI:          mti,
 		Fields:       responseFields,
 		ResponseCode: responseCode,
 		ResponseText: responseText,
@@ -142,6 +246,38 @@ func GenerateMockAuthResponse(request *AuthorizationRequest, responseCode string
 	}
 }
 
+// ResponseMTI returns the response MTI for a request MTI (e.g. 0100 -> 0110)
+func ResponseMTI(mti string) (string, error) {
+	if len(mti) != 4 || !isDigits(mti) {
+		return "", fmt.Errorf("invalid MTI: %q", mti)
+	}
+
+	function := mti[2]
+	if function != '0' && function != '2' {
+		return "", fmt.Errorf("MTI %s is not a request or advice", mti)
+	}
+
+	return mti[:2] + string(function+1) + mti[3:], nil
+}
+
+// Pack packs the request against the given dialect spec, in that dialect's field formats
+func (r *AuthorizationRequest) Pack(spec *Spec) ([]byte, error) {
+	return NewPacker(spec).Pack(versionedMTI(r.MTI, spec), DialectFields(r.Fields, spec, r.Timestamp))
+}
+
+// Pack packs the response against the given dialect spec, in that dialect's field formats
+func (r *AuthorizationResponse) Pack(spec *Spec) ([]byte, error) {
+	return NewPacker(spec).Pack(versionedMTI(r.MTI, spec), DialectFields(r.Fields, spec, r.Timestamp))
+}
+
+// versionedMTI rewrites the MTI version digit to match the spec (e.g. 0100 -> 1100)
+func versionedMTI(mti string, spec *Spec) string {
+	if spec.MTIVersion == "" || len(mti) != 4 {
+		return mti
+	}
+	return spec.MTIVersion + mti[1:]
+}
+
 // ResponseCodes contains common ISO-8583 response codes
 var ResponseCodes = map[string]string{
 	"00": "Approved",
@@ -152,6 +288,7 @@ var ResponseCodes = map[string]string{
 	"12": "Invalid transaction",
 	"13": "Invalid amount",
 	"14": "Invalid card number",
+	"25": "Unable to locate record",
 	"30": "Format error",
 	"41": "Lost card",
 	"43": "Stolen card",
@@ -166,5 +303,7 @@ var ResponseCodes = map[string]string{
 	"65": "Exceeds withdrawal frequency",
 	"75": "PIN tries exceeded",
 	"91": "Issuer unavailable",
+	"94": "Duplicate transmission",
 	"96": "System malfunction",
+	"N7": "Decline for CVV2 failure",
 }
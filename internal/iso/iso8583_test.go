@@ -1,9 +1,11 @@
 package iso
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/felipemacedo/cardgen-pro/internal/emv"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
 )
 
@@ -15,6 +17,7 @@ func TestGenerateISO8583Fields(t *testing.T) {
 		ExpiryMonth: 12,
 		ExpiryYear:  2027,
 		Track2:      "4000000000000002=2712201001234",
+		Track1:      "B4000000000000002^DOE/JOHN^2712201001234",
 	}
 
 	fields := GenerateISO8583Fields(card, 10000, "986")
@@ -54,9 +57,35 @@ func TestGenerateISO8583Fields(t *testing.T) {
 		t.Errorf("Field 49 (Currency) = %s, want 986", fields["49"])
 	}
 
-	// Verify Track2 (field 35) is present
-	if _, ok := fields["35"]; !ok {
-		t.Errorf("Field 35 (Track2) missing")
+	// Verify Track2 (field 35) is sent as Track 2 equivalent data
+	if fields["35"] != "4000000000000002D2712201001234" {
+		t.Errorf("Field 35 (Track2) = %s, want D separator", fields["35"])
+	}
+
+	// Verify chip data (field 55) carries the amount and currency
+	objects, err := emv.DecodeHex(fields["55"])
+	if err != nil {
+		t.Fatalf("Field 55 (ICC data) does not decode: %v", err)
+	}
+	if amount, _ := emv.Find(objects, "9F02"); fmt.Sprintf("%X", amount.Value) != "000000010000" {
+		t.Errorf("Field 55 tag 9F02 = %X, want 000000010000", amount.Value)
+	}
+	if currency, _ := emv.Find(objects, "5F2A"); fmt.Sprintf("%X", currency.Value) != "0986" {
+		t.Errorf("Field 55 tag 5F2A = %X, want 0986", currency.Value)
+	}
+
+	// Verify Track1 (field 45)
+	if fields["45"] != card.Track1 {
+		t.Errorf("Field 45 (Track1) = %s, want %s", fields["45"], card.Track1)
+	}
+
+	// Verify PIN data (field 52) is only sent with a PIN block
+	if _, ok := fields["52"]; ok {
+		t.Errorf("Field 52 present without a PIN block: %s", fields["52"])
+	}
+	card.PINBlock = "2A3D408A1977DDE9"
+	if fields := GenerateISO8583Fields(card, 10000, "986"); fields["52"] != card.PINBlock {
+		t.Errorf("Field 52 (PIN data) = %s, want %s", fields["52"], card.PINBlock)
 	}
 }
 
@@ -0,0 +1,431 @@
+package iso
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Packer converts ISO8583Fields to and from wire bytes for a given Spec
+//
+// DESIGN RATIONALE:
+// - Wire layout: MTI | primary bitmap | [secondary bitmap] | data elements
+// - The secondary bitmap (bit 1) is emitted only when fields 65-128 are present
+// - Binary fields travel as raw bytes but are hex strings in ISO8583Fields
+// - Track 2 separators ('=' or 'D') are BCD nibble 0xD and decode back as 'D'
+type Packer struct {
+	spec *Spec
+}
+
+// NewPacker creates a packer for the given spec
+func NewPacker(spec *Spec) *Packer {
+	return &Packer{spec: spec}
+}
+
+// Spec returns the spec used by the packer
+func (p *Packer) Spec() *Spec {
+	return p.spec
+}
+
+// Pack packs a message using the default ISO 8583:1987 ASCII spec
+func Pack(mti string, fields ISO8583Fields) ([]byte, error) {
+	return NewPacker(DefaultSpec()).Pack(mti, fields)
+}
+
+// Unpack unpacks a message using the default ISO 8583:1987 ASCII spec
+func Unpack(data []byte) (string, ISO8583Fields, error) {
+	return NewPacker(DefaultSpec()).Unpack(data)
+}
+
+// Pack encodes the MTI, bitmap(s) and fields into wire bytes
+func (p *Packer) Pack(mti string, fields ISO8583Fields) ([]byte, error) {
+	numbers, err := fieldNumbers(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.encodeMTI(mti)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := make([]byte, 8)
+	for _, num := range numbers {
+		if num > 64 {
+			bitmap = make([]byte, 16)
+			bitmap[0] |= 0x80
+			break
+		}
+	}
+	for _, num := range numbers {
+		bitmap[(num-1)/8] |= 0x80 >> uint((num-1)%8)
+	}
+	out = append(out, p.encodeBitmap(bitmap)...)
+
+	for _, num := range numbers {
+		spec, ok := p.spec.Fields[num]
+		if !ok {
+			return nil, fmt.Errorf("field %d: not defined in spec %s", num, p.spec.Name)
+		}
+
+		encoded, err := p.encodeField(spec, fields[strconv.Itoa(num)])
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", num, err)
+		}
+		out = append(out, encoded...)
+	}
+
+	return out, nil
+}
+
+// Unpack decodes wire bytes into the MTI and fields
+//
+// When a data element fails to decode, the MTI and the fields decoded before it are
+// returned with the error, so a host can still answer with a format error.
+func (p *Packer) Unpack(data []byte) (string, ISO8583Fields, error) {
+	mti, offset, err := p.decodeMTI(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	bitmap, n, err := p.decodeBitmap(data[offset:])
+	if err != nil {
+		return "", nil, err
+	}
+	offset += n
+
+	if bitmap[0]&0x80 != 0 {
+		secondary, n, err := p.decodeBitmap(data[offset:])
+		if err != nil {
+			return "", nil, fmt.Errorf("secondary %w", err)
+		}
+		bitmap = append(bitmap, secondary...)
+		offset += n
+	}
+
+	fields := ISO8583Fields{}
+	for num := 2; num <= len(bitmap)*8; num++ {
+		if bitmap[(num-1)/8]&(0x80>>uint((num-1)%8)) == 0 {
+			continue
+		}
+
+		spec, ok := p.spec.Fields[num]
+		if !ok {
+			return mti, fields, fmt.Errorf("field %d: not defined in spec %s", num, p.spec.Name)
+		}
+
+		value, n, err := p.decodeField(spec, data[offset:])
+		if err != nil {
+			return mti, fields, fmt.Errorf("field %d: %w", num, err)
+		}
+		fields[strconv.Itoa(num)] = value
+		offset += n
+	}
+
+	if offset != len(data) {
+		return mti, fields, fmt.Errorf("%d trailing bytes after last field", len(data)-offset)
+	}
+
+	return mti, fields, nil
+}
+
+// fieldNumbers returns the sorted numeric field keys of a message
+func fieldNumbers(fields ISO8583Fields) ([]int, error) {
+	numbers := make([]int, 0, len(fields))
+	for key := range fields {
+		num, err := strconv.Atoi(key)
+		if err != nil || num < 2 || num > 128 || num == 65 {
+			return nil, fmt.Errorf("invalid field number: %q", key)
+		}
+		numbers = append(numbers, num)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func (p *Packer) encodeMTI(mti string) ([]byte, error) {
+	if len(mti) != 4 || !isDigits(mti) {
+		return nil, fmt.Errorf("invalid MTI: %q", mti)
+	}
+	if p.spec.Encoding == EncodingBCD {
+		return encodeBCD(mti)
+	}
+	return []byte(mti), nil
+}
+
+func (p *Packer) decodeMTI(data []byte) (string, int, error) {
+	if p.spec.Encoding == EncodingBCD {
+		if len(data) < 2 {
+			return "", 0, fmt.Errorf("message too short for MTI")
+		}
+		mti, err := decodeBCD(data[:2], 4)
+		return mti, 2, err
+	}
+
+	if len(data) < 4 {
+		return "", 0, fmt.Errorf("message too short for MTI")
+	}
+	mti := string(data[:4])
+	if !isDigits(mti) {
+		return "", 0, fmt.Errorf("invalid MTI: %q", mti)
+	}
+	return mti, 4, nil
+}
+
+func (p *Packer) encodeBitmap(bitmap []byte) []byte {
+	if p.spec.BitmapEncoding == EncodingASCII {
+		return []byte(strings.ToUpper(hex.EncodeToString(bitmap)))
+	}
+	return bitmap
+}
+
+func (p *Packer) decodeBitmap(data []byte) ([]byte, int, error) {
+	if p.spec.BitmapEncoding == EncodingASCII {
+		if len(data) < 16 {
+			return nil, 0, fmt.Errorf("bitmap truncated")
+		}
+		bitmap, err := hex.DecodeString(string(data[:16]))
+		if err != nil {
+			return nil, 0, fmt.Errorf("bitmap is not valid hex: %w", err)
+		}
+		return bitmap, 16, nil
+	}
+
+	if len(data) < 8 {
+		return nil, 0, fmt.Errorf("bitmap truncated")
+	}
+	bitmap := make([]byte, 8)
+	copy(bitmap, data[:8])
+	return bitmap, 8, nil
+}
+
+// fieldEncoding returns the effective encoding of a field
+func (p *Packer) fieldEncoding(spec FieldSpec) Encoding {
+	if spec.Encoding != "" {
+		return spec.Encoding
+	}
+	return p.spec.Encoding
+}
+
+func (p *Packer) encodeField(spec FieldSpec, value string) ([]byte, error) {
+	encoding := p.fieldEncoding(spec)
+
+	var data []byte
+	var length int
+
+	switch spec.Type {
+	case TypeBinary:
+		raw, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("binary value is not valid hex: %w", err)
+		}
+		if spec.LengthType == LengthFixed && len(raw) != spec.Length {
+			return nil, fmt.Errorf("length %d bytes, want %d", len(raw), spec.Length)
+		}
+		data, length = raw, len(raw)
+
+	case TypeNumeric, TypeTrack:
+		if !isTrackOrDigits(value, spec.Type) {
+			return nil, fmt.Errorf("invalid characters for type %s: %q", spec.Type, value)
+		}
+		if spec.LengthType == LengthFixed && len(value) < spec.Length {
+			value = strings.Repeat("0", spec.Length-len(value)) + value
+		}
+		length = len(value)
+		if encoding == EncodingBCD {
+			packed, err := encodeBCD(value)
+			if err != nil {
+				return nil, err
+			}
+			data = packed
+		} else {
+			data = []byte(value)
+		}
+
+	default:
+		if spec.LengthType == LengthFixed && len(value) < spec.Length {
+			value += strings.Repeat(" ", spec.Length-len(value))
+		}
+		data, length = []byte(value), len(value)
+	}
+
+	if length > spec.Length {
+		return nil, fmt.Errorf("length %d exceeds maximum %d", length, spec.Length)
+	}
+
+	prefix, err := encodeLengthPrefix(spec.LengthType, length, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(prefix, data...), nil
+}
+
+func (p *Packer) decodeField(spec FieldSpec, data []byte) (string, int, error) {
+	encoding := p.fieldEncoding(spec)
+
+	length, offset, err := decodeLengthPrefix(spec, data, encoding)
+	if err != nil {
+		return "", 0, err
+	}
+	if length > spec.Length {
+		return "", 0, fmt.Errorf("length %d exceeds maximum %d", length, spec.Length)
+	}
+
+	size := length
+	bcd := encoding == EncodingBCD && (spec.Type == TypeNumeric || spec.Type == TypeTrack)
+	if bcd {
+		size = (length + 1) / 2
+	}
+	if len(data) < offset+size {
+		return "", 0, fmt.Errorf("truncated: need %d bytes, have %d", size, len(data)-offset)
+	}
+	raw := data[offset : offset+size]
+
+	var value string
+	switch {
+	case spec.Type == TypeBinary:
+		value = strings.ToUpper(hex.EncodeToString(raw))
+	case bcd:
+		value, err = decodeBCD(raw, length)
+		if err != nil {
+			return "", 0, err
+		}
+	default:
+		value = string(raw)
+	}
+
+	return value, offset + size, nil
+}
+
+func encodeLengthPrefix(lengthType LengthType, length int, encoding Encoding) ([]byte, error) {
+	digits := 0
+	switch lengthType {
+	case LengthFixed:
+		return nil, nil
+	case LengthLLVAR:
+		digits = 2
+	case LengthLLLVAR:
+		digits = 3
+	default:
+		return nil, fmt.Errorf("unknown length type: %s", lengthType)
+	}
+
+	prefix := fmt.Sprintf("%0*d", digits, length)
+	if len(prefix) > digits {
+		return nil, fmt.Errorf("length %d does not fit %s prefix", length, lengthType)
+	}
+	if encoding == EncodingBCD {
+		return encodeBCD(prefix)
+	}
+	return []byte(prefix), nil
+}
+
+func decodeLengthPrefix(spec FieldSpec, data []byte, encoding Encoding) (int, int, error) {
+	digits := 0
+	switch spec.LengthType {
+	case LengthFixed:
+		return spec.Length, 0, nil
+	case LengthLLVAR:
+		digits = 2
+	case LengthLLLVAR:
+		digits = 3
+	default:
+		return 0, 0, fmt.Errorf("unknown length type: %s", spec.LengthType)
+	}
+
+	var prefix string
+	var size int
+	if encoding == EncodingBCD {
+		size = (digits + 1) / 2
+		if len(data) < size {
+			return 0, 0, fmt.Errorf("length prefix truncated")
+		}
+		decoded, err := decodeBCD(data[:size], digits)
+		if err != nil {
+			return 0, 0, err
+		}
+		prefix = decoded
+	} else {
+		size = digits
+		if len(data) < size {
+			return 0, 0, fmt.Errorf("length prefix truncated")
+		}
+		prefix = string(data[:size])
+	}
+
+	length, err := strconv.Atoi(prefix)
+	if err != nil || length < 0 {
+		return 0, 0, fmt.Errorf("invalid length prefix: %q", prefix)
+	}
+	return length, size, nil
+}
+
+// encodeBCD packs digits (and Track 2 separators) two per byte,
+// left-padding odd-length values with a zero nibble
+func encodeBCD(value string) ([]byte, error) {
+	if len(value)%2 != 0 {
+		value = "0" + value
+	}
+
+	out := make([]byte, len(value)/2)
+	for i := 0; i < len(value); i++ {
+		var nibble byte
+		switch c := value[i]; {
+		case c >= '0' && c <= '9':
+			nibble = c - '0'
+		case c == '=' || c == 'D' || c == 'd':
+			nibble = 0xD
+		default:
+			return nil, fmt.Errorf("cannot BCD-encode character %q", c)
+		}
+		out[i/2] |= nibble << uint(4*(1-i%2))
+	}
+	return out, nil
+}
+
+// decodeBCD unpacks the rightmost length digits from packed BCD bytes
+func decodeBCD(data []byte, length int) (string, error) {
+	var sb strings.Builder
+	skip := len(data)*2 - length
+	for i := 0; i < len(data)*2; i++ {
+		nibble := (data[i/2] >> uint(4*(1-i%2))) & 0x0F
+		if i < skip {
+			continue
+		}
+		switch {
+		case nibble <= 9:
+			sb.WriteByte('0' + nibble)
+		case nibble == 0xD:
+			sb.WriteByte('D')
+		default:
+			return "", fmt.Errorf("invalid BCD nibble: %X", nibble)
+		}
+	}
+	return sb.String(), nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isTrackOrDigits(s string, fieldType FieldType) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		if fieldType == TypeTrack && (c == '=' || c == 'D' || c == 'd') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package iso
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPackUnpackASCII(t *testing.T) {
+	fields := ISO8583Fields{
+		"2":  "4000000000000002",
+		"3":  "000000",
+		"4":  "000000010000",
+		"11": "123456",
+		"14": "2712",
+		"35": "4000000000000002=27122011234",
+		"41": "TERM0001",
+		"42": "MERCHANT000001 ",
+		"49": "986",
+	}
+
+	data, err := Pack("0100", fields)
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	// MTI (4) + binary primary bitmap (8) + PAN LLVAR prefix "16"
+	if !bytes.HasPrefix(data, []byte("0100")) {
+		t.Errorf("Pack() does not start with MTI: %q", data[:4])
+	}
+	if string(data[12:14]) != "16" {
+		t.Errorf("Pack() PAN length prefix = %q, want 16", data[12:14])
+	}
+
+	mti, unpacked, err := Unpack(data)
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+
+	if mti != "0100" {
+		t.Errorf("Unpack() MTI = %s, want 0100", mti)
+	}
+
+	for key, want := range fields {
+		if unpacked[key] != want {
+			t.Errorf("Field %s = %q, want %q", key, unpacked[key], want)
+		}
+	}
+}
+
+func TestPackUnpackBCD(t *testing.T) {
+	packer := NewPacker(DefaultSpec().WithEncoding(EncodingBCD))
+
+	fields := ISO8583Fields{
+		"2":  "4000000000006",
+		"3":  "000000",
+		"4":  "000000010000",
+		"35": "4000000000006=2712201",
+		"39": "00",
+		"49": "986",
+	}
+
+	data, err := packer.Pack("0110", fields)
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	// BCD MTI is 2 bytes
+	if !bytes.HasPrefix(data, []byte{0x01, 0x10}) {
+		t.Errorf("Pack() BCD MTI = %X, want 0110", data[:2])
+	}
+
+	mti, unpacked, err := packer.Unpack(data)
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+
+	if mti != "0110" {
+		t.Errorf("Unpack() MTI = %s, want 0110", mti)
+	}
+
+	if unpacked["2"] != fields["2"] {
+		t.Errorf("Field 2 = %q, want %q (odd-length BCD)", unpacked["2"], fields["2"])
+	}
+
+	// Track 2 separator comes back in its BCD 'D' form
+	if unpacked["35"] != "4000000000006D2712201" {
+		t.Errorf("Field 35 = %q, want separator D", unpacked["35"])
+	}
+}
+
+func TestPackSecondaryBitmap(t *testing.T) {
+	fields := ISO8583Fields{
+		"3":   "000000",
+		"70":  "301",
+		"128": "0102030405060708",
+	}
+
+	data, err := Pack("0800", fields)
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	if data[4]&0x80 == 0 {
+		t.Error("Pack() did not set secondary bitmap indicator (bit 1)")
+	}
+
+	_, unpacked, err := Unpack(data)
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+
+	for key, want := range fields {
+		if unpacked[key] != want {
+			t.Errorf("Field %s = %q, want %q", key, unpacked[key], want)
+		}
+	}
+}
+
+func TestPackHexBitmap(t *testing.T) {
+	spec := DefaultSpec()
+	spec.BitmapEncoding = EncodingASCII
+	packer := NewPacker(spec)
+
+	data, err := packer.Pack("0800", ISO8583Fields{"11": "000001", "70": "301"})
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	if string(data[4:20]) != "8020000000000000" {
+		t.Errorf("Pack() primary bitmap = %s, want 8020000000000000", data[4:20])
+	}
+
+	if _, _, err := packer.Unpack(data); err != nil {
+		t.Errorf("Unpack() unexpected error: %v", err)
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mti    string
+		fields ISO8583Fields
+	}{
+		{"Invalid MTI", "01A0", ISO8583Fields{"3": "000000"}},
+		{"Invalid field number", "0100", ISO8583Fields{"abc": "1"}},
+		{"Bitmap field", "0100", ISO8583Fields{"1": "1"}},
+		{"Fixed field too long", "0100", ISO8583Fields{"3": "0000000"}},
+		{"Variable field too long", "0100", ISO8583Fields{"2": "40000000000000000000"}},
+		{"Non-numeric numeric field", "0100", ISO8583Fields{"4": "00000001000X"}},
+		{"Invalid binary", "0100", ISO8583Fields{"52": "ZZ"}},
+		{"Wrong binary length", "0100", ISO8583Fields{"52": "0102"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Pack(tt.mti, tt.fields); err == nil {
+				t.Errorf("Pack() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	valid, err := Pack("0100", ISO8583Fields{"2": "4000000000000002", "3": "000000"})
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", []byte{}},
+		{"MTI only", []byte("0100")},
+		{"Truncated field", valid[:len(valid)-2]},
+		{"Trailing bytes", append(append([]byte{}, valid...), '9')},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Unpack(tt.data); err == nil {
+				t.Errorf("Unpack() expected error but got none")
+			}
+		})
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	fields := ISO8583Fields{
+		"2":  "4000000000000002",
+		"3":  "000000",
+		"4":  "000000010000",
+		"11": "123456",
+		"49": "986",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Pack("0100", fields)
+	}
+}
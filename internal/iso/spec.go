@@ -0,0 +1,209 @@
+package iso
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+//go:embed specs/*.json
+var bundledSpecs embed.FS
+
+// FieldType identifies the ISO-8583 data representation of a field
+type FieldType string
+
+const (
+	TypeNumeric             FieldType = "n"   // Numeric digits 0-9
+	TypeAlphanumeric        FieldType = "an"  // Letters and digits
+	TypeAlphanumericSpecial FieldType = "ans" // Letters, digits and special characters
+	TypeTrack               FieldType = "z"   // Track 2/3 code set (digits and separator)
+	TypeBinary              FieldType = "b"   // Raw bytes (hex encoded in ISO8583Fields)
+)
+
+// LengthType identifies how the length of a field is determined
+type LengthType string
+
+const (
+	LengthFixed  LengthType = "fixed"  // Always exactly Length characters/bytes
+	LengthLLVAR  LengthType = "llvar"  // 2-digit length prefix, up to 99
+	LengthLLLVAR LengthType = "lllvar" // 3-digit length prefix, up to 999
+)
+
+// Encoding identifies how numeric data, MTI and length prefixes are written
+type Encoding string
+
+const (
+	EncodingASCII  Encoding = "ascii"  // One ASCII character per digit
+	EncodingBCD    Encoding = "bcd"    // Packed BCD, two digits per byte
+	EncodingBinary Encoding = "binary" // Raw bytes (bitmaps only)
+)
+
+// FieldSpec describes a single ISO-8583 data element
+//
+// Length is expressed in characters for n/an/ans/z fields and in bytes for
+// b fields. For variable fields it is the maximum length.
+type FieldSpec struct {
+	Description string     `json:"description"`
+	Type        FieldType  `json:"type"`
+	Length      int        `json:"length"`
+	LengthType  LengthType `json:"length_type"`
+	Encoding    Encoding   `json:"encoding,omitempty"` // Optional per-field override of Spec.Encoding
+}
+
+// Spec describes an ISO-8583 dialect: field formats and wire encodings
+//
+// DESIGN RATIONALE:
+// - Every network/acquirer uses a slightly different flavour of ISO-8583
+// - Keeping formats in a table (instead of code) makes dialects swappable
+// - Encoding applies to MTI, length prefixes and numeric/track fields
+// - BitmapEncoding is either binary (8 bytes per bitmap) or ascii (16 hex chars)
+// - MTIVersion (first MTI digit) lets requests be re-versioned per dialect
+type Spec struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	MTIVersion     string            `json:"mti_version,omitempty"`
+	Encoding       Encoding          `json:"encoding"`
+	BitmapEncoding Encoding          `json:"bitmap_encoding"`
+	Fields         map[int]FieldSpec `json:"fields"`
+}
+
+// WithEncoding returns a copy of the spec using a different data encoding
+func (s *Spec) WithEncoding(encoding Encoding) *Spec {
+	clone := *s
+	clone.Encoding = encoding
+	clone.Fields = copyFields(s.Fields)
+	return &clone
+}
+
+// DefaultSpec returns the bundled ISO 8583:1987 spec (ASCII encoding)
+func DefaultSpec() *Spec {
+	spec, err := BundledSpec("iso1987")
+	if err != nil {
+		panic(fmt.Sprintf("bundled iso1987 spec is invalid: %v", err))
+	}
+	return spec
+}
+
+// BundledSpecNames returns the names of the specs shipped with cardgen-pro
+func BundledSpecNames() []string {
+	entries, _ := bundledSpecs.ReadDir("specs")
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BundledSpec returns a bundled spec by name (e.g. "iso1987", "iso1993")
+func BundledSpec(name string) (*Spec, error) {
+	data, err := bundledSpecs.ReadFile("specs/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown spec: %s (available: %s)", name, strings.Join(BundledSpecNames(), ", "))
+	}
+	return ParseSpec(data)
+}
+
+// LoadSpec loads a spec from a JSON file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ResolveSpec returns a bundled spec by name or loads it from a file path
+func ResolveSpec(nameOrPath string) (*Spec, error) {
+	if strings.HasSuffix(nameOrPath, ".json") {
+		return LoadSpec(nameOrPath)
+	}
+	return BundledSpec(nameOrPath)
+}
+
+// ParseSpec parses and validates a JSON spec
+func ParseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate checks that the spec is internally consistent
+func (s *Spec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("spec name is required")
+	}
+	if s.MTIVersion != "" && (len(s.MTIVersion) != 1 || !isDigits(s.MTIVersion)) {
+		return fmt.Errorf("mti_version must be a single digit, got %q", s.MTIVersion)
+	}
+	if s.Encoding != EncodingASCII && s.Encoding != EncodingBCD {
+		return fmt.Errorf("encoding must be ascii or bcd, got %q", s.Encoding)
+	}
+	if s.BitmapEncoding != EncodingBinary && s.BitmapEncoding != EncodingASCII {
+		return fmt.Errorf("bitmap_encoding must be binary or ascii, got %q", s.BitmapEncoding)
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("spec %s defines no fields", s.Name)
+	}
+
+	for num, field := range s.Fields {
+		if err := field.validate(); err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if num < 2 || num > 128 || num == 65 {
+			return fmt.Errorf("field %d: number must be 2-128 (65 is reserved for the tertiary bitmap)", num)
+		}
+	}
+	return nil
+}
+
+func (f FieldSpec) validate() error {
+	switch f.Type {
+	case TypeNumeric, TypeAlphanumeric, TypeAlphanumericSpecial, TypeTrack, TypeBinary:
+	default:
+		return fmt.Errorf("unknown type %q", f.Type)
+	}
+
+	maxLength := 0
+	switch f.LengthType {
+	case LengthFixed:
+		maxLength = 999
+	case LengthLLVAR:
+		maxLength = 99
+	case LengthLLLVAR:
+		maxLength = 999
+	default:
+		return fmt.Errorf("unknown length_type %q", f.LengthType)
+	}
+	if f.Length < 1 || f.Length > maxLength {
+		return fmt.Errorf("length %d out of range 1-%d for %s", f.Length, maxLength, f.LengthType)
+	}
+
+	if f.Encoding != "" && f.Encoding != EncodingASCII && f.Encoding != EncodingBCD {
+		return fmt.Errorf("encoding must be ascii or bcd, got %q", f.Encoding)
+	}
+	return nil
+}
+
+func copyFields(fields map[int]FieldSpec) map[int]FieldSpec {
+	clone := make(map[int]FieldSpec, len(fields))
+	for num, field := range fields {
+		clone[num] = field
+	}
+	return clone
+}
@@ -0,0 +1,168 @@
+package iso
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func TestBundledSpecs(t *testing.T) {
+	names := BundledSpecNames()
+	if len(names) < 2 {
+		t.Fatalf("BundledSpecNames() = %v, want at least iso1987 and iso1993", names)
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			spec, err := BundledSpec(name)
+			if err != nil {
+				t.Fatalf("BundledSpec(%s) unexpected error: %v", name, err)
+			}
+
+			if spec.Name != name {
+				t.Errorf("Spec name = %s, want %s", spec.Name, name)
+			}
+
+			// Every data element except the tertiary bitmap must be described
+			for num := 2; num <= 128; num++ {
+				if num == 65 {
+					continue
+				}
+				if _, ok := spec.Fields[num]; !ok {
+					t.Errorf("Spec %s missing field %d", name, num)
+				}
+			}
+		})
+	}
+
+	if _, err := BundledSpec("iso9999"); err == nil {
+		t.Error("BundledSpec(iso9999) expected error but got none")
+	}
+}
+
+func TestParseSpecValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"Invalid JSON", `{`},
+		{"Missing name", `{"encoding":"ascii","bitmap_encoding":"binary","fields":{"2":{"type":"n","length":19,"length_type":"llvar"}}}`},
+		{"Bad encoding", `{"name":"x","encoding":"ebcdic","bitmap_encoding":"binary","fields":{"2":{"type":"n","length":19,"length_type":"llvar"}}}`},
+		{"No fields", `{"name":"x","encoding":"ascii","bitmap_encoding":"binary","fields":{}}`},
+		{"Unknown type", `{"name":"x","encoding":"ascii","bitmap_encoding":"binary","fields":{"2":{"type":"q","length":19,"length_type":"llvar"}}}`},
+		{"LLVAR too long", `{"name":"x","encoding":"ascii","bitmap_encoding":"binary","fields":{"2":{"type":"n","length":100,"length_type":"llvar"}}}`},
+		{"Field out of range", `{"name":"x","encoding":"ascii","bitmap_encoding":"binary","fields":{"129":{"type":"n","length":1,"length_type":"fixed"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSpec([]byte(tt.json)); err == nil {
+				t.Error("ParseSpec() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestLoadSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.json")
+	custom := `{
+		"name": "custom",
+		"encoding": "bcd",
+		"bitmap_encoding": "ascii",
+		"fields": {
+			"2":  {"description": "PAN", "type": "n", "length": 19, "length_type": "llvar"},
+			"4":  {"description": "Amount", "type": "n", "length": 12, "length_type": "fixed"},
+			"41": {"description": "Terminal", "type": "ans", "length": 8, "length_type": "fixed"}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(custom), 0o600); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+
+	spec, err := ResolveSpec(path)
+	if err != nil {
+		t.Fatalf("ResolveSpec() unexpected error: %v", err)
+	}
+
+	packer := NewPacker(spec)
+	data, err := packer.Pack("0100", ISO8583Fields{"2": "4000000000000002", "4": "10000", "41": "T1"})
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+
+	_, fields, err := packer.Unpack(data)
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+
+	if fields["4"] != "000000010000" {
+		t.Errorf("Field 4 = %q, want zero-padded 000000010000", fields["4"])
+	}
+	if fields["41"] != "T1      " {
+		t.Errorf("Field 41 = %q, want space-padded", fields["41"])
+	}
+}
+
+func TestAuthorizationRequestPackDialects(t *testing.T) {
+	card := &models.Card{
+		PAN:         "4000000000000002",
+		ExpiryMonth: 12,
+		ExpiryYear:  2027,
+		Track2:      "4000000000000002=27122011234",
+		PINBlock:    "1B9C1845EB993A7A",
+		KSN:         "FFFF9876543210E00001",
+	}
+	request := GenerateMockAuthRequest(card, 10000, "986")
+	if iso1993, _ := BundledSpec("iso1993"); DialectFields(request.Fields, iso1993, request.Timestamp)["12"] != request.Timestamp.Format("060102150405") {
+		t.Errorf("iso1993 field 12 = %s, want YYMMDDhhmmss", DialectFields(request.Fields, iso1993, request.Timestamp)["12"])
+	}
+
+	for _, name := range []string{"iso1987", "iso1993"} {
+		t.Run(name, func(t *testing.T) {
+			spec, err := BundledSpec(name)
+			if err != nil {
+				t.Fatalf("BundledSpec(%s) unexpected error: %v", name, err)
+			}
+
+			// The 1987 DE53 is n 16: a 10-byte DUKPT KSN does not fit
+			wantKSN := card.KSN
+			if name == "iso1987" {
+				if _, err := request.Pack(spec); err == nil || !strings.Contains(err.Error(), "field 53") {
+					t.Errorf("Pack() with a KSN error = %v, want a field 53 error", err)
+				}
+				delete(request.Fields, "53")
+				defer func() { request.Fields["53"] = card.KSN }()
+				wantKSN = ""
+			}
+
+			data, err := request.Pack(spec)
+			if err != nil {
+				t.Fatalf("Pack() unexpected error: %v", err)
+			}
+
+			mti, fields, err := NewPacker(spec).Unpack(data)
+			if err != nil {
+				t.Fatalf("Unpack() unexpected error: %v", err)
+			}
+
+			if mti[0] != spec.MTIVersion[0] {
+				t.Errorf("MTI = %s, want version %s", mti, spec.MTIVersion)
+			}
+			if fields["2"] != card.PAN {
+				t.Errorf("Field 2 = %s, want %s", fields["2"], card.PAN)
+			}
+			if fields["55"] != request.Fields["55"] {
+				t.Errorf("Field 55 = %s, want %s", fields["55"], request.Fields["55"])
+			}
+			if fields["52"] != card.PINBlock || fields["53"] != wantKSN {
+				t.Errorf("Fields 52/53 = %s/%s, want %s/%s", fields["52"], fields["53"], card.PINBlock, wantKSN)
+			}
+			if want := DialectFields(request.Fields, spec, request.Timestamp); fields["12"] != want["12"] || fields["22"] != want["22"] {
+				t.Errorf("Fields 12/22 = %s/%s, want %s/%s", fields["12"], fields["22"], want["12"], want["22"])
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// Package keystore keeps named test keys as TR-31 key blocks wrapped under a key block
+// protection key (KBPK)
+//
+// FOR TEST/SANDBOX USE ONLY - it keeps keys out of command lines and config files, but a
+// KBPK in an environment variable is no substitute for an HSM.
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+// ErrNotFound is returned when the store has no key of that name
+var ErrNotFound = errors.New("key not found")
+
+// Key usages (ANSI X9.143) of the keys cardgen-pro uses
+const (
+	UsageBDK     = "B0" // DUKPT base derivation key
+	UsageCVK     = "C0" // Card verification key
+	UsageDEK     = "D0" // Data encryption, e.g. format-preserving PAN tokenization
+	UsageIMK     = "E0" // EMV issuer master key for application cryptograms
+	UsageKEK     = "K0" // Key encryption or wrapping
+	UsageZPK     = "P0" // PIN encryption
+	UsagePVK     = "V0" // PIN verification, other algorithm
+	UsageIBM3624 = "V1" // PIN verification, IBM 3624
+	UsageVisaPVV = "V2" // PIN verification, Visa PVV
+)
+
+// Requirement is what an operation needs of a key: one of the usages, and a mode of use
+// that allows the operation
+type Requirement struct {
+	Name      string // Key role in messages, e.g. "CVK"
+	Usages    []string
+	Operation byte // 'G' generate, 'V' verify, 'E' encrypt, 'D' decrypt, 'X' derive keys
+}
+
+// Requirements of the keys the generator (generate/encrypt) and the authorizer (verify/decrypt) use
+var (
+	CVKGenerate = Requirement{"CVK", []string{UsageCVK}, 'G'}
+	CVKVerify   = Requirement{"CVK", []string{UsageCVK}, 'V'}
+	ZPKEncrypt  = Requirement{"ZPK", []string{UsageZPK}, 'E'}
+	ZPKDecrypt  = Requirement{"ZPK", []string{UsageZPK}, 'D'}
+	BDKDerive   = Requirement{"BDK", []string{UsageBDK}, 'X'}
+	IMKDerive   = Requirement{"IMK", []string{UsageIMK}, 'X'}
+	PVKGenerate = Requirement{"PVK", []string{UsageVisaPVV, UsageIBM3624, UsagePVK}, 'G'}
+	PVKVerify   = Requirement{"PVK", []string{UsageVisaPVV, UsageIBM3624, UsagePVK}, 'V'}
+	DEKEncrypt  = Requirement{"DEK", []string{UsageDEK}, 'E'}
+	DEKDecrypt  = Requirement{"DEK", []string{UsageDEK}, 'D'}
+)
+
+// DefaultModeOfUse returns the mode of use a key of a usage gets unless another is given
+func DefaultModeOfUse(usage string) byte {
+	switch usage {
+	case UsageBDK, UsageIMK:
+		return 'X'
+	case UsageCVK, UsagePVK, UsageIBM3624, UsageVisaPVV:
+		return 'C'
+	case UsageDEK, UsageKEK, UsageZPK:
+		return 'B'
+	default:
+		return 'N'
+	}
+}
+
+// Allows reports whether a mode of use permits an operation
+func Allows(mode, operation byte) bool {
+	switch mode {
+	case operation, 'N':
+		return true
+	case 'B':
+		return operation == 'E' || operation == 'D'
+	case 'C':
+		return operation == 'G' || operation == 'V'
+	default:
+		return false
+	}
+}
+
+// Entry describes a stored key without revealing it
+type Entry struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	KeyUsage      string `json:"key_usage"`
+	Algorithm     string `json:"algorithm"`
+	ModeOfUse     string `json:"mode_of_use"`
+	Exportability string `json:"exportability"`
+	KCV           string `json:"kcv"`
+	KeyBlock      string `json:"key_block"`
+}
+
+// Store is a set of named key blocks, persisted as JSON
+//
+// DESIGN RATIONALE:
+// - Keys only exist in clear in memory; the file holds TR-31 key blocks, whose MAC binds
+// each key to its usage, mode of use and exportability
+// - The KBPK never touches the file: only its check value, to fail fast on the wrong one
+// - Version D (AES) key blocks need a 32-byte KBPK; 16- and 24-byte KBPKs wrap version B
+// (TDES) blocks. Imported blocks of either version are kept as they are
+// - FOR TEST/SANDBOX USE ONLY
+type Store struct {
+	path string
+	kbpk []byte
+	keys map[string]string
+}
+
+// storeFile is the JSON layout of a key store
+type storeFile struct {
+	KBPKCheckValue string            `json:"kbpk_kcv"`
+	Keys           map[string]string `json:"keys"`
+}
+
+// Open loads the key store at path under a KBPK, or starts an empty one if the file does not exist
+func Open(path string, kbpk []byte) (*Store, error) {
+	s := &Store{path: path, kbpk: kbpk, keys: make(map[string]string)}
+	if _, err := versionFor(s.kbpk); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key store %s: %w", path, err)
+	}
+	if kcv := s.kbpkCheckValue(); file.KBPKCheckValue != "" && file.KBPKCheckValue != kcv {
+		return nil, fmt.Errorf("key store %s is under a KBPK with check value %s, not %s", path, file.KBPKCheckValue, kcv)
+	}
+	for name, block := range file.Keys {
+		s.keys[name] = block
+	}
+	return s, nil
+}
+
+// ParseKBPK parses a KBPK from 32 or 48 hex characters (TDES) or 64 (AES-256)
+func ParseKBPK(s string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(s, " ", "")
+	if len(cleaned) != 32 && len(cleaned) != 48 && len(cleaned) != 64 {
+		return nil, fmt.Errorf("KBPK must be 32, 48 or 64 hex characters, got %d", len(cleaned))
+	}
+
+	kbpk, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("KBPK must be hex: %w", err)
+	}
+	return kbpk, nil
+}
+
+// Has reports whether the store has a key of that name
+func (s *Store) Has(name string) bool {
+	_, ok := s.keys[name]
+	return ok
+}
+
+// Add wraps a key under the KBPK and stores it, replacing a key of the same name
+//
+// The header's version is set from the KBPK; the key block is returned.
+func (s *Store) Add(name string, header cardcrypto.TR31Header, key []byte) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("key name must not be empty")
+	}
+	version, err := versionFor(s.kbpk)
+	if err != nil {
+		return "", err
+	}
+	header.Version = version
+
+	block, err := cardcrypto.WrapTR31(s.kbpk, header, key, nil)
+	if err != nil {
+		return "", err
+	}
+	s.keys[name] = block
+	return block, s.save()
+}
+
+// Import verifies a key block wrapped under the KBPK and stores it as-is
+func (s *Store) Import(name, block string) error {
+	if name == "" {
+		return fmt.Errorf("key name must not be empty")
+	}
+	block = strings.TrimSpace(block)
+	if _, _, err := cardcrypto.UnwrapTR31(s.kbpk, block); err != nil {
+		return err
+	}
+	s.keys[name] = block
+	return s.save()
+}
+
+// Export returns the key block of a key, rewrapped under kbpk when it is not nil
+//
+// Only exportable ('E') keys can be rewrapped for another KBPK.
+func (s *Store) Export(name string, kbpk []byte) (string, error) {
+	block, ok := s.keys[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if kbpk == nil {
+		return block, nil
+	}
+
+	header, key, err := cardcrypto.UnwrapTR31(s.kbpk, block)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	if header.Exportability != 'E' {
+		return "", fmt.Errorf("%s: key is not exportable (exportability %c)", name, header.Exportability)
+	}
+	header.Version, err = versionFor(kbpk)
+	if err != nil {
+		return "", err
+	}
+	return cardcrypto.WrapTR31(kbpk, header, key, nil)
+}
+
+// Key unwraps a key for an operation, enforcing its usage and mode of use
+func (s *Store) Key(name string, required Requirement) ([]byte, error) {
+	block, ok := s.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	header, key, err := cardcrypto.UnwrapTR31(s.kbpk, block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if !contains(required.Usages, header.KeyUsage) {
+		return nil, fmt.Errorf("%s: key usage %s cannot serve as a %s (needs %s)",
+			name, header.KeyUsage, required.Name, strings.Join(required.Usages, ", "))
+	}
+	if !Allows(header.ModeOfUse, required.Operation) {
+		return nil, fmt.Errorf("%s: mode of use %c does not allow %s operation %c",
+			name, header.ModeOfUse, required.Name, required.Operation)
+	}
+	return key, nil
+}
+
+// List returns the stored keys sorted by name, with their headers and check values
+func (s *Store) List() ([]Entry, error) {
+	entries := make([]Entry, 0, len(s.keys))
+	for name, block := range s.keys {
+		header, key, err := cardcrypto.UnwrapTR31(s.kbpk, block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		kcv, err := CheckValue(header.Algorithm, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entries = append(entries, Entry{
+			Name:          name,
+			Version:       string(header.Version),
+			KeyUsage:      header.KeyUsage,
+			Algorithm:     string(header.Algorithm),
+			ModeOfUse:     string(header.ModeOfUse),
+			Exportability: string(header.Exportability),
+			KCV:           kcv,
+			KeyBlock:      block,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// CheckValue returns the check value of a key: 6 hex of encrypted zeros for TDES ('T'),
+// 10 hex of the CMAC of zeros for AES ('A')
+func CheckValue(algorithm byte, key []byte) (string, error) {
+	var kcv []byte
+	var err error
+	switch algorithm {
+	case 'T':
+		kcv, err = cardcrypto.KeyCheckValue(key)
+		kcv = kcv[:min(3, len(kcv))]
+	case 'A':
+		kcv, err = cardcrypto.AESKeyCheckValue(key)
+		kcv = kcv[:min(5, len(kcv))]
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(kcv)), nil
+}
+
+// kbpkCheckValue returns the check value recorded in the file for the KBPK
+func (s *Store) kbpkCheckValue() string {
+	algorithm := byte('T')
+	if len(s.kbpk) == 32 {
+		algorithm = 'A'
+	}
+	kcv, _ := CheckValue(algorithm, s.kbpk)
+	return kcv
+}
+
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(storeFile{KBPKCheckValue: s.kbpkCheckValue(), Keys: s.keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// versionFor returns the key block version a KBPK wraps: B for TDES, D for AES-256
+func versionFor(kbpk []byte) (byte, error) {
+	switch len(kbpk) {
+	case 16, 24:
+		return cardcrypto.TR31VersionB, nil
+	case 32:
+		return cardcrypto.TR31VersionD, nil
+	default:
+		return 0, fmt.Errorf("KBPK must be 16 or 24 bytes (TDES) or 32 bytes (AES-256), got %d", len(kbpk))
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
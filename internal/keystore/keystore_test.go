@@ -0,0 +1,153 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+)
+
+var (
+	testKBPK, _ = hex.DecodeString("89E88CF7931444F334BD7547FC3F380C")
+	testKey, _  = hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+)
+
+func header(usage string, mode, exportability byte) cardcrypto.TR31Header {
+	return cardcrypto.TR31Header{KeyUsage: usage, Algorithm: 'T', ModeOfUse: mode, KeyVersion: "00", Exportability: exportability}
+}
+
+func TestStoreKey(t *testing.T) {
+	s, err := Open("", testKBPK)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	s.Add("cvk", header(UsageCVK, 'C', 'E'), testKey)
+	s.Add("cvk-verify", header(UsageCVK, 'V', 'E'), testKey)
+	s.Add("zpk", header(UsageZPK, 'E', 'N'), testKey)
+	s.Add("dek", header(UsageDEK, DefaultModeOfUse(UsageDEK), 'E'), testKey)
+
+	tests := []struct {
+		name     string
+		required Requirement
+		ok       bool
+	}{
+		{"cvk", CVKGenerate, true},
+		{"cvk", CVKVerify, true},
+		{"cvk-verify", CVKVerify, true},
+		{"cvk-verify", CVKGenerate, false}, // Mode of use V does not generate
+		{"zpk", ZPKEncrypt, true},
+		{"zpk", ZPKDecrypt, false},
+		{"cvk", ZPKEncrypt, false}, // Usage C0 is not a PIN key
+		{"dek", DEKEncrypt, true},
+		{"dek", DEKDecrypt, true},
+		{"zpk", DEKEncrypt, false},
+	}
+	for _, tt := range tests {
+		key, err := s.Key(tt.name, tt.required)
+		if tt.ok && (err != nil || !bytes.Equal(key, testKey)) {
+			t.Errorf("Key(%s, %s %c) = %X, %v; want the key", tt.name, tt.required.Name, tt.required.Operation, key, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("Key(%s, %s %c) expected error", tt.name, tt.required.Name, tt.required.Operation)
+		}
+	}
+
+	if _, err := s.Key("missing", CVKGenerate); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Key(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreImportExport(t *testing.T) {
+	s, _ := Open("", testKBPK)
+	s.Add("bdk", header(UsageBDK, 'X', 'E'), testKey)
+	s.Add("imk", header(UsageIMK, 'X', 'N'), testKey)
+
+	// Export under a partner's AES KBPK and import it into their store
+	partnerKBPK := bytes.Repeat([]byte{0x5A}, 32)
+	block, err := s.Export("bdk", partnerKBPK)
+	if err != nil || block[0] != 'D' || !strings.HasPrefix(block[5:], "B0TX00E") {
+		t.Fatalf("Export(bdk) = %q, %v; want a version D B0 block", block, err)
+	}
+	partner, _ := Open("", partnerKBPK)
+	if err := partner.Import("bdk", block); err != nil {
+		t.Fatalf("Import() unexpected error: %v", err)
+	}
+	if key, err := partner.Key("bdk", BDKDerive); err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("imported Key(bdk) = %X, %v; want %X", key, err, testKey)
+	}
+
+	if _, err := s.Export("imk", partnerKBPK); err == nil {
+		t.Error("Export(non-exportable key) expected error")
+	}
+	if own, _ := s.Export("imk", nil); own != s.keys["imk"] {
+		t.Errorf("Export(imk, nil) = %q, want the stored block", own)
+	}
+	if err := s.Import("bdk2", block); err == nil {
+		t.Error("Import(block under another KBPK) expected error")
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	s, _ := Open(path, testKBPK)
+	if _, err := s.Add("pvk", header(UsageVisaPVV, 'C', 'E'), testKey); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("0123456789ABCDEF")) {
+		t.Error("key store file contains the clear key")
+	}
+
+	reopened, err := Open(path, testKBPK)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	entries, err := reopened.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List() = %v, %v; want 1 entry", entries, err)
+	}
+	if e := entries[0]; e.Name != "pvk" || e.KeyUsage != UsageVisaPVV || e.ModeOfUse != "C" || e.KCV != "08D7B4" {
+		t.Errorf("List() = %+v, want pvk V2 C with KCV 08D7B4", e)
+	}
+
+	wrongKBPK := bytes.Repeat([]byte{0x11}, 16)
+	if _, err := Open(path, wrongKBPK); err == nil {
+		t.Error("Open(wrong KBPK) expected error")
+	}
+}
+
+func TestCheckValue(t *testing.T) {
+	if kcv, err := CheckValue('T', testKey); err != nil || kcv != "08D7B4" {
+		t.Errorf("CheckValue(T) = %s, %v; want 08D7B4", kcv, err)
+	}
+	aesKey, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	if kcv, err := CheckValue('A', aesKey); err != nil || kcv != "7AD386C376" {
+		t.Errorf("CheckValue(A) = %s, %v; want 7AD386C376", kcv, err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		mode, operation byte
+		want            bool
+	}{
+		{'N', 'G', true},
+		{'C', 'G', true},
+		{'C', 'E', false},
+		{'B', 'D', true},
+		{'E', 'D', false},
+		{'X', 'X', true},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.mode, tt.operation); got != tt.want {
+			t.Errorf("Allows(%c, %c) = %v, want %v", tt.mode, tt.operation, got, tt.want)
+		}
+	}
+}
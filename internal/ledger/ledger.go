@@ -0,0 +1,411 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+)
+
+var (
+	// ErrNotFound is returned when no transaction matches the reference
+	ErrNotFound = errors.New("transaction not found")
+	// ErrInvalidState is returned when a transition is not allowed from the current status
+	ErrInvalidState = errors.New("invalid transaction state")
+	// ErrAmountExceeded is returned when a capture or refund exceeds the allowed amount
+	ErrAmountExceeded = errors.New("amount exceeds allowed total")
+	// ErrDuplicate is returned when an authorization with the same RRN is already recorded
+	ErrDuplicate = errors.New("duplicate RRN")
+)
+
+// Status is the lifecycle state of a transaction
+type Status string
+
+const (
+	StatusAuthorized        Status = "authorized"
+	StatusCaptured          Status = "captured"
+	StatusPartiallyRefunded Status = "partially_refunded"
+	StatusRefunded          Status = "refunded"
+	StatusVoided            Status = "voided"
+	StatusReversed          Status = "reversed"
+)
+
+// Event records a single lifecycle transition
+type Event struct {
+	Type   string    `json:"type"` // "authorization", "capture", "void", "refund" or "reversal"
+	Amount int64     `json:"amount"`
+	At     time.Time `json:"at"`
+}
+
+// Transaction is an approved authorization and everything that happened to it
+type Transaction struct {
+	RRN        string    `json:"rrn"`       // DE37, primary reference
+	STAN       string    `json:"stan"`      // DE11, used to match reversals
+	AuthCode   string    `json:"auth_code"` // DE38, secondary reference
+	MaskedPAN  string    `json:"masked_pan"`
+	Currency   string    `json:"currency"`
+	Authorized int64     `json:"authorized"`
+	Captured   int64     `json:"captured"`
+	Refunded   int64     `json:"refunded"`
+	Status     Status    `json:"status"`
+	History    []Event   `json:"history"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Ledger tracks transactions and enforces the capture/void/refund/reversal lifecycle
+//
+// DESIGN RATIONALE:
+// - In-memory by default; with a path every change is written to a JSON file
+// - Transactions are keyed by RRN and can also be looked up by auth code
+// - Only masked PANs are stored, so persisted files never contain card numbers
+// - FOR TEST/SANDBOX USE ONLY
+//
+// LIFECYCLE RULES:
+// capture  - once, only while authorized, amount <= authorized (0 = full amount)
+// void     - only while authorized (before capture)
+// refund   - after capture, repeatable while total refunds <= captured
+// reversal - while authorized or captured without refunds, matched by original STAN (DE90 or DE11) and PAN
+type Ledger struct {
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+	path         string
+	now          func() time.Time
+}
+
+// New creates an in-memory ledger
+func New() *Ledger {
+	return &Ledger{
+		transactions: make(map[string]*Transaction),
+		now:          time.Now,
+	}
+}
+
+// Open creates a ledger persisted to path, loading existing transactions if the file exists
+func Open(path string) (*Ledger, error) {
+	l := New()
+	l.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []*Transaction
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return nil, fmt.Errorf("failed to parse ledger %s: %w", path, err)
+	}
+	for _, txn := range transactions {
+		l.transactions[txn.RRN] = txn
+	}
+
+	return l, nil
+}
+
+// Record stores an approved authorization (x100) or financial request (x200, captured immediately)
+func (l *Ledger) Record(request *iso.AuthorizationRequest, response *iso.AuthorizationResponse) (*Transaction, error) {
+	if response.ResponseCode != "00" {
+		return nil, fmt.Errorf("%w: only approved requests are recorded", ErrInvalidState)
+	}
+
+	fields := request.Fields
+	rrn := strings.TrimSpace(fields["37"])
+	if rrn == "" {
+		return nil, fmt.Errorf("missing RRN (field 37)")
+	}
+
+	amount, err := strconv.ParseInt(fields["4"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount (field 4): %q", fields["4"])
+	}
+
+	authCode := strings.TrimSpace(response.Fields["38"])
+	if authCode == "" {
+		authCode = response.AuthCode
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.transactions[rrn]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicate, rrn)
+	}
+
+	now := l.now()
+	txn := &Transaction{
+		RRN:        rrn,
+		STAN:       fields["11"],
+		AuthCode:   authCode,
+		MaskedPAN:  generator.MaskPAN(fields["2"]),
+		Currency:   fields["49"],
+		Authorized: amount,
+		Status:     StatusAuthorized,
+		History:    []Event{{Type: "authorization", Amount: amount, At: now}},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if len(request.MTI) == 4 && request.MTI[1:] == "200" {
+		txn.Captured = amount
+		txn.Status = StatusCaptured
+		txn.History = append(txn.History, Event{Type: "capture", Amount: amount, At: now})
+	}
+
+	l.transactions[rrn] = txn
+	if err := l.save(); err != nil {
+		delete(l.transactions, rrn)
+		return nil, err
+	}
+	return txn.clone(), nil
+}
+
+// Get returns the transaction for an RRN or auth code
+func (l *Ledger) Get(ref string) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	txn, err := l.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	return txn.clone(), nil
+}
+
+// List returns all transactions, oldest first
+func (l *Ledger) List() []*Transaction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := make([]*Transaction, 0, len(l.transactions))
+	for _, txn := range l.transactions {
+		list = append(list, txn.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].RRN < list[j].RRN
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Capture captures an authorization; amount 0 captures the full authorized amount
+func (l *Ledger) Capture(ref string, amount int64) (*Transaction, error) {
+	return l.update(ref, func(txn *Transaction) (string, int64, error) {
+		if txn.Status != StatusAuthorized {
+			return "", 0, fmt.Errorf("%w: cannot capture a %s transaction", ErrInvalidState, txn.Status)
+		}
+		if amount == 0 {
+			amount = txn.Authorized
+		}
+		if amount < 0 || amount > txn.Authorized {
+			return "", 0, fmt.Errorf("%w: capture %d, authorized %d", ErrAmountExceeded, amount, txn.Authorized)
+		}
+
+		txn.Captured = amount
+		txn.Status = StatusCaptured
+		return "capture", amount, nil
+	})
+}
+
+// Void cancels an authorization that has not been captured
+func (l *Ledger) Void(ref string) (*Transaction, error) {
+	return l.update(ref, func(txn *Transaction) (string, int64, error) {
+		if txn.Status != StatusAuthorized {
+			return "", 0, fmt.Errorf("%w: cannot void a %s transaction", ErrInvalidState, txn.Status)
+		}
+
+		txn.Status = StatusVoided
+		return "void", txn.Authorized, nil
+	})
+}
+
+// Refund refunds part or all of the captured amount; amount 0 refunds the remaining balance
+func (l *Ledger) Refund(ref string, amount int64) (*Transaction, error) {
+	return l.update(ref, func(txn *Transaction) (string, int64, error) {
+		if txn.Status != StatusCaptured && txn.Status != StatusPartiallyRefunded {
+			return "", 0, fmt.Errorf("%w: cannot refund a %s transaction", ErrInvalidState, txn.Status)
+		}
+
+		remaining := txn.Captured - txn.Refunded
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount < 0 || amount > remaining {
+			return "", 0, fmt.Errorf("%w: refund %d, refundable %d", ErrAmountExceeded, amount, remaining)
+		}
+
+		txn.Refunded += amount
+		txn.Status = StatusPartiallyRefunded
+		if txn.Refunded == txn.Captured {
+			txn.Status = StatusRefunded
+		}
+		return "refund", amount, nil
+	})
+}
+
+// Reverse applies a reversal (0400) to the authorization with the original STAN
+//
+// The original STAN is taken from DE90 (original data elements, positions 5-10)
+// when present, otherwise from DE11. When DE2 is present the PAN must match too.
+// A captured transaction with no refunds, such as an x200 financial request, is
+// reversed for its captured amount.
+func (l *Ledger) Reverse(fields iso.ISO8583Fields) (*Transaction, error) {
+	stan := fields["11"]
+	if de90 := fields["90"]; len(de90) >= 10 {
+		stan = de90[4:10]
+	}
+	if stan == "" {
+		return nil, fmt.Errorf("%w: missing original STAN", ErrNotFound)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var match *Transaction
+	for _, txn := range l.transactions {
+		if txn.STAN != stan {
+			continue
+		}
+		if fields["2"] != "" && txn.MaskedPAN != generator.MaskPAN(fields["2"]) {
+			continue
+		}
+		// Prefer the most recent authorization when STANs have wrapped
+		if match == nil || txn.newer(match) {
+			match = txn
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: no authorization with STAN %s", ErrNotFound, stan)
+	}
+
+	return l.apply(match, func(txn *Transaction) (string, int64, error) {
+		switch txn.Status {
+		case StatusAuthorized:
+			txn.Status = StatusReversed
+			return "reversal", txn.Authorized, nil
+		case StatusCaptured:
+			txn.Status = StatusReversed
+			return "reversal", txn.Captured, nil
+		default:
+			return "", 0, fmt.Errorf("%w: cannot reverse a %s transaction", ErrInvalidState, txn.Status)
+		}
+	})
+}
+
+// update looks up a transaction and applies a transition to it
+func (l *Ledger) update(ref string, transition func(*Transaction) (string, int64, error)) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	txn, err := l.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	return l.apply(txn, transition)
+}
+
+// apply runs a transition, records the event and persists the ledger (caller holds the lock)
+//
+// A transition the ledger file cannot record is rolled back, so memory and file agree.
+func (l *Ledger) apply(txn *Transaction, transition func(*Transaction) (string, int64, error)) (*Transaction, error) {
+	updated := txn.clone()
+	eventType, amount, err := transition(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	now := l.now()
+	updated.History = append(updated.History, Event{Type: eventType, Amount: amount, At: now})
+	updated.UpdatedAt = now
+	previous := *txn
+	*txn = *updated
+	if err := l.save(); err != nil {
+		*txn = previous
+		return nil, err
+	}
+	return txn.clone(), nil
+}
+
+// find resolves an RRN or auth code (caller holds the lock)
+func (l *Ledger) find(ref string) (*Transaction, error) {
+	if txn, ok := l.transactions[ref]; ok {
+		return txn, nil
+	}
+
+	var match *Transaction
+	for _, txn := range l.transactions {
+		if ref != "" && txn.AuthCode == ref && (match == nil || txn.newer(match)) {
+			match = txn
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return match, nil
+}
+
+// save writes all transactions to the ledger file, if any (caller holds the lock)
+func (l *Ledger) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	list := make([]*Transaction, 0, len(l.transactions))
+	for _, txn := range l.transactions {
+		list = append(list, txn)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RRN < list[j].RRN })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// ResponseCode maps the result of a ledger operation to a DE39 response code
+func ResponseCode(err error) string {
+	switch {
+	case err == nil:
+		return "00"
+	case errors.Is(err, ErrNotFound):
+		return "25" // Unable to locate record
+	case errors.Is(err, ErrAmountExceeded):
+		return "13" // Invalid amount
+	case errors.Is(err, ErrDuplicate):
+		return "94" // Duplicate transmission
+	default:
+		return "12" // Invalid transaction
+	}
+}
+
+// newer orders transactions by creation time, then RRN
+func (t *Transaction) newer(other *Transaction) bool {
+	if t.CreatedAt.Equal(other.CreatedAt) {
+		return t.RRN > other.RRN
+	}
+	return t.CreatedAt.After(other.CreatedAt)
+}
+
+func (t *Transaction) clone() *Transaction {
+	c := *t
+	c.History = append([]Event(nil), t.History...)
+	return &c
+}
@@ -0,0 +1,194 @@
+package ledger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+)
+
+func record(t *testing.T, l *Ledger, mti, rrn, stan string, amount string) *Transaction {
+	t.Helper()
+
+	request := &iso.AuthorizationRequest{
+		MTI:    mti,
+		Fields: iso.ISO8583Fields{"2": "4000000000000002", "4": amount, "11": stan, "37": rrn, "49": "986"},
+	}
+	response := iso.GenerateMockAuthResponse(request, "00", "Approved")
+	response.Fields["38"] = "123456"
+
+	txn, err := l.Record(request, response)
+	if err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+	return txn
+}
+
+func TestRecord(t *testing.T) {
+	l := New()
+
+	auth := record(t, l, "0100", "000000000001", "000001", "000000010000")
+	if auth.Status != StatusAuthorized || auth.Authorized != 10000 {
+		t.Errorf("Record(0100) = %s/%d, want authorized/10000", auth.Status, auth.Authorized)
+	}
+	if auth.MaskedPAN != "400000******0002" {
+		t.Errorf("MaskedPAN = %s, want 400000******0002", auth.MaskedPAN)
+	}
+
+	financial := record(t, l, "0200", "000000000002", "000002", "000000005000")
+	if financial.Status != StatusCaptured || financial.Captured != 5000 {
+		t.Errorf("Record(0200) = %s/%d, want captured/5000", financial.Status, financial.Captured)
+	}
+
+	request := &iso.AuthorizationRequest{MTI: "0100", Fields: iso.ISO8583Fields{"4": "000000000100", "37": "000000000001"}}
+	if _, err := l.Record(request, &iso.AuthorizationResponse{ResponseCode: "00"}); !errors.Is(err, ErrDuplicate) || ResponseCode(err) != "94" {
+		t.Errorf("Record() duplicate RRN error = %v, want ErrDuplicate (94)", err)
+	}
+	if _, err := l.Record(request, &iso.AuthorizationResponse{ResponseCode: "05"}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Record() declined error = %v, want ErrInvalidState", err)
+	}
+
+	if txn, err := l.Get("123456"); err != nil || txn.RRN != "000000000002" {
+		t.Errorf("Get(auth code) = %v, %v; want latest transaction with that code", txn, err)
+	}
+	if len(l.List()) != 2 {
+		t.Errorf("List() returned %d transactions, want 2", len(l.List()))
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	l := New()
+	record(t, l, "0100", "000000000001", "000001", "000000010000")
+
+	steps := []struct {
+		name       string
+		apply      func() (*Transaction, error)
+		wantErr    error
+		wantStatus Status
+	}{
+		{"Refund before capture", func() (*Transaction, error) { return l.Refund("000000000001", 100) }, ErrInvalidState, ""},
+		{"Capture over authorized", func() (*Transaction, error) { return l.Capture("000000000001", 10001) }, ErrAmountExceeded, ""},
+		{"Partial capture", func() (*Transaction, error) { return l.Capture("000000000001", 8000) }, nil, StatusCaptured},
+		{"Second capture", func() (*Transaction, error) { return l.Capture("000000000001", 100) }, ErrInvalidState, ""},
+		{"Void after capture", func() (*Transaction, error) { return l.Void("000000000001") }, ErrInvalidState, ""},
+		{"First partial refund", func() (*Transaction, error) { return l.Refund("000000000001", 3000) }, nil, StatusPartiallyRefunded},
+		{"Refund over captured total", func() (*Transaction, error) { return l.Refund("000000000001", 5001) }, ErrAmountExceeded, ""},
+		{"Refund remaining", func() (*Transaction, error) { return l.Refund("000000000001", 0) }, nil, StatusRefunded},
+		{"Unknown reference", func() (*Transaction, error) { return l.Capture("999999999999", 0) }, ErrNotFound, ""},
+	}
+
+	for _, step := range steps {
+		txn, err := step.apply()
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if err == nil && txn.Status != step.wantStatus {
+			t.Errorf("%s: status = %s, want %s", step.name, txn.Status, step.wantStatus)
+		}
+	}
+
+	txn, _ := l.Get("000000000001")
+	if txn.Captured != 8000 || txn.Refunded != 8000 || len(txn.History) != 4 {
+		t.Errorf("Final transaction = captured %d, refunded %d, %d events; want 8000, 8000, 4",
+			txn.Captured, txn.Refunded, len(txn.History))
+	}
+}
+
+func TestVoidAndReverse(t *testing.T) {
+	l := New()
+	record(t, l, "0100", "000000000001", "000001", "000000010000")
+	record(t, l, "0100", "000000000002", "000002", "000000010000")
+
+	if txn, err := l.Void("000000000001"); err != nil || txn.Status != StatusVoided {
+		t.Errorf("Void() = %v, %v; want voided", txn, err)
+	}
+
+	// DE90 original data elements: MTI (4) + STAN (6) + ...
+	reversal := iso.ISO8583Fields{"2": "4000000000000002", "11": "000777", "90": "0100000002"}
+	if txn, err := l.Reverse(reversal); err != nil || txn.Status != StatusReversed || txn.RRN != "000000000002" {
+		t.Errorf("Reverse(DE90) = %v, %v; want reversal of 000000000002", txn, err)
+	}
+
+	if _, err := l.Reverse(iso.ISO8583Fields{"2": "4000000000000002", "11": "000001"}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Reverse() of voided transaction error = %v, want ErrInvalidState", err)
+	}
+	if _, err := l.Reverse(iso.ISO8583Fields{"2": "5100000000000016", "11": "000002"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Reverse() with other PAN error = %v, want ErrNotFound", err)
+	}
+	if code := ResponseCode(ErrNotFound); code != "25" {
+		t.Errorf("ResponseCode(ErrNotFound) = %s, want 25", code)
+	}
+}
+
+func TestReverseFinancial(t *testing.T) {
+	l := New()
+	record(t, l, "0200", "000000000001", "000001", "000000005000")
+	record(t, l, "0200", "000000000002", "000002", "000000007000")
+
+	// Timeout reversal of a 0200: the original STAN in DE11
+	txn, err := l.Reverse(iso.ISO8583Fields{"2": "4000000000000002", "11": "000001"})
+	if err != nil || txn.Status != StatusReversed {
+		t.Fatalf("Reverse(0200) = %v, %v; want reversed", txn, err)
+	}
+	if last := txn.History[len(txn.History)-1]; last.Type != "reversal" || last.Amount != 5000 {
+		t.Errorf("Reverse(0200) event = %s/%d, want reversal/5000", last.Type, last.Amount)
+	}
+	if code := ResponseCode(err); code != "00" {
+		t.Errorf("ResponseCode() = %s, want 00", code)
+	}
+
+	// Once refunded, the financial transaction can no longer be reversed
+	if _, err := l.Refund("000000000002", 1000); err != nil {
+		t.Fatalf("Refund() unexpected error: %v", err)
+	}
+	if _, err := l.Reverse(iso.ISO8583Fields{"2": "4000000000000002", "11": "000002"}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Reverse() of refunded transaction error = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	record(t, l, "0100", "000000000001", "000001", "000000010000")
+	if _, err := l.Capture("000000000001", 0); err != nil {
+		t.Fatalf("Capture() unexpected error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() existing ledger unexpected error: %v", err)
+	}
+
+	txn, err := reopened.Get("000000000001")
+	if err != nil {
+		t.Fatalf("Get() after reopen unexpected error: %v", err)
+	}
+	if txn.Status != StatusCaptured || txn.Captured != 10000 {
+		t.Errorf("Reopened transaction = %s/%d, want captured/10000", txn.Status, txn.Captured)
+	}
+
+	// Operations the ledger file cannot record leave the ledger as it was
+	os.RemoveAll(filepath.Dir(path))
+	if _, err := reopened.Refund("000000000001", 5000); err == nil {
+		t.Error("Refund() without a writable ledger file expected error but got none")
+	}
+	if txn, _ := reopened.Get("000000000001"); txn.Status != StatusCaptured || txn.Refunded != 0 || len(txn.History) != 2 {
+		t.Errorf("Transaction after a failed save = %+v, want it captured and unrefunded", txn)
+	}
+	request := &iso.AuthorizationRequest{
+		MTI:    "0100",
+		Fields: iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000002", "37": "000000000002", "49": "986"},
+	}
+	if _, err := reopened.Record(request, iso.GenerateMockAuthResponse(request, "00", "Approved")); err == nil {
+		t.Error("Record() without a writable ledger file expected error but got none")
+	}
+	if _, err := reopened.Get("000000000002"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an unrecorded transaction error = %v, want ErrNotFound", err)
+	}
+}
@@ -10,26 +10,53 @@ type Card struct {
 	ExpiryMonth  int               `json:"expiry_month"`
 	ExpiryYear   int               `json:"expiry_year"`
 	CVC          string            `json:"cvc,omitempty"`
+	CVV          string            `json:"cvv,omitempty"`  // Magnetic stripe CVV (requires a CVK)
+	ICVV         string            `json:"icvv,omitempty"` // Chip iCVV (requires a CVK)
+	PANSequence  string            `json:"pan_sequence,omitempty"` // Chip PSN (DE23), set with an IMK
+	ICCMasterKey string            `json:"-"`                      // Hex MK-AC derived from the IMK; never serialized
+	PIN          string            `json:"pin,omitempty"`              // Test PIN derived from the secret
+	PINBlockFormat string          `json:"pin_block_format,omitempty"` // ISO 9564 format, e.g. "ISO-0"
+	ClearPINBlock string           `json:"clear_pin_block,omitempty"`  // Hex; format 4: the plaintext PIN field
+	PINBlock     string            `json:"pin_block,omitempty"`        // Hex, encrypted under the ZPK or DUKPT PIN key (DE52)
+	KSN          string            `json:"ksn,omitempty"`              // DUKPT key serial number of the PIN block (DE53)
+	PVKI         string            `json:"pvki,omitempty"`             // PIN verification key index of the PVV
+	PVV          string            `json:"pvv,omitempty"`              // Visa PIN verification value (Track 2 discretionary data)
+	PINOffset    string            `json:"pin_offset,omitempty"`       // IBM 3624 PIN offset
 	Track2       string            `json:"track2,omitempty"`
+	Track1       string            `json:"track1,omitempty"`            // Format B, without sentinels (DE45)
+	CardholderName string          `json:"cardholder_name,omitempty"`
+	RawTrack1    string            `json:"raw_track1,omitempty"`        // With sentinels and LRC
+	RawTrack2    string            `json:"raw_track2,omitempty"`        // With sentinels and LRC
+	Track2Equivalent string        `json:"track2_equivalent,omitempty"` // DE35 form, packed BCD as hex ('D' separator)
 	ISOFields    map[string]string `json:"iso_fields,omitempty"`
 	GeneratedAt  time.Time         `json:"generated_at"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
+	IssuerName   string            `json:"issuer_name,omitempty"`
+	Country      string            `json:"country,omitempty"`
+	ProductType  string            `json:"product_type,omitempty"`
+	ProductTier  string            `json:"product_tier,omitempty"`
 }
 
 // CardBrand represents a card brand configuration
 type CardBrand struct {
-	Name        string
-	BINRanges   []BINRange
-	PANLength   []int
-	CVCLength   int
-	ServiceCode string
+	Name        string     `json:"name"`
+	BINRanges   []BINRange `json:"bin_ranges"`
+	PANLength   []int      `json:"pan_length"`
+	CVCLength   int        `json:"cvc_length"`
+	ServiceCode string     `json:"service_code"`
+	IssuerName  string     `json:"issuer_name,omitempty"`
+	Country     string     `json:"country,omitempty"`      // ISO 3166-1 alpha-2 (e.g. "BR")
+	ProductType string     `json:"product_type,omitempty"` // credit, debit, prepaid or combo
+	TokenBIN    string     `json:"token_bin,omitempty"`    // BIN network token DPANs are issued from
 }
 
 // BINRange represents a valid BIN range for a brand
 type BINRange struct {
-	Start  string
-	End    string
-	Length int
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Length  int    `json:"length,omitempty"`
+	Lengths []int  `json:"lengths,omitempty"`  // The only PAN lengths the range issues; empty allows any of the brand's
+	NonLuhn bool   `json:"non_luhn,omitempty"` // PANs in this range carry no Luhn check digit (e.g. some UnionPay debit)
 }
 
 // GenerateOptions contains options for card generation
@@ -38,9 +65,25 @@ type GenerateOptions struct {
 	Brand       string
 	Count       int
 	Secret      string
+	CVK         string // Hex CVK-A || CVK-B; computes CVV, CVV2 (as CVC) and iCVV with the 3DES algorithm
+	IMK         string // Hex issuer master key (MK-AC); DE55 then carries a verifiable ARQC
+	IncludePIN  bool   // Derive a test PIN from the secret (implied by ZPK, BDK and PVK)
+	ZPK         string // Hex zone PIN key; adds clear and encrypted PIN blocks (DE52)
+	PINFormat   int    // ISO 9564 PIN block format: 0, 1, 3 (3DES) or 4 (AES)
+	BDK         string // Hex DUKPT base derivation key; PIN blocks use per-card transaction keys instead of a ZPK
+	KSN         string // DUKPT KSN of the first card (20 hex TDES, 24 hex AES); advances per card
+	PVK         string // Hex PIN verification key; adds the PIN's PVV (on Track 2) and IBM 3624 offset
+	PVKI        int    // PIN verification key index 1-6 of the PVV (0 = 1)
 	IncludeISO  bool
 	IncludeTrack2 bool
+	IncludeTrack1 bool
+	CardholderName string // Track 1 name, "SURNAME/GIVEN" (default TEST/CARDHOLDER)
+	RawTracks   bool   // Also emit raw tracks (sentinels, LRC) and BCD Track 2 equivalent data
 	Metadata    map[string]string
+	Seed        int64 // 0 = crypto/rand; otherwise reproducible output
+	PANLength   int   // 0 = the BIN range's default length; must be one of CardBrand.PANLength
+	Country     string // Only BINs issued in this country (ISO 3166-1 alpha-2), per the BIN database
+	CardType    string // Only credit, debit or prepaid BINs, per the BIN database
 }
 
 // TransformOptions contains options for transforming orders with CVCs
@@ -50,6 +93,15 @@ type TransformOptions struct {
 	Secret     string
 }
 
+// TokenizeOptions contains options for format-preserving tokenization of card or order PANs
+type TokenizeOptions struct {
+	InputPath  string
+	OutputPath string
+	Key        string // Hex AES key (16, 24 or 32 bytes)
+	Algorithm  string // "ff1" (default) or "ff3-1"
+	Detokenize bool   // Recover the PANs from tokens made under the same key and algorithm
+}
+
 // Order represents a payment order/transaction
 type Order struct {
 	ID          string            `json:"id"`
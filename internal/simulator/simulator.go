@@ -0,0 +1,283 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/ledger"
+)
+
+// Simulator is a TCP ISO-8583 issuer simulator
+//
+// DESIGN RATIONALE:
+// - Speaks ISO-8583 over TCP with a length header (2-byte binary by default)
+// - Each connection is handled in its own goroutine; messages are processed in order
+// - Responses are decided locally (no issuer host) by the authorizer rules engine
+// - FOR TEST/SANDBOX USE ONLY
+//
+// SUPPORTED MESSAGES:
+// 0100 Authorization request    -> 0110
+// 0200 Financial request        -> 0210
+// 0400 Reversal request         -> 0410 (matched against the ledger, if set)
+// 0800 Network management       -> 0810
+type Simulator struct {
+	addr       string
+	packer     *iso.Packer
+	framing    iso.Framing
+	authorizer *authorizer.Engine
+	ledger     *ledger.Ledger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSimulator creates a simulator listening on addr (e.g. ":8583")
+func NewSimulator(addr string, spec *iso.Spec) *Simulator {
+	return &Simulator{
+		addr:       addr,
+		packer:     iso.NewPacker(spec),
+		framing:    iso.DefaultFraming,
+		authorizer: authorizer.NewEngine(authorizer.DefaultRules()),
+		conns:      make(map[net.Conn]struct{}),
+	}
+}
+
+// SetAuthorizer changes the decision engine used for 0100/0200 requests
+func (s *Simulator) SetAuthorizer(engine *authorizer.Engine) {
+	s.authorizer = engine
+}
+
+// SetLedger records approved requests in l and matches reversals against it
+func (s *Simulator) SetLedger(l *ledger.Ledger) {
+	s.ledger = l
+}
+
+// SetFraming changes the length header used to delimit messages
+func (s *Simulator) SetFraming(framing iso.Framing) {
+	s.framing = framing
+}
+
+// ListenAndServe listens on the configured address and serves connections
+func (s *Simulator) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *Simulator) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	log.Printf("ISO-8583 simulator listening on %s (spec %s, %s)",
+		listener.Addr(), s.packer.Spec().Name, s.packer.Spec().Encoding)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				s.wg.Wait()
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops the listener and closes all open connections
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn processes framed messages from a single connection
+func (s *Simulator) handleConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	for {
+		message, err := s.framing.Read(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[%s] read error: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		reply, err := s.HandleMessage(message)
+		if err != nil {
+			log.Printf("[%s] %v", conn.RemoteAddr(), err)
+			if reply == nil {
+				// Without a response MTI there is nothing to answer; closing beats a silent wait
+				return
+			}
+		}
+
+		if err := s.framing.Write(conn, reply); err != nil {
+			log.Printf("[%s] write error: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// HandleMessage unpacks a request, decides the outcome and packs the response
+//
+// A request that cannot be unpacked, or whose response cannot be packed, is answered
+// with a format error (30) returned along with the error. When the MTI has no response
+// MTI, the reply is nil.
+func (s *Simulator) HandleMessage(message []byte) ([]byte, error) {
+	mti, fields, err := s.packer.Unpack(message)
+	if err != nil {
+		return s.formatErrorReply(mti, fields), fmt.Errorf("failed to unpack message: %w", err)
+	}
+
+	responseMTI, responseFields, err := s.Respond(mti, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("%s %s -> %s [%s] %s", mti, generator.MaskPAN(fields["2"]),
+		responseMTI, responseFields["39"], iso.ResponseCodes[responseFields["39"]])
+
+	reply, err := s.packer.Pack(responseMTI, iso.DialectFields(responseFields, s.packer.Spec(), time.Now()))
+	if err != nil {
+		return s.formatErrorReply(mti, fields), fmt.Errorf("failed to pack response: %w", err)
+	}
+	return reply, nil
+}
+
+// formatErrorReply packs a format error (30) response to a request, echoing its STAN
+// when it was decoded; nil when the request MTI has no response MTI
+func (s *Simulator) formatErrorReply(mti string, fields iso.ISO8583Fields) []byte {
+	responseMTI, err := iso.ResponseMTI(mti)
+	if err != nil {
+		return nil
+	}
+
+	response := iso.ISO8583Fields{"39": "30"}
+	if stan, ok := fields["11"]; ok {
+		response["11"] = stan
+	}
+
+	reply, err := s.packer.Pack(responseMTI, iso.DialectFields(response, s.packer.Spec(), time.Now()))
+	if err != nil {
+		return nil
+	}
+	return reply
+}
+
+// Respond builds the response MTI and fields for a decoded request
+func (s *Simulator) Respond(mti string, fields iso.ISO8583Fields) (string, iso.ISO8583Fields, error) {
+	responseMTI, err := iso.ResponseMTI(mti)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch mti[1:] {
+	case "800":
+		return responseMTI, networkResponse(fields), nil
+	case "100", "200", "400":
+		code := s.decide(mti, fields)
+		request := &iso.AuthorizationRequest{MTI: mti, Fields: fields}
+		response := s.authResponse(request, code)
+
+		if s.ledger != nil && code == "00" && mti[1:] != "400" {
+			// An approval the ledger cannot record could never be captured or reversed,
+			// so it is declined (e.g. 94 for an RRN already recorded)
+			if _, err := s.ledger.Record(request, response); err != nil {
+				log.Printf("ledger: %v", err)
+				response = s.authResponse(request, ledger.ResponseCode(err))
+			}
+		}
+
+		return responseMTI, response.Fields, nil
+	default:
+		return responseMTI, iso.ISO8583Fields{"11": fields["11"], "39": "12"}, nil
+	}
+}
+
+// authResponse builds the response to an authorization, financial or reversal request
+func (s *Simulator) authResponse(request *iso.AuthorizationRequest, code string) *iso.AuthorizationResponse {
+	response := iso.GenerateMockAuthResponse(request, code, iso.ResponseCodes[code])
+
+	// Card-sensitive data is never echoed back (DE48 carries the CVC2 and token cryptogram)
+	for _, field := range []string{"35", "45", "48", "52", "55"} {
+		delete(response.Fields, field)
+	}
+	if de55, ok := s.authorizer.ResponseICCData(request.Fields, code); ok {
+		response.Fields["55"] = de55
+	}
+	if response.AuthCode != "" {
+		response.Fields["38"] = response.AuthCode[len(response.AuthCode)-6:]
+	}
+
+	return response
+}
+
+// decide picks the response code for an authorization, financial or reversal request
+func (s *Simulator) decide(mti string, fields iso.ISO8583Fields) string {
+	if fields["2"] == "" || fields["11"] == "" {
+		return "30" // Format error
+	}
+
+	if mti[1:] == "400" {
+		return s.reverse(fields)
+	}
+
+	return s.authorizer.Decide(fields).Code
+}
+
+// reverse matches a reversal against the ledger; without a ledger reversals are always accepted
+func (s *Simulator) reverse(fields iso.ISO8583Fields) string {
+	if s.ledger == nil {
+		return "00"
+	}
+
+	_, err := s.ledger.Reverse(fields)
+	return ledger.ResponseCode(err)
+}
+
+// networkResponse echoes the network management identifiers with an approval
+func networkResponse(fields iso.ISO8583Fields) iso.ISO8583Fields {
+	response := iso.ISO8583Fields{"39": "00"}
+	for _, field := range []string{"7", "11", "70"} {
+		if value, ok := fields[field]; ok {
+			response[field] = value
+		}
+	}
+	return response
+}
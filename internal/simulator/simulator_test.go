@@ -0,0 +1,271 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/ledger"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
+)
+
+func startSimulator(t *testing.T, spec *iso.Spec) (*Simulator, net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	sim := NewSimulator(listener.Addr().String(), spec)
+	go sim.Serve(listener)
+	t.Cleanup(func() { sim.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial simulator: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return sim, conn
+}
+
+func exchange(t *testing.T, conn net.Conn, packer *iso.Packer, mti string, fields iso.ISO8583Fields) (string, iso.ISO8583Fields) {
+	t.Helper()
+
+	message, err := packer.Pack(mti, fields)
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+	if err := iso.WriteFrame(conn, message); err != nil {
+		t.Fatalf("WriteFrame() unexpected error: %v", err)
+	}
+
+	reply, err := iso.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+
+	responseMTI, responseFields, err := packer.Unpack(reply)
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+	return responseMTI, responseFields
+}
+
+func TestSimulatorMessages(t *testing.T) {
+	spec := iso.DefaultSpec()
+	_, conn := startSimulator(t, spec)
+	packer := iso.NewPacker(spec)
+
+	tests := []struct {
+		name    string
+		mti     string
+		fields  iso.ISO8583Fields
+		wantMTI string
+		wantRC  string
+	}{
+		{
+			"Authorization approved", "0100",
+			iso.ISO8583Fields{"2": "4000000000000002", "3": "000000", "4": "000000010000", "11": "000001", "35": "4000000000000002=27122011234", "48": iso.FormatPrivateData(map[string]string{iso.SubelementCVC2: "123"})},
+			"0110", "00",
+		},
+		{
+			"Financial invalid PAN", "0200",
+			iso.ISO8583Fields{"2": "4000000000000001", "3": "000000", "4": "000000010000", "11": "000002"},
+			"0210", "14",
+		},
+		{
+			"Authorization missing amount", "0100",
+			iso.ISO8583Fields{"2": "4000000000000002", "3": "000000", "11": "000003"},
+			"0110", "30",
+		},
+		{
+			"Reversal", "0400",
+			iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000001"},
+			"0410", "00",
+		},
+		{
+			"Echo test", "0800",
+			iso.ISO8583Fields{"7": "1016120000", "11": "000004", "70": "301"},
+			"0810", "00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mti, fields := exchange(t, conn, packer, tt.mti, tt.fields)
+
+			if mti != tt.wantMTI {
+				t.Errorf("Response MTI = %s, want %s", mti, tt.wantMTI)
+			}
+			if fields["39"] != tt.wantRC {
+				t.Errorf("Response code = %s, want %s", fields["39"], tt.wantRC)
+			}
+			if fields["11"] != tt.fields["11"] {
+				t.Errorf("STAN = %s, want echo of %s", fields["11"], tt.fields["11"])
+			}
+			if _, ok := fields["35"]; ok {
+				t.Error("Response echoed Track 2 data")
+			}
+			if _, ok := fields["48"]; ok {
+				t.Error("Response echoed DE48 (CVC2)")
+			}
+		})
+	}
+}
+
+func TestSimulatorISO1993Decline(t *testing.T) {
+	spec, err := iso.BundledSpec("iso1993")
+	if err != nil {
+		t.Fatalf("BundledSpec(iso1993) unexpected error: %v", err)
+	}
+	_, conn := startSimulator(t, spec)
+
+	// A Luhn failure (14) comes back as action code 111 and reads back as 14
+	client := iso.NewClient(conn.RemoteAddr().String(), spec)
+	client.Timeout = 5 * time.Second
+	request := iso.GenerateMockAuthRequest(&models.Card{PAN: "4000000000000001", ExpiryMonth: 12, ExpiryYear: 2099, CVC: "123"}, 10000, "986")
+	response, err := client.SendAuthRequest(request)
+	if err != nil {
+		t.Fatalf("SendAuthRequest() unexpected error: %v", err)
+	}
+	if response.MTI != "1110" || response.Fields["39"] != "111" || response.ResponseCode != "14" {
+		t.Errorf("Response = %s [%s] %s, want 1110 [111] 14", response.MTI, response.Fields["39"], response.ResponseCode)
+	}
+	if len(response.Fields["12"]) != 12 || len(response.Fields["22"]) != 12 {
+		t.Errorf("Fields 12/22 = %q/%q, want 1993 date-time and POS data code", response.Fields["12"], response.Fields["22"])
+	}
+
+	// Alphanumeric codes such as N7 pack as action codes too
+	fields := iso.ISO8583Fields{"11": "000001", "39": "N7"}
+	if _, err := iso.NewPacker(spec).Pack("1110", iso.DialectFields(fields, spec, time.Now())); err != nil {
+		t.Errorf("Pack(N7 under iso1993) unexpected error: %v", err)
+	}
+}
+
+func TestSimulatorTokenARPC(t *testing.T) {
+	const imk = "0123456789ABCDEFFEDCBA9876543210"
+
+	engine := authorizer.NewEngine(&authorizer.Rules{IMK: imk})
+	vault := token.New()
+	engine.SetVault(vault)
+	sim := NewSimulator("", iso.DefaultSpec())
+	sim.SetAuthorizer(engine)
+
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: "visa", IMK: imk})
+	if err != nil {
+		t.Fatalf("GenerateCard() unexpected error: %v", err)
+	}
+	tok, err := vault.Provision(card, "")
+	if err != nil {
+		t.Fatalf("Provision() unexpected error: %v", err)
+	}
+	cryptogram, _, _ := vault.Cryptogram(tok.DPAN, 10000, "986")
+
+	// A token chip transaction: DPAN in DE2, chip data under the card keys
+	fields := iso.GenerateISO8583Fields(card, 10000, "986")
+	fields["2"] = tok.DPAN
+	fields["14"] = fmt.Sprintf("%02d%02d", tok.ExpiryYear%100, tok.ExpiryMonth)
+	fields["48"] = iso.FormatPrivateData(map[string]string{iso.SubelementTokenCryptogram: cryptogram})
+
+	_, response, err := sim.Respond("0100", fields)
+	if err != nil {
+		t.Fatalf("Respond() unexpected error: %v", err)
+	}
+	if response["39"] != "00" || response["55"] == "" || response["55"] == fields["55"] {
+		t.Errorf("Response 39/55 = %s/%s, want 00 with an ARPC", response["39"], response["55"])
+	}
+}
+
+func TestSimulatorUnprocessableMessages(t *testing.T) {
+	spec := iso.DefaultSpec()
+	_, conn := startSimulator(t, spec)
+	packer := iso.NewPacker(spec)
+
+	// A request that fails to unpack gets a format error echoing its STAN
+	message, err := packer.Pack("0100", iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000007"})
+	if err != nil {
+		t.Fatalf("Pack() unexpected error: %v", err)
+	}
+	if err := iso.WriteFrame(conn, append(message, "XX"...)); err != nil {
+		t.Fatalf("WriteFrame() unexpected error: %v", err)
+	}
+	reply, err := iso.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+	mti, fields, err := packer.Unpack(reply)
+	if err != nil || mti != "0110" || fields["39"] != "30" || fields["11"] != "000007" {
+		t.Errorf("Reply to a malformed request = %s %v, %v; want 0110 with 39=30 and 11=000007", mti, fields, err)
+	}
+
+	// A message with no response MTI closes the connection instead of leaving the client waiting
+	if err := iso.WriteFrame(conn, []byte("01X0")); err != nil {
+		t.Fatalf("WriteFrame() unexpected error: %v", err)
+	}
+	if _, err := iso.ReadFrame(conn); err == nil {
+		t.Error("ReadFrame() after an undecodable MTI expected the connection to close")
+	}
+}
+
+func TestSimulatorApprovalAuthCode(t *testing.T) {
+	sim := NewSimulator("", iso.DefaultSpec())
+
+	_, fields, err := sim.Respond("0100", iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000001"})
+	if err != nil {
+		t.Fatalf("Respond() unexpected error: %v", err)
+	}
+
+	if len(fields["38"]) != 6 {
+		t.Errorf("Field 38 (auth code) = %q, want 6 characters", fields["38"])
+	}
+
+	if _, _, err := sim.Respond("0110", iso.ISO8583Fields{}); err == nil {
+		t.Error("Respond() to a response MTI expected error but got none")
+	}
+}
+
+func TestSimulatorReversalLedger(t *testing.T) {
+	sim := NewSimulator("", iso.DefaultSpec())
+	sim.SetLedger(ledger.New())
+
+	auth := iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000042", "37": "261016000042"}
+	if _, fields, _ := sim.Respond("0100", auth); fields["39"] != "00" {
+		t.Fatalf("Authorization response code = %s, want 00", fields["39"])
+	}
+
+	// Same RRN (e.g. generated in the same second): declined, not approved and lost
+	duplicate := iso.ISO8583Fields{"2": "4000000000000002", "4": "000000020000", "11": "000043", "37": "261016000042"}
+	if _, fields, _ := sim.Respond("0100", duplicate); fields["39"] != "94" || fields["38"] != "" {
+		t.Errorf("Duplicate RRN response = %s (auth code %q), want 94 without auth code", fields["39"], fields["38"])
+	}
+
+	tests := []struct {
+		name   string
+		fields iso.ISO8583Fields
+		want   string
+	}{
+		{"Unknown STAN", iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000099"}, "25"},
+		{"Matching STAN", iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000042"}, "00"},
+		{"Already reversed", iso.ISO8583Fields{"2": "4000000000000002", "4": "000000010000", "11": "000042"}, "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fields, err := sim.Respond("0400", tt.fields)
+			if err != nil {
+				t.Fatalf("Respond() unexpected error: %v", err)
+			}
+			if fields["39"] != tt.want {
+				t.Errorf("Reversal response code = %s, want %s", fields["39"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+// Package token keeps a vault of network tokens: DPANs standing in for the FPANs of
+// generated cards, with their own expiry, requestor, status and transaction cryptograms
+//
+// FOR TEST/SANDBOX USE ONLY - the vault file holds FPANs and token keys in clear.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the vault has no token for the DPAN
+	ErrNotFound = errors.New("token not found")
+	// ErrInvalidState is returned when a token's status does not allow the operation
+	ErrInvalidState = errors.New("invalid token state")
+	// ErrCryptogram is returned when a token cryptogram does not verify
+	ErrCryptogram = errors.New("invalid token cryptogram")
+)
+
+// Status is the lifecycle state of a token
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+	StatusDeleted   Status = "deleted"
+)
+
+// DefaultRequestorID is the token requestor ID (11 digits) of tokens provisioned without one
+const DefaultRequestorID = "40010030273"
+
+// TokenValidityYears is how long a token is valid from provisioning, whatever the card's expiry
+const TokenValidityYears = 3
+
+// TokenBINs maps a bundled brand to the BIN its DPANs are issued from
+//
+// Each BIN lies in one of the brand's Luhn ranges, so DPANs detect as the card's brand,
+// but away from the magic card prefixes. A brand definition's token_bin takes precedence.
+var TokenBINs = map[string]string{
+	"visa":       "489537",
+	"mastercard": "520473",
+	"amex":       "374245",
+	"elo":        "509120",
+	"hipercard":  "606282",
+	"hiper":      "637095",
+	"cabal":      "604410",
+	"discover":   "601156",
+	"jcb":        "353011",
+	"diners":     "360569",
+	"unionpay":   "625814",
+	"maestro":    "675964",
+	"rupay":      "608123",
+	"mir":        "220412",
+}
+
+// CryptogramLength is the length of a decoded token cryptogram (TAVV)
+const CryptogramLength = 20
+
+// Token is a DPAN and what it stands for
+type Token struct {
+	DPAN             string    `json:"dpan"`
+	FPAN             string    `json:"fpan"`
+	MaskedFPAN       string    `json:"masked_fpan"`
+	Brand            string    `json:"brand"`
+	ExpiryMonth      int       `json:"expiry_month"` // Token expiry, sent in DE14 with the DPAN
+	ExpiryYear       int       `json:"expiry_year"`
+	FPANExpiryMonth  int       `json:"fpan_expiry_month"`
+	FPANExpiryYear   int       `json:"fpan_expiry_year"`
+	TokenRequestorID string    `json:"token_requestor_id"`
+	Status           Status    `json:"status"`
+	Key              string    `json:"key,omitempty"` // Hex per-token cryptogram key
+	ATC              int       `json:"atc"`           // ATC of the last cryptogram generated
+	LastVerifiedATC  int       `json:"last_verified_atc"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Vault maps DPANs to FPANs and issues and verifies their cryptograms
+//
+// DESIGN RATIONALE:
+// - In-memory by default; with a path every change is written to a JSON file, like the ledger
+// - DPANs are Luhn-valid PANs from the brand's token BIN, as long as the FPAN, so they
+// pass the same card-level checks as the cards they replace
+// - One FPAN can have several tokens, e.g. one per token requestor
+// - FOR TEST/SANDBOX USE ONLY
+//
+// LIFECYCLE RULES:
+// active    - can be suspended or deleted; the only status that transacts
+// suspended - can be resumed (active) or deleted
+// deleted   - final
+type Vault struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+	path   string
+	now    func() time.Time
+}
+
+// New creates an in-memory vault
+func New() *Vault {
+	return &Vault{
+		tokens: make(map[string]*Token),
+		now:    time.Now,
+	}
+}
+
+// Open creates a vault persisted to path, loading existing tokens if the file exists
+func Open(path string) (*Vault, error) {
+	v := New()
+	v.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []*Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token vault %s: %w", path, err)
+	}
+	for _, t := range tokens {
+		v.tokens[t.DPAN] = t
+	}
+
+	return v, nil
+}
+
+// TokenBIN returns the BIN a brand's DPANs are issued from, empty when it has none
+func TokenBIN(brand string) string {
+	if bin := generator.CardBrands[brand].TokenBIN; bin != "" {
+		return bin
+	}
+	return TokenBINs[brand]
+}
+
+// Provision issues a token for a card; an empty requestorID uses DefaultRequestorID
+func (v *Vault) Provision(card *models.Card, requestorID string) (*Token, error) {
+	if requestorID == "" {
+		requestorID = DefaultRequestorID
+	}
+	if len(requestorID) != 11 || !isDigits(requestorID) {
+		return nil, fmt.Errorf("token requestor ID must be 11 digits, got %q", requestorID)
+	}
+	if !generator.ValidatePAN(card.PAN) {
+		return nil, fmt.Errorf("invalid FPAN %s", generator.MaskPAN(card.PAN))
+	}
+	if card.ExpiryMonth < 1 || card.ExpiryMonth > 12 || card.ExpiryYear == 0 {
+		return nil, fmt.Errorf("invalid FPAN expiry %02d/%d", card.ExpiryMonth, card.ExpiryYear)
+	}
+
+	brand, _ := generator.DetectBrand(card.PAN)
+	bin := TokenBIN(brand)
+	if bin == "" {
+		return nil, fmt.Errorf("no token BIN for brand %q: set token_bin in its brand definition", brand)
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate token key: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	dpan, err := v.newDPAN(bin, len(card.PAN), card.PAN)
+	if err != nil {
+		return nil, err
+	}
+
+	now := v.now()
+	expiry := now.AddDate(TokenValidityYears, 0, 0)
+	t := &Token{
+		DPAN:             dpan,
+		FPAN:             card.PAN,
+		MaskedFPAN:       generator.MaskPAN(card.PAN),
+		Brand:            brand,
+		ExpiryMonth:      int(expiry.Month()),
+		ExpiryYear:       expiry.Year(),
+		FPANExpiryMonth:  card.ExpiryMonth,
+		FPANExpiryYear:   card.ExpiryYear,
+		TokenRequestorID: requestorID,
+		Status:           StatusActive,
+		Key:              hex.EncodeToString(key),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	v.tokens[dpan] = t
+	if err := v.save(); err != nil {
+		delete(v.tokens, dpan)
+		return nil, err
+	}
+	return t.clone(), nil
+}
+
+// Get returns the token for a DPAN
+func (v *Vault) Get(dpan string) (*Token, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t, ok := v.tokens[dpan]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, generator.MaskPAN(dpan))
+	}
+	return t.clone(), nil
+}
+
+// List returns all tokens, oldest first
+func (v *Vault) List() []*Token {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	list := make([]*Token, 0, len(v.tokens))
+	for _, t := range v.tokens {
+		list = append(list, t.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].DPAN < list[j].DPAN
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// SetStatus moves a token to a new status, following the lifecycle rules
+func (v *Vault) SetStatus(dpan string, status Status) (*Token, error) {
+	return v.update(dpan, func(t *Token) error {
+		switch {
+		case status != StatusActive && status != StatusSuspended && status != StatusDeleted:
+			return fmt.Errorf("unknown token status %q (use %s, %s or %s)", status, StatusActive, StatusSuspended, StatusDeleted)
+		case t.Status == StatusDeleted:
+			return fmt.Errorf("%w: token is deleted", ErrInvalidState)
+		case t.Status == status:
+			return fmt.Errorf("%w: token is already %s", ErrInvalidState, status)
+		}
+
+		t.Status = status
+		return nil
+	})
+}
+
+// Cryptogram generates the cryptogram (TAVV) of a transaction with an active token
+//
+// The token is returned as of this transaction, with the ATC the cryptogram carries.
+//
+// ALGORITHM:
+// 1. ATC = the next transaction counter; UN = 4 random bytes
+// 2. MAC = HMAC-SHA256(token key, DPAN || token expiry YYMM || amount (12 digits) ||
+// currency || ATC || UN), leftmost 14 bytes
+// 3. Cryptogram = base64(ATC (2 bytes) || UN || MAC), 28 characters
+func (v *Vault) Cryptogram(dpan string, amount int64, currency string) (string, *Token, error) {
+	unpredictable := make([]byte, 4)
+	if _, err := rand.Read(unpredictable); err != nil {
+		return "", nil, fmt.Errorf("failed to generate unpredictable number: %w", err)
+	}
+
+	var cryptogram string
+	t, err := v.update(dpan, func(t *Token) error {
+		if t.Status != StatusActive {
+			return fmt.Errorf("%w: cannot transact with a %s token", ErrInvalidState, t.Status)
+		}
+
+		// The ATC also moves past verified cryptograms from other copies of the vault
+		atc := max(t.ATC, t.LastVerifiedATC) + 1
+		if atc > 0xFFFF {
+			return fmt.Errorf("%w: ATC exhausted", ErrInvalidState)
+		}
+
+		data := append([]byte{byte(atc >> 8), byte(atc)}, unpredictable...)
+		mac, err := t.mac(data, amount, currency)
+		if err != nil {
+			return err
+		}
+
+		t.ATC = atc
+		cryptogram = base64.StdEncoding.EncodeToString(append(data, mac...))
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return cryptogram, t, nil
+}
+
+// Verify checks a transaction's cryptogram with an active token
+//
+// Each ATC is accepted once: a cryptogram whose ATC is not above the last verified
+// one is a replay.
+func (v *Vault) Verify(dpan, cryptogram string, amount int64, currency string) error {
+	_, err := v.update(dpan, func(t *Token) error {
+		if t.Status != StatusActive {
+			return fmt.Errorf("%w: cannot transact with a %s token", ErrInvalidState, t.Status)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(cryptogram)
+		if err != nil || len(decoded) != CryptogramLength {
+			return fmt.Errorf("%w: must be %d bytes of base64", ErrCryptogram, CryptogramLength)
+		}
+
+		expected, err := t.mac(decoded[:6], amount, currency)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(expected, decoded[6:]) != 1 {
+			return fmt.Errorf("%w: MAC mismatch", ErrCryptogram)
+		}
+
+		atc := int(decoded[0])<<8 | int(decoded[1])
+		if atc <= t.LastVerifiedATC {
+			return fmt.Errorf("%w: ATC %d already used", ErrCryptogram, atc)
+		}
+
+		t.LastVerifiedATC = atc
+		return nil
+	})
+	return err
+}
+
+// mac computes the truncated HMAC of a cryptogram's ATC || UN for a transaction
+func (t *Token) mac(atcUN []byte, amount int64, currency string) ([]byte, error) {
+	key, err := hex.DecodeString(t.Key)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("token %s has no valid key", generator.MaskPAN(t.DPAN))
+	}
+
+	h := hmac.New(sha256.New, key)
+	fmt.Fprintf(h, "%s%02d%02d%012d%s", t.DPAN, t.ExpiryYear%100, t.ExpiryMonth, amount, currency)
+	h.Write(atcUN)
+	return h.Sum(nil)[:CryptogramLength-len(atcUN)], nil
+}
+
+// update looks up a token, applies a change to a copy and persists it on success
+//
+// A change the vault file cannot record is rolled back, so memory and file agree.
+func (v *Vault) update(dpan string, change func(*Token) error) (*Token, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t, ok := v.tokens[dpan]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, generator.MaskPAN(dpan))
+	}
+
+	updated := t.clone()
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = v.now()
+	previous := *t
+	*t = *updated
+	if err := v.save(); err != nil {
+		*t = previous
+		return nil, err
+	}
+	return t.clone(), nil
+}
+
+// newDPAN picks a DPAN not yet in the vault (caller holds the lock)
+func (v *Vault) newDPAN(bin string, length int, fpan string) (string, error) {
+	for attempt := 0; attempt < 100; attempt++ {
+		dpan, err := generator.GeneratePAN(bin, length)
+		if err != nil {
+			return "", err
+		}
+		if _, taken := v.tokens[dpan]; !taken && dpan != fpan {
+			return dpan, nil
+		}
+	}
+	return "", fmt.Errorf("no free DPAN in token BIN %s", bin)
+}
+
+// save writes all tokens to the vault file, if any (caller holds the lock)
+func (v *Vault) save() error {
+	if v.path == "" {
+		return nil
+	}
+
+	list := make([]*Token, 0, len(v.tokens))
+	for _, t := range v.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DPAN < list[j].DPAN })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write token vault: %w", err)
+	}
+	return os.Rename(tmp, v.path)
+}
+
+func (t *Token) clone() *Token {
+	c := *t
+	return &c
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+func testCard(t *testing.T, brand string) *models.Card {
+	t.Helper()
+	card, err := generator.GenerateCard(models.GenerateOptions{Brand: brand, Count: 1})
+	if err != nil {
+		t.Fatalf("GenerateCard(%s) unexpected error: %v", brand, err)
+	}
+	return card
+}
+
+func TestProvision(t *testing.T) {
+	v := New()
+	v.now = func() time.Time { return time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC) }
+
+	for _, brand := range generator.BrandNames() {
+		card := testCard(t, brand)
+		tok, err := v.Provision(card, "")
+		if err != nil {
+			t.Fatalf("Provision(%s) unexpected error: %v", brand, err)
+		}
+
+		if !strings.HasPrefix(tok.DPAN, TokenBIN(brand)) || len(tok.DPAN) != len(card.PAN) || !generator.ValidateLuhn(tok.DPAN) {
+			t.Errorf("Provision(%s) DPAN = %s, want a Luhn-valid %d-digit PAN from BIN %s", brand, tok.DPAN, len(card.PAN), TokenBIN(brand))
+		}
+		if detected, _ := generator.DetectBrand(tok.DPAN); detected != brand {
+			t.Errorf("DetectBrand(DPAN) = %s, want %s", detected, brand)
+		}
+		if tok.FPAN != card.PAN || tok.Status != StatusActive || tok.TokenRequestorID != DefaultRequestorID {
+			t.Errorf("Provision(%s) = %+v, want an active token for the FPAN", brand, tok)
+		}
+		if tok.ExpiryMonth != 3 || tok.ExpiryYear != 2029 {
+			t.Errorf("token expiry = %02d/%d, want 03/2029", tok.ExpiryMonth, tok.ExpiryYear)
+		}
+	}
+
+	if _, err := v.Provision(testCard(t, "visa"), "123"); err == nil {
+		t.Error("Provision(short requestor ID) expected error")
+	}
+}
+
+func TestProvisionCustomBrand(t *testing.T) {
+	original := generator.CardBrands
+	t.Cleanup(func() { generator.CardBrands = original })
+
+	acme := models.CardBrand{
+		Name:        "Acme Debit",
+		BINRanges:   []models.BINRange{{Start: "999900", End: "999909", Length: 16}},
+		PANLength:   []int{16},
+		CVCLength:   3,
+		ServiceCode: "201",
+	}
+	withTokenBIN := acme
+	withTokenBIN.Name, withTokenBIN.TokenBIN = "Acme Credit", "99991901"
+	withTokenBIN.BINRanges = []models.BINRange{{Start: "999910", End: "999919", Length: 16}}
+	if err := generator.RegisterBrands(map[string]models.CardBrand{"acme": acme, "acmecredit": withTokenBIN}); err != nil {
+		t.Fatalf("RegisterBrands() unexpected error: %v", err)
+	}
+
+	v := New()
+	if _, err := v.Provision(testCard(t, "acme"), ""); err == nil || !strings.Contains(err.Error(), "set token_bin") {
+		t.Errorf("Provision(brand without token BIN) error = %v, want a no token BIN error", err)
+	}
+
+	tok, err := v.Provision(testCard(t, "acmecredit"), "")
+	if err != nil {
+		t.Fatalf("Provision(brand with token_bin) unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(tok.DPAN, "99991901") || tok.Brand != "acmecredit" {
+		t.Errorf("Provision(brand with token_bin) = %s/%s, want a DPAN from 99991901", tok.Brand, tok.DPAN)
+	}
+}
+
+func TestStatusLifecycle(t *testing.T) {
+	v := New()
+	tok, _ := v.Provision(testCard(t, "mastercard"), "")
+
+	steps := []struct {
+		status Status
+		ok     bool
+	}{
+		{StatusActive, false}, // Already active
+		{StatusSuspended, true},
+		{StatusActive, true},
+		{StatusDeleted, true},
+		{StatusActive, false}, // Deleted is final
+		{"frozen", false},
+	}
+	for _, step := range steps {
+		_, err := v.SetStatus(tok.DPAN, step.status)
+		if step.ok != (err == nil) {
+			t.Errorf("SetStatus(%s) error = %v, want ok = %v", step.status, err, step.ok)
+		}
+	}
+
+	if _, err := v.SetStatus("5204730000000000", StatusDeleted); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetStatus(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCryptogram(t *testing.T) {
+	v := New()
+	tok, _ := v.Provision(testCard(t, "visa"), "")
+
+	cryptogram, current, err := v.Cryptogram(tok.DPAN, 12000, "986")
+	if err != nil || len(cryptogram) != 28 || current.ATC != 1 {
+		t.Fatalf("Cryptogram() = %q, %+v, %v; want 28 base64 characters and ATC 1", cryptogram, current, err)
+	}
+
+	if err := v.Verify(tok.DPAN, cryptogram, 12001, "986"); !errors.Is(err, ErrCryptogram) {
+		t.Errorf("Verify(other amount) error = %v, want ErrCryptogram", err)
+	}
+	if err := v.Verify(tok.DPAN, cryptogram, 12000, "840"); !errors.Is(err, ErrCryptogram) {
+		t.Errorf("Verify(other currency) error = %v, want ErrCryptogram", err)
+	}
+	if err := v.Verify(tok.DPAN, cryptogram, 12000, "986"); err != nil {
+		t.Errorf("Verify() unexpected error: %v", err)
+	}
+	if err := v.Verify(tok.DPAN, cryptogram, 12000, "986"); !errors.Is(err, ErrCryptogram) {
+		t.Errorf("Verify(replay) error = %v, want ErrCryptogram", err)
+	}
+
+	v.SetStatus(tok.DPAN, StatusSuspended)
+	if _, _, err := v.Cryptogram(tok.DPAN, 12000, "986"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Cryptogram(suspended) error = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestVaultPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	v, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	tok, _ := v.Provision(testCard(t, "mastercard"), "50120834693")
+	cryptogram, _, _ := v.Cryptogram(tok.DPAN, 500, "986")
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	got, err := reopened.Get(tok.DPAN)
+	if err != nil || got.FPAN != tok.FPAN || got.TokenRequestorID != "50120834693" || got.ATC != 1 {
+		t.Fatalf("Get() = %+v, %v; want the provisioned token with ATC 1", got, err)
+	}
+	if err := reopened.Verify(tok.DPAN, cryptogram, 500, "986"); err != nil {
+		t.Errorf("Verify() after reopening unexpected error: %v", err)
+	}
+	if list := reopened.List(); len(list) != 1 {
+		t.Errorf("List() returned %d tokens, want 1", len(list))
+	}
+
+	// Changes the vault file cannot record leave the vault as it was
+	os.RemoveAll(filepath.Dir(path))
+	if _, _, err := reopened.Cryptogram(tok.DPAN, 500, "986"); err == nil {
+		t.Error("Cryptogram() without a writable vault file expected error but got none")
+	}
+	if got, _ := reopened.Get(tok.DPAN); got.ATC != 1 {
+		t.Errorf("ATC after a failed save = %d, want 1", got.ATC)
+	}
+	if _, err := reopened.Provision(testCard(t, "visa"), ""); err == nil {
+		t.Error("Provision() without a writable vault file expected error but got none")
+	}
+	if list := reopened.List(); len(list) != 1 {
+		t.Errorf("List() after a failed save returned %d tokens, want 1", len(list))
+	}
+}
@@ -0,0 +1,192 @@
+// Package track formats magnetic stripe track data (ISO/IEC 7813)
+//
+// DESIGN RATIONALE:
+// - Track data (Track 1/2) is what ISO-8583 DE45/DE35 carry, without sentinels
+// - Raw tracks add the start/end sentinels and the LRC, as read from the stripe
+// - Track 2 equivalent data (chip, DE35) uses 'D' as separator and packs as BCD
+//
+// FOR TEST/SANDBOX USE ONLY
+package track
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Track capacities in characters, excluding sentinels and LRC
+const (
+	MaxTrack1Length = 76
+	MaxTrack2Length = 37
+	MaxNameLength   = 26
+)
+
+// Sentinels and separators
+const (
+	Track1Start     = '%'
+	Track2Start     = ';'
+	EndSentinel     = '?'
+	Track1Separator = '^'
+	Track2Separator = '='
+	EquivalentSep   = 'D' // Track 2 equivalent data separator (BCD nibble 0xD)
+	FormatCodeB     = 'B' // Track 1 format code for financial cards
+)
+
+// Fields are the data elements encoded on both tracks
+type Fields struct {
+	PAN           string
+	Name          string // Track 1 only, "SURNAME/GIVEN NAME"
+	Expiry        string // YYMM
+	ServiceCode   string // 3 digits
+	Discretionary string // Issuer data, e.g. PVKI + PVV + CVV
+}
+
+// Discretionary builds the issuer discretionary data: PVKI (1) + PVV (4) + CVV (3)
+func Discretionary(pvki, pvv, cvv string) string {
+	return pvki + pvv + cvv
+}
+
+// PVV extracts the PVKI and PVV from Track 2 (or Track 2 equivalent) discretionary data
+//
+// It reports false when the data is too short or the PVKI is 0 (no PVV on the card).
+func PVV(track2 string) (pvki, pvv string, ok bool) {
+	sep := strings.IndexAny(strings.ToUpper(track2), string(Track2Separator)+string(EquivalentSep))
+	if sep < 0 {
+		return "", "", false
+	}
+
+	// YYMM + service code, then PVKI (1) + PVV (4)
+	rest := track2[sep+1:]
+	if len(rest) < 12 || !isDigits(rest[7:12]) || rest[7] == '0' {
+		return "", "", false
+	}
+	return rest[7:8], rest[8:12], true
+}
+
+// Track2 formats Track 2 data: PAN=YYMM<service code><discretionary>
+func Track2(f Fields) (string, error) {
+	if err := f.validate(); err != nil {
+		return "", err
+	}
+	if !isDigits(f.Discretionary) && f.Discretionary != "" {
+		return "", fmt.Errorf("track 2 discretionary data must be digits")
+	}
+
+	data := f.PAN + string(Track2Separator) + f.Expiry + f.ServiceCode + f.Discretionary
+	if len(data) > MaxTrack2Length {
+		return "", fmt.Errorf("track 2 data is %d characters, exceeds %d", len(data), MaxTrack2Length)
+	}
+	return data, nil
+}
+
+// Track1 formats Track 1 format B data: B<PAN>^<NAME>^YYMM<service code><discretionary>
+func Track1(f Fields) (string, error) {
+	if err := f.validate(); err != nil {
+		return "", err
+	}
+
+	name, err := FormatName(f.Name)
+	if err != nil {
+		return "", err
+	}
+
+	data := string(FormatCodeB) + f.PAN + string(Track1Separator) + name + string(Track1Separator) +
+		f.Expiry + f.ServiceCode + f.Discretionary
+	if len(data) > MaxTrack1Length {
+		return "", fmt.Errorf("track 1 data is %d characters, exceeds %d", len(data), MaxTrack1Length)
+	}
+	return data, nil
+}
+
+// FormatName normalizes a cardholder name for Track 1: upper case, 2-26 characters
+// of A-Z, space, '.', '-' and '/', with "SURNAME/GIVEN" ordering left to the caller
+func FormatName(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if len(name) < 2 || len(name) > MaxNameLength {
+		return "", fmt.Errorf("cardholder name must be 2-%d characters", MaxNameLength)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'A' && c <= 'Z') && c != ' ' && c != '.' && c != '-' && c != '/' {
+			return "", fmt.Errorf("cardholder name contains %q (allowed: A-Z, space, '.', '-', '/')", c)
+		}
+	}
+	return name, nil
+}
+
+// Raw1 adds the sentinels and LRC to Track 1 data, as encoded on the stripe
+//
+// Track 1 characters are 6-bit (ASCII - 0x20); the LRC is the XOR of all
+// characters including both sentinels, rendered back as ASCII.
+func Raw1(data string) string {
+	raw := string(Track1Start) + data + string(EndSentinel)
+	return raw + string(lrc(raw, 0x20, 0x3F))
+}
+
+// Raw2 adds the sentinels and LRC to Track 2 data, as encoded on the stripe
+//
+// Track 2 characters are 4-bit (ASCII - 0x30); the LRC is the XOR of all
+// characters including both sentinels, rendered back as ASCII (0-9 : ; < = > ?).
+func Raw2(data string) string {
+	raw := string(Track2Start) + data + string(EndSentinel)
+	return raw + string(lrc(raw, 0x30, 0x0F))
+}
+
+// CheckLRC reports whether a raw track (with sentinels and LRC) has a valid LRC
+func CheckLRC(raw string) bool {
+	if len(raw) < 3 {
+		return false
+	}
+
+	switch raw[0] {
+	case Track1Start:
+		return Raw1(raw[1:len(raw)-2]) == raw
+	case Track2Start:
+		return Raw2(raw[1:len(raw)-2]) == raw
+	}
+	return false
+}
+
+// Equivalent converts Track 2 data to Track 2 equivalent data ('D' separator)
+func Equivalent(track2 string) string {
+	return strings.ReplaceAll(track2, string(Track2Separator), string(EquivalentSep))
+}
+
+// EquivalentBCD packs Track 2 equivalent data as BCD (hex), padding an odd length with F
+func EquivalentBCD(track2 string) string {
+	packed := strings.ToUpper(Equivalent(track2))
+	if len(packed)%2 == 1 {
+		packed += "F"
+	}
+	return packed
+}
+
+// lrc XORs the character codes (ASCII - offset, masked) and returns the LRC character
+func lrc(raw string, offset, mask byte) byte {
+	var sum byte
+	for i := 0; i < len(raw); i++ {
+		sum ^= (raw[i] - offset) & mask
+	}
+	return sum + offset
+}
+
+func (f Fields) validate() error {
+	if len(f.PAN) < 12 || len(f.PAN) > 19 || !isDigits(f.PAN) {
+		return fmt.Errorf("PAN must be 12-19 digits")
+	}
+	if len(f.Expiry) != 4 || !isDigits(f.Expiry) {
+		return fmt.Errorf("expiry must be YYMM")
+	}
+	if len(f.ServiceCode) != 3 || !isDigits(f.ServiceCode) {
+		return fmt.Errorf("service code must be 3 digits")
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,118 @@
+package track
+
+import (
+	"strings"
+	"testing"
+)
+
+var testFields = Fields{
+	PAN:           "4000000000000002",
+	Name:          "doe/john",
+	Expiry:        "2712",
+	ServiceCode:   "201",
+	Discretionary: Discretionary("1", "2345", "678"),
+}
+
+func TestTrack2(t *testing.T) {
+	got, err := Track2(testFields)
+	if err != nil || got != "4000000000000002=271220112345678" {
+		t.Errorf("Track2() = %q, %v; want 4000000000000002=271220112345678", got, err)
+	}
+
+	long := testFields
+	long.PAN = "4000000000000000006"
+	long.Discretionary = "1234567890123"
+	if _, err := Track2(long); err == nil {
+		t.Error("Track2() over 37 characters expected error but got none")
+	}
+
+	bad := testFields
+	bad.Expiry = "271"
+	if _, err := Track2(bad); err == nil {
+		t.Error("Track2() with bad expiry expected error but got none")
+	}
+}
+
+func TestTrack1(t *testing.T) {
+	got, err := Track1(testFields)
+	want := "B4000000000000002^DOE/JOHN^271220112345678"
+	if err != nil || got != want {
+		t.Errorf("Track1() = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestFormatName(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Doe/John", "DOE/JOHN", false},
+		{" o'brien/pat ", "", true},
+		{"X", "", true},
+		{strings.Repeat("A", MaxNameLength+1), "", true},
+		{"SMITH-JONES/A. B", "SMITH-JONES/A. B", false},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatName(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("FormatName(%q) = %q, %v; want %q, wantErr %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRawTracks(t *testing.T) {
+	track2, _ := Track2(testFields)
+	raw2 := Raw2(track2)
+	if !strings.HasPrefix(raw2, ";"+track2+"?") || len(raw2) != len(track2)+3 {
+		t.Errorf("Raw2() = %q, want ;<data>?<LRC>", raw2)
+	}
+
+	track1, _ := Track1(testFields)
+	raw1 := Raw1(track1)
+	if !strings.HasPrefix(raw1, "%"+track1+"?") || len(raw1) != len(track1)+3 {
+		t.Errorf("Raw1() = %q, want %%<data>?<LRC>", raw1)
+	}
+
+	// Known LRC: ;1=2? XORs to 0x0A (':')
+	if got := Raw2("1=2"); got != ";1=2?:" {
+		t.Errorf("Raw2(1=2) = %q, want ;1=2?:", got)
+	}
+
+	for _, raw := range []string{raw1, raw2} {
+		if !CheckLRC(raw) {
+			t.Errorf("CheckLRC(%q) = false, want true", raw)
+		}
+		corrupted := raw[:5] + "9" + raw[6:]
+		if corrupted != raw && CheckLRC(corrupted) {
+			t.Errorf("CheckLRC(%q) = true, want false", corrupted)
+		}
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	if got := Equivalent("4000000000000002=2712201"); got != "4000000000000002D2712201" {
+		t.Errorf("Equivalent() = %q, want D separator", got)
+	}
+	if got := EquivalentBCD("4000000000000002=27122011"); got != "4000000000000002D27122011F" {
+		t.Errorf("EquivalentBCD() = %q, want F padding", got)
+	}
+	if got := EquivalentBCD("4000000000000002=2712201"); got != "4000000000000002D2712201" {
+		t.Errorf("EquivalentBCD() = %q, want no padding", got)
+	}
+}
+
+func TestPVV(t *testing.T) {
+	for _, data := range []string{"4000000000000002=271220112345678", "4000000000000002D271220112345678F"} {
+		if pvki, pvv, ok := PVV(data); !ok || pvki != "1" || pvv != "2345" {
+			t.Errorf("PVV(%q) = %q, %q, %v; want 1, 2345", data, pvki, pvv, ok)
+		}
+	}
+
+	for _, data := range []string{"4000000000000002=27122011234", "4000000000000002=271220102345678", "4000000000000002"} {
+		if _, _, ok := PVV(data); ok {
+			t.Errorf("PVV(%q) reported a PVV", data)
+		}
+	}
+}
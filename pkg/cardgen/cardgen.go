@@ -0,0 +1,269 @@
+package cardgen
+
+import (
+	"fmt"
+
+	"github.com/felipemacedo/cardgen-pro/internal/api"
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+)
+
+// Card is a generated payment card
+type Card = models.Card
+
+// ISOFields holds ISO-8583 data elements keyed by field number (e.g. "2", "39")
+type ISOFields = iso.ISO8583Fields
+
+// AuthorizationRequest is a mock ISO-8583 authorization request
+type AuthorizationRequest = iso.AuthorizationRequest
+
+// AuthorizationResponse is a mock ISO-8583 authorization response
+type AuthorizationResponse = iso.AuthorizationResponse
+
+// Scenario is a predefined test scenario with its expected outcome
+type Scenario = api.Scenario
+
+// BINInfo describes a BIN: brand, issuer, country, card type and product tier
+type BINInfo = generator.BINInfo
+
+// Option configures card generation
+type Option func(*config)
+
+type config struct {
+	opts     models.GenerateOptions
+	amount   int64
+	currency string
+}
+
+func newConfig(options []Option) *config {
+	c := &config{opts: models.GenerateOptions{Brand: "visa", Count: 1}}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithBrand selects the card brand by catalog key (see Brands, e.g. visa, elo, unionpay); default visa
+func WithBrand(brand string) Option {
+	return func(c *config) { c.opts.Brand = brand }
+}
+
+// WithBIN sets the BIN (at least 6 digits) the PAN starts with; it must belong to the brand
+func WithBIN(bin string) Option {
+	return func(c *config) { c.opts.BIN = bin }
+}
+
+// WithLength sets the PAN length; it must be one the brand issues (e.g. 13, 16 or 19 for Visa)
+func WithLength(length int) Option {
+	return func(c *config) { c.opts.PANLength = length }
+}
+
+// WithCountry only uses BINs issued in the country (ISO 3166-1 alpha-2), per the bundled BIN database
+func WithCountry(country string) Option {
+	return func(c *config) { c.opts.Country = country }
+}
+
+// WithCardType only uses credit, debit or prepaid BINs, per the bundled BIN database
+func WithCardType(cardType string) Option {
+	return func(c *config) { c.opts.CardType = cardType }
+}
+
+// WithSecret enables deterministic CVC generation with the given secret
+func WithSecret(secret string) Option {
+	return func(c *config) { c.opts.Secret = secret }
+}
+
+// WithCVK computes real CVV, CVV2 (as the CVC) and iCVV with a test CVK-A || CVK-B
+// (32 hex characters), using the standard 3DES algorithm. Amex keeps the secret-based CVC.
+func WithCVK(cvk string) Option {
+	return func(c *config) { c.opts.CVK = cvk }
+}
+
+// WithIMK personalizes cards with an ICC master key derived from a test issuer master
+// key (32 hex characters), so DE55 from WithISO carries a verifiable ARQC
+func WithIMK(imk string) Option {
+	return func(c *config) { c.opts.IMK = imk }
+}
+
+// WithPIN includes a test PIN derived from the secret (see WithSecret)
+func WithPIN() Option {
+	return func(c *config) { c.opts.IncludePIN = true }
+}
+
+// WithZPK adds the test PIN's clear and encrypted PIN blocks (DE52 with WithISO) under a
+// test zone PIN key, in ISO 9564 format 0, 1, 3 (3DES) or 4 (AES). Requires WithSecret.
+func WithZPK(zpk string, format int) Option {
+	return func(c *config) {
+		c.opts.ZPK = zpk
+		c.opts.PINFormat = format
+	}
+}
+
+// WithDUKPT adds per-card DUKPT PIN blocks and KSNs (DE52/DE53 with WithISO) from a test
+// BDK, starting at the KSN (20 hex for TDES, 24 hex for AES DUKPT). Requires WithSecret.
+func WithDUKPT(bdk, ksn string) Option {
+	return func(c *config) {
+		c.opts.BDK = bdk
+		c.opts.KSN = ksn
+	}
+}
+
+// WithPVK adds the test PIN's Visa PVV (in the track discretionary data, with the
+// PVKI 1-6) and IBM 3624 offset from a test PIN verification key. Requires WithSecret.
+func WithPVK(pvk string, pvki int) Option {
+	return func(c *config) {
+		c.opts.PVK = pvk
+		c.opts.PVKI = pvki
+	}
+}
+
+// WithTrack2 includes Track 2 equivalent data
+func WithTrack2() Option {
+	return func(c *config) { c.opts.IncludeTrack2 = true }
+}
+
+// WithTrack1 includes Track 1 format B data with the cardholder name ("SURNAME/GIVEN";
+// empty uses the default test name)
+func WithTrack1(name string) Option {
+	return func(c *config) {
+		c.opts.IncludeTrack1 = true
+		c.opts.CardholderName = name
+	}
+}
+
+// WithRawTracks adds raw tracks (sentinels and LRC) and BCD Track 2 equivalent data
+func WithRawTracks() Option {
+	return func(c *config) { c.opts.RawTracks = true }
+}
+
+// WithISO includes ISO-8583 authorization fields for the given amount (minor units) and currency
+func WithISO(amount int64, currency string) Option {
+	return func(c *config) {
+		c.opts.IncludeISO = true
+		c.amount = amount
+		c.currency = currency
+	}
+}
+
+// WithSeed makes generation reproducible: the same seed and options yield identical cards
+func WithSeed(seed int64) Option {
+	return func(c *config) { c.opts.Seed = seed }
+}
+
+// WithMetadata attaches metadata to generated cards
+func WithMetadata(metadata map[string]string) Option {
+	return func(c *config) { c.opts.Metadata = metadata }
+}
+
+// Generate generates a single card
+func Generate(options ...Option) (*Card, error) {
+	return generate(newConfig(options))
+}
+
+// GenerateN generates n cards with the same options
+func GenerateN(n int, options ...Option) ([]*Card, error) {
+	c := newConfig(options)
+	c.opts.Count = n
+
+	cards, err := generator.GenerateCards(c.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, card := range cards {
+		addISO(c, card)
+	}
+	return cards, nil
+}
+
+// MustGenerate is like Generate but panics on error (for test setup)
+func MustGenerate(options ...Option) *Card {
+	card, err := Generate(options...)
+	if err != nil {
+		panic(fmt.Sprintf("cardgen: %v", err))
+	}
+	return card
+}
+
+func generate(c *config) (*Card, error) {
+	card, err := generator.GenerateCard(c.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	addISO(c, card)
+	return card, nil
+}
+
+func addISO(c *config, card *Card) {
+	if c.opts.IncludeISO {
+		card.ISOFields = iso.GenerateISO8583FieldsAt(card, c.amount, c.currency, card.GeneratedAt)
+	}
+}
+
+// Brands returns the keys of the brand catalog, sorted: the bundled brands and any
+// registered from a brands file
+func Brands() []string {
+	return generator.BrandNames()
+}
+
+// LookupBIN describes a PAN or BIN prefix (6-19 digits) using the bundled BIN database,
+// falling back to the brand's BIN ranges
+func LookupBIN(panOrPrefix string) (BINInfo, error) {
+	return generator.LookupBIN(panOrPrefix)
+}
+
+// ValidateLuhn reports whether a PAN passes the Luhn check
+func ValidateLuhn(pan string) bool {
+	return generator.ValidateLuhn(pan)
+}
+
+// LuhnCheckDigit returns the check digit to append to a partial PAN
+func LuhnCheckDigit(partialPAN string) int {
+	return generator.CalculateLuhnCheckDigit(partialPAN)
+}
+
+// MaskPAN masks a PAN as first6 + * + last4 (e.g. "400000******1234")
+func MaskPAN(pan string) string {
+	return generator.MaskPAN(pan)
+}
+
+// CVC derives the deterministic CVC for a card (month "01"-"12", 4-digit year)
+func CVC(pan, expMonth, expYear, secret string) (string, error) {
+	return generator.GenerateDeterministicCVC(pan, expMonth, expYear, secret)
+}
+
+// AuthRequest builds a mock 0100 authorization request for a card
+func AuthRequest(card *Card, amount int64, currency string) *AuthorizationRequest {
+	return iso.GenerateMockAuthRequest(card, amount, currency)
+}
+
+// AuthResponse builds a mock response to a request with the given DE39 response code
+func AuthResponse(request *AuthorizationRequest, responseCode string) *AuthorizationResponse {
+	return iso.GenerateMockAuthResponse(request, responseCode, ResponseText(responseCode))
+}
+
+// Authorize decides the response with the default authorization rules (Luhn, expiry, magic values)
+func Authorize(request *AuthorizationRequest) *AuthorizationResponse {
+	return authorizer.NewEngine(authorizer.DefaultRules()).Authorize(request)
+}
+
+// ResponseText returns the description of an ISO-8583 response code
+func ResponseText(responseCode string) string {
+	return iso.ResponseCodes[responseCode]
+}
+
+// Scenarios returns the predefined test scenarios
+func Scenarios() []Scenario {
+	return api.GetScenarios()
+}
+
+// ScenarioCard generates a card whose authorization yields the scenario's response code
+//
+// Only WithSecret and WithZPK are honored; brand, BIN and amount come from the scenario.
+func ScenarioCard(scenarioID string, options ...Option) (*Card, error) {
+	opts := newConfig(options).opts
+	return api.GenerateScenarioCard(scenarioID, opts.Secret, opts.ZPK)
+}
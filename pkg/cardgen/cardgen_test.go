@@ -0,0 +1,93 @@
+package cardgen_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/pkg/cardgen"
+)
+
+func TestGenerate(t *testing.T) {
+	cards, err := cardgen.GenerateN(5,
+		cardgen.WithBrand("mastercard"),
+		cardgen.WithSecret("public-api-secret"),
+		cardgen.WithTrack2(),
+		cardgen.WithTrack1("doe/jane"),
+		cardgen.WithISO(10000, "986"),
+	)
+	if err != nil {
+		t.Fatalf("GenerateN() unexpected error: %v", err)
+	}
+
+	for _, card := range cards {
+		if !cardgen.ValidateLuhn(card.PAN) {
+			t.Errorf("PAN %s failed Luhn check", card.MaskedPAN)
+		}
+		if card.Brand != "Mastercard" || card.Track2 == "" || card.ISOFields["4"] != "000000010000" {
+			t.Errorf("Card = %+v, missing requested data", card)
+		}
+		if card.CardholderName != "DOE/JANE" || card.ISOFields["45"] != card.Track1 {
+			t.Errorf("Card Track1 = %q, DE45 = %q; want Track 1 with DOE/JANE in both", card.Track1, card.ISOFields["45"])
+		}
+
+		cvc, err := cardgen.CVC(card.PAN, fmt.Sprintf("%02d", card.ExpiryMonth), fmt.Sprint(card.ExpiryYear), "public-api-secret")
+		if err != nil || cvc != card.CVC {
+			t.Errorf("CVC() = %s, %v; want %s", cvc, err, card.CVC)
+		}
+	}
+
+	if _, err := cardgen.Generate(cardgen.WithBrand("unknown")); err == nil {
+		t.Error("Generate() with unknown brand expected error but got none")
+	}
+
+	for _, brand := range cardgen.Brands() {
+		if _, err := cardgen.Generate(cardgen.WithBrand(brand)); err != nil {
+			t.Errorf("Generate(WithBrand(%s)) unexpected error: %v", brand, err)
+		}
+	}
+}
+
+func TestBINFilters(t *testing.T) {
+	card, err := cardgen.Generate(cardgen.WithBrand("visa"), cardgen.WithCountry("BR"), cardgen.WithCardType("debit"))
+	if err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+
+	info, err := cardgen.LookupBIN(card.PAN)
+	if err != nil {
+		t.Fatalf("LookupBIN() unexpected error: %v", err)
+	}
+	if info.Country != "BR" || info.Type != "debit" || card.Country != "BR" || card.ProductType != "debit" {
+		t.Errorf("LookupBIN(%s) = %+v, card = %+v; want BR debit", card.MaskedPAN, info, card)
+	}
+}
+
+func TestScenarioCardAuthorize(t *testing.T) {
+	for _, scenario := range cardgen.Scenarios() {
+		card, err := cardgen.ScenarioCard(scenario.ID)
+		if err != nil {
+			continue // PIX and boleto have no card
+		}
+
+		response := cardgen.Authorize(cardgen.AuthRequest(card, scenario.Amount, scenario.Currency))
+		if response.ResponseCode != scenario.ResponseCode {
+			t.Errorf("%s: Authorize() = %s, want %s", scenario.ID, response.ResponseCode, scenario.ResponseCode)
+		}
+	}
+}
+
+func ExampleGenerate() {
+	card := cardgen.MustGenerate(cardgen.WithBrand("visa"), cardgen.WithBIN("424242"))
+
+	fmt.Println(cardgen.ValidateLuhn(card.PAN), card.PAN[:6], len(card.PAN))
+	// Output: true 424242 16
+}
+
+func ExampleAuthResponse() {
+	card := cardgen.MustGenerate()
+	request := cardgen.AuthRequest(card, 100051, "986")
+	response := cardgen.AuthResponse(request, "51")
+
+	fmt.Println(response.MTI, response.ResponseCode, response.ResponseText)
+	// Output: 0110 51 Insufficient funds
+}
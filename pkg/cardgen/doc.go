@@ -0,0 +1,37 @@
+// Package cardgen is the public Go API of cardgen-pro for generating test card
+// fixtures directly inside Go tests and services.
+//
+// It covers card generation (Luhn-valid PANs, expiry, deterministic CVC, Track 1/2),
+// Luhn validation, PAN masking, offline BIN lookup, mock ISO-8583 authorization
+// messages and the predefined test scenarios, including magic cards with
+// guaranteed outcomes.
+//
+//	card, err := cardgen.Generate(
+//		cardgen.WithBrand("mastercard"),
+//		cardgen.WithSecret(os.Getenv("CARDGEN_SECRET")),
+//		cardgen.WithTrack2(),
+//	)
+//
+//	request := cardgen.AuthRequest(card, 10000, "986")
+//	response := cardgen.Authorize(request) // DE39 decided by the default rules
+//
+// COMPATIBILITY GUARANTEES:
+//
+// This package follows semantic versioning as part of the module. Within a major
+// version:
+//   - Exported functions, options and constants are not removed or renamed, and
+//     their signatures do not change
+//   - New options and functions may be added; Option values stay opaque
+//   - Types re-exported as aliases (Card, ISOFields, AuthorizationRequest,
+//     AuthorizationResponse, Scenario, BINInfo) only gain fields; existing
+//     fields and JSON tags are not removed or renamed
+//   - Deterministic outputs (CVC for a given secret, Luhn check digits, masking
+//     format, magic card outcomes) do not change
+//
+// Randomly generated values (PAN digits, expiry) are not part of the guarantee,
+// including the exact output for a given WithSeed value: a seed reproduces output
+// within a release, but may produce different cards after an upgrade.
+// Everything under internal/ may change at any time; depend on this package instead.
+//
+// FOR TEST/SANDBOX USE ONLY - generated cards are not real payment credentials.
+package cardgen
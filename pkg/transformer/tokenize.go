@@ -0,0 +1,224 @@
+package transformer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/felipemacedo/cardgen-pro/internal/cardcrypto"
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
+)
+
+// Tokenization algorithms
+const (
+	AlgorithmFF1  = "ff1"
+	AlgorithmFF31 = "ff3-1"
+)
+
+// MinTokenizePANLength is the shortest PAN that can be tokenized (ISO/IEC 7812 minimum)
+const MinTokenizePANLength = 12
+
+// minTokenMiddle is the fewest enciphered digits: FF1/FF3-1's minimum domain is 10^6 values
+const minTokenMiddle = 6
+
+// fpeCipher is a format-preserving cipher over numeral strings (FF1 or FF3-1)
+type fpeCipher interface {
+	Encrypt(tweak []byte, x string) (string, error)
+	Decrypt(tweak []byte, x string) (string, error)
+}
+
+// PANTokenizer replaces PANs with format-preserving tokens and recovers them, as payment
+// gateways do to keep PANs out of downstream systems
+//
+// DESIGN RATIONALE:
+// A token keeps the BIN6 and last4 of its PAN, so BIN routing, brand detection, masking
+// and "card ending in" displays behave exactly as with the PAN. Only the middle digits
+// are enciphered, under a tweak of the clear digits: PANs sharing middle digits but not
+// their ends get unrelated tokens. PANs under 16 digits (15-digit Amex, 13/14-digit
+// Visa and Diners) leave fewer than 6 digits between BIN6 and last4, so they keep only
+// their first 4 digits clear, or fewer for PANs under 14 digits (see clearHeadLength).
+// The token is Luhn-valid, like the PAN, by cycle walking: the middle digits are
+// enciphered again until the token passes the Luhn check. Since the cipher is a
+// permutation, deciphering until the Luhn check passes again lands back on the PAN, so
+// tokens are reversible with the key and nothing is stored. PANs of non-Luhn BIN ranges
+// walk the other way, to a token that fails the Luhn check like they do.
+type PANTokenizer struct {
+	cipher fpeCipher
+}
+
+// NewPANTokenizer creates a tokenizer for an algorithm ("ff1" default, or "ff3-1") under a hex AES key
+func NewPANTokenizer(algorithm, keyHex string) (*PANTokenizer, error) {
+	key, err := hex.DecodeString(strings.ReplaceAll(keyHex, " ", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tokenization key: must be hex: %w", err)
+	}
+
+	var cipher fpeCipher
+	switch strings.ToLower(algorithm) {
+	case "", AlgorithmFF1:
+		cipher, err = cardcrypto.NewFF1(key, 10)
+	case AlgorithmFF31:
+		cipher, err = cardcrypto.NewFF31(key, 10)
+	default:
+		return nil, fmt.Errorf("unknown tokenization algorithm %q (use %s or %s)", algorithm, AlgorithmFF1, AlgorithmFF31)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PANTokenizer{cipher: cipher}, nil
+}
+
+// Tokenize returns the token of a PAN of 12 to 19 digits
+//
+// The PAN must pass the Luhn check, or belong to a non-Luhn BIN range (see
+// generator.ValidatePAN); its token then fails the Luhn check too.
+func (t *PANTokenizer) Tokenize(pan string) (string, error) {
+	return t.walk(pan, t.cipher.Encrypt)
+}
+
+// Detokenize returns the PAN of a token
+func (t *PANTokenizer) Detokenize(token string) (string, error) {
+	return t.walk(token, t.cipher.Decrypt)
+}
+
+// convert tokenizes a PAN or detokenizes a token
+func (t *PANTokenizer) convert(pan string, detokenize bool) (string, error) {
+	if detokenize {
+		return t.Detokenize(pan)
+	}
+	return t.Tokenize(pan)
+}
+
+// walk applies the cipher to the middle digits until the result passes the Luhn check
+// as the input does
+func (t *PANTokenizer) walk(pan string, apply func(tweak []byte, x string) (string, error)) (string, error) {
+	if len(pan) < MinTokenizePANLength {
+		return "", fmt.Errorf("PAN %s is too short to tokenize: need %d digits or more", generator.MaskPAN(pan), MinTokenizePANLength)
+	}
+	luhn := generator.ValidateLuhn(pan)
+	if !luhn && !generator.ValidatePAN(pan) {
+		return "", fmt.Errorf("PAN %s fails the Luhn check and is not in a non-Luhn BIN range", generator.MaskPAN(pan))
+	}
+
+	headLength := clearHeadLength(len(pan))
+	head, middle, tail := pan[:headLength], pan[headLength:len(pan)-4], pan[len(pan)-4:]
+	tweak := panTweak(head + tail)
+	for {
+		var err error
+		if middle, err = apply(tweak, middle); err != nil {
+			return "", err
+		}
+		if candidate := head + middle + tail; generator.ValidateLuhn(candidate) == luhn {
+			return candidate, nil
+		}
+	}
+}
+
+// clearHeadLength returns how many leading digits a token keeps: BIN6, else the first 4,
+// else as many as leave the minimum of enciphered digits before the last4
+func clearHeadLength(panLength int) int {
+	switch {
+	case panLength-6-4 >= minTokenMiddle:
+		return 6
+	case panLength-4-4 >= minTokenMiddle:
+		return 4
+	default:
+		return panLength - 4 - minTokenMiddle
+	}
+}
+
+// panTweak packs the clear digits as BCD into the 7 bytes of an FF3-1 tweak (FF1 takes any length)
+func panTweak(digits string) []byte {
+	if len(digits)%2 != 0 {
+		digits += "0"
+	}
+	tweak := make([]byte, cardcrypto.FF3TweakLength)
+	hex.Decode(tweak, []byte(digits))
+	return tweak
+}
+
+// TokenizeOrders reads orders, replaces their PANs with tokens (or tokens with PANs), and writes them
+// Supports JSON and NDJSON input, like TransformOrders
+func TokenizeOrders(opts models.TokenizeOptions) error {
+	tokenizer, err := NewPANTokenizer(opts.Algorithm, opts.Key)
+	if err != nil {
+		return err
+	}
+
+	orders, err := ReadOrders(opts.InputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read orders: %w", err)
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		if order.PAN, err = tokenizer.convert(order.PAN, opts.Detokenize); err != nil {
+			return fmt.Errorf("order %s: %w", order.ID, err)
+		}
+	}
+
+	if err := WriteOrders(opts.OutputPath, orders); err != nil {
+		return fmt.Errorf("failed to write orders: %w", err)
+	}
+	return nil
+}
+
+// TokenizeCards reads cards, replaces their PANs with tokens (or tokens with PANs), and writes them as JSON
+// Accepts every input ReadCards does. The PAN is replaced wherever a card carries it: tracks,
+// raw tracks, Track 2 equivalent data and ISO 8583 fields. Values computed from the PAN
+// (CVV, PIN blocks, ARQC) are left as they are.
+func TokenizeCards(opts models.TokenizeOptions) error {
+	tokenizer, err := NewPANTokenizer(opts.Algorithm, opts.Key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(opts.InputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read cards: %w", err)
+	}
+	cards, err := ReadCards(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read cards: %w", err)
+	}
+
+	for i, card := range cards {
+		pan, err := tokenizer.convert(card.PAN, opts.Detokenize)
+		if err != nil {
+			return fmt.Errorf("card %d: %w", i+1, err)
+		}
+		replaceCardPAN(card, pan)
+	}
+
+	if err := WriteCardsJSON(opts.OutputPath, cards); err != nil {
+		return fmt.Errorf("failed to write cards: %w", err)
+	}
+	return nil
+}
+
+// replaceCardPAN puts a new PAN in every card field that carries the PAN
+func replaceCardPAN(card *models.Card, pan string) {
+	old := card.PAN
+	card.PAN = pan
+	if card.MaskedPAN != "" {
+		card.MaskedPAN = generator.MaskPAN(pan)
+	}
+
+	card.Track1 = strings.ReplaceAll(card.Track1, old, pan)
+	card.Track2 = strings.ReplaceAll(card.Track2, old, pan)
+	if card.RawTrack1 != "" {
+		card.RawTrack1 = track.Raw1(card.Track1)
+	}
+	if card.RawTrack2 != "" {
+		card.RawTrack2 = track.Raw2(card.Track2)
+	}
+	card.Track2Equivalent = strings.ReplaceAll(card.Track2Equivalent, old, pan)
+
+	for id, value := range card.ISOFields {
+		card.ISOFields[id] = strings.ReplaceAll(value, old, pan)
+	}
+}
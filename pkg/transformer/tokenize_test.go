@@ -0,0 +1,65 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/felipemacedo/cardgen-pro/internal/generator"
+)
+
+func TestPANTokenizerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name, pan string
+		clearHead int
+	}{
+		{"12 digits", "501234567896", 2},
+		{"13-digit Visa", "4012888888881", 3},
+		{"14-digit Diners", "30569309025904", 4},
+		{"15-digit Amex", "370774784827208", 4},
+		{"16 digits", "4571340214818362", 6},
+		{"19 digits", "6217008897361112340", 6},
+		{"19-digit non-Luhn", "6217008897361112341", 6},
+	}
+	for _, algorithm := range []string{AlgorithmFF1, AlgorithmFF31} {
+		tokenizer, err := NewPANTokenizer(algorithm, "2B7E151628AED2A6ABF7158809CF4F3C")
+		if err != nil {
+			t.Fatalf("NewPANTokenizer(%s) unexpected error: %v", algorithm, err)
+		}
+		for _, tt := range tests {
+			token, err := tokenizer.Tokenize(tt.pan)
+			if err != nil {
+				t.Errorf("%s Tokenize(%s) unexpected error: %v", algorithm, tt.name, err)
+				continue
+			}
+			n := len(tt.pan)
+			luhn := generator.ValidateLuhn(tt.pan)
+			if token == tt.pan || len(token) != n || generator.ValidateLuhn(token) != luhn {
+				t.Errorf("%s Tokenize(%s) = %s, want a different PAN of %d digits, Luhn-valid %v", algorithm, tt.pan, token, n, luhn)
+			}
+			if token[:tt.clearHead] != tt.pan[:tt.clearHead] || token[n-4:] != tt.pan[n-4:] {
+				t.Errorf("%s Tokenize(%s) = %s, want the first %d and last 4 digits kept", algorithm, tt.pan, token, tt.clearHead)
+			}
+			if back, err := tokenizer.Detokenize(token); err != nil || back != tt.pan {
+				t.Errorf("%s Detokenize(%s) = %s, %v; want %s", algorithm, token, back, err, tt.pan)
+			}
+		}
+	}
+}
+
+func TestPANTokenizerErrors(t *testing.T) {
+	tokenizer, err := NewPANTokenizer(AlgorithmFF1, "2B7E151628AED2A6ABF7158809CF4F3C")
+	if err != nil {
+		t.Fatalf("NewPANTokenizer() unexpected error: %v", err)
+	}
+	for _, pan := range []string{"4571340214818363", "45713402148", "not-a-pan"} {
+		if _, err := tokenizer.Tokenize(pan); err == nil {
+			t.Errorf("Tokenize(%s) expected error", pan)
+		}
+	}
+
+	if _, err := NewPANTokenizer("ff3", "2B7E151628AED2A6ABF7158809CF4F3C"); err == nil {
+		t.Error("NewPANTokenizer(ff3) expected error: unknown algorithm")
+	}
+	if _, err := NewPANTokenizer(AlgorithmFF1, "not-hex"); err == nil {
+		t.Error("NewPANTokenizer(not-hex) expected error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/felipemacedo/cardgen-pro/internal/generator"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
@@ -174,3 +175,92 @@ func WriteCardsCSV(path string, cards []*models.Card) error {
 
 	return nil
 }
+
+// ReadCards reads card records from a JSON array, NDJSON, the CSV written by
+// WriteCardsCSV, or a plain list of PANs (one per line, '#' comments allowed)
+func ReadCards(r io.Reader) ([]*models.Card, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case trimmed == "":
+		return nil, fmt.Errorf("no cards in input")
+	case strings.HasPrefix(trimmed, "["):
+		var cards []*models.Card
+		if err := json.Unmarshal([]byte(trimmed), &cards); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return cards, nil
+	case strings.HasPrefix(trimmed, "{"):
+		return readCardsNDJSON(trimmed)
+	case strings.HasPrefix(trimmed, "PAN,"):
+		return readCardsCSV(trimmed)
+	}
+
+	var cards []*models.Card
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pan := strings.NewReplacer(" ", "", "-", "").Replace(line)
+		cards = append(cards, &models.Card{PAN: pan})
+	}
+	return cards, nil
+}
+
+func readCardsNDJSON(data string) ([]*models.Card, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+
+	var cards []*models.Card
+	for {
+		var card models.Card
+		if err := decoder.Decode(&card); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse NDJSON: %w", err)
+		}
+		cards = append(cards, &card)
+	}
+	return cards, nil
+}
+
+func readCardsCSV(data string) ([]*models.Card, error) {
+	rows, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	cards := make([]*models.Card, 0, len(rows)-1)
+	for line, row := range rows[1:] {
+		card := &models.Card{
+			PAN:    field(row, "PAN"),
+			Brand:  field(row, "Brand"),
+			CVC:    field(row, "CVC"),
+			Track2: field(row, "Track2"),
+		}
+		for name, target := range map[string]*int{"ExpiryMonth": &card.ExpiryMonth, "ExpiryYear": &card.ExpiryYear} {
+			if value := field(row, name); value != "" {
+				if _, err := fmt.Sscanf(value, "%d", target); err != nil {
+					return nil, fmt.Errorf("CSV line %d: invalid %s %q", line+2, name, value)
+				}
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
@@ -4,10 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/felipemacedo/cardgen-pro/internal/api"
+	"github.com/felipemacedo/cardgen-pro/internal/authorizer"
 	"github.com/felipemacedo/cardgen-pro/internal/generator"
+	"github.com/felipemacedo/cardgen-pro/internal/iso"
 	"github.com/felipemacedo/cardgen-pro/internal/models"
+	"github.com/felipemacedo/cardgen-pro/internal/token"
+	"github.com/felipemacedo/cardgen-pro/internal/track"
 	"github.com/felipemacedo/cardgen-pro/pkg/transformer"
 )
 
@@ -161,6 +168,188 @@ func TestIntegrationGenerateCards(t *testing.T) {
 	}
 }
 
+func TestIntegrationScenarioCards(t *testing.T) {
+	secret := "scenario-test-secret"
+	zpk := "0123456789ABCDEFFEDCBA9876543210"
+
+	// Restrictive rules must not override magic outcomes
+	engine := authorizer.NewEngine(&authorizer.Rules{
+		DefaultLimit: 100,
+		CVCSecret:    secret,
+		ZPK:          zpk,
+	})
+
+	for _, scenario := range api.GetScenarios() {
+		t.Run(scenario.ID, func(t *testing.T) {
+			card, err := api.GenerateScenarioCard(scenario.ID, secret, zpk)
+			if scenario.CardBrand == "pix" || scenario.CardBrand == "boleto" {
+				if err == nil {
+					t.Fatalf("GenerateScenarioCard(%s) expected error for non-card scenario", scenario.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateScenarioCard(%s) unexpected error: %v", scenario.ID, err)
+			}
+
+			if !generator.ValidateLuhn(card.PAN) {
+				t.Errorf("Scenario PAN %s failed Luhn check", card.MaskedPAN)
+			}
+			if card.ISOFields["22"] == "051" && card.ISOFields["52"] == "" {
+				t.Errorf("Scenario %s declares PIN entry but has no DE52", scenario.ID)
+			}
+
+			for i := 0; i < 3; i++ {
+				request := &iso.AuthorizationRequest{MTI: "0100", Fields: card.ISOFields}
+				response := engine.Authorize(request)
+				if response.ResponseCode != scenario.ResponseCode {
+					t.Errorf("Authorize() = %s, want %s", response.ResponseCode, scenario.ResponseCode)
+				}
+
+				// A fresh card for the same scenario must behave the same
+				card, _ = api.GenerateScenarioCard(scenario.ID, secret, zpk)
+			}
+
+			t.Logf("✓ Scenario %s: %s -> %s", scenario.ID, card.MaskedPAN, scenario.ResponseCode)
+		})
+	}
+}
+
+func TestIntegrationTokenizedPayment(t *testing.T) {
+	scenario, _ := api.GetScenario("tokenized_payment")
+	card, err := api.GenerateScenarioCard(scenario.ID, "", "")
+	if err != nil {
+		t.Fatalf("GenerateScenarioCard() unexpected error: %v", err)
+	}
+
+	vault := token.New()
+	engine := authorizer.NewEngine(authorizer.DefaultRules())
+	engine.SetVault(vault)
+
+	tok, err := vault.Provision(card, scenario.Metadata["token_requestor_id"])
+	if err != nil {
+		t.Fatalf("Provision() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(tok.DPAN, scenario.Metadata["token_bin"]) {
+		t.Errorf("DPAN %s is not from token BIN %s", tok.DPAN, scenario.Metadata["token_bin"])
+	}
+
+	authorize := func() *iso.AuthorizationResponse {
+		cryptogram, _, err := vault.Cryptogram(tok.DPAN, scenario.Amount, scenario.Currency)
+		if err != nil {
+			t.Fatalf("Cryptogram() unexpected error: %v", err)
+		}
+		fields := api.TokenISOFields(tok, cryptogram, scenario.Amount, scenario.Currency)
+		return engine.Authorize(&iso.AuthorizationRequest{MTI: "0100", Fields: fields})
+	}
+
+	response := authorize()
+	if response.ResponseCode != scenario.ResponseCode || response.Fields["2"] != tok.DPAN {
+		t.Errorf("Authorize(DPAN) = %s with DE2 %s, want %s with the DPAN", response.ResponseCode, response.Fields["2"], scenario.ResponseCode)
+	}
+
+	vault.SetStatus(tok.DPAN, token.StatusSuspended)
+	vault.SetStatus(tok.DPAN, token.StatusActive)
+	if response := authorize(); response.ResponseCode != scenario.ResponseCode {
+		t.Errorf("Authorize(resumed token) = %s, want %s", response.ResponseCode, scenario.ResponseCode)
+	}
+
+	t.Logf("✓ Token %s -> %s: %s", generator.MaskPAN(tok.DPAN), tok.MaskedFPAN, response.ResponseCode)
+}
+
+func TestIntegrationFormatPreservingTokenization(t *testing.T) {
+	dir := t.TempDir()
+	key := "2B7E151628AED2A6ABF7158809CF4F3C"
+
+	cards, err := generator.GenerateCards(models.GenerateOptions{
+		Brand: "visa", Count: 5, Secret: "tokenize-secret", IncludeTrack1: true, IncludeTrack2: true, RawTracks: true,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCards() unexpected error: %v", err)
+	}
+	for _, card := range cards {
+		card.ISOFields = iso.GenerateISO8583Fields(card, 1000, "986")
+	}
+
+	cardsPath := filepath.Join(dir, "cards.json")
+	tokensPath := filepath.Join(dir, "tokens.json")
+	backPath := filepath.Join(dir, "back.json")
+	if err := transformer.WriteCardsJSON(cardsPath, cards); err != nil {
+		t.Fatalf("WriteCardsJSON() unexpected error: %v", err)
+	}
+
+	opts := models.TokenizeOptions{InputPath: cardsPath, OutputPath: tokensPath, Key: key}
+	if err := transformer.TokenizeCards(opts); err != nil {
+		t.Fatalf("TokenizeCards() unexpected error: %v", err)
+	}
+	tokenized := readCardsFile(t, tokensPath)
+
+	for i, card := range tokenized {
+		pan := cards[i].PAN
+		if card.PAN == pan || card.PAN[:6] != pan[:6] || card.PAN[len(pan)-4:] != pan[len(pan)-4:] || !generator.ValidateLuhn(card.PAN) {
+			t.Errorf("token %s of %s: want a different Luhn-valid PAN with the same BIN6 and last4", card.PAN, pan)
+		}
+		if card.MaskedPAN != cards[i].MaskedPAN || !strings.HasPrefix(card.Track2, card.PAN+"=") || !track.CheckLRC(card.RawTrack1) || !track.CheckLRC(card.RawTrack2) {
+			t.Errorf("token %s: masked PAN, tracks or raw track LRCs not updated", card.PAN)
+		}
+		if card.ISOFields["2"] != card.PAN || strings.Contains(card.ISOFields["35"], pan) {
+			t.Errorf("token %s: ISO fields still carry the PAN", card.PAN)
+		}
+	}
+
+	opts = models.TokenizeOptions{InputPath: tokensPath, OutputPath: backPath, Key: key, Detokenize: true}
+	if err := transformer.TokenizeCards(opts); err != nil {
+		t.Fatalf("TokenizeCards(detokenize) unexpected error: %v", err)
+	}
+	for i, card := range readCardsFile(t, backPath) {
+		if card.PAN != cards[i].PAN || card.RawTrack2 != cards[i].RawTrack2 || card.ISOFields["35"] != cards[i].ISOFields["35"] {
+			t.Errorf("detokenized card %d = %s, want %s with its tracks and ISO fields", i, card.PAN, cards[i].PAN)
+		}
+	}
+
+	// Orders, under FF3-1
+	orders := []models.Order{
+		{ID: "ORD001", PAN: "4000000000000002", ExpiryMonth: 12, ExpiryYear: 2027, Amount: 10000, Currency: "986"},
+		{ID: "ORD002", PAN: "5100000000000016", ExpiryMonth: 6, ExpiryYear: 2026, Amount: 25000, Currency: "986"},
+		{ID: "ORD003", PAN: "378282246310005", ExpiryMonth: 3, ExpiryYear: 2028, Amount: 5000, Currency: "986"},
+	}
+	ordersPath := filepath.Join(dir, "orders.json")
+	if err := writeOrdersToFile(ordersPath, orders); err != nil {
+		t.Fatalf("Failed to write input orders: %v", err)
+	}
+
+	opts = models.TokenizeOptions{InputPath: ordersPath, OutputPath: tokensPath, Key: key, Algorithm: "ff3-1"}
+	if err := transformer.TokenizeOrders(opts); err != nil {
+		t.Fatalf("TokenizeOrders() unexpected error: %v", err)
+	}
+	opts = models.TokenizeOptions{InputPath: tokensPath, OutputPath: backPath, Key: key, Algorithm: "ff3-1", Detokenize: true}
+	if err := transformer.TokenizeOrders(opts); err != nil {
+		t.Fatalf("TokenizeOrders(detokenize) unexpected error: %v", err)
+	}
+	restored, err := readOrdersFromFile(backPath)
+	if err != nil {
+		t.Fatalf("Failed to read output orders: %v", err)
+	}
+	for i, order := range restored {
+		if order.PAN != orders[i].PAN {
+			t.Errorf("order %s detokenized to %s, want %s", order.ID, order.PAN, orders[i].PAN)
+		}
+	}
+
+	// Tokens only come back under the same key
+	tokenizer, _ := transformer.NewPANTokenizer("ff1", key)
+	tok, _ := tokenizer.Tokenize(cards[0].PAN)
+	other, _ := transformer.NewPANTokenizer("ff1", "000102030405060708090A0B0C0D0E0F")
+	if pan, err := other.Detokenize(tok); err != nil || pan == cards[0].PAN {
+		t.Errorf("Detokenize(other key) = %s, %v; want another Luhn-valid PAN", pan, err)
+	}
+	if _, err := tokenizer.Tokenize("45713402148"); err == nil {
+		t.Error("Tokenize(11-digit PAN) expected error")
+	}
+
+	t.Logf("✓ %s -> %s", cards[0].PAN, tok)
+}
+
 func TestIntegrationCVCDeterminism(t *testing.T) {
 	secret := "determinism-test-secret"
 	pan := "4000000000000002"
@@ -288,6 +477,86 @@ func TestIntegrationOutputFormats(t *testing.T) {
 	})
 }
 
+func TestIntegrationValidateFixtures(t *testing.T) {
+	paths, err := filepath.Glob("../fixtures/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+			defer file.Close()
+
+			cards, err := transformer.ReadCards(file)
+			if err != nil {
+				t.Fatalf("ReadCards() unexpected error: %v", err)
+			}
+
+			// Seeded fixtures expire relative to the seed epoch, not today
+			for _, card := range cards {
+				if result := generator.ValidateCardAt(card, generator.SeedEpoch); !result.Valid {
+					t.Errorf("Fixture card %s invalid: %+v", result.MaskedPAN, result.Failed())
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationReadCardsFormats(t *testing.T) {
+	cards, err := generator.GenerateCards(models.GenerateOptions{Brand: "amex", Count: 3, Secret: "read-test-secret", IncludeTrack2: true})
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	writers := map[string]func(string, []*models.Card) error{
+		"cards.json":   transformer.WriteCardsJSON,
+		"cards.ndjson": transformer.WriteCardsNDJSON,
+		"cards.csv":    transformer.WriteCardsCSV,
+	}
+
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := write(path, cards); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", name, err)
+			}
+			defer file.Close()
+
+			read, err := transformer.ReadCards(file)
+			if err != nil {
+				t.Fatalf("ReadCards() unexpected error: %v", err)
+			}
+			if len(read) != len(cards) {
+				t.Fatalf("ReadCards() returned %d cards, want %d", len(read), len(cards))
+			}
+			for i, card := range read {
+				if card.PAN != cards[i].PAN || card.CVC != cards[i].CVC || card.Track2 != cards[i].Track2 || card.ExpiryYear != cards[i].ExpiryYear {
+					t.Errorf("ReadCards()[%d] = %+v, want %+v", i, card, cards[i])
+				}
+				if result := generator.ValidateCard(card); !result.Valid {
+					t.Errorf("ValidateCard(%s) = %+v, want valid", result.MaskedPAN, result.Failed())
+				}
+			}
+		})
+	}
+
+	t.Run("PAN list", func(t *testing.T) {
+		read, err := transformer.ReadCards(strings.NewReader("# PANs\n4000000000000002\n\n5100 0000 0000 0016\n"))
+		if err != nil || len(read) != 2 || read[1].PAN != "5100000000000016" {
+			t.Errorf("ReadCards() = %v, %v; want 2 PANs", read, err)
+		}
+	})
+}
+
 // Helper functions
 
 func writeOrdersToFile(path string, orders []models.Order) error {
@@ -315,3 +584,18 @@ func readOrdersFromFile(path string) ([]models.Order, error) {
 
 	return orders, nil
 }
+
+func readCardsFile(t *testing.T, path string) []*models.Card {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	cards, err := transformer.ReadCards(file)
+	if err != nil {
+		t.Fatalf("ReadCards(%s) unexpected error: %v", path, err)
+	}
+	return cards
+}